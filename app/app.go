@@ -2,8 +2,9 @@ package app
 
 import (
 	"context"
-	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -11,44 +12,92 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/darioblanco/shortesturl/app/internal/admin"
+	"github.com/darioblanco/shortesturl/app/internal/auth"
 	"github.com/darioblanco/shortesturl/app/internal/cache"
 	"github.com/darioblanco/shortesturl/app/internal/config"
 	apphttp "github.com/darioblanco/shortesturl/app/internal/http"
 	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/darioblanco/shortesturl/app/internal/tracing"
 	_ "github.com/darioblanco/shortesturl/docs"
+	"gopkg.in/yaml.v2"
 )
 
 // An Application holds the configuration, context, logger models and router
 // needed to run shortesturl
 type Application interface {
+	CreateAPIKey(name string)
+	MigrateKeyPrefix(dryRun bool)
 	Serve()
 }
 
+// An Admin runs the administrative commands of shortesturl (see NewAdmin)
+type Admin interface {
+	RunAdmin(args []string) error
+}
+
 type application struct {
+	cache  cache.Cache
 	conf   *config.Values
 	ctx    context.Context
 	logger logging.Logger
 	router http.Handler
+	// watcher reloads the configuration while the server runs
+	watcher *config.Watcher
+	// shutdownTracing exports the pending spans once the server is stopped
+	shutdownTracing tracing.Shutdown
 }
 
-// New creates an application instance
-func New(ctx context.Context) Application {
-	// Config
-	configFilename := "config.dev"
-	if _, err := os.Stat("cmd/config.dev.yaml"); errors.Is(err, os.ErrNotExist) {
-		configFilename = "config.default"
+// Sources locates the layers of the configuration (see config.Sources)
+type Sources = config.Sources
+
+// RegisterFlags defines the flags that locate the configuration in the given flag set
+// (--config, --config-dir and --set), returning the sources they fill once it is parsed
+func RegisterFlags(fs *flag.FlagSet) *Sources {
+	return config.RegisterFlags(fs)
+}
+
+// PrintConfig writes the effective configuration of the given sources as YAML,
+// with the secrets redacted
+func PrintConfig(sources Sources, w io.Writer) error {
+	conf, err := config.Load(sources)
+	if err != nil {
+		return err
 	}
-	conf, err := config.New(configFilename, "./cmd")
+	out, err := yaml.Marshal(conf.Redacted())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// AdminUsage writes the commands of the administrative CLI (see RunAdmin)
+func AdminUsage(w io.Writer) {
+	admin.Usage(w)
+}
+
+// New creates an application instance with the configuration of the given sources
+func New(ctx context.Context, sources Sources) Application {
+	// Config
+	watcher, err := config.NewWatcher(sources)
 	if err != nil {
 		log.Fatalf("Unable to load configuration: %v", err)
 	}
+	conf := watcher.Values()
 
 	// Logger
 	logger, err := logging.NewLogger(conf)
 	if err != nil {
 		log.Fatalf("Unable to load logger: %v", err)
 	}
-	logger.Info("Loaded config", "filename", configFilename)
+	logger.Info("Loaded config", "files", watcher.Files())
+
+	// Tracing
+	shutdownTracing, err := tracing.New(ctx, conf, logger)
+	if err != nil {
+		log.Fatalf("Unable to load tracing: %v", err)
+	}
 
 	// Cache
 	c, err := cache.New(ctx, conf, logger)
@@ -57,22 +106,105 @@ func New(ctx context.Context) Application {
 	}
 
 	// Router
+	// The cache operations of the served requests are observed in the metrics
 	router, err := apphttp.NewRouter(
 		ctx,
 		conf,
 		logger,
-		c,
+		cache.Instrument(c),
 	)
 	if err != nil {
 		log.Fatalf("Unable to load router: %v", err)
 	}
 
 	return &application{
+		cache:           c,
+		conf:            conf,
+		ctx:             ctx,
+		logger:          logger,
+		router:          router,
+		watcher:         watcher,
+		shutdownTracing: shutdownTracing,
+	}
+}
+
+// NewAdmin creates an application instance with the configuration of the given sources that
+// only runs the administrative commands, thus it neither loads the router nor the tracing.
+// The backends that only live as long as the process are refused, as the changes of the
+// commands would be lost once they exit.
+func NewAdmin(ctx context.Context, sources Sources) (Admin, error) {
+	conf, err := config.Load(sources)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load configuration: %w", err)
+	}
+	if backend := cache.Backend(conf); cache.IsEphemeral(backend) {
+		return nil, fmt.Errorf(
+			"the %s storage backend only lives as long as the command, use bolt or redis", backend,
+		)
+	}
+	logger, err := logging.NewLogger(conf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load logger: %w", err)
+	}
+	// The informational logs would clutter the output of the commands, unless a log
+	// level is configured
+	if conf.LogLevel == "" {
+		if err := logging.SetLevel(logger, "warn"); err != nil {
+			return nil, err
+		}
+	}
+	c, err := cache.New(ctx, conf, logger)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to cache: %w", err)
+	}
+	return &application{
+		cache:  c,
 		conf:   conf,
 		ctx:    ctx,
 		logger: logger,
-		router: router,
+	}, nil
+}
+
+// CreateAPIKey generates a new API key with the given name. Its token is only printed once,
+// as the key store just keeps its hash.
+func (a *application) CreateAPIKey(name string) {
+	token, identity, err := auth.NewKeyStore(a.cache).Create(a.ctx, name)
+	if err != nil {
+		log.Fatalf("Unable to create api key: %v", err)
 	}
+	a.logger.Info("Created api key", "keyId", identity.KeyID, "name", identity.Name)
+	if !a.conf.Auth.Enabled {
+		a.logger.Warn("Authentication is disabled, the api key is not required until it is enabled")
+	}
+	fmt.Println(token)
+}
+
+// MigrateKeyPrefix renames the keys stored without the configured key prefix
+// by previous versions of the application
+func (a *application) MigrateKeyPrefix(dryRun bool) {
+	a.logger.Info("Migrating keys", "keyPrefix", a.conf.Redis.KeyPrefix, "dryRun", dryRun)
+	migration, err := cache.MigrateKeyPrefix(a.ctx, a.cache, apphttp.IsServiceKey, dryRun)
+	for _, key := range migration.Conflicts {
+		a.logger.Warn("Unable to migrate key, its prefixed key already exists", "key", key)
+	}
+	if err != nil {
+		log.Fatalf("Unable to migrate keys: %v", err)
+	}
+	a.logger.Info("Migrated keys",
+		"migrated", migration.Migrated,
+		"conflicts", len(migration.Conflicts),
+		"dryRun", dryRun,
+	)
+}
+
+// RunAdmin runs the administrative command of the given arguments (e.g. get 64fc5e),
+// which manages the short urls in the store directly, writing its output to stdout
+func (a *application) RunAdmin(args []string) error {
+	links, err := apphttp.NewAdmin(a.ctx, a.conf, a.logger, a.cache)
+	if err != nil {
+		return err
+	}
+	return admin.New(links, os.Stdin, os.Stdout, os.Stderr).Run(a.ctx, args)
 }
 
 // Serve sets the application ready to receive and process requests
@@ -86,16 +218,23 @@ func (a *application) Serve() {
 	// Server run context
 	serverCtx, serverStopCtx := context.WithCancel(a.ctx)
 
+	// Reload the configuration on SIGHUP (and on file changes, if watched)
+	go func() {
+		if err := a.watcher.Watch(serverCtx, a.reloaded); err != nil {
+			a.logger.Warn("Unable to watch config", "error", err)
+		}
+	}()
+
 	// Listen for syscall signals for process to interrupt/quit
 	// See https://github.com/go-chi/chi/blob/master/_examples/graceful/main.go
 	sig := make(chan os.Signal, 1)
-	signal.Notify(
-		sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	go func() {
 		<-sig
 
 		// Shutdown signal with grace period of 30 seconds
-		shutdownCtx, _ := context.WithTimeout(serverCtx, 30*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(serverCtx, 30*time.Second)
+		defer cancel()
 
 		go func() {
 			<-shutdownCtx.Done()
@@ -122,4 +261,28 @@ func (a *application) Serve() {
 
 	// Wait for server context to be stopped
 	<-serverCtx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.shutdownTracing(shutdownCtx); err != nil {
+		a.logger.Error("unable to export pending spans", "error", err)
+	}
+}
+
+// reloaded applies the log level of a configuration reload, whose other reloadable
+// values are read on each request
+func (a *application) reloaded(reloadable config.ReloadableValues, err error) {
+	if err != nil {
+		a.logger.Warn("Unable to reload config, keeping the current values", "error", err)
+		return
+	}
+	if err := logging.SetLevel(a.logger, reloadable.LogLevel); err != nil {
+		a.logger.Warn("Unable to change the log level", "error", err)
+	}
+	a.logger.Info("Reloaded config",
+		"logLevel", reloadable.LogLevel,
+		"urlLength", reloadable.UrlLength,
+		"urlExpirationInHours", int64(reloadable.UrlExpirationInHours),
+		"urlMaxExpirationInHours", int64(reloadable.UrlMaxExpirationInHours),
+	)
 }
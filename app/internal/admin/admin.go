@@ -0,0 +1,265 @@
+// Package admin implements the commands of the administrative CLI, which manage
+// the short urls in the configured store directly
+package admin
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	apphttp "github.com/darioblanco/shortesturl/app/internal/http"
+)
+
+// A command describes the arguments that follow its name
+type command struct {
+	name    string
+	usage   string
+	summary string
+}
+
+// commands are the available commands, in the order of the usage
+var commands = []command{
+	{"create", "[flags] <url>", "shorten a long url"},
+	{"get", "[flags] <slug>", "show a short url"},
+	{"delete", "[flags] <slug>", "delete a short url and its click stats"},
+	{"list", "[flags]", "list the short urls"},
+	{"expire", "[flags] <slug>", "change the expiration of a short url"},
+	{"stats", "[flags] <slug>", "show the click stats of a short url"},
+	{"import", "[flags]", "import the short urls of a CSV or JSON lines file"},
+	{"export", "[flags]", "export the short urls as CSV or JSON lines"},
+}
+
+// A CLI runs the administrative commands against the store of the service
+type CLI struct {
+	admin  *apphttp.Admin
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// New creates a CLI that manages the short urls through the given admin,
+// reading the imports from stdin and writing the output of the commands to stdout
+func New(admin *apphttp.Admin, stdin io.Reader, stdout io.Writer, stderr io.Writer) *CLI {
+	return &CLI{admin: admin, stdin: stdin, stdout: stdout, stderr: stderr}
+}
+
+// Usage writes the available commands
+func Usage(w io.Writer) {
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "Run '<command> -h' to list the flags of a command.")
+}
+
+// Run runs the command of the given arguments (e.g. get 64fc5e)
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("a command is required")
+	}
+	switch args[0] {
+	case "create":
+		return c.create(ctx, args[1:])
+	case "get":
+		return c.get(ctx, args[1:])
+	case "delete":
+		return c.delete(ctx, args[1:])
+	case "list":
+		return c.list(ctx, args[1:])
+	case "expire":
+		return c.expire(ctx, args[1:])
+	case "stats":
+		return c.stats(ctx, args[1:])
+	case "import":
+		return c.importLinks(ctx, args[1:])
+	case "export":
+		return c.exportLinks(ctx, args[1:])
+	}
+	names := make([]string, len(commands))
+	for i, cmd := range commands {
+		names[i] = cmd.name
+	}
+	return fmt.Errorf("unknown command %q (available: %s)", args[0], strings.Join(names, ", "))
+}
+
+// flagSet returns the flag set of the given command, along with its output format flag
+func (c *CLI) flagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			usage, summary := cmd.usage, strings.ToUpper(cmd.summary[:1])+cmd.summary[1:]
+			fs.Usage = func() {
+				fmt.Fprintf(fs.Output(), "Usage: %s %s\n\n%s.\n\nFlags:\n", name, usage, summary)
+				fs.PrintDefaults()
+			}
+		}
+	}
+	output := fs.String("output", outputTable, "the output format: table or json")
+	return fs, output
+}
+
+// parse parses the given arguments of a command, which expects the given number of
+// positional arguments after its flags, checking the output format
+func parse(fs *flag.FlagSet, output *string, args []string, positional int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output != outputTable && *output != outputJSON {
+		return fmt.Errorf("invalid output %q (allowed: table, json)", *output)
+	}
+	if fs.NArg() != positional {
+		fs.Usage()
+		return fmt.Errorf("%s expects %d argument(s), got %d", fs.Name(), positional, fs.NArg())
+	}
+	return nil
+}
+
+func (c *CLI) create(ctx context.Context, args []string) error {
+	fs, output := c.flagSet("create")
+	data := &apphttp.URLPayload{}
+	fs.StringVar(&data.Alias, "alias", "", "a custom alias instead of the generated slug")
+	fs.StringVar(&data.Title, "title", "", "the title of the short url")
+	tags := fs.String("tags", "", "the comma-separated tags of the short url")
+	fs.StringVar(&data.ExpiresIn, "expires-in", "", "the expiration as a duration (e.g. 72h)")
+	expiresAt := fs.String("expires-at", "", "the expiration as an RFC 3339 date")
+	reuse := fs.Bool("reuse", true, "return the existing short url of the long url (if any)")
+	if err := parse(fs, output, args, 1); err != nil {
+		return err
+	}
+	data.URL = fs.Arg(0)
+	if *tags != "" {
+		data.Tags = strings.Split(*tags, ",")
+	}
+	if *expiresAt != "" {
+		t, err := time.Parse(time.RFC3339, *expiresAt)
+		if err != nil {
+			return fmt.Errorf("invalid expires-at: %w", err)
+		}
+		data.ExpiresAt = &t
+	}
+	if !*reuse {
+		data.Reuse = reuse
+	}
+	link, err := c.admin.Create(ctx, data)
+	if err != nil {
+		return err
+	}
+	return c.writeLink(*output, link)
+}
+
+func (c *CLI) get(ctx context.Context, args []string) error {
+	fs, output := c.flagSet("get")
+	if err := parse(fs, output, args, 1); err != nil {
+		return err
+	}
+	link, err := c.admin.Get(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return c.writeLink(*output, link)
+}
+
+func (c *CLI) delete(ctx context.Context, args []string) error {
+	fs, output := c.flagSet("delete")
+	if err := parse(fs, output, args, 1); err != nil {
+		return err
+	}
+	slug := fs.Arg(0)
+	if err := c.admin.Delete(ctx, slug); err != nil {
+		return err
+	}
+	if *output == outputJSON {
+		return writeJSON(c.stdout, map[string]interface{}{"slug": slug, "deleted": true})
+	}
+	_, err := fmt.Fprintf(c.stdout, "Deleted %s\n", slug)
+	return err
+}
+
+func (c *CLI) list(ctx context.Context, args []string) error {
+	fs, output := c.flagSet("list")
+	prefix := fs.String("prefix", "", "only list the short urls whose slug starts with the prefix")
+	limit := fs.Int("limit", 0, "the maximum number of short urls to list (0 lists all of them)")
+	if err := parse(fs, output, args, 0); err != nil {
+		return err
+	}
+	links := []*apphttp.Link{}
+	errLimit := errors.New("limit reached")
+	err := c.admin.List(ctx, *prefix, func(link *apphttp.Link) error {
+		links = append(links, link)
+		if *limit > 0 && len(links) >= *limit {
+			return errLimit
+		}
+		return nil
+	})
+	if err != nil && err != errLimit {
+		return err
+	}
+	if *output == outputJSON {
+		return writeJSON(c.stdout, links)
+	}
+	return writeLinkTable(c.stdout, links)
+}
+
+func (c *CLI) expire(ctx context.Context, args []string) error {
+	fs, output := c.flagSet("expire")
+	in := fs.Duration("in", 0, "expire the short url after the duration (e.g. 72h)")
+	at := fs.String("at", "", "expire the short url at the RFC 3339 date")
+	never := fs.Bool("never", false, "remove the expiration of the short url")
+	if err := parse(fs, output, args, 1); err != nil {
+		return err
+	}
+	set := 0
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "in" || f.Name == "at" || f.Name == "never" {
+			set++
+		}
+	})
+	if set != 1 {
+		return errors.New("exactly one of -in, -at or -never is required")
+	}
+	expiration := *in
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return fmt.Errorf("invalid at: %w", err)
+		}
+		if expiration = time.Until(t); expiration <= 0 {
+			return errors.New("the expiration date must be in the future")
+		}
+	} else if !*never && expiration <= 0 {
+		return errors.New("the expiration must be a positive duration")
+	}
+	link, err := c.admin.Expire(ctx, fs.Arg(0), expiration)
+	if err != nil {
+		return err
+	}
+	return c.writeLink(*output, link)
+}
+
+func (c *CLI) stats(ctx context.Context, args []string) error {
+	fs, output := c.flagSet("stats")
+	if err := parse(fs, output, args, 1); err != nil {
+		return err
+	}
+	stats, err := c.admin.Stats(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *output == outputJSON {
+		return writeJSON(c.stdout, stats)
+	}
+	return writeStatsTable(c.stdout, stats)
+}
+
+// writeLink writes the given link in the given output format
+func (c *CLI) writeLink(output string, link *apphttp.Link) error {
+	if output == outputJSON {
+		return writeJSON(c.stdout, link)
+	}
+	return writeLinkTable(c.stdout, []*apphttp.Link{link})
+}
@@ -0,0 +1,187 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	apphttp "github.com/darioblanco/shortesturl/app/internal/http"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCLI(t *testing.T, c cache.Cache) *CLI {
+	admin, err := apphttp.NewAdmin(
+		context.Background(),
+		&config.Values{
+			HttpScheme: "http",
+			HttpHost:   "localhost",
+			HttpPort:   3000,
+			UrlLength:  6,
+		},
+		logging.NewTest(t),
+		c,
+	)
+	require.NoError(t, err)
+	return New(admin, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+}
+
+// run runs the given command, returning its output
+func run(t *testing.T, cli *CLI, args ...string) (string, error) {
+	out := &bytes.Buffer{}
+	cli.stdout = out
+	err := cli.Run(context.Background(), args)
+	return out.String(), err
+}
+
+func TestUsage(t *testing.T) {
+	out := &bytes.Buffer{}
+	Usage(out)
+	for _, cmd := range commands {
+		assert.Contains(t, out.String(), "  "+cmd.name)
+	}
+}
+
+func TestCLI_Run_Invalid(t *testing.T) {
+	cli := newTestCLI(t, cache.NewTest())
+	tests := map[string]struct {
+		args     []string
+		expected string
+	}{
+		"no command": {
+			args:     nil,
+			expected: "a command is required",
+		},
+		"unknown command": {
+			args:     []string{"rename"},
+			expected: `unknown command "rename"`,
+		},
+		"missing argument": {
+			args:     []string{"get"},
+			expected: "get expects 1 argument(s), got 0",
+		},
+		"extra argument": {
+			args:     []string{"list", "abc"},
+			expected: "list expects 0 argument(s)",
+		},
+		"unknown flag": {
+			args:     []string{"list", "-sort", "slug"},
+			expected: "not defined",
+		},
+		"invalid output": {
+			args:     []string{"list", "-output", "xml"},
+			expected: `invalid output "xml"`,
+		},
+		"invalid expiresAt": {
+			args:     []string{"create", "-expires-at", "tomorrow", "https://a.com"},
+			expected: "invalid expires-at",
+		},
+		"no expiration": {
+			args:     []string{"expire", "abc"},
+			expected: "exactly one of -in, -at or -never",
+		},
+		"two expirations": {
+			args:     []string{"expire", "-in", "1h", "-never", "abc"},
+			expected: "exactly one of",
+		},
+		"past expiration": {
+			args:     []string{"expire", "-at", "2020-01-01T00:00:00Z", "abc"},
+			expected: "must be in the future",
+		},
+		"zero expiration": {
+			args:     []string{"expire", "-in", "0s", "abc"},
+			expected: "positive duration",
+		},
+		"not found": {
+			args:     []string{"get", "abc"},
+			expected: "link not found",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(name, func(t *testing.T) {
+			_, err := run(t, cli, tt.args...)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestCLI_Links(t *testing.T) {
+	cli := newTestCLI(t, cache.NewTest())
+
+	out, err := run(t, cli, "create", "-title", "Dario Blanco", "-tags", "profile,github",
+		"https://github.com/darioblanco")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "SLUG"))
+	assert.Contains(t, lines[1], "64fc5e")
+	assert.Contains(t, lines[1], "https://github.com/darioblanco")
+	assert.Contains(t, lines[1], "profile,github")
+
+	_, err = run(t, cli, "create", "-alias", "dario", "-expires-in", "24h",
+		"https://darioblanco.com")
+	require.NoError(t, err)
+
+	out, err = run(t, cli, "get", "-output", "json", "dario")
+	require.NoError(t, err)
+	link := &apphttp.Link{}
+	require.NoError(t, json.Unmarshal([]byte(out), link))
+	assert.Equal(t, "https://darioblanco.com", link.URL)
+	assert.Equal(t, "http://localhost:3000/dario", link.ShortURL)
+	require.NotNil(t, link.ExpiresAt)
+
+	out, err = run(t, cli, "list", "-output", "json")
+	require.NoError(t, err)
+	var links []*apphttp.Link
+	require.NoError(t, json.Unmarshal([]byte(out), &links))
+	assert.Len(t, links, 2)
+
+	out, err = run(t, cli, "list", "-prefix", "da")
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+	assert.Contains(t, out, "dario")
+
+	out, err = run(t, cli, "list", "-limit", "1", "-output", "json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(out), &links))
+	assert.Len(t, links, 1)
+
+	out, err = run(t, cli, "expire", "-never", "-output", "json", "dario")
+	require.NoError(t, err)
+	link = &apphttp.Link{}
+	require.NoError(t, json.Unmarshal([]byte(out), link))
+	assert.Nil(t, link.ExpiresAt)
+
+	at := time.Now().Add(72 * time.Hour).UTC().Round(time.Second)
+	out, err = run(t, cli, "expire", "-at", at.Format(time.RFC3339), "dario")
+	require.NoError(t, err)
+	assert.Contains(t, out, at.Format(time.RFC3339))
+
+	out, err = run(t, cli, "stats", "dario")
+	require.NoError(t, err)
+	assert.Contains(t, out, "TOTAL")
+
+	out, err = run(t, cli, "delete", "dario")
+	require.NoError(t, err)
+	assert.Equal(t, "Deleted dario\n", out)
+	_, err = run(t, cli, "get", "dario")
+	assert.ErrorIs(t, err, apphttp.ErrLinkExpired)
+}
+
+func TestCLI_Help(t *testing.T) {
+	cli := newTestCLI(t, cache.NewTest())
+	stderr := &bytes.Buffer{}
+	cli.stderr = stderr
+	_, err := run(t, cli, "get", "-h")
+	assert.ErrorIs(t, err, flag.ErrHelp)
+	assert.Contains(t, stderr.String(), "Usage: get [flags] <slug>\n\nShow a short url.\n")
+}
@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	apphttp "github.com/darioblanco/shortesturl/app/internal/http"
+)
+
+const (
+	// outputTable writes aligned columns for humans
+	outputTable = "table"
+	// outputJSON writes the payloads of the API, for scripts
+	outputJSON = "json"
+)
+
+// writeJSON writes the given value as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeLinkTable writes the given links as a table, one per row
+func writeLinkTable(w io.Writer, links []*apphttp.Link) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SLUG\tURL\tCREATED AT\tEXPIRES AT\tDISABLED\tOWNER\tTITLE\tTAGS")
+	for _, link := range links {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\t%s\t%s\t%s\n",
+			link.Slug,
+			link.URL,
+			formatTime(link.CreatedAt),
+			formatTime(link.ExpiresAt),
+			link.Disabled,
+			orDash(link.Owner),
+			orDash(link.Title),
+			orDash(strings.Join(link.Tags, ",")),
+		)
+	}
+	return tw.Flush()
+}
+
+// writeStatsTable writes the given click stats as a table, one stat per row
+func writeStatsTable(w io.Writer, stats *apphttp.LinkStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "SLUG\t%s\n", stats.Slug)
+	fmt.Fprintf(tw, "TOTAL\t%d\n", stats.Total)
+	fmt.Fprintf(tw, "LAST CLICK AT\t%s\n", formatTime(stats.LastClickAt))
+	fmt.Fprintf(tw, "LAST REQUEST ID\t%s\n", orDash(stats.LastRequestID))
+	fmt.Fprintf(tw, "LAST 24 HOURS\t%d\n", sumClicks(stats.Hourly))
+	fmt.Fprintf(tw, "LAST 30 DAYS\t%d\n", sumClicks(stats.Daily))
+	fmt.Fprintf(tw, "TOP REFERERS\t%s\n", formatTopClicks(stats.TopReferers))
+	fmt.Fprintf(tw, "TOP USER AGENTS\t%s\n", formatTopClicks(stats.TopUserAgents))
+	fmt.Fprintf(tw, "TOP COUNTRIES\t%s\n", formatTopClicks(stats.TopCountries))
+	return tw.Flush()
+}
+
+// sumClicks returns the clicks of the given buckets
+func sumClicks(buckets []apphttp.ClickBucket) int64 {
+	var total int64
+	for _, bucket := range buckets {
+		total += bucket.Clicks
+	}
+	return total
+}
+
+// formatTopClicks formats the given top clicks as a comma-separated list (e.g. github.com (12))
+func formatTopClicks(top []apphttp.TopClicks) string {
+	values := make([]string, len(top))
+	for i, clicks := range top {
+		values[i] = fmt.Sprintf("%s (%d)", clicks.Value, clicks.Clicks)
+	}
+	return orDash(strings.Join(values, ", "))
+}
+
+// formatTime formats the given time in RFC 3339, being a dash if it is not set
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// orDash returns a dash instead of an empty value, so the columns keep their alignment
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	apphttp "github.com/darioblanco/shortesturl/app/internal/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteLinkTable(t *testing.T) {
+	createdAt := time.Date(2026, 10, 17, 15, 4, 5, 0, time.UTC)
+	out := &bytes.Buffer{}
+	require.NoError(t, writeLinkTable(out, []*apphttp.Link{
+		{
+			Slug:      "64fc5e",
+			URL:       "https://github.com/darioblanco",
+			CreatedAt: &createdAt,
+			Title:     "Dario Blanco",
+			Tags:      []string{"profile", "github"},
+		},
+		{Slug: "legacy", URL: "https://darioblanco.com", Disabled: true, Owner: "0123456789ab"},
+	}))
+	assert.Equal(t,
+		"SLUG    URL                             CREATED AT            EXPIRES AT  DISABLED  OWNER         TITLE         TAGS\n"+
+			"64fc5e  https://github.com/darioblanco  2026-10-17T15:04:05Z  -           false     -             Dario Blanco  profile,github\n"+
+			"legacy  https://darioblanco.com         -                     -           true      0123456789ab  -             -\n",
+		out.String(),
+	)
+}
+
+func TestWriteStatsTable(t *testing.T) {
+	lastClickAt := time.Date(2026, 10, 17, 15, 4, 5, 0, time.UTC)
+	out := &bytes.Buffer{}
+	require.NoError(t, writeStatsTable(out, &apphttp.LinkStats{
+		Slug:          "64fc5e",
+		Total:         42,
+		LastClickAt:   &lastClickAt,
+		LastRequestID: "shortesturl/Xk3JCDnHVc-000042",
+		Hourly:        []apphttp.ClickBucket{{Clicks: 1}, {Clicks: 2}},
+		Daily:         []apphttp.ClickBucket{{Clicks: 3}, {Clicks: 4}},
+		TopReferers: []apphttp.TopClicks{
+			{Value: "github.com", Clicks: 12},
+			{Value: "news.ycombinator.com", Clicks: 3},
+		},
+	}))
+	assert.Equal(t,
+		"SLUG             64fc5e\n"+
+			"TOTAL            42\n"+
+			"LAST CLICK AT    2026-10-17T15:04:05Z\n"+
+			"LAST REQUEST ID  shortesturl/Xk3JCDnHVc-000042\n"+
+			"LAST 24 HOURS    3\n"+
+			"LAST 30 DAYS     7\n"+
+			"TOP REFERERS     github.com (12), news.ycombinator.com (3)\n"+
+			"TOP USER AGENTS  -\n"+
+			"TOP COUNTRIES    -\n",
+		out.String(),
+	)
+}
+
+func TestWriteJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, writeJSON(out, map[string]int{"imported": 1}))
+	assert.Equal(t, "{\n  \"imported\": 1\n}\n", out.String())
+}
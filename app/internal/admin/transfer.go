@@ -0,0 +1,269 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	apphttp "github.com/darioblanco/shortesturl/app/internal/http"
+)
+
+// progressInterval is the minimum time between two progress reports of an import or export
+const progressInterval = time.Second
+
+// An exportCheckpoint is the state of an interrupted export to a file
+type exportCheckpoint struct {
+	apphttp.ExportProgress
+	// Offset is the size of the file once the last page was written, as the next page
+	// could be partially written when the export was interrupted
+	Offset int64 `json:"offset"`
+}
+
+func (c *CLI) importLinks(ctx context.Context, args []string) error {
+	fs, output := c.flagSet("import")
+	file := fs.String("file", "-", "the file to import, or - to read stdin")
+	format := fs.String("format", "", "the format of the file: csv or jsonl "+
+		"(defaults to csv for .csv files and to jsonl otherwise)")
+	conflict := fs.String("conflict", apphttp.ConflictSkip,
+		"the policy of the slugs that already hold a link: skip, overwrite or fail")
+	dryRun := fs.Bool("dry-run", false, "report the outcome of the import without storing any link")
+	batchSize := fs.Int("batch-size", 500, "the number of links stored with a single pipeline")
+	checkpoint := fs.String("checkpoint", "",
+		"the file that saves the progress, resuming the import from it if it exists")
+	if err := parse(fs, output, args, 0); err != nil {
+		return err
+	}
+	if *checkpoint != "" && *dryRun {
+		return errors.New("-checkpoint can not be used with -dry-run")
+	}
+	opts := apphttp.ImportOptions{
+		Format:    transferFormat(*format, *file),
+		Conflict:  *conflict,
+		DryRun:    *dryRun,
+		BatchSize: *batchSize,
+	}
+	if *checkpoint != "" {
+		resume := &apphttp.ImportProgress{}
+		found, err := loadCheckpoint(*checkpoint, resume)
+		if err != nil {
+			return err
+		}
+		if found {
+			fmt.Fprintf(c.stderr, "Resuming the import after %d records\n", resume.Records)
+			opts.Resume = resume
+		}
+	}
+
+	r := c.stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	reporter := &progressReporter{w: c.stderr, interval: progressInterval}
+	opts.Progress = func(progress apphttp.ImportProgress) {
+		if *checkpoint != "" {
+			if err := saveCheckpoint(*checkpoint, progress); err != nil {
+				fmt.Fprintf(c.stderr, "Unable to save the checkpoint: %v\n", err)
+			}
+		}
+		reporter.report("Processed %d records: %s", progress.Records, importCounts(progress))
+	}
+	progress, err := c.admin.Import(ctx, r, opts)
+	if err != nil {
+		if *checkpoint == "" {
+			return err
+		}
+		if saveErr := saveCheckpoint(*checkpoint, progress); saveErr != nil {
+			return fmt.Errorf("%w (unable to save the checkpoint: %v)", err, saveErr)
+		}
+		return fmt.Errorf("%w (resume the import with -checkpoint %s)", err, *checkpoint)
+	}
+	if *checkpoint != "" {
+		if err := removeCheckpoint(*checkpoint); err != nil {
+			return err
+		}
+	}
+	if *output == outputJSON {
+		return writeJSON(c.stdout, progress)
+	}
+	summary := fmt.Sprintf("Imported %d records: %s", progress.Records, importCounts(*progress))
+	if *dryRun {
+		summary += " (dry run)"
+	}
+	_, err = fmt.Fprintln(c.stdout, summary)
+	return err
+}
+
+func (c *CLI) exportLinks(ctx context.Context, args []string) error {
+	fs, output := c.flagSet("export")
+	prefix := fs.String("prefix", "", "only export the short urls whose slug starts with the prefix")
+	file := fs.String("file", "-", "the file to write, or - to write stdout")
+	format := fs.String("format", "", "the format of the file: csv or jsonl "+
+		"(defaults to csv for .csv files and to jsonl otherwise)")
+	checkpoint := fs.String("checkpoint", "",
+		"the file that saves the progress, resuming the export from it if it exists")
+	if err := parse(fs, output, args, 0); err != nil {
+		return err
+	}
+	opts := apphttp.ExportOptions{Format: transferFormat(*format, *file), Prefix: *prefix}
+	reporter := &progressReporter{w: c.stderr, interval: progressInterval}
+	if *file == "-" {
+		if *checkpoint != "" {
+			return errors.New("-checkpoint requires -file")
+		}
+		opts.Progress = func(progress apphttp.ExportProgress) {
+			reporter.report("Exported %d links", progress.Exported)
+		}
+		_, err := c.admin.Export(ctx, c.stdout, opts)
+		return err
+	}
+
+	resume := &exportCheckpoint{}
+	found := false
+	if *checkpoint != "" {
+		var err error
+		if found, err = loadCheckpoint(*checkpoint, resume); err != nil {
+			return err
+		}
+	}
+	f, err := openExportFile(*file, resume, found)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if found {
+		fmt.Fprintf(c.stderr, "Resuming the export after %d links\n", resume.Exported)
+		opts.Resume = &resume.ExportProgress
+	}
+	opts.Progress = func(progress apphttp.ExportProgress) {
+		if *checkpoint != "" {
+			offset, err := f.Seek(0, io.SeekCurrent)
+			if err == nil {
+				err = saveCheckpoint(*checkpoint, exportCheckpoint{progress, offset})
+			}
+			if err != nil {
+				fmt.Fprintf(c.stderr, "Unable to save the checkpoint: %v\n", err)
+			}
+		}
+		reporter.report("Exported %d links", progress.Exported)
+	}
+	progress, err := c.admin.Export(ctx, f, opts)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		if *checkpoint != "" {
+			return fmt.Errorf("%w (resume the export with -checkpoint %s)", err, *checkpoint)
+		}
+		return err
+	}
+	if *checkpoint != "" {
+		if err := removeCheckpoint(*checkpoint); err != nil {
+			return err
+		}
+	}
+	if *output == outputJSON {
+		return writeJSON(c.stdout, progress)
+	}
+	_, err = fmt.Fprintf(c.stdout, "Exported %d links to %s\n", progress.Exported, *file)
+	return err
+}
+
+// openExportFile creates the file of an export, unless the export is resumed from the
+// given checkpoint, which discards what was written after its last complete page
+func openExportFile(name string, resume *exportCheckpoint, found bool) (*os.File, error) {
+	if !found {
+		return os.Create(name)
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(resume.Offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(resume.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// transferFormat returns the given format, which defaults to CSV for the .csv files
+func transferFormat(format, file string) string {
+	if format == "" && strings.EqualFold(filepath.Ext(file), ".csv") {
+		return apphttp.FormatCSV
+	}
+	return format
+}
+
+// importCounts describes the outcome of the processed records of an import
+func importCounts(progress apphttp.ImportProgress) string {
+	return fmt.Sprintf("%d imported, %d overwritten, %d skipped, %d expired",
+		progress.Imported, progress.Overwritten, progress.Skipped, progress.Expired)
+}
+
+// loadCheckpoint reads the given checkpoint file into v, returning false if it does not exist
+func loadCheckpoint(name string, v interface{}) (bool, error) {
+	content, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return false, fmt.Errorf("invalid checkpoint %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// saveCheckpoint writes v to the given checkpoint file, replacing it atomically
+// so that an interruption never leaves a partial checkpoint behind
+func saveCheckpoint(name string, v interface{}) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// removeCheckpoint removes the given checkpoint file once its transfer is complete
+func removeCheckpoint(name string) error {
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// A progressReporter writes the progress of a transfer at most once per interval
+type progressReporter struct {
+	w        io.Writer
+	interval time.Duration
+	last     time.Time
+}
+
+// report writes the given progress line, unless the last one was written too recently
+func (p *progressReporter) report(format string, args ...interface{}) {
+	now := time.Now()
+	if !p.last.IsZero() && now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+	fmt.Fprintf(p.w, format+"\n", args...)
+}
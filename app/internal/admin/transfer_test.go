@@ -0,0 +1,223 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	apphttp "github.com/darioblanco/shortesturl/app/internal/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// limitedCache fails the scans once the given number of them succeeded
+type limitedCache struct {
+	cache.Cache
+	scans int
+	limit int
+}
+
+func (c *limitedCache) Scan(
+	ctx context.Context, cursor string, match string, count int,
+) ([]string, string, error) {
+	if c.limit > 0 && c.scans >= c.limit {
+		return nil, "", errors.New("interrupted")
+	}
+	c.scans++
+	return c.Cache.Scan(ctx, cursor, match, count)
+}
+
+func TestCLI_ExportImport(t *testing.T) {
+	source := newTestCLI(t, cache.NewTest())
+	for _, longURL := range []string{"https://github.com/darioblanco", "https://darioblanco.com"} {
+		_, err := run(t, source, "create", longURL)
+		require.NoError(t, err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "links.jsonl")
+	out, err := run(t, source, "export", "-file", file)
+	require.NoError(t, err)
+	assert.Equal(t, "Exported 2 links to "+file+"\n", out)
+	exported, err := run(t, source, "export")
+	require.NoError(t, err)
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(exported, "\n"))
+	assert.Len(t, content, len(exported))
+
+	// The format of the .csv files is inferred from their extension
+	csvFile := filepath.Join(dir, "links.csv")
+	_, err = run(t, source, "export", "-file", csvFile)
+	require.NoError(t, err)
+	content, err = os.ReadFile(csvFile)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "slug,url,"))
+
+	target := newTestCLI(t, cache.NewTest())
+	out, err = run(t, target, "import", "-file", csvFile, "-dry-run")
+	require.NoError(t, err)
+	assert.Equal(t,
+		"Imported 2 records: 2 imported, 0 overwritten, 0 skipped, 0 expired (dry run)\n", out)
+	_, err = run(t, target, "get", "64fc5e")
+	assert.ErrorIs(t, err, apphttp.ErrLinkNotFound)
+
+	out, err = run(t, target, "import", "-file", csvFile)
+	require.NoError(t, err)
+	assert.Equal(t, "Imported 2 records: 2 imported, 0 overwritten, 0 skipped, 0 expired\n", out)
+
+	target.stdin = strings.NewReader(exported)
+	out, err = run(t, target, "import", "-output", "json")
+	require.NoError(t, err)
+	assert.JSONEq(t,
+		`{"records":2,"imported":0,"overwritten":0,"skipped":2,"expired":0}`, out)
+
+	target.stdin = strings.NewReader(exported)
+	out, err = run(t, target, "import", "-conflict", "overwrite")
+	require.NoError(t, err)
+	assert.Equal(t, "Imported 2 records: 0 imported, 2 overwritten, 0 skipped, 0 expired\n", out)
+
+	target.stdin = strings.NewReader(strings.Replace(exported, "darioblanco.com", "example.com", 1))
+	_, err = run(t, target, "import", "-conflict", "fail")
+	assert.ErrorIs(t, err, apphttp.ErrSlugTaken)
+
+	_, err = run(t, target, "import", "-file", filepath.Join(dir, "missing.jsonl"))
+	assert.Error(t, err)
+}
+
+func TestCLI_ExportImport_Invalid(t *testing.T) {
+	cli := newTestCLI(t, cache.NewTest())
+	tests := map[string]struct {
+		args     []string
+		expected string
+	}{
+		"import format": {
+			args:     []string{"import", "-format", "xml"},
+			expected: `invalid format "xml"`,
+		},
+		"import conflict": {
+			args:     []string{"import", "-conflict", "merge"},
+			expected: `invalid conflict policy "merge"`,
+		},
+		"dry run checkpoint": {
+			args:     []string{"import", "-dry-run", "-checkpoint", "import.json"},
+			expected: "-checkpoint can not be used with -dry-run",
+		},
+		"export format": {
+			args:     []string{"export", "-format", "xml"},
+			expected: `invalid format "xml"`,
+		},
+		"stdout checkpoint": {
+			args:     []string{"export", "-checkpoint", "export.json"},
+			expected: "-checkpoint requires -file",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(name, func(t *testing.T) {
+			_, err := run(t, cli, tt.args...)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestCLI_Import_Checkpoint(t *testing.T) {
+	cli := newTestCLI(t, cache.NewTest())
+	stderr := &bytes.Buffer{}
+	cli.stderr = stderr
+	dir := t.TempDir()
+	checkpoint := filepath.Join(dir, "import.json")
+	records := []string{
+		`{"slug":"first","url":"https://example.com/1"}`,
+		`{"slug":"second","url":"https://example.com/2"}`,
+		`{"slug":"third","url":"ftp://example.com/3"}`,
+		`{"slug":"fourth","url":"https://example.com/4"}`,
+	}
+
+	// The import stops at the invalid record, saving the progress of the previous ones
+	cli.stdin = strings.NewReader(strings.Join(records, "\n"))
+	_, err := run(t, cli, "import", "-batch-size", "1", "-checkpoint", checkpoint)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "record 3")
+	assert.Contains(t, err.Error(), "resume the import with -checkpoint "+checkpoint)
+	content, err := os.ReadFile(checkpoint)
+	require.NoError(t, err)
+	assert.JSONEq(t,
+		`{"records":2,"imported":2,"overwritten":0,"skipped":0,"expired":0}`, string(content))
+	assert.Contains(t, stderr.String(), "Processed 1 records: 1 imported")
+
+	// The fixed input is resumed after the records that were already imported
+	records[2] = `{"slug":"third","url":"https://example.com/3"}`
+	cli.stdin = strings.NewReader(strings.Join(records, "\n"))
+	out, err := run(t, cli, "import", "-batch-size", "1", "-checkpoint", checkpoint)
+	require.NoError(t, err)
+	assert.Equal(t, "Imported 4 records: 4 imported, 0 overwritten, 0 skipped, 0 expired\n", out)
+	assert.Contains(t, stderr.String(), "Resuming the import after 2 records\n")
+	assert.NoFileExists(t, checkpoint)
+}
+
+func TestCLI_Export_Checkpoint(t *testing.T) {
+	ctx := context.Background()
+	// The memory store scans pages of the requested size
+	c := &limitedCache{Cache: cache.NewMemory(ctx, &config.Values{}), limit: 1}
+	cli := newTestCLI(t, c)
+	stderr := &bytes.Buffer{}
+	cli.stderr = stderr
+	for i := 0; i < 2500; i++ {
+		longURL := fmt.Sprintf("https://example.com/%d", i)
+		require.NoError(t, c.Set(ctx, fmt.Sprintf("slug%d", i), longURL, time.Hour))
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "links.csv")
+	checkpoint := filepath.Join(dir, "export.json")
+
+	// The export is interrupted after its first page
+	_, err := run(t, cli, "export", "-file", file, "-checkpoint", checkpoint)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "resume the export with -checkpoint "+checkpoint)
+	assert.FileExists(t, checkpoint)
+	// A partial page written before the interruption is discarded when resuming
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("partial,")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	c.limit = 0
+	out, err := run(t, cli, "export", "-file", file, "-checkpoint", checkpoint)
+	require.NoError(t, err)
+	assert.Equal(t, "Exported 2500 links to "+file+"\n", out)
+	assert.Contains(t, stderr.String(), "Resuming the export after 1000 links\n")
+	assert.NoFileExists(t, checkpoint)
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "partial")
+	assert.Len(t, strings.Split(strings.TrimSpace(string(content)), "\n"), 2501)
+	assert.Equal(t, 1, strings.Count(string(content), "slug,url"))
+}
+
+func TestTransferFormat(t *testing.T) {
+	assert.Equal(t, apphttp.FormatCSV, transferFormat("", "links.CSV"))
+	assert.Equal(t, "", transferFormat("", "links.jsonl"))
+	assert.Equal(t, "", transferFormat("", "-"))
+	assert.Equal(t, apphttp.FormatJSONL, transferFormat(apphttp.FormatJSONL, "links.csv"))
+}
+
+func TestProgressReporter(t *testing.T) {
+	out := &bytes.Buffer{}
+	reporter := &progressReporter{w: out, interval: time.Hour}
+	reporter.report("Exported %d links", 1000)
+	reporter.report("Exported %d links", 2000)
+	assert.Equal(t, "Exported 1000 links\n", out.String())
+	reporter.interval = 0
+	reporter.report("Exported %d links", 3000)
+	assert.Equal(t, "Exported 1000 links\nExported 3000 links\n", out.String())
+}
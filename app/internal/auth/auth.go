@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+)
+
+// keyIDBytes and secretBytes are the random bytes of the ID and the secret of an API key
+const (
+	keyIDBytes  = 6
+	secretBytes = 32
+)
+
+// An Identity is the authenticated caller of a request
+type Identity struct {
+	// KeyID identifies the API key without revealing it, thus it can be logged
+	KeyID     string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// A KeyStore creates and validates the API keys of the service.
+// Only a hash of each key is stored, so a leaked store does not leak the keys.
+type KeyStore interface {
+	// Create generates a new API key with the given name, returning its token.
+	// The token can not be recovered afterwards.
+	Create(ctx context.Context, name string) (string, *Identity, error)
+	// Authenticate returns the identity of the given token, or nil if it is not a valid API key
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+type keyStore struct {
+	cache  cache.Cache
+	random io.Reader
+}
+
+// NewKeyStore creates a key store that keeps the hashed API keys in the given cache
+func NewKeyStore(c cache.Cache) KeyStore {
+	return &keyStore{cache: c, random: rand.Reader}
+}
+
+func (s *keyStore) Create(ctx context.Context, name string) (string, *Identity, error) {
+	if name == "" {
+		return "", nil, errors.New("the api key name can not be empty")
+	}
+	b := make([]byte, keyIDBytes+secretBytes)
+	if _, err := io.ReadFull(s.random, b); err != nil {
+		return "", nil, err
+	}
+	identity := &Identity{
+		KeyID:     hex.EncodeToString(b[:keyIDBytes]),
+		Name:      name,
+		CreatedAt: time.Now().UTC().Round(time.Second),
+	}
+	// The key ID is part of the token, so a token can be traced to its key
+	token := identity.KeyID + "." + base64.RawURLEncoding.EncodeToString(b[keyIDBytes:])
+	value, err := json.Marshal(identity)
+	if err != nil {
+		return "", nil, err
+	}
+	success, err := s.cache.SetIfNotExists(ctx, keyHash(token), string(value), 0)
+	if err != nil {
+		return "", nil, err
+	}
+	if !success {
+		// It can only happen with a broken random source
+		return "", nil, errors.New("api key collision")
+	}
+	return token, identity, nil
+}
+
+func (s *keyStore) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	if token == "" {
+		return nil, nil
+	}
+	value, err := s.cache.Get(ctx, keyHash(token))
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var identity Identity
+	if err := json.Unmarshal([]byte(value), &identity); err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// KeyPrefix namespaces the cache keys of the API keys
+const KeyPrefix = "apikey:"
+
+// keyHash returns the cache key of the given token. As tokens are long random
+// strings, a fast hash is enough: they can not be brute forced like passwords.
+// Looking up the hash also avoids comparing secrets in non-constant time.
+func keyHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return KeyPrefix + hex.EncodeToString(sum[:])
+}
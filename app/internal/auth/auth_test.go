@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("mock error")
+}
+
+func newTestKeyStore(t *testing.T) *keyStore {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewKeyStore(cache.NewMemory(ctx, &config.Values{})).(*keyStore)
+}
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	s := newTestKeyStore(t)
+	ctx := context.Background()
+	token, identity, err := s.Create(ctx, "ci")
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", identity.Name)
+	assert.Len(t, identity.KeyID, 2*keyIDBytes)
+	assert.True(t, strings.HasPrefix(token, identity.KeyID+"."))
+	assert.False(t, identity.CreatedAt.IsZero())
+
+	authenticated, err := s.Authenticate(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, identity, authenticated)
+
+	// Only the hash of the token is stored
+	value, err := s.cache.Get(ctx, keyHash(token))
+	assert.NoError(t, err)
+	assert.NotContains(t, value, token)
+}
+
+func TestCreate_EmptyName(t *testing.T) {
+	token, identity, err := newTestKeyStore(t).Create(context.Background(), "")
+	assert.EqualError(t, err, "the api key name can not be empty")
+	assert.Equal(t, "", token)
+	assert.Nil(t, identity)
+}
+
+func TestCreate_RandomError(t *testing.T) {
+	s := newTestKeyStore(t)
+	s.random = errReader{}
+	_, _, err := s.Create(context.Background(), "ci")
+	assert.EqualError(t, err, "mock error")
+}
+
+func TestCreate_Collision(t *testing.T) {
+	s := newTestKeyStore(t)
+	random := bytes.Repeat([]byte{1}, keyIDBytes+secretBytes)
+	s.random = bytes.NewReader(random)
+	_, _, err := s.Create(context.Background(), "ci")
+	assert.NoError(t, err)
+	s.random = bytes.NewReader(random)
+	_, _, err = s.Create(context.Background(), "deploy")
+	assert.EqualError(t, err, "api key collision")
+}
+
+func TestCreate_CacheError(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	defer mr.Close()
+	mr.SetError("mock error")
+	_, _, err := NewKeyStore(c).Create(context.Background(), "ci")
+	assert.EqualError(t, err, "mock error")
+}
+
+func TestAuthenticate_InvalidToken(t *testing.T) {
+	s := newTestKeyStore(t)
+	for _, token := range []string{"", "invalid", "0123456789ab.secret"} {
+		identity, err := s.Authenticate(context.Background(), token)
+		assert.NoError(t, err)
+		assert.Nil(t, identity)
+	}
+}
+
+func TestAuthenticate_CacheError(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	defer mr.Close()
+	mr.SetError("mock error")
+	identity, err := NewKeyStore(c).Authenticate(context.Background(), "token")
+	assert.EqualError(t, err, "mock error")
+	assert.Nil(t, identity)
+}
+
+func TestAuthenticate_Corrupted(t *testing.T) {
+	s := newTestKeyStore(t)
+	assert.NoError(t, s.cache.Set(context.Background(), keyHash("token"), "corrupted", 0))
+	identity, err := s.Authenticate(context.Background(), "token")
+	assert.Error(t, err)
+	assert.Nil(t, identity)
+}
@@ -0,0 +1,620 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	bolt "go.etcd.io/bbolt"
+)
+
+// defaultBoltPath is the database file used when the configuration does not define it
+const defaultBoltPath = "shortesturl.db"
+
+// boltOpenTimeout is how long opening the database file waits for the lock of another process
+// (e.g. the server while the administrative CLI runs), as bolt allows a single one at a time
+var boltOpenTimeout = 5 * time.Second
+
+// boltBucket is the bucket that holds every key of the cache
+var boltBucket = []byte("keys")
+
+// boltMembersBucket is the bucket that holds the member counters of IncrementMember, in a
+// nested bucket per key whose values are the counters of its members (8 bytes, big endian),
+// thus a counter is incremented without reading the rest.
+var boltMembersBucket = []byte("members")
+
+// boltMembersExpirationBucket holds the expiration dates of the member keys that expire
+// (8 bytes, unix nanoseconds), as their nested buckets can not be prefixed like the values
+var boltMembersExpirationBucket = []byte("membersExpiration")
+
+// A boltStore keeps the keys in an embedded database file, thus they survive restarts.
+// Every value is prefixed with its expiration date (8 bytes, unix nanoseconds, zero if
+// it never expires). Bolt allows a single writer at a time, which makes the check and the
+// set of SetIfNotExists atomic without optimistic locking.
+type boltStore struct {
+	db *bolt.DB
+	// closed is closed once the database is closed
+	closed chan struct{}
+}
+
+// NewBolt creates a cache instance backed by a bolt database file. A background sweeper
+// removes the expired keys until the given context is done, closing the database afterwards.
+func NewBolt(ctx context.Context, conf *config.Values) (Cache, error) {
+	path := conf.Storage.Path
+	if path == "" {
+		path = defaultBoltPath
+	}
+	// Another process might hold the file lock, it should not hang forever
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf(
+			"bolt database %s is locked by another process (e.g. a running server)", path,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{boltBucket, boltMembersBucket, boltMembersExpirationBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	b := &boltStore{db: db, closed: make(chan struct{})}
+	interval := time.Duration(conf.Storage.JanitorIntervalInSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultJanitorInterval
+	}
+	go b.sweeper(ctx, interval)
+	return b, nil
+}
+
+// sweeper periodically removes the expired keys
+func (b *boltStore) sweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			b.db.Close()
+			close(b.closed)
+			return
+		case now := <-ticker.C:
+			b.removeExpired(now)
+		}
+	}
+}
+
+func (b *boltStore) removeExpired(now time.Time) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		for k, v := c.First(); k != nil; {
+			if _, expiresAt := decodeBoltValue(v); isExpired(expiresAt, now) {
+				// Deleting moves the cursor to the next key
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				k, v = c.Seek(k)
+				continue
+			}
+			k, v = c.Next()
+		}
+		c = tx.Bucket(boltMembersExpirationBucket).Cursor()
+		for k, v := c.First(); k != nil; {
+			if isExpired(decodeBoltExpiration(v), now) {
+				if err := deleteBoltMembers(tx, string(k)); err != nil {
+					return err
+				}
+				k, v = c.Seek(k)
+				continue
+			}
+			k, v = c.Next()
+		}
+		return nil
+	})
+}
+
+func (b *boltStore) Get(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	var value string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		value, _ = getBoltValue(tx, key, time.Now())
+		return nil
+	})
+	return value, err
+}
+
+func (b *boltStore) GetLink(ctx context.Context, key string) (*Link, error) {
+	return getLink(ctx, b, key)
+}
+
+func (b *boltStore) GetAll(ctx context.Context, keys []string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	values := make([]string, len(keys))
+	err := b.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for i, key := range keys {
+			values[i], _ = getBoltValue(tx, key, now)
+		}
+		return nil
+	})
+	return values, err
+}
+
+func (b *boltStore) GetLinks(ctx context.Context, keys []string) ([]*Link, error) {
+	return getLinks(ctx, b, keys)
+}
+
+func (b *boltStore) SetIfNotExists(
+	ctx context.Context, key string, value string, expiration time.Duration,
+) (bool, error) {
+	return b.setIfNotExists(ctx, key, value, expiration, func(previousValue string) bool {
+		return value == previousValue
+	})
+}
+
+func (b *boltStore) SetLinkIfNotExists(
+	ctx context.Context, key string, link *Link, expiration time.Duration,
+) (bool, error) {
+	return b.setIfNotExists(ctx, key, link.String(), expiration, sameURL(link.URL, link.CreatedBy))
+}
+
+// setIfNotExists sets the key if it does not exist, succeeding as well if the
+// key already holds a value that is considered the same by the given function
+func (b *boltStore) setIfNotExists(
+	ctx context.Context,
+	key string,
+	value string,
+	expiration time.Duration,
+	same func(previousValue string) bool,
+) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	success := true
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		if previousValue, ok := getBoltValue(tx, key, now); ok {
+			// The key was already stored with the same value, or it is a collision
+			success = same(previousValue)
+			return nil
+		}
+		return putBoltValue(tx, key, value, expiresAt(now, expiration))
+	})
+	return success && err == nil, err
+}
+
+// SetLinksIfNotExists stores every link in a single transaction, as each one is synced to disk
+func (b *boltStore) SetLinksIfNotExists(ctx context.Context, entries []LinkEntry) ([]bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	results := make([]bool, len(entries))
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for i, entry := range entries {
+			if previousValue, ok := getBoltValue(tx, entry.Key, now); ok {
+				results[i] = sameLink(entry)(previousValue)
+				continue
+			}
+			results[i] = true
+			err := putBoltValue(
+				tx, entry.Key, entry.Link.String(), expiresAt(now, entry.Expiration),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (b *boltStore) SetAll(ctx context.Context, entries []Entry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for _, entry := range entries {
+			err := putBoltValue(tx, entry.Key, entry.Value, expiresAt(now, entry.Expiration))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *boltStore) Set(
+	ctx context.Context, key string, value string, expiration time.Duration,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return putBoltValue(tx, key, value, expiresAt(time.Now(), expiration))
+	})
+}
+
+func (b *boltStore) Update(
+	ctx context.Context, key string, value string, expiration time.Duration,
+) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	var success bool
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		v := tx.Bucket(boltBucket).Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		_, keyExpiresAt := decodeBoltValue(v)
+		if isExpired(keyExpiresAt, now) {
+			return nil
+		}
+		if expiration != KeepTTL {
+			keyExpiresAt = expiresAt(now, expiration)
+		}
+		success = true
+		return putBoltValue(tx, key, value, keyExpiresAt)
+	})
+	return success && err == nil, err
+}
+
+func (b *boltStore) UpdateLink(
+	ctx context.Context, key string, link *Link, expiration time.Duration,
+) (bool, error) {
+	return b.Update(ctx, key, link.String(), expiration)
+}
+
+func (b *boltStore) Delete(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	var deleted bool
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		_, deleted = getBoltValue(tx, key, now)
+		if err := tx.Bucket(boltBucket).Delete([]byte(key)); err != nil {
+			return err
+		}
+		if getBoltMembers(tx, key, now) != nil {
+			deleted = true
+		}
+		return deleteBoltMembers(tx, key)
+	})
+	return deleted && err == nil, err
+}
+
+// Scan uses the last key of each page as cursor, as bolt keeps the keys sorted
+func (b *boltStore) Scan(
+	ctx context.Context, cursor string, match string, count int,
+) ([]string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	if count <= 0 {
+		count = defaultScanCount
+	}
+	var keys []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		// The first count keys of both buckets are among the first count+1 keys of each one
+		for _, bucket := range [][]byte{boltBucket, boltMembersBucket} {
+			found := 0
+			c := tx.Bucket(bucket).Cursor()
+			for k, v := c.Seek([]byte(cursor)); k != nil && found <= count; k, v = c.Next() {
+				key := string(k)
+				if key == cursor || !matchGlob(match, key) {
+					continue
+				}
+				if bytes.Equal(bucket, boltBucket) {
+					if _, expiresAt := decodeBoltValue(v); isExpired(expiresAt, now) {
+						continue
+					}
+				} else if getBoltMembers(tx, key, now) == nil {
+					continue
+				}
+				keys = append(keys, key)
+				found++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	keys, next := scanPage(keys, count)
+	return keys, next, nil
+}
+
+func (b *boltStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	var ttl time.Duration
+	err := b.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		var expiresAt time.Time
+		if v := tx.Bucket(boltBucket).Get([]byte(key)); v != nil {
+			_, expiresAt = decodeBoltValue(v)
+		} else {
+			expiresAt = decodeBoltExpiration(tx.Bucket(boltMembersExpirationBucket).Get([]byte(key)))
+		}
+		if !expiresAt.IsZero() && !isExpired(expiresAt, now) {
+			ttl = expiresAt.Sub(now)
+		}
+		return nil
+	})
+	return ttl, err
+}
+
+func (b *boltStore) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	var success bool
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		keyExpiresAt := expiresAt(now, expiration)
+		if value, ok := getBoltValue(tx, key, now); ok {
+			success = true
+			return putBoltValue(tx, key, value, keyExpiresAt)
+		}
+		if getBoltMembers(tx, key, now) == nil {
+			return nil
+		}
+		success = true
+		expirations := tx.Bucket(boltMembersExpirationBucket)
+		if keyExpiresAt.IsZero() {
+			return expirations.Delete([]byte(key))
+		}
+		return expirations.Put([]byte(key), encodeBoltValue("", keyExpiresAt))
+	})
+	return success && err == nil, err
+}
+
+func (b *boltStore) Increment(ctx context.Context, key string) (int64, error) {
+	return b.increment(ctx, key, 1, 0)
+}
+
+func (b *boltStore) IncrementWithExpiration(
+	ctx context.Context, key string, increment int64, expiration time.Duration,
+) (int64, error) {
+	return b.increment(ctx, key, increment, expiration)
+}
+
+// increment increments the counter of the given key by the given increment, which
+// is created with the given expiration (zero if it never expires)
+func (b *boltStore) increment(
+	ctx context.Context, key string, increment int64, expiration time.Duration,
+) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	var n int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		var expiresAt time.Time
+		if expiration > 0 {
+			expiresAt = now.Add(expiration)
+		}
+		if v := tx.Bucket(boltBucket).Get([]byte(key)); v != nil {
+			value, valueExpiresAt := decodeBoltValue(v)
+			if !isExpired(valueExpiresAt, now) {
+				var err error
+				if n, err = strconv.ParseInt(value, 10, 64); err != nil {
+					return errNotInteger
+				}
+				// Like redis, incrementing a key does not change its expiration
+				expiresAt = valueExpiresAt
+			}
+		}
+		n += increment
+		return putBoltValue(tx, key, strconv.FormatInt(n, 10), expiresAt)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (b *boltStore) IncrementMember(
+	ctx context.Context, key string, member string, maxMembers int,
+) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	var count int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltMembersBucket).Bucket([]byte(key)) != nil &&
+			getBoltMembers(tx, key, time.Now()) == nil {
+			// The members of an expired key are not inherited
+			if err := deleteBoltMembers(tx, key); err != nil {
+				return err
+			}
+		}
+		members, err := tx.Bucket(boltMembersBucket).CreateBucketIfNotExists([]byte(key))
+		if err != nil {
+			return err
+		}
+		if v := members.Get([]byte(member)); v != nil {
+			if count, err = decodeBoltCounter(v); err != nil {
+				return err
+			}
+		}
+		count++
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(count))
+		if err := members.Put([]byte(member), v); err != nil {
+			return err
+		}
+		if maxMembers <= 0 {
+			return nil
+		}
+		return trimBoltMembers(members, maxMembers)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (b *boltStore) TopMembers(ctx context.Context, key string, limit int) ([]MemberCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var counts []MemberCount
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltMembersBucket).Get([]byte(key)) != nil {
+			// The key holds a value instead of a nested bucket
+			return bolt.ErrIncompatibleValue
+		}
+		members := getBoltMembers(tx, key, time.Now())
+		if members == nil {
+			return nil
+		}
+		return members.ForEach(func(member, v []byte) error {
+			count, err := decodeBoltCounter(v)
+			if err != nil {
+				return err
+			}
+			counts = append(counts, MemberCount{Member: string(member), Count: count})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return topMembers(counts, limit), nil
+}
+
+// trimBoltMembers removes the members that TopMembers sorts last until there are
+// no more than the given maximum. As it is called on every increment, there is
+// usually a single member to remove.
+func trimBoltMembers(members *bolt.Bucket, maxMembers int) error {
+	for {
+		var lowest []byte
+		var lowestCount int64
+		n := 0
+		err := members.ForEach(func(member, v []byte) error {
+			count, err := decodeBoltCounter(v)
+			if err != nil {
+				return err
+			}
+			// The members are sorted, thus the first one of the lowest count is the lowest
+			if n == 0 || count < lowestCount {
+				lowest, lowestCount = member, count
+			}
+			n++
+			return nil
+		})
+		if err != nil || n <= maxMembers {
+			return err
+		}
+		if err := members.Delete(lowest); err != nil {
+			return err
+		}
+	}
+}
+
+// getBoltMembers returns the nested bucket of the member counters of the given key,
+// being nil if it does not exist or it expired
+func getBoltMembers(tx *bolt.Tx, key string, now time.Time) *bolt.Bucket {
+	members := tx.Bucket(boltMembersBucket).Bucket([]byte(key))
+	if members == nil {
+		return nil
+	}
+	if isExpired(decodeBoltExpiration(tx.Bucket(boltMembersExpirationBucket).Get([]byte(key))), now) {
+		return nil
+	}
+	return members
+}
+
+// deleteBoltMembers removes the member counters of the given key and their expiration
+func deleteBoltMembers(tx *bolt.Tx, key string) error {
+	if err := tx.Bucket(boltMembersExpirationBucket).Delete([]byte(key)); err != nil {
+		return err
+	}
+	err := tx.Bucket(boltMembersBucket).DeleteBucket([]byte(key))
+	if errors.Is(err, bolt.ErrBucketNotFound) {
+		return nil
+	}
+	return err
+}
+
+// decodeBoltExpiration returns the expiration date of a member key, which is zero
+// if it never expires
+func decodeBoltExpiration(v []byte) time.Time {
+	if v == nil {
+		return time.Time{}
+	}
+	_, expiresAt := decodeBoltValue(v)
+	return expiresAt
+}
+
+// decodeBoltCounter returns the given member counter
+func decodeBoltCounter(v []byte) (int64, error) {
+	if len(v) != 8 {
+		return 0, errNotInteger
+	}
+	return int64(binary.BigEndian.Uint64(v)), nil
+}
+
+// getBoltValue returns the value of the given key, and false if it does not exist or it expired
+func getBoltValue(tx *bolt.Tx, key string, now time.Time) (string, bool) {
+	v := tx.Bucket(boltBucket).Get([]byte(key))
+	if v == nil {
+		return "", false
+	}
+	value, expiresAt := decodeBoltValue(v)
+	if isExpired(expiresAt, now) {
+		return "", false
+	}
+	return value, true
+}
+
+func putBoltValue(tx *bolt.Tx, key string, value string, expiresAt time.Time) error {
+	return tx.Bucket(boltBucket).Put([]byte(key), encodeBoltValue(value, expiresAt))
+}
+
+func encodeBoltValue(value string, expiresAt time.Time) []byte {
+	v := make([]byte, 8+len(value))
+	if !expiresAt.IsZero() {
+		binary.BigEndian.PutUint64(v, uint64(expiresAt.UnixNano()))
+	}
+	copy(v[8:], value)
+	return v
+}
+
+func decodeBoltValue(v []byte) (string, time.Time) {
+	if len(v) < 8 {
+		// It can not happen unless the file was written by a different program
+		return "", time.Time{}
+	}
+	var expiresAt time.Time
+	if nanos := binary.BigEndian.Uint64(v); nanos != 0 {
+		expiresAt = time.Unix(0, int64(nanos))
+	}
+	return string(v[8:]), expiresAt
+}
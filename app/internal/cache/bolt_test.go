@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	bolt "go.etcd.io/bbolt"
+)
+
+type BoltTestSuite struct {
+	suite.Suite
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	cache  *boltStore
+}
+
+func (s *BoltTestSuite) SetupSuite() {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	c, err := NewBolt(s.ctx, &config.Values{Storage: config.StorageValues{
+		Path: filepath.Join(s.T().TempDir(), "test.db"),
+	}})
+	assert.NoError(s.T(), err)
+	s.cache = c.(*boltStore)
+}
+
+func (s *BoltTestSuite) TearDownSuite() {
+	s.cancel()
+}
+
+func TestBolt(t *testing.T) {
+	suite.Run(t, new(BoltTestSuite))
+}
+
+func TestNewBolt_Reopen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	path := filepath.Join(t.TempDir(), "test.db")
+	conf := &config.Values{Storage: config.StorageValues{Path: path}}
+	c, err := NewBolt(ctx, conf)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Set(ctx, "key1", "val1", 0))
+	// The database is closed once the context is done
+	cancel()
+	<-c.(*boltStore).closed
+
+	c, err = NewBolt(context.Background(), conf)
+	assert.NoError(t, err)
+	val, err := c.Get(context.Background(), "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "val1", val)
+}
+
+func TestNewBolt_Locked(t *testing.T) {
+	timeout := boltOpenTimeout
+	boltOpenTimeout = 10 * time.Millisecond
+	defer func() { boltOpenTimeout = timeout }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "test.db")
+	conf := &config.Values{Storage: config.StorageValues{Path: path}}
+	_, err := NewBolt(ctx, conf)
+	assert.NoError(t, err)
+
+	// The database is still open, thus its file is locked
+	_, err = NewBolt(ctx, conf)
+	assert.EqualError(t, err,
+		"bolt database "+path+" is locked by another process (e.g. a running server)")
+}
+
+func (s *BoltTestSuite) TestIncrement_Expired() {
+	s.cache.Set(s.ctx, "counter3", "41", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	val, err := s.cache.Increment(s.ctx, "counter3")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(1), val)
+}
+
+func (s *BoltTestSuite) TestIncrement_NotAnInteger() {
+	s.cache.Set(s.ctx, "counter4", "value", 0)
+	_, err := s.cache.Increment(s.ctx, "counter4")
+	assert.Equal(s.T(), errNotInteger, err)
+}
+
+func (s *BoltTestSuite) TestRemoveExpired() {
+	s.cache.Set(s.ctx, "sweep1", "val1", time.Minute)
+	s.cache.Set(s.ctx, "sweep2", "val2", time.Minute)
+	s.cache.Set(s.ctx, "sweep3", "val3", 0)
+	assert.NoError(s.T(), s.cache.removeExpired(time.Now().Add(time.Hour)))
+	s.cache.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		assert.Nil(s.T(), b.Get([]byte("sweep1")))
+		assert.Nil(s.T(), b.Get([]byte("sweep2")))
+		assert.NotNil(s.T(), b.Get([]byte("sweep3")))
+		return nil
+	})
+}
+
+func (s *BoltTestSuite) TestMembers_Corrupted() {
+	s.cache.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltMembersBucket).Put([]byte("members1"), []byte("corrupted"))
+	})
+	_, err := s.cache.IncrementMember(s.ctx, "members1", "member", 0)
+	assert.Error(s.T(), err)
+	_, err = s.cache.TopMembers(s.ctx, "members1", 0)
+	assert.Error(s.T(), err)
+
+	s.cache.db.Update(func(tx *bolt.Tx) error {
+		members, err := tx.Bucket(boltMembersBucket).CreateBucket([]byte("members2"))
+		if err != nil {
+			return err
+		}
+		return members.Put([]byte("member"), []byte("corrupted"))
+	})
+	_, err = s.cache.IncrementMember(s.ctx, "members2", "member", 0)
+	assert.Equal(s.T(), errNotInteger, err)
+	_, err = s.cache.TopMembers(s.ctx, "members2", 0)
+	assert.Equal(s.T(), errNotInteger, err)
+}
+
+func TestDecodeBoltValue_Corrupted(t *testing.T) {
+	value, expiresAt := decodeBoltValue([]byte("abc"))
+	assert.Equal(t, "", value)
+	assert.True(t, expiresAt.IsZero())
+}
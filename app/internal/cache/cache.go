@@ -4,15 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/darioblanco/shortesturl/app/internal/config"
 	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/darioblanco/shortesturl/app/internal/metrics"
 	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// A Cache exposes functions from an in-memory store
+// A Cache exposes functions from a key/value store
 type Cache interface {
 	// Get gets the value of the given key.
 	// If the key does not exist, the returned string will be empty ("").
@@ -23,47 +30,211 @@ type Cache interface {
 	SetIfNotExists(
 		ctx context.Context, key string, value string, expiration time.Duration,
 	) (bool, error)
+	// GetLink gets the link stored in the given key, which can be a legacy plain url.
+	// If the key does not exist, the returned link will be nil.
+	GetLink(ctx context.Context, key string) (*Link, error)
+	// SetLinkIfNotExists set key to hold the link if key does not exist (returning true),
+	// like SetIfNotExists. If key already holds a link to the same long url that was created
+	// by the same API key, no operation is performed and true is returned too, as the short
+	// url can be shared.
+	SetLinkIfNotExists(
+		ctx context.Context, key string, link *Link, expiration time.Duration,
+	) (bool, error)
+	// UpdateLink sets key to hold the link only if the key already exists (returning true),
+	// like Update.
+	UpdateLink(
+		ctx context.Context, key string, link *Link, expiration time.Duration,
+	) (bool, error)
+	// GetAll gets the values of the given keys at once, in the same order.
+	// The value of the keys that do not exist will be empty ("").
+	GetAll(ctx context.Context, keys []string) ([]string, error)
+	// GetLinks gets the links stored in the given keys at once, in the same order.
+	// The link of the keys that do not exist will be nil.
+	GetLinks(ctx context.Context, keys []string) ([]*Link, error)
+	// SetLinksIfNotExists stores several links at once like SetLinkIfNotExists,
+	// returning the result of each one in the same order. The unique entries fail
+	// if their key already holds any link.
+	SetLinksIfNotExists(ctx context.Context, entries []LinkEntry) ([]bool, error)
+	// SetAll sets several keys at once like Set.
+	SetAll(ctx context.Context, entries []Entry) error
+	// Set sets key to hold the string value, overwriting any previous value.
+	// Zero expiration means the key is there forever.
+	Set(ctx context.Context, key string, value string, expiration time.Duration) error
+	// Update sets key to hold the string value only if the key already exists (returning true).
+	// If the key does not exist, no operation is performed and false is returned as a result.
+	// KeepTTL keeps the current expiration of the key, and zero expiration means the key is
+	// there forever.
+	Update(ctx context.Context, key string, value string, expiration time.Duration) (bool, error)
+	// Delete removes the given key, returning false if it did not exist
+	Delete(ctx context.Context, key string) (bool, error)
+	// Scan returns a page of up to about count keys that match the given glob-style pattern
+	// (e.g. "tombstone:*"), and the cursor of the next page. The iteration starts with
+	// an empty cursor, and it is complete once the returned cursor is empty. Every key
+	// that exists during the whole iteration is returned, although redis can return
+	// the same key more than once.
+	Scan(ctx context.Context, cursor string, match string, count int) ([]string, string, error)
+	// TTL returns the remaining time to live of the given key.
+	// If the key does not exist or it has no expiration, the returned duration will be zero.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// Expire sets the expiration of the given key, whatever it holds, returning false if the
+	// key does not exist. Zero expiration means the key is there forever.
+	Expire(ctx context.Context, key string, expiration time.Duration) (bool, error)
+	// Increment increments the integer value of the given key by one, returning the new value.
+	// If the key does not exist, it is set to 0 before performing the operation.
+	Increment(ctx context.Context, key string) (int64, error)
+	// IncrementWithExpiration increments the integer value of the given key by the given
+	// increment, like Increment, but the key is created with the given expiration if it
+	// does not exist. The expiration of an existing key is not changed.
+	IncrementWithExpiration(
+		ctx context.Context, key string, increment int64, expiration time.Duration,
+	) (int64, error)
+	// IncrementMember increments by one the counter of the given member of the key,
+	// returning the new count. If the key holds more than maxMembers members afterwards
+	// (zero means there is no limit), the last ones in the order of TopMembers are removed.
+	// The expiration of the key is not changed, and keys holding member counters must not
+	// be used with the string operations.
+	IncrementMember(
+		ctx context.Context, key string, member string, maxMembers int,
+	) (int64, error)
+	// TopMembers returns the member counters of the given key, sorted by count and then by
+	// member (both descending). A limit of zero returns every member.
+	TopMembers(ctx context.Context, key string, limit int) ([]MemberCount, error)
+}
+
+// KeepTTL is the expiration that keeps the current expiration of a key in Update
+const KeepTTL time.Duration = redis.KeepTTL
+
+// defaultScanCount is the page size of Scan when the count is not positive
+const defaultScanCount = 10
+
+// ErrInvalidCursor is returned by Scan if the cursor was not returned by a previous call
+var ErrInvalidCursor = errors.New("invalid scan cursor")
+
+// An Entry is a string value to be stored in a key by SetAll
+type Entry struct {
+	Key        string
+	Value      string
+	Expiration time.Duration
+}
+
+// A LinkEntry is a link to be stored in a key by SetLinksIfNotExists
+type LinkEntry struct {
+	Key        string
+	Link       *Link
+	Expiration time.Duration
+	// Unique links are never shared: a key that already holds a link to
+	// the same long url is a collision too
+	Unique bool
+}
+
+// A MemberCount is the counter of a member of a key
+type MemberCount struct {
+	Member string
+	Count  int64
 }
 
 type cache struct {
-	client    *redis.Client
+	client    redis.UniversalClient
 	logger    logging.Logger
 	keyPrefix string
 }
 
-// New creates a new cache instance
+// Backend returns the storage backend selected in the config. If it is not set, it is
+// derived from the environment: the development environment keeps the keys in memory,
+// while the rest require redis.
+func Backend(conf *config.Values) string {
+	if conf.Storage.Backend != "" {
+		return conf.Storage.Backend
+	}
+	if conf.IsDevelopment {
+		return "memory"
+	}
+	return "redis"
+}
+
+// IsEphemeral returns true if the given storage backend loses its keys once the process exits
+func IsEphemeral(backend string) bool {
+	return backend == "memory" || backend == "miniredis"
+}
+
+// New creates a new cache instance with the storage backend selected in the config
+// (see Backend)
 func New(
 	ctx context.Context, conf *config.Values, logger logging.Logger,
 ) (Cache, error) {
-	var opts *redis.Options
-	if conf.IsDevelopment {
-		mr, _ := miniredis.Run()
-		opts = &redis.Options{
-			Addr: fmt.Sprintf("%s:%s", mr.Host(), mr.Port()),
+	backend := Backend(conf)
+	switch backend {
+	case "bolt":
+		c, err := NewBolt(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Loaded bolt cache", "path", conf.Storage.Path)
+		return c, nil
+	case "memory":
+		logger.Info("Loaded in-memory cache",
+			"maxEntries", conf.Storage.MaxEntries,
+			"shards", conf.Storage.Shards,
+		)
+		return NewMemory(ctx, conf), nil
+	case "miniredis":
+		mr, err := miniredis.Run()
+		if err != nil {
+			return nil, err
 		}
 		logger.Debug("Loaded miniredis configuration")
-	} else {
-		opts = &redis.Options{
-			Addr: fmt.Sprintf("%s:%s", conf.RedisHost, conf.RedisPort),
+		return newRedis(ctx, &redis.UniversalOptions{
+			Addrs: []string{fmt.Sprintf("%s:%s", mr.Host(), mr.Port())},
+		}, conf.Redis.KeyPrefix, logger)
+	case "redis":
+		opts, err := redisOptions(conf)
+		if err != nil {
+			return nil, err
 		}
-		logger.Debug("Loaded production redis configuration")
+		logger.Debug("Loaded production redis configuration",
+			"masterName", opts.MasterName,
+			"tls", opts.TLSConfig != nil,
+		)
+		return newRedis(ctx, opts, conf.Redis.KeyPrefix, logger)
 	}
-	client := redis.NewClient(opts)
+	return nil, fmt.Errorf(
+		"invalid storage backend %q (allowed: bolt, memory, miniredis, redis)", backend,
+	)
+}
+
+// newRedis creates a cache instance backed by a redis client, which connects
+// to a single node, a Sentinel master or a Cluster depending on the options.
+// All the keys are namespaced with the given prefix (if any).
+func newRedis(
+	ctx context.Context, opts *redis.UniversalOptions, keyPrefix string, logger logging.Logger,
+) (Cache, error) {
+	client := redis.NewUniversalClient(opts)
 	// If redis is not available, it will hang here until it times out
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, err
 	}
-	logger.Info("Connected to cache", "address", client.Options().Addr)
+	logger.Info("Connected to cache", "addresses", opts.Addrs, "keyPrefix", keyPrefix)
 	return &cache{
-		client: client,
-		logger: logger,
+		client:    client,
+		logger:    logger,
+		keyPrefix: keyPrefix,
 	}, nil
 }
 
+// key returns the redis key of the given cache key, namespaced with the key prefix.
+// This allows sharing the same redis with other services.
+func (c cache) key(key string) string {
+	if c.keyPrefix == "" {
+		return key
+	}
+	return c.keyPrefix + ":" + key
+}
+
 func (c cache) Get(ctx context.Context, key string) (string, error) {
 	value, err := c.client.Get(
 		ctx,
-		key,
+		c.key(key),
 	).Result()
 	if err == redis.Nil {
 		// The given key does not exist, in our architecture, each key MUST always have a url
@@ -72,19 +243,77 @@ func (c cache) Get(ctx context.Context, key string) (string, error) {
 	return value, err
 }
 
+func (c cache) GetLink(ctx context.Context, key string) (*Link, error) {
+	return getLink(ctx, c, key)
+}
+
+// GetAll pipelines the reads, which go-redis splits by node in a Cluster
+func (c cache) GetAll(ctx context.Context, keys []string) ([]string, error) {
+	values := make([]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	cmds := make([]*redis.StringCmd, len(keys))
+	// The error of the pipeline is checked for each command, as a missing key is an error too
+	c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, c.key(key))
+		}
+		return nil
+	})
+	for i, cmd := range cmds {
+		value, err := cmd.Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (c cache) GetLinks(ctx context.Context, keys []string) ([]*Link, error) {
+	return getLinks(ctx, c, keys)
+}
+
 func (c cache) SetIfNotExists(
 	ctx context.Context, key string, value string, expiration time.Duration,
 ) (bool, error) {
+	return c.setIfNotExists(ctx, key, value, expiration, func(previousValue string) bool {
+		return value == previousValue
+	})
+}
+
+func (c cache) SetLinkIfNotExists(
+	ctx context.Context, key string, link *Link, expiration time.Duration,
+) (bool, error) {
+	return c.setIfNotExists(ctx, key, link.String(), expiration, sameURL(link.URL, link.CreatedBy))
+}
+
+// setIfNotExists sets the key if it does not exist, succeeding as well if the
+// key already holds a value that is considered the same by the given function
+func (c cache) setIfNotExists(
+	ctx context.Context,
+	key string,
+	value string,
+	expiration time.Duration,
+	same func(previousValue string) bool,
+) (bool, error) {
+	key = c.key(key)
 	collisionError := errors.New("url collision")
 	txf := func(tx *redis.Tx) error {
 		// Check if the key candidate is already stored
-		previousValue, err := c.Get(ctx, key)
+		// The read uses the watched connection, otherwise each writer would hold two
+		// connections of the pool at the same time, exhausting it under high concurrency
+		previousValue, err := tx.Get(ctx, key).Result()
+		if err == redis.Nil {
+			previousValue, err = "", nil
+		}
 		if err != nil {
 			return err
 		}
 		if previousValue != "" {
 			// The key is already being used
-			if value == previousValue {
+			if same(previousValue) {
 				// The key was already stored in the service, no need to set it again
 				return nil
 			}
@@ -116,6 +345,252 @@ func (c cache) SetIfNotExists(
 			return true, err
 		}
 		// optimistic lock lost
+		trace.SpanFromContext(ctx).AddEvent("optimistic lock lost",
+			trace.WithAttributes(attribute.Int("attempt", retries+1)))
+		if retries < 4 {
+			metrics.CacheLockRetries.Inc()
+		}
 	}
+	metrics.CacheLockFailures.Inc()
 	return false, errors.New("max retries reached (4)")
 }
+
+// SetLinksIfNotExists pipelines a SETNX for every link, instead of watching each key, and then
+// reads the keys that already exist to find out which ones hold a link that can be shared
+func (c cache) SetLinksIfNotExists(ctx context.Context, entries []LinkEntry) ([]bool, error) {
+	results := make([]bool, len(entries))
+	if len(entries) == 0 {
+		return results, nil
+	}
+	cmds := make([]*redis.BoolCmd, len(entries))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, entry := range entries {
+			cmds[i] = pipe.SetNX(ctx, c.key(entry.Key), entry.Link.String(), entry.Expiration)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var existing []int
+	var keys []string
+	for i, cmd := range cmds {
+		if results[i] = cmd.Val(); !results[i] && !entries[i].Unique {
+			existing = append(existing, i)
+			keys = append(keys, entries[i].Key)
+		}
+	}
+	values, err := c.GetAll(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range existing {
+		results[i] = sameLink(entries[i])(values[j])
+	}
+	return results, nil
+}
+
+func (c cache) SetAll(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, entry := range entries {
+			pipe.Set(ctx, c.key(entry.Key), entry.Value, entry.Expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+func (c cache) Set(
+	ctx context.Context, key string, value string, expiration time.Duration,
+) error {
+	return c.client.Set(ctx, c.key(key), value, expiration).Err()
+}
+
+func (c cache) Update(
+	ctx context.Context, key string, value string, expiration time.Duration,
+) (bool, error) {
+	return c.client.SetXX(ctx, c.key(key), value, expiration).Result()
+}
+
+func (c cache) UpdateLink(
+	ctx context.Context, key string, link *Link, expiration time.Duration,
+) (bool, error) {
+	return c.Update(ctx, key, link.String(), expiration)
+}
+
+func (c cache) Delete(ctx context.Context, key string) (bool, error) {
+	n, err := c.client.Del(ctx, c.key(key)).Result()
+	return n > 0, err
+}
+
+// Scan iterates the nodes that hold the keys one after the other, as SCAN only returns
+// the keys of the node that runs it. The cursor holds the index of the node besides its
+// SCAN cursor (e.g. "1-42"), unless it is the first node, so it is the plain SCAN cursor
+// if there is a single one.
+func (c cache) Scan(
+	ctx context.Context, cursor string, match string, count int,
+) ([]string, string, error) {
+	node, n, err := parseScanCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	if count <= 0 {
+		count = defaultScanCount
+	}
+	nodes, err := c.scanNodes(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if node >= len(nodes) {
+		return nil, "", ErrInvalidCursor
+	}
+	keys, next, err := nodes[node].Scan(ctx, n, c.key(match), int64(count)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	if c.keyPrefix != "" {
+		for i, key := range keys {
+			keys[i] = strings.TrimPrefix(key, c.keyPrefix+":")
+		}
+	}
+	if next == 0 {
+		// The node is complete, thus the iteration continues with the next one (if any)
+		if node++; node == len(nodes) {
+			return keys, "", nil
+		}
+	}
+	if node == 0 {
+		return keys, strconv.FormatUint(next, 10), nil
+	}
+	return keys, fmt.Sprintf("%d-%d", node, next), nil
+}
+
+// parseScanCursor returns the node index and the SCAN cursor of the given Scan cursor
+func parseScanCursor(cursor string) (int, uint64, error) {
+	if cursor == "" {
+		return 0, 0, nil
+	}
+	node := 0
+	if i := strings.IndexByte(cursor, '-'); i >= 0 {
+		var err error
+		if node, err = strconv.Atoi(cursor[:i]); err != nil || node < 0 {
+			return 0, 0, ErrInvalidCursor
+		}
+		cursor = cursor[i+1:]
+	}
+	n, err := strconv.ParseUint(cursor, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+	return node, n, nil
+}
+
+// scanNodes returns the clients of the nodes that hold the keys. A Cluster spreads its keys
+// across its masters, which are sorted by address so every Scan call finds them in the same
+// order. The keys of the masters that join or leave the Cluster during an iteration can be
+// missed.
+func (c cache) scanNodes(ctx context.Context) ([]redis.Cmdable, error) {
+	cluster, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return []redis.Cmdable{c.client}, nil
+	}
+	var mu sync.Mutex
+	var masters []*redis.Client
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		masters = append(masters, client)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(masters, func(i, j int) bool {
+		return masters[i].Options().Addr < masters[j].Options().Addr
+	})
+	nodes := make([]redis.Cmdable, len(masters))
+	for i, master := range masters {
+		nodes[i] = master
+	}
+	return nodes, nil
+}
+
+func (c cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := c.client.PTTL(ctx, c.key(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		// -1 when the key has no expiration, -2 when the key does not exist
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (c cache) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	if expiration > 0 {
+		return c.client.PExpire(ctx, c.key(key), expiration).Result()
+	}
+	// PERSIST returns false for the existing keys without expiration too
+	exists, err := c.client.Exists(ctx, c.key(key)).Result()
+	if err != nil || exists == 0 {
+		return false, err
+	}
+	return true, c.client.Persist(ctx, c.key(key)).Err()
+}
+
+func (c cache) Increment(ctx context.Context, key string) (int64, error) {
+	return c.client.Incr(ctx, c.key(key)).Result()
+}
+
+// incrementWithExpiration sets the expiration only when the key is created,
+// which can not be done atomically with INCRBY and PEXPIRE alone
+var incrementWithExpiration = redis.NewScript(`
+local created = redis.call("EXISTS", KEYS[1]) == 0
+local n = redis.call("INCRBY", KEYS[1], ARGV[1])
+if created then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return n
+`)
+
+func (c cache) IncrementWithExpiration(
+	ctx context.Context, key string, increment int64, expiration time.Duration,
+) (int64, error) {
+	return incrementWithExpiration.Run(
+		ctx, c.client, []string{c.key(key)}, increment, expiration.Milliseconds(),
+	).Int64()
+}
+
+// incrementMember trims the sorted set in the same call, so it never holds more
+// than the maximum members. The lowest ranks are the last ones of TopMembers.
+var incrementMember = redis.NewScript(`
+local n = redis.call("ZINCRBY", KEYS[1], 1, ARGV[1])
+local max = tonumber(ARGV[2])
+if max > 0 then
+	redis.call("ZREMRANGEBYRANK", KEYS[1], 0, -max - 1)
+end
+return tonumber(n)
+`)
+
+func (c cache) IncrementMember(
+	ctx context.Context, key string, member string, maxMembers int,
+) (int64, error) {
+	return incrementMember.Run(ctx, c.client, []string{c.key(key)}, member, maxMembers).Int64()
+}
+
+func (c cache) TopMembers(ctx context.Context, key string, limit int) ([]MemberCount, error) {
+	// A stop index of -1 returns every member
+	members, err := c.client.ZRevRangeWithScores(ctx, c.key(key), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]MemberCount, len(members))
+	for i, m := range members {
+		counts[i] = MemberCount{Member: m.Member.(string), Count: int64(m.Score)}
+	}
+	return counts, nil
+}
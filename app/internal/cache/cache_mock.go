@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alicebob/miniredis/v2"
@@ -13,6 +14,38 @@ func NewTest() Cache {
 	return client
 }
 
+// NewMiniredisCluster returns the miniredis instances of a Cluster with two masters,
+// which split the hash slots in half, and the Cache instance of the Cluster
+func NewMiniredisCluster() ([]*miniredis.Miniredis, Cache) {
+	nodes := make([]*miniredis.Miniredis, 2)
+	slots := make([]goRedis.ClusterSlot, len(nodes))
+	addrs := make([]string, len(nodes))
+	for i := range nodes {
+		nodes[i], _ = miniredis.Run()
+		addrs[i] = nodes[i].Addr()
+		slots[i] = goRedis.ClusterSlot{
+			Start: i * 8192,
+			End:   i*8192 + 8191,
+			Nodes: []goRedis.ClusterNode{{Addr: nodes[i].Addr()}},
+		}
+	}
+	client := goRedis.NewClusterClient(&goRedis.ClusterOptions{
+		Addrs: addrs,
+		ClusterSlots: func(ctx context.Context) ([]goRedis.ClusterSlot, error) {
+			return slots, nil
+		},
+		NewClient: func(opt *goRedis.Options) *goRedis.Client {
+			node := goRedis.NewClient(opt)
+			node.AddHook(commandInfoHook{})
+			return node
+		},
+	})
+	return nodes, &cache{
+		client:    client,
+		keyPrefix: "test",
+	}
+}
+
 // NewMiniredis returns a miniredis and Cache instance for test purposes
 func NewMiniredis() (*miniredis.Miniredis, Cache) {
 	mr, _ := miniredis.Run()
@@ -26,3 +59,42 @@ func NewMiniredis() (*miniredis.Miniredis, Cache) {
 		keyPrefix: "test",
 	}
 }
+
+// keyCommands are the commands of the cache whose first argument is a key
+// (the keys of the scripts are found by the client without their info)
+var keyCommands = []string{
+	"eval", "evalsha",
+	"del", "exists", "get", "incr", "persist", "pexpire", "pttl", "renamenx", "set", "setnx", "type",
+	"zadd", "zcard", "zincrby", "zrange", "zremrangebyrank", "zrevrange",
+}
+
+// A commandInfoHook replies to COMMAND with the key positions of the commands of the cache.
+// The Cluster client needs them to send each command to the node of its key, but it can not
+// parse the reply of miniredis.
+type commandInfoHook struct{}
+
+func (commandInfoHook) BeforeProcess(ctx context.Context, cmd goRedis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (commandInfoHook) AfterProcess(ctx context.Context, cmd goRedis.Cmder) error {
+	if info, ok := cmd.(*goRedis.CommandsInfoCmd); ok {
+		commands := make(map[string]*goRedis.CommandInfo, len(keyCommands))
+		for _, name := range keyCommands {
+			commands[name] = &goRedis.CommandInfo{Name: name, FirstKeyPos: 1, LastKeyPos: 1, StepCount: 1}
+		}
+		info.SetErr(nil)
+		info.SetVal(commands)
+	}
+	return nil
+}
+
+func (commandInfoHook) BeforeProcessPipeline(
+	ctx context.Context, cmds []goRedis.Cmder,
+) (context.Context, error) {
+	return ctx, nil
+}
+
+func (commandInfoHook) AfterProcessPipeline(ctx context.Context, cmds []goRedis.Cmder) error {
+	return nil
+}
@@ -3,11 +3,14 @@ package cache
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/darioblanco/shortesturl/app/internal/config"
 	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
@@ -38,7 +41,7 @@ func (s *TestSuite) SetupSuite() {
 	assert.NoError(s.T(), err)
 	assert.Equal(s.T(),
 		fmt.Sprintf("%s:%s", mr.Host(), mr.Port()),
-		c.(*cache).client.Options().Addr,
+		c.(*cache).client.(*redis.Client).Options().Addr,
 	)
 	s.cache = c
 }
@@ -60,17 +63,112 @@ func TestNew(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t,
 		fmt.Sprintf("%s:%s", mr.Host(), mr.Port()),
-		c.(*cache).client.Options().Addr,
+		c.(*cache).client.(*redis.Client).Options().Addr,
 	)
 }
 
+func TestNew_KeyPrefix(t *testing.T) {
+	mr, _ := miniredis.Run()
+	defer mr.Close()
+	c, err := New(
+		context.Background(),
+		&config.Values{
+			Redis: config.RedisValues{
+				Addrs:     []string{mr.Addr()},
+				KeyPrefix: "shortesturl",
+			},
+		},
+		logging.NewTest(t),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Set(context.Background(), "key", "value", 0))
+	val, err := mr.Get("shortesturl:key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+	assert.False(t, mr.Exists("key"))
+}
+
 func TestNew_Development(t *testing.T) {
-	_, err := New(
+	c, err := New(
 		context.Background(),
 		&config.Values{IsDevelopment: true},
 		logging.NewTest(t),
 	)
 	assert.NoError(t, err)
+	assert.IsType(t, &memory{}, c)
+}
+
+func TestBackend(t *testing.T) {
+	assert.Equal(t, "redis", Backend(&config.Values{}))
+	assert.Equal(t, "memory", Backend(&config.Values{IsDevelopment: true}))
+	assert.Equal(t, "bolt", Backend(&config.Values{
+		IsDevelopment: true,
+		Storage:       config.StorageValues{Backend: "bolt"},
+	}))
+}
+
+func TestIsEphemeral(t *testing.T) {
+	assert.True(t, IsEphemeral("memory"))
+	assert.True(t, IsEphemeral("miniredis"))
+	assert.False(t, IsEphemeral("bolt"))
+	assert.False(t, IsEphemeral("redis"))
+}
+
+func TestNew_Memory(t *testing.T) {
+	c, err := New(
+		context.Background(),
+		&config.Values{Storage: config.StorageValues{Backend: "memory"}},
+		logging.NewTest(t),
+	)
+	assert.NoError(t, err)
+	assert.IsType(t, &memory{}, c)
+}
+
+func TestNew_Bolt(t *testing.T) {
+	c, err := New(
+		context.Background(),
+		&config.Values{Storage: config.StorageValues{
+			Backend: "bolt",
+			Path:    filepath.Join(t.TempDir(), "test.db"),
+		}},
+		logging.NewTest(t),
+	)
+	assert.NoError(t, err)
+	assert.IsType(t, &boltStore{}, c)
+}
+
+func TestNew_BoltError(t *testing.T) {
+	c, err := New(
+		context.Background(),
+		&config.Values{Storage: config.StorageValues{
+			Backend: "bolt",
+			Path:    t.TempDir(),
+		}},
+		logging.NewTest(t),
+	)
+	assert.Error(t, err)
+	assert.Nil(t, c)
+}
+
+func TestNew_Miniredis(t *testing.T) {
+	c, err := New(
+		context.Background(),
+		&config.Values{Storage: config.StorageValues{Backend: "miniredis"}},
+		logging.NewTest(t),
+	)
+	assert.NoError(t, err)
+	assert.IsType(t, &cache{}, c)
+}
+
+func TestNew_InvalidBackend(t *testing.T) {
+	c, err := New(
+		context.Background(),
+		&config.Values{Storage: config.StorageValues{Backend: "mongodb"}},
+		logging.NewTest(t),
+	)
+	assert.EqualError(t, err,
+		"invalid storage backend \"mongodb\" (allowed: bolt, memory, miniredis, redis)")
+	assert.Nil(t, c)
 }
 
 func (s *TestSuite) TestNew_PingError() {
@@ -120,3 +218,120 @@ func (s *TestSuite) TestSetIfNotExists_AlreadyExistsWithDifferentValue() {
 	assert.NoError(s.T(), err)
 	assert.False(s.T(), res)
 }
+
+func (s *TestSuite) TestSet() {
+	s.mr.Set("key6", "val6")
+	err := s.cache.Set(s.ctx, "key6", "newVal6", time.Hour)
+	assert.NoError(s.T(), err)
+	val, _ := s.mr.Get("key6")
+	assert.Equal(s.T(), "newVal6", val)
+	assert.Equal(s.T(), time.Hour, s.mr.TTL("key6"))
+}
+
+func (s *TestSuite) TestTTL() {
+	s.mr.Set("key7", "val7")
+	s.mr.SetTTL("key7", time.Minute)
+	ttl, err := s.cache.TTL(s.ctx, "key7")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), time.Minute, ttl)
+}
+
+func (s *TestSuite) TestTTL_NoExpiration() {
+	s.mr.Set("key8", "val8")
+	ttl, err := s.cache.TTL(s.ctx, "key8")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), time.Duration(0), ttl)
+}
+
+func (s *TestSuite) TestTTL_KeyNotFound() {
+	ttl, err := s.cache.TTL(s.ctx, "key9")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), time.Duration(0), ttl)
+}
+
+func (s *TestSuite) TestTTL_Error() {
+	mr, c := NewMiniredis()
+	mr.SetError("mock error")
+	_, err := c.TTL(s.ctx, "key10")
+	assert.Error(s.T(), err)
+}
+
+func (s *TestSuite) TestIncrement() {
+	val, err := s.cache.Increment(s.ctx, "counter1")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(1), val)
+	val, err = s.cache.Increment(s.ctx, "counter1")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(2), val)
+}
+
+func (s *TestSuite) TestIncrement_NotAnInteger() {
+	s.mr.Set("counter2", "value")
+	_, err := s.cache.Increment(s.ctx, "counter2")
+	assert.Error(s.T(), err)
+}
+
+func (s *TestSuite) TestScan_KeyPrefix() {
+	s.mr.Set("test:key1", "value")
+	s.mr.Set("other:key2", "value")
+	keys, cursor, err := s.cache.Scan(s.ctx, "", "key*", 100)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"key1"}, keys)
+	assert.Equal(s.T(), "", cursor)
+}
+
+func (s *TestSuite) TestScan_InvalidCursor() {
+	for _, cursor := range []string{"invalid", "1-0", "-1-0", "a-0", "0-b"} {
+		_, _, err := s.cache.Scan(s.ctx, cursor, "*", 10)
+		assert.Equal(s.T(), ErrInvalidCursor, err, cursor)
+	}
+}
+
+func (s *TestSuite) TestScan_Cluster() {
+	nodes, c := NewMiniredisCluster()
+	for i, mr := range nodes {
+		defer mr.Close()
+		mr.Set(fmt.Sprintf("test:key%d", i), "value")
+		mr.Set(fmt.Sprintf("other:key%d", i), "value")
+	}
+	// Each page holds the keys of a single master
+	first, cursor, err := c.Scan(s.ctx, "", "key*", 100)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), first, 1)
+	assert.Equal(s.T(), "1-0", cursor)
+	second, cursor, err := c.Scan(s.ctx, cursor, "key*", 100)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), "", cursor)
+	assert.ElementsMatch(s.T(), []string{"key0", "key1"}, append(first, second...))
+
+	_, _, err = c.Scan(s.ctx, "2-0", "key*", 100)
+	assert.Equal(s.T(), ErrInvalidCursor, err)
+}
+
+func (s *TestSuite) TestScan_Error() {
+	mr, c := NewMiniredis()
+	mr.SetError("mock error")
+	_, _, err := c.Scan(s.ctx, "", "*", 10)
+	assert.Error(s.T(), err)
+}
+
+func (s *TestSuite) TestGetAll_Error() {
+	mr, c := NewMiniredis()
+	mr.SetError("mock error")
+	_, err := c.GetAll(s.ctx, []string{"key1", "key2"})
+	assert.Error(s.T(), err)
+}
+
+func (s *TestSuite) TestSetLinksIfNotExists_Error() {
+	mr, c := NewMiniredis()
+	mr.SetError("mock error")
+	_, err := c.SetLinksIfNotExists(s.ctx, []LinkEntry{{Key: "key1", Link: &Link{URL: "value"}}})
+	assert.Error(s.T(), err)
+}
+
+func (s *TestSuite) TestSetAll_Error() {
+	mr, c := NewMiniredis()
+	mr.SetError("mock error")
+	err := c.SetAll(s.ctx, []Entry{{Key: "key1", Value: "value"}})
+	assert.Error(s.T(), err)
+}
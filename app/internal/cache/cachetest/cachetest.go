@@ -0,0 +1,758 @@
+// Package cachetest provides a conformance test suite that every implementation
+// of the cache.Cache interface must pass, so all storage backends behave the same way.
+package cachetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A Backend is a Cache instance under test
+type Backend struct {
+	Cache cache.Cache
+	// Advance moves the clock of the backend forward, so the keys can expire.
+	// If not set, the suite sleeps for the given duration instead.
+	Advance func(d time.Duration)
+}
+
+// A Factory creates a new and empty Backend for every test of the suite
+type Factory func(t *testing.T) Backend
+
+// RunConformance runs the conformance test suite over the backends created by the given factory
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b Backend)
+	}{
+		{"Get", testGet},
+		{"Get_KeyNotFound", testGetKeyNotFound},
+		{"SetIfNotExists", testSetIfNotExists},
+		{"SetIfNotExists_AlreadyExistsWithSameValue", testSetIfNotExistsSameValue},
+		{"SetIfNotExists_AlreadyExistsWithDifferentValue", testSetIfNotExistsDifferentValue},
+		{"SetIfNotExists_ConcurrentWriters", testSetIfNotExistsConcurrentWriters},
+		{"GetLink", testGetLink},
+		{"GetLink_Legacy", testGetLinkLegacy},
+		{"GetLink_KeyNotFound", testGetLinkKeyNotFound},
+		{"SetLinkIfNotExists", testSetLinkIfNotExists},
+		{"SetLinkIfNotExists_AlreadyExistsWithSameURL", testSetLinkIfNotExistsSameURL},
+		{"SetLinkIfNotExists_AlreadyExistsWithDifferentURL", testSetLinkIfNotExistsDifferentURL},
+		{"SetLinkIfNotExists_AlreadyExistsWithDifferentOwner", testSetLinkIfNotExistsDifferentOwner},
+		{"UpdateLink", testUpdateLink},
+		{"GetAll", testGetAll},
+		{"GetLinks", testGetLinks},
+		{"SetLinksIfNotExists", testSetLinksIfNotExists},
+		{"SetAll", testSetAll},
+		{"Set", testSet},
+		{"Update", testUpdate},
+		{"Update_KeepTTL", testUpdateKeepTTL},
+		{"Update_KeyNotFound", testUpdateKeyNotFound},
+		{"Delete", testDelete},
+		{"Delete_KeyNotFound", testDeleteKeyNotFound},
+		{"Delete_Members", testDeleteMembers},
+		{"Scan", testScan},
+		{"Scan_Match", testScanMatch},
+		{"Scan_Expired", testScanExpired},
+		{"Expiration", testExpiration},
+		{"TTL", testTTL},
+		{"Expire", testExpire},
+		{"Expire_Members", testExpireMembers},
+		{"Increment", testIncrement},
+		{"Increment_KeepsExpiration", testIncrementKeepsExpiration},
+		{"Increment_NotAnInteger", testIncrementNotAnInteger},
+		{"IncrementWithExpiration", testIncrementWithExpiration},
+		{"IncrementWithExpiration_Expired", testIncrementWithExpirationExpired},
+		{"IncrementMember", testIncrementMember},
+		{"IncrementMember_MaxMembers", testIncrementMemberMaxMembers},
+		{"TopMembers", testTopMembers},
+		{"TopMembers_KeyNotFound", testTopMembersKeyNotFound},
+		{"ContextCanceled", testContextCanceled},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, factory(t))
+		})
+	}
+}
+
+func (b Backend) advance(d time.Duration) {
+	if b.Advance != nil {
+		b.Advance(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+func testGet(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key", "value", 0))
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+}
+
+func testGetKeyNotFound(t *testing.T, b Backend) {
+	val, err := b.Cache.Get(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "", val)
+}
+
+func testSetIfNotExists(t *testing.T, b Backend) {
+	ctx := context.Background()
+	res, err := b.Cache.SetIfNotExists(ctx, "key", "value", 0)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+}
+
+func testSetIfNotExistsSameValue(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key", "value", time.Hour))
+	res, err := b.Cache.SetIfNotExists(ctx, "key", "value", 0)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	// The key is not set again, thus the original expiration is kept
+	ttl, err := b.Cache.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+}
+
+func testSetIfNotExistsDifferentValue(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key", "valueDifferent", 0))
+	res, err := b.Cache.SetIfNotExists(ctx, "key", "value", 0)
+	assert.NoError(t, err)
+	assert.False(t, res)
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "valueDifferent", val)
+}
+
+func testSetIfNotExistsConcurrentWriters(t *testing.T, b Backend) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	results := make([]bool, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := b.Cache.SetIfNotExists(ctx, "key", fmt.Sprintf("value%d", i%2), 0)
+			assert.NoError(t, err)
+			results[i] = res
+		}(i)
+	}
+	wg.Wait()
+	// Only the writers of the value that was stored first succeed
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	for i, res := range results {
+		assert.Equal(t, val == fmt.Sprintf("value%d", i%2), res, "writer %d", i)
+	}
+}
+
+// newLink returns a link record with every field set
+func newLink(longURL string) *cache.Link {
+	createdAt := time.Date(2026, 10, 17, 15, 4, 5, 0, time.UTC)
+	expiresAt := createdAt.Add(time.Hour)
+	return &cache.Link{
+		Version:   cache.LinkVersion,
+		URL:       longURL,
+		CreatedAt: &createdAt,
+		CreatedBy: "0123456789ab",
+		Title:     "Title",
+		Tags:      []string{"a", "b"},
+		ExpiresAt: &expiresAt,
+		Disabled:  true,
+	}
+}
+
+func testGetLink(t *testing.T, b Backend) {
+	ctx := context.Background()
+	link := newLink("https://example.com")
+	res, err := b.Cache.SetLinkIfNotExists(ctx, "key", link, 0)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	stored, err := b.Cache.GetLink(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, link, stored)
+}
+
+func testGetLinkLegacy(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key", "https://example.com", 0))
+	link, err := b.Cache.GetLink(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, &cache.Link{URL: "https://example.com"}, link)
+}
+
+func testGetLinkKeyNotFound(t *testing.T, b Backend) {
+	link, err := b.Cache.GetLink(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Nil(t, link)
+}
+
+func testSetLinkIfNotExists(t *testing.T, b Backend) {
+	ctx := context.Background()
+	res, err := b.Cache.SetLinkIfNotExists(ctx, "key", newLink("https://example.com"), time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	ttl, err := b.Cache.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+}
+
+func testSetLinkIfNotExistsSameURL(t *testing.T, b Backend) {
+	ctx := context.Background()
+	// Legacy values hold the long url only, thus they were not created by any API key
+	assert.NoError(t, b.Cache.Set(ctx, "key", "https://example.com", 0))
+	link := newLink("https://example.com")
+	link.CreatedBy = ""
+	res, err := b.Cache.SetLinkIfNotExists(ctx, "key", link, 0)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	// The link is not set again, even if the metadata is different
+	link.Title = "Different"
+	res, err = b.Cache.SetLinkIfNotExists(ctx, "key", link, 0)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", val)
+}
+
+func testSetLinkIfNotExistsDifferentURL(t *testing.T, b Backend) {
+	ctx := context.Background()
+	link := newLink("https://example.com")
+	res, err := b.Cache.SetLinkIfNotExists(ctx, "key", link, 0)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	res, err = b.Cache.SetLinkIfNotExists(ctx, "key", newLink("https://example.org"), 0)
+	assert.NoError(t, err)
+	assert.False(t, res)
+	stored, err := b.Cache.GetLink(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, link, stored)
+}
+
+func testSetLinkIfNotExistsDifferentOwner(t *testing.T, b Backend) {
+	ctx := context.Background()
+	link := newLink("https://example.com")
+	res, err := b.Cache.SetLinkIfNotExists(ctx, "key", link, 0)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	// The short url is not shared with another API key, nor without one
+	for _, createdBy := range []string{"ba9876543210", ""} {
+		other := newLink("https://example.com")
+		other.CreatedBy = createdBy
+		res, err = b.Cache.SetLinkIfNotExists(ctx, "key", other, 0)
+		assert.NoError(t, err)
+		assert.False(t, res)
+	}
+	stored, err := b.Cache.GetLink(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, link, stored)
+}
+
+func testUpdateLink(t *testing.T, b Backend) {
+	ctx := context.Background()
+	link := newLink("https://example.com")
+	res, err := b.Cache.UpdateLink(ctx, "key", link, 0)
+	assert.NoError(t, err)
+	assert.False(t, res)
+	assert.NoError(t, b.Cache.Set(ctx, "key", "https://example.org", time.Hour))
+	res, err = b.Cache.UpdateLink(ctx, "key", link, cache.KeepTTL)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	stored, err := b.Cache.GetLink(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, link, stored)
+	ttl, err := b.Cache.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+}
+
+func testGetAll(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key1", "value1", 0))
+	assert.NoError(t, b.Cache.Set(ctx, "key3", "value3", 0))
+	values, err := b.Cache.GetAll(ctx, []string{"key1", "key2", "key3", "key1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"value1", "", "value3", "value1"}, values)
+	values, err = b.Cache.GetAll(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func testGetLinks(t *testing.T, b Backend) {
+	ctx := context.Background()
+	link := newLink("https://example.com")
+	_, err := b.Cache.SetLinkIfNotExists(ctx, "key1", link, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, b.Cache.Set(ctx, "key3", "https://example.org", 0))
+	links, err := b.Cache.GetLinks(ctx, []string{"key1", "key2", "key3"})
+	assert.NoError(t, err)
+	assert.Equal(t, []*cache.Link{link, nil, {URL: "https://example.org"}}, links)
+}
+
+func testSetLinksIfNotExists(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "same", "https://example.com", 0))
+	assert.NoError(t, b.Cache.Set(ctx, "different", "https://example.org", 0))
+	link := newLink("https://example.com")
+	// The legacy values were not created by any API key
+	link.CreatedBy = ""
+	owned := newLink("https://example.com")
+	results, err := b.Cache.SetLinksIfNotExists(ctx, []cache.LinkEntry{
+		{Key: "new", Link: link, Expiration: time.Hour},
+		{Key: "same", Link: link},
+		// Links are only shared within the same API key
+		{Key: "same", Link: owned},
+		{Key: "different", Link: link},
+		// The first link of a key wins within the same batch
+		{Key: "new", Link: newLink("https://example.net")},
+		{Key: "new", Link: link},
+		// Unique links are never shared
+		{Key: "same", Link: link, Unique: true},
+		{Key: "unique", Link: link, Unique: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, true, false, false, false, true, false, true}, results)
+	links, err := b.Cache.GetLinks(ctx, []string{"new", "same", "different"})
+	assert.NoError(t, err)
+	assert.Equal(t, []*cache.Link{
+		link,
+		{URL: "https://example.com"},
+		{URL: "https://example.org"},
+	}, links)
+	ttl, err := b.Cache.TTL(ctx, "new")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+	results, err = b.Cache.SetLinksIfNotExists(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func testSetAll(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key1", "value", time.Hour))
+	assert.NoError(t, b.Cache.SetAll(ctx, []cache.Entry{
+		{Key: "key1", Value: "value1"},
+		{Key: "key2", Value: "value2", Expiration: time.Hour},
+	}))
+	values, err := b.Cache.GetAll(ctx, []string{"key1", "key2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"value1", "value2"}, values)
+	ttl, err := b.Cache.TTL(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+	ttl, err = b.Cache.TTL(ctx, "key2")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+	assert.NoError(t, b.Cache.SetAll(ctx, nil))
+}
+
+func testSet(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key", "value", time.Hour))
+	assert.NoError(t, b.Cache.Set(ctx, "key", "newValue", 0))
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "newValue", val)
+	// The expiration is overwritten too
+	ttl, err := b.Cache.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+}
+
+func testUpdate(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key", "value", 0))
+	res, err := b.Cache.Update(ctx, "key", "newValue", time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "newValue", val)
+	ttl, err := b.Cache.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+	// Zero expiration removes the expiration
+	res, err = b.Cache.Update(ctx, "key", "newValue", 0)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	ttl, err = b.Cache.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+}
+
+func testUpdateKeepTTL(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key", "value", time.Hour))
+	res, err := b.Cache.Update(ctx, "key", "newValue", cache.KeepTTL)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "newValue", val)
+	ttl, err := b.Cache.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+}
+
+func testUpdateKeyNotFound(t *testing.T, b Backend) {
+	ctx := context.Background()
+	res, err := b.Cache.Update(ctx, "key", "value", 0)
+	assert.NoError(t, err)
+	assert.False(t, res)
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "", val)
+}
+
+func testDelete(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key", "value", time.Hour))
+	res, err := b.Cache.Delete(ctx, "key")
+	assert.NoError(t, err)
+	assert.True(t, res)
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "", val)
+	// A deleted key can be set again
+	res, err = b.Cache.SetIfNotExists(ctx, "key", "newValue", 0)
+	assert.NoError(t, err)
+	assert.True(t, res)
+}
+
+func testDeleteKeyNotFound(t *testing.T, b Backend) {
+	res, err := b.Cache.Delete(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.False(t, res)
+}
+
+func testDeleteMembers(t *testing.T, b Backend) {
+	ctx := context.Background()
+	_, err := b.Cache.IncrementMember(ctx, "members", "member", 0)
+	assert.NoError(t, err)
+	res, err := b.Cache.Delete(ctx, "members")
+	assert.NoError(t, err)
+	assert.True(t, res)
+	counts, err := b.Cache.TopMembers(ctx, "members", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+}
+
+// scanAll iterates all the keys that match the given pattern, with pages of the given size
+func scanAll(t *testing.T, b Backend, match string, count int) []string {
+	ctx := context.Background()
+	found := make(map[string]bool)
+	cursor := ""
+	for pages := 0; pages == 0 || cursor != ""; pages++ {
+		require.Less(t, pages, 100, "the scan does not finish")
+		var keys []string
+		var err error
+		keys, cursor, err = b.Cache.Scan(ctx, cursor, match, count)
+		require.NoError(t, err)
+		for _, key := range keys {
+			found[key] = true
+		}
+	}
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func testScan(t *testing.T, b Backend) {
+	ctx := context.Background()
+	var expected []string
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("key%02d", i)
+		assert.NoError(t, b.Cache.Set(ctx, key, "value", 0))
+		expected = append(expected, key)
+	}
+	_, err := b.Cache.IncrementMember(ctx, "members", "member", 0)
+	assert.NoError(t, err)
+	expected = append(expected, "members")
+	sort.Strings(expected)
+	assert.Equal(t, expected, scanAll(t, b, "*", 10))
+	assert.Equal(t, expected, scanAll(t, b, "*", 0))
+}
+
+func testScanMatch(t *testing.T, b Backend) {
+	ctx := context.Background()
+	for _, key := range []string{"64fc5e", "tombstone:64fc5e", "tombstone:104b16", "owner:64fc5e"} {
+		assert.NoError(t, b.Cache.Set(ctx, key, "value", 0))
+	}
+	assert.Equal(t, []string{"tombstone:104b16", "tombstone:64fc5e"}, scanAll(t, b, "tombstone:*", 1))
+	assert.Equal(t, []string{}, scanAll(t, b, "missing:*", 10))
+}
+
+func testScanExpired(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key1", "value", 0))
+	assert.NoError(t, b.Cache.Set(ctx, "key2", "value", 10*time.Millisecond))
+	b.advance(20 * time.Millisecond)
+	assert.Equal(t, []string{"key1"}, scanAll(t, b, "*", 10))
+}
+
+func testExpiration(t *testing.T, b Backend) {
+	ctx := context.Background()
+	res, err := b.Cache.SetIfNotExists(ctx, "key", "valueDifferent", 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, res)
+	b.advance(20 * time.Millisecond)
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "", val)
+	// An expired key can be set again
+	res, err = b.Cache.SetIfNotExists(ctx, "key", "value", 0)
+	assert.NoError(t, err)
+	assert.True(t, res)
+}
+
+func testTTL(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key1", "value", time.Hour))
+	ttl, err := b.Cache.TTL(ctx, "key1")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+
+	assert.NoError(t, b.Cache.Set(ctx, "key2", "value", 0))
+	ttl, err = b.Cache.TTL(ctx, "key2")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	ttl, err = b.Cache.TTL(ctx, "key3")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+}
+
+func testExpire(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "key", "value", 0))
+	success, err := b.Cache.Expire(ctx, "key", time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, success)
+	ttl, err := b.Cache.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+	val, err := b.Cache.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	// A zero expiration keeps the key forever
+	success, err = b.Cache.Expire(ctx, "key", 0)
+	assert.NoError(t, err)
+	assert.True(t, success)
+	ttl, err = b.Cache.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	success, err = b.Cache.Expire(ctx, "missing", time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, success)
+	success, err = b.Cache.Expire(ctx, "missing", 0)
+	assert.NoError(t, err)
+	assert.False(t, success)
+}
+
+func testExpireMembers(t *testing.T, b Backend) {
+	ctx := context.Background()
+	_, err := b.Cache.IncrementMember(ctx, "members", "member", 0)
+	assert.NoError(t, err)
+	success, err := b.Cache.Expire(ctx, "members", 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, success)
+	ttl, err := b.Cache.TTL(ctx, "members")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 0)
+	// Incrementing a member does not change the expiration
+	_, err = b.Cache.IncrementMember(ctx, "members", "member", 0)
+	assert.NoError(t, err)
+
+	b.advance(20 * time.Millisecond)
+	counts, err := b.Cache.TopMembers(ctx, "members", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+	assert.Equal(t, []string{}, scanAll(t, b, "*", 10))
+	// The counters start again once the key expires
+	val, err := b.Cache.IncrementMember(ctx, "members", "member", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+	ttl, err = b.Cache.TTL(ctx, "members")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+}
+
+func testIncrement(t *testing.T, b Backend) {
+	ctx := context.Background()
+	val, err := b.Cache.Increment(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+	val, err = b.Cache.Increment(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), val)
+}
+
+func testIncrementKeepsExpiration(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "counter", "41", time.Hour))
+	val, err := b.Cache.Increment(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), val)
+	ttl, err := b.Cache.TTL(ctx, "counter")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+}
+
+func testIncrementNotAnInteger(t *testing.T, b Backend) {
+	ctx := context.Background()
+	assert.NoError(t, b.Cache.Set(ctx, "counter", "value", 0))
+	_, err := b.Cache.Increment(ctx, "counter")
+	assert.Error(t, err)
+}
+
+func testIncrementWithExpiration(t *testing.T, b Backend) {
+	ctx := context.Background()
+	val, err := b.Cache.IncrementWithExpiration(ctx, "counter", 1, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+	// The expiration is only set when the key is created
+	val, err = b.Cache.IncrementWithExpiration(ctx, "counter", 5, 2*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), val)
+	ttl, err := b.Cache.TTL(ctx, "counter")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+}
+
+func testIncrementWithExpirationExpired(t *testing.T, b Backend) {
+	ctx := context.Background()
+	val, err := b.Cache.IncrementWithExpiration(ctx, "counter", 1, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+	b.advance(20 * time.Millisecond)
+	// The counter starts again once it expires
+	val, err = b.Cache.IncrementWithExpiration(ctx, "counter", 1, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+}
+
+func testIncrementMember(t *testing.T, b Backend) {
+	ctx := context.Background()
+	val, err := b.Cache.IncrementMember(ctx, "members", "member1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+	val, err = b.Cache.IncrementMember(ctx, "members", "member1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), val)
+	val, err = b.Cache.IncrementMember(ctx, "members", "member2", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+}
+
+func testIncrementMemberMaxMembers(t *testing.T, b Backend) {
+	ctx := context.Background()
+	for _, member := range []string{"a", "a", "a", "b", "c"} {
+		_, err := b.Cache.IncrementMember(ctx, "members", member, 2)
+		assert.NoError(t, err)
+	}
+	// The new member is kept, while b is the last one of the members with the lowest count
+	counts, err := b.Cache.TopMembers(ctx, "members", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []cache.MemberCount{{Member: "a", Count: 3}, {Member: "c", Count: 1}}, counts)
+
+	val, err := b.Cache.IncrementMember(ctx, "members", "b", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), val)
+	counts, err = b.Cache.TopMembers(ctx, "members", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []cache.MemberCount{{Member: "a", Count: 3}, {Member: "c", Count: 1}}, counts)
+}
+
+func testTopMembers(t *testing.T, b Backend) {
+	ctx := context.Background()
+	for member, count := range map[string]int{"a": 1, "b": 3, "c": 2, "d": 1} {
+		for i := 0; i < count; i++ {
+			_, err := b.Cache.IncrementMember(ctx, "members", member, 0)
+			assert.NoError(t, err)
+		}
+	}
+	counts, err := b.Cache.TopMembers(ctx, "members", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []cache.MemberCount{
+		{Member: "b", Count: 3},
+		{Member: "c", Count: 2},
+		{Member: "d", Count: 1},
+		{Member: "a", Count: 1},
+	}, counts)
+
+	counts, err = b.Cache.TopMembers(ctx, "members", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []cache.MemberCount{
+		{Member: "b", Count: 3},
+		{Member: "c", Count: 2},
+	}, counts)
+}
+
+func testTopMembersKeyNotFound(t *testing.T, b Backend) {
+	counts, err := b.Cache.TopMembers(context.Background(), "members", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+}
+
+func testContextCanceled(t *testing.T, b Backend) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := b.Cache.Get(ctx, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.GetLink(ctx, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.SetIfNotExists(ctx, "key", "value", 0)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.SetLinkIfNotExists(ctx, "key", &cache.Link{URL: "value"}, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.UpdateLink(ctx, "key", &cache.Link{URL: "value"}, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.GetAll(ctx, []string{"key"})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.GetLinks(ctx, []string{"key"})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.SetLinksIfNotExists(ctx, []cache.LinkEntry{
+		{Key: "key", Link: &cache.Link{URL: "value"}},
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, b.Cache.SetAll(ctx, []cache.Entry{{Key: "key", Value: "value"}}), context.Canceled)
+	assert.ErrorIs(t, b.Cache.Set(ctx, "key", "value", 0), context.Canceled)
+	_, err = b.Cache.Update(ctx, "key", "value", 0)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.Delete(ctx, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, _, err = b.Cache.Scan(ctx, "", "*", 0)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.TTL(ctx, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.Expire(ctx, "key", time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.Increment(ctx, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.IncrementWithExpiration(ctx, "key", 1, time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.IncrementMember(ctx, "key", "member", 0)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = b.Cache.TopMembers(ctx, "key", 0)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,63 @@
+package cache_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/cache/cachetest"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConformance_Redis(t *testing.T) {
+	cachetest.RunConformance(t, func(t *testing.T) cachetest.Backend {
+		mr, c := cache.NewMiniredis()
+		t.Cleanup(mr.Close)
+		return cachetest.Backend{Cache: c, Advance: mr.FastForward}
+	})
+}
+
+func TestConformance_Cluster(t *testing.T) {
+	cachetest.RunConformance(t, func(t *testing.T) cachetest.Backend {
+		nodes, c := cache.NewMiniredisCluster()
+		for _, mr := range nodes {
+			t.Cleanup(mr.Close)
+		}
+		return cachetest.Backend{Cache: c, Advance: func(d time.Duration) {
+			for _, mr := range nodes {
+				mr.FastForward(d)
+			}
+		}}
+	})
+}
+
+func TestConformance_Instrumented(t *testing.T) {
+	cachetest.RunConformance(t, func(t *testing.T) cachetest.Backend {
+		mr, c := cache.NewMiniredis()
+		t.Cleanup(mr.Close)
+		return cachetest.Backend{Cache: cache.Instrument(c), Advance: mr.FastForward}
+	})
+}
+
+func TestConformance_Memory(t *testing.T) {
+	cachetest.RunConformance(t, func(t *testing.T) cachetest.Backend {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		return cachetest.Backend{Cache: cache.NewMemory(ctx, &config.Values{})}
+	})
+}
+
+func TestConformance_Bolt(t *testing.T) {
+	cachetest.RunConformance(t, func(t *testing.T) cachetest.Backend {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		c, err := cache.NewBolt(ctx, &config.Values{Storage: config.StorageValues{
+			Path: filepath.Join(t.TempDir(), "test.db"),
+		}})
+		assert.NoError(t, err)
+		return cachetest.Backend{Cache: c}
+	})
+}
@@ -0,0 +1,86 @@
+package cache
+
+import "strings"
+
+// matchGlob reports whether the given key matches the glob-style pattern, following the
+// rules of the redis SCAN MATCH option: * matches any sequence, ? matches a single byte,
+// [abc], [^abc] and [a-z] match a byte of a set, and \ escapes the next byte.
+func matchGlob(pattern string, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if matchGlob(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			negated := len(pattern) > 0 && pattern[0] == '^'
+			if negated {
+				pattern = pattern[1:]
+			}
+			matched := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				switch {
+				case pattern[0] == '\\' && len(pattern) > 1:
+					matched = matched || pattern[1] == key[0]
+					pattern = pattern[2:]
+				case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+					low, high := pattern[0], pattern[2]
+					if low > high {
+						low, high = high, low
+					}
+					matched = matched || (key[0] >= low && key[0] <= high)
+					pattern = pattern[3:]
+				default:
+					matched = matched || pattern[0] == key[0]
+					pattern = pattern[1:]
+				}
+			}
+			if len(pattern) > 0 {
+				// Skips the closing bracket
+				pattern = pattern[1:]
+			}
+			if matched == negated {
+				return false
+			}
+			key = key[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+// globPrefix returns the literal prefix of the given glob-style pattern,
+// which every matching key starts with
+func globPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		key      string
+		expected bool
+	}{
+		{pattern: "*", key: "64fc5e", expected: true},
+		{pattern: "*", key: "", expected: true},
+		{pattern: "64fc5e", key: "64fc5e", expected: true},
+		{pattern: "64fc5e", key: "64fc5", expected: false},
+		{pattern: "tombstone:*", key: "tombstone:64fc5e", expected: true},
+		{pattern: "tombstone:*", key: "owner:64fc5e", expected: false},
+		{pattern: "*:64fc5e", key: "owner:64fc5e", expected: true},
+		{pattern: "a**b", key: "axxb", expected: true},
+		{pattern: "64fc5?", key: "64fc5e", expected: true},
+		{pattern: "64fc5?", key: "64fc5", expected: false},
+		{pattern: "[a-c]x", key: "bx", expected: true},
+		{pattern: "[c-a]x", key: "bx", expected: true},
+		{pattern: "[a-c]x", key: "dx", expected: false},
+		{pattern: "[^a-c]x", key: "dx", expected: true},
+		{pattern: "[abc]x", key: "cx", expected: true},
+		{pattern: "[\\]]x", key: "]x", expected: true},
+		{pattern: "[ab", key: "a", expected: true},
+		{pattern: "[ab]", key: "", expected: false},
+		{pattern: "\\*", key: "*", expected: true},
+		{pattern: "\\*", key: "a", expected: false},
+		{pattern: "a\\", key: "a\\", expected: true},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.pattern+" "+tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchGlob(tt.pattern, tt.key))
+		})
+	}
+}
+
+func TestGlobPrefix(t *testing.T) {
+	assert.Equal(t, "", globPrefix(""))
+	assert.Equal(t, "tombstone:", globPrefix("tombstone:*"))
+	assert.Equal(t, "clicks:", globPrefix("clicks:?"))
+	assert.Equal(t, "key", globPrefix("key[12]"))
+	assert.Equal(t, "a", globPrefix(`a\*`))
+	assert.Equal(t, "apikey:hash", globPrefix("apikey:hash"))
+}
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/metrics"
+	"github.com/darioblanco/shortesturl/app/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumented is a Cache that observes the latency of every operation of the wrapped one,
+// and traces it as a child span of the span of its context
+type instrumented struct {
+	cache Cache
+}
+
+// Instrument returns a Cache that records the latency of each operation of the given one
+// in the cache metrics, labeled by operation and result, and in a span named after the
+// operation (e.g. cache.get)
+func Instrument(c Cache) Cache {
+	return &instrumented{cache: c}
+}
+
+// start starts the span of the given operation, returning the function that ends it
+// and records its latency
+func start(ctx context.Context, operation string) (context.Context, func(err error)) {
+	t1 := time.Now()
+	ctx, span := tracing.Start(ctx, "cache."+operation, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, func(err error) {
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		metrics.CacheOperationDuration.WithLabelValues(operation, result).
+			Observe(time.Since(t1).Seconds())
+		tracing.End(span, err)
+	}
+}
+
+func (c *instrumented) Get(ctx context.Context, key string) (string, error) {
+	ctx, end := start(ctx, "get")
+	value, err := c.cache.Get(ctx, key)
+	end(err)
+	return value, err
+}
+
+func (c *instrumented) SetIfNotExists(
+	ctx context.Context, key string, value string, expiration time.Duration,
+) (bool, error) {
+	ctx, end := start(ctx, "set_if_not_exists")
+	success, err := c.cache.SetIfNotExists(ctx, key, value, expiration)
+	end(err)
+	return success, err
+}
+
+func (c *instrumented) GetLink(ctx context.Context, key string) (*Link, error) {
+	ctx, end := start(ctx, "get_link")
+	link, err := c.cache.GetLink(ctx, key)
+	end(err)
+	return link, err
+}
+
+func (c *instrumented) SetLinkIfNotExists(
+	ctx context.Context, key string, link *Link, expiration time.Duration,
+) (bool, error) {
+	ctx, end := start(ctx, "set_link_if_not_exists")
+	success, err := c.cache.SetLinkIfNotExists(ctx, key, link, expiration)
+	end(err)
+	return success, err
+}
+
+func (c *instrumented) UpdateLink(
+	ctx context.Context, key string, link *Link, expiration time.Duration,
+) (bool, error) {
+	ctx, end := start(ctx, "update_link")
+	success, err := c.cache.UpdateLink(ctx, key, link, expiration)
+	end(err)
+	return success, err
+}
+
+func (c *instrumented) GetAll(ctx context.Context, keys []string) ([]string, error) {
+	ctx, end := start(ctx, "get_all")
+	values, err := c.cache.GetAll(ctx, keys)
+	end(err)
+	return values, err
+}
+
+func (c *instrumented) GetLinks(ctx context.Context, keys []string) ([]*Link, error) {
+	ctx, end := start(ctx, "get_links")
+	links, err := c.cache.GetLinks(ctx, keys)
+	end(err)
+	return links, err
+}
+
+func (c *instrumented) SetLinksIfNotExists(
+	ctx context.Context, entries []LinkEntry,
+) ([]bool, error) {
+	ctx, end := start(ctx, "set_links_if_not_exists")
+	results, err := c.cache.SetLinksIfNotExists(ctx, entries)
+	end(err)
+	return results, err
+}
+
+func (c *instrumented) SetAll(ctx context.Context, entries []Entry) error {
+	ctx, end := start(ctx, "set_all")
+	err := c.cache.SetAll(ctx, entries)
+	end(err)
+	return err
+}
+
+func (c *instrumented) Set(
+	ctx context.Context, key string, value string, expiration time.Duration,
+) error {
+	ctx, end := start(ctx, "set")
+	err := c.cache.Set(ctx, key, value, expiration)
+	end(err)
+	return err
+}
+
+func (c *instrumented) Update(
+	ctx context.Context, key string, value string, expiration time.Duration,
+) (bool, error) {
+	ctx, end := start(ctx, "update")
+	success, err := c.cache.Update(ctx, key, value, expiration)
+	end(err)
+	return success, err
+}
+
+func (c *instrumented) Delete(ctx context.Context, key string) (bool, error) {
+	ctx, end := start(ctx, "delete")
+	deleted, err := c.cache.Delete(ctx, key)
+	end(err)
+	return deleted, err
+}
+
+func (c *instrumented) Scan(
+	ctx context.Context, cursor string, match string, count int,
+) ([]string, string, error) {
+	ctx, end := start(ctx, "scan")
+	keys, next, err := c.cache.Scan(ctx, cursor, match, count)
+	end(err)
+	return keys, next, err
+}
+
+func (c *instrumented) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ctx, end := start(ctx, "ttl")
+	ttl, err := c.cache.TTL(ctx, key)
+	end(err)
+	return ttl, err
+}
+
+func (c *instrumented) Expire(
+	ctx context.Context, key string, expiration time.Duration,
+) (bool, error) {
+	ctx, end := start(ctx, "expire")
+	success, err := c.cache.Expire(ctx, key, expiration)
+	end(err)
+	return success, err
+}
+
+func (c *instrumented) Increment(ctx context.Context, key string) (int64, error) {
+	ctx, end := start(ctx, "increment")
+	value, err := c.cache.Increment(ctx, key)
+	end(err)
+	return value, err
+}
+
+func (c *instrumented) IncrementWithExpiration(
+	ctx context.Context, key string, increment int64, expiration time.Duration,
+) (int64, error) {
+	ctx, end := start(ctx, "increment_with_expiration")
+	value, err := c.cache.IncrementWithExpiration(ctx, key, increment, expiration)
+	end(err)
+	return value, err
+}
+
+func (c *instrumented) IncrementMember(
+	ctx context.Context, key string, member string, maxMembers int,
+) (int64, error) {
+	ctx, end := start(ctx, "increment_member")
+	count, err := c.cache.IncrementMember(ctx, key, member, maxMembers)
+	end(err)
+	return count, err
+}
+
+func (c *instrumented) TopMembers(
+	ctx context.Context, key string, limit int,
+) ([]MemberCount, error) {
+	ctx, end := start(ctx, "top_members")
+	members, err := c.cache.TopMembers(ctx, key, limit)
+	end(err)
+	return members, err
+}
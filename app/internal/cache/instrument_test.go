@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/darioblanco/shortesturl/app/internal/metrics"
+	"github.com/darioblanco/shortesturl/app/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInstrument(t *testing.T) {
+	recorder := tracing.NewTest()
+	mr, c := NewMiniredis()
+	defer mr.Close()
+	instrumented := Instrument(c)
+	ok := metrics.CacheOperationDuration.WithLabelValues("set", "ok")
+	failed := metrics.CacheOperationDuration.WithLabelValues("set", "error")
+	okCount, failedCount := metrics.SampleCount(ok), metrics.SampleCount(failed)
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	assert.NoError(t, instrumented.Set(ctx, "key", "value", 0))
+	value, err := mr.Get("test:key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+	mr.SetError("mock error")
+	assert.EqualError(t, instrumented.Set(ctx, "key", "value", 0), "mock error")
+	parent.End()
+
+	assert.Equal(t, okCount+1, metrics.SampleCount(ok))
+	assert.Equal(t, failedCount+1, metrics.SampleCount(failed))
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	for _, span := range spans[:2] {
+		assert.Equal(t, "cache.set", span.Name())
+		assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	}
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
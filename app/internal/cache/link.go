@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LinkVersion is the version of the link records written by the service
+const LinkVersion = 1
+
+// A Link is the record stored in the key of a short url. It is serialized as a JSON
+// object that carries its version, so the format can evolve without breaking the keys
+// stored by previous versions of the service.
+type Link struct {
+	// Version is zero for the legacy values, which only hold the long url
+	Version int    `json:"v"`
+	URL     string `json:"url"`
+	// CreatedAt is nil for the legacy values
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	// CreatedBy is the ID of the API key that encoded the short url (if any)
+	CreatedBy string   `json:"createdBy,omitempty"`
+	Title     string   `json:"title,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	// ExpiresAt mirrors the expiration of the key, being nil if it never expires
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Disabled  bool       `json:"disabled,omitempty"`
+}
+
+// IsLinkKey reports whether the given key holds the link of a short url. The rest of the
+// keys are namespaced by the service with a colon (e.g. apikey:{hash} or tombstone:{slug}).
+func IsLinkKey(key string) bool {
+	return !strings.Contains(key, ":")
+}
+
+// ParseLink decodes the given stored value. Values that are not a JSON object were
+// stored by previous versions of the service as a plain long url, and they are returned
+// as a legacy link (version zero) without metadata.
+func ParseLink(value string) (*Link, error) {
+	if !strings.HasPrefix(value, "{") {
+		return &Link{URL: value}, nil
+	}
+	link := &Link{}
+	if err := json.Unmarshal([]byte(value), link); err != nil {
+		return nil, fmt.Errorf("invalid link record: %w", err)
+	}
+	if link.Version < 1 || link.Version > LinkVersion {
+		return nil, fmt.Errorf("unsupported link record version %d", link.Version)
+	}
+	return link, nil
+}
+
+// String returns the serialized link, as it is stored
+func (l *Link) String() string {
+	// Marshaling can not fail, as the struct only holds strings, booleans and times
+	value, _ := json.Marshal(l)
+	return string(value)
+}
+
+// getLink returns the link stored in the given key, being nil if it does not exist
+func getLink(ctx context.Context, c Cache, key string) (*Link, error) {
+	value, err := c.Get(ctx, key)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	return ParseLink(value)
+}
+
+// getLinks returns the links stored in the given keys, being nil the ones that do not exist
+func getLinks(ctx context.Context, c Cache, keys []string) ([]*Link, error) {
+	values, err := c.GetAll(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	links := make([]*Link, len(values))
+	for i, value := range values {
+		if value == "" {
+			continue
+		}
+		if links[i], err = ParseLink(value); err != nil {
+			return nil, err
+		}
+	}
+	return links, nil
+}
+
+// sameURL returns a function that reports whether the stored value is a link (of any
+// version) to the given long url that was created by the given API key (if any), as
+// the short urls are only shared within the API key that encoded them
+func sameURL(longURL string, createdBy string) func(value string) bool {
+	return func(value string) bool {
+		link, err := ParseLink(value)
+		return err == nil && link.URL == longURL && link.CreatedBy == createdBy
+	}
+}
+
+// sameLink returns a function that reports whether the stored value can be shared
+// with the given entry, which is never the case for the unique ones
+func sameLink(entry LinkEntry) func(value string) bool {
+	if entry.Unique {
+		return func(string) bool { return false }
+	}
+	return sameURL(entry.Link.URL, entry.Link.CreatedBy)
+}
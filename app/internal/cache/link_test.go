@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLink(t *testing.T) {
+	createdAt := time.Date(2026, 10, 17, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    string
+		expected *Link
+		err      string
+	}{
+		{
+			name:     "legacy",
+			value:    "https://example.com",
+			expected: &Link{URL: "https://example.com"},
+		},
+		{
+			name:  "record",
+			value: `{"v":1,"url":"https://example.com","createdAt":"2026-10-17T15:04:05Z","tags":["a"]}`,
+			expected: &Link{
+				Version:   1,
+				URL:       "https://example.com",
+				CreatedAt: &createdAt,
+				Tags:      []string{"a"},
+			},
+		},
+		{
+			name:  "invalid",
+			value: `{"v":1,`,
+			err:   "invalid link record: unexpected end of JSON input",
+		},
+		{
+			name:  "unsupported version",
+			value: `{"v":2,"url":"https://example.com"}`,
+			err:   "unsupported link record version 2",
+		},
+		{
+			name:  "missing version",
+			value: `{"url":"https://example.com"}`,
+			err:   "unsupported link record version 0",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := ParseLink(tt.value)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, link)
+		})
+	}
+}
+
+func TestLink_String(t *testing.T) {
+	createdAt := time.Date(2026, 10, 17, 15, 4, 5, 0, time.UTC)
+	link := &Link{Version: LinkVersion, URL: "https://example.com", CreatedAt: &createdAt}
+	assert.Equal(t,
+		`{"v":1,"url":"https://example.com","createdAt":"2026-10-17T15:04:05Z"}`,
+		link.String(),
+	)
+	parsed, err := ParseLink(link.String())
+	assert.NoError(t, err)
+	assert.Equal(t, link, parsed)
+}
+
+func TestIsLinkKey(t *testing.T) {
+	assert.True(t, IsLinkKey("64fc5e"))
+	assert.True(t, IsLinkKey("launch-2026"))
+	assert.False(t, IsLinkKey("tombstone:64fc5e"))
+	assert.False(t, IsLinkKey("counter:slug"))
+}
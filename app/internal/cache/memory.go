@@ -0,0 +1,551 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+)
+
+const (
+	// defaultMemoryShards is the number of shards used when the configuration does not define it
+	defaultMemoryShards = 16
+	// defaultJanitorInterval is the janitor interval used when the configuration does not define it
+	defaultJanitorInterval = time.Minute
+)
+
+// errNotInteger is returned when incrementing a key that does not hold an integer
+var errNotInteger = errors.New("value is not an integer or out of range")
+
+// A memory store keeps the keys in the process memory. The keyspace is split in shards,
+// each one protected by its own lock, so concurrent requests over different keys
+// barely block each other.
+type memory struct {
+	shards []*memoryShard
+}
+
+// A memoryShard holds a subset of the keys. The short urls are ordered by their last access
+// (most recent first) so the least recently used one can be evicted, while the service keys
+// (e.g. api keys, counters and tombstones) are never evicted. Every key is also kept in
+// a sorted index, so the scans start from their cursor.
+type memoryShard struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List
+	pinned     *list.List
+	keys       []string
+	maxEntries int
+}
+
+type memoryEntry struct {
+	key   string
+	value string
+	// pinned is true for the service keys, which are not evicted nor counted by maxEntries
+	pinned bool
+	// members holds the member counters, if the key is used by IncrementMember
+	members map[string]int64
+	// expiresAt is zero if the key never expires
+	expiresAt time.Time
+}
+
+// NewMemory creates an in-memory cache instance. A background janitor removes the
+// expired keys until the given context is done.
+func NewMemory(ctx context.Context, conf *config.Values) Cache {
+	shards := conf.Storage.Shards
+	if shards <= 0 {
+		shards = defaultMemoryShards
+	}
+	// The maximum number of entries is split evenly between shards
+	maxEntries := 0
+	if conf.Storage.MaxEntries > 0 {
+		maxEntries = (conf.Storage.MaxEntries + shards - 1) / shards
+	}
+	m := &memory{shards: make([]*memoryShard, shards)}
+	for i := range m.shards {
+		m.shards[i] = &memoryShard{
+			entries:    make(map[string]*list.Element),
+			order:      list.New(),
+			pinned:     list.New(),
+			maxEntries: maxEntries,
+		}
+	}
+	interval := time.Duration(conf.Storage.JanitorIntervalInSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultJanitorInterval
+	}
+	go m.janitor(ctx, interval)
+	return m
+}
+
+func (m *memory) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return m.shards[h.Sum32()%uint32(len(m.shards))]
+}
+
+// janitor periodically removes the expired keys of every shard
+func (m *memory) janitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, s := range m.shards {
+				s.removeExpired(now)
+			}
+		}
+	}
+}
+
+func (m *memory) Get(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.get(key, time.Now())
+	if entry == nil {
+		return "", nil
+	}
+	return entry.value, nil
+}
+
+func (m *memory) GetLink(ctx context.Context, key string) (*Link, error) {
+	return getLink(ctx, m, key)
+}
+
+func (m *memory) GetAll(ctx context.Context, keys []string) ([]string, error) {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		var err error
+		if values[i], err = m.Get(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (m *memory) GetLinks(ctx context.Context, keys []string) ([]*Link, error) {
+	return getLinks(ctx, m, keys)
+}
+
+func (m *memory) SetIfNotExists(
+	ctx context.Context, key string, value string, expiration time.Duration,
+) (bool, error) {
+	return m.setIfNotExists(ctx, key, value, expiration, func(previousValue string) bool {
+		return value == previousValue
+	})
+}
+
+func (m *memory) SetLinkIfNotExists(
+	ctx context.Context, key string, link *Link, expiration time.Duration,
+) (bool, error) {
+	return m.setIfNotExists(ctx, key, link.String(), expiration, sameURL(link.URL, link.CreatedBy))
+}
+
+// setIfNotExists sets the key if it does not exist, succeeding as well if the
+// key already holds a value that is considered the same by the given function
+func (m *memory) setIfNotExists(
+	ctx context.Context,
+	key string,
+	value string,
+	expiration time.Duration,
+	same func(previousValue string) bool,
+) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s := m.shard(key)
+	// The shard lock makes the check and the set atomic
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if entry := s.get(key, now); entry != nil {
+		// The key was already stored with the same value, or it is a collision
+		return same(entry.value), nil
+	}
+	s.set(key, value, expiresAt(now, expiration))
+	return true, nil
+}
+
+func (m *memory) SetLinksIfNotExists(ctx context.Context, entries []LinkEntry) ([]bool, error) {
+	results := make([]bool, len(entries))
+	for i, entry := range entries {
+		var err error
+		results[i], err = m.setIfNotExists(
+			ctx, entry.Key, entry.Link.String(), entry.Expiration, sameLink(entry),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (m *memory) SetAll(ctx context.Context, entries []Entry) error {
+	for _, entry := range entries {
+		if err := m.Set(ctx, entry.Key, entry.Value, entry.Expiration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memory) Set(
+	ctx context.Context, key string, value string, expiration time.Duration,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, value, expiresAt(time.Now(), expiration))
+	return nil
+}
+
+func (m *memory) Update(
+	ctx context.Context, key string, value string, expiration time.Duration,
+) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	entry := s.get(key, now)
+	if entry == nil {
+		return false, nil
+	}
+	keyExpiresAt := entry.expiresAt
+	if expiration != KeepTTL {
+		keyExpiresAt = expiresAt(now, expiration)
+	}
+	s.set(key, value, keyExpiresAt)
+	return true, nil
+}
+
+func (m *memory) UpdateLink(
+	ctx context.Context, key string, link *Link, expiration time.Duration,
+) (bool, error) {
+	return m.Update(ctx, key, link.String(), expiration)
+}
+
+func (m *memory) Delete(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.get(key, time.Now()) == nil {
+		return false, nil
+	}
+	s.remove(s.entries[key])
+	return true, nil
+}
+
+// Scan uses the last key of each page as cursor. Every shard is read from the cursor
+// in its sorted index, like the buckets of bolt.
+func (m *memory) Scan(
+	ctx context.Context, cursor string, match string, count int,
+) ([]string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	if count <= 0 {
+		count = defaultScanCount
+	}
+	// The keys that match the pattern start with its literal prefix
+	prefix := globPrefix(match)
+	start := cursor
+	if prefix > start {
+		start = prefix
+	}
+	now := time.Now()
+	var keys []string
+	for _, s := range m.shards {
+		s.mu.Lock()
+		// The first count keys of the page are among the first count+1 keys of each shard
+		found := 0
+		for i := sort.SearchStrings(s.keys, start); i < len(s.keys) && found <= count; i++ {
+			key := s.keys[i]
+			if !strings.HasPrefix(key, prefix) {
+				break
+			}
+			if key == cursor || !matchGlob(match, key) {
+				continue
+			}
+			if s.entries[key].Value.(*memoryEntry).expired(now) {
+				continue
+			}
+			keys = append(keys, key)
+			found++
+		}
+		s.mu.Unlock()
+	}
+	keys, next := scanPage(keys, count)
+	return keys, next, nil
+}
+
+func (m *memory) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	entry := s.get(key, now)
+	if entry == nil || entry.expiresAt.IsZero() {
+		return 0, nil
+	}
+	return entry.expiresAt.Sub(now), nil
+}
+
+func (m *memory) Expire(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	entry := s.get(key, now)
+	if entry == nil {
+		return false, nil
+	}
+	entry.expiresAt = expiresAt(now, expiration)
+	return true, nil
+}
+
+func (m *memory) Increment(ctx context.Context, key string) (int64, error) {
+	return m.increment(ctx, key, 1, 0)
+}
+
+func (m *memory) IncrementWithExpiration(
+	ctx context.Context, key string, increment int64, expiration time.Duration,
+) (int64, error) {
+	return m.increment(ctx, key, increment, expiration)
+}
+
+// increment increments the counter of the given key by the given increment, which
+// is created with the given expiration (zero if it never expires)
+func (m *memory) increment(
+	ctx context.Context, key string, increment int64, expiration time.Duration,
+) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var n int64
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = now.Add(expiration)
+	}
+	if entry := s.get(key, now); entry != nil {
+		var err error
+		n, err = strconv.ParseInt(entry.value, 10, 64)
+		if err != nil {
+			return 0, errNotInteger
+		}
+		// Like redis, incrementing a key does not change its expiration
+		expiresAt = entry.expiresAt
+	}
+	n += increment
+	s.set(key, strconv.FormatInt(n, 10), expiresAt)
+	return n, nil
+}
+
+func (m *memory) IncrementMember(
+	ctx context.Context, key string, member string, maxMembers int,
+) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.get(key, time.Now())
+	if entry == nil {
+		entry = s.set(key, "", time.Time{})
+	}
+	if entry.members == nil {
+		entry.members = make(map[string]int64)
+	}
+	entry.members[member]++
+	count := entry.members[member]
+	if maxMembers > 0 {
+		for len(entry.members) > maxMembers {
+			delete(entry.members, lowestMember(entry.members))
+		}
+	}
+	return count, nil
+}
+
+// lowestMember returns the member that TopMembers sorts last, by count and then by member
+// (both ascending), like the lowest rank of a redis sorted set
+func lowestMember(members map[string]int64) string {
+	var lowest string
+	var lowestCount int64
+	for member, count := range members {
+		if lowest == "" || count < lowestCount || (count == lowestCount && member < lowest) {
+			lowest, lowestCount = member, count
+		}
+	}
+	return lowest
+}
+
+func (m *memory) TopMembers(ctx context.Context, key string, limit int) ([]MemberCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s := m.shard(key)
+	s.mu.Lock()
+	var counts []MemberCount
+	if entry := s.get(key, time.Now()); entry != nil {
+		counts = make([]MemberCount, 0, len(entry.members))
+		for member, count := range entry.members {
+			counts = append(counts, MemberCount{Member: member, Count: count})
+		}
+	}
+	s.mu.Unlock()
+	return topMembers(counts, limit), nil
+}
+
+// topMembers sorts the given counters like redis sorted sets in reverse order,
+// by count and then by member (both descending), returning up to limit members
+func topMembers(counts []MemberCount, limit int) []MemberCount {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Member > counts[j].Member
+	})
+	if limit > 0 && len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts
+}
+
+// scanPage sorts the given keys (removing duplicates) and returns the first count of them,
+// and the cursor of the next page, which is the last returned key (empty if there are no more)
+func scanPage(keys []string, count int) ([]string, string) {
+	if count <= 0 {
+		count = defaultScanCount
+	}
+	sort.Strings(keys)
+	unique := keys[:0]
+	for i, key := range keys {
+		if i == 0 || key != keys[i-1] {
+			unique = append(unique, key)
+		}
+	}
+	if len(unique) <= count {
+		return unique, ""
+	}
+	return unique[:count], unique[count-1]
+}
+
+// expiresAt returns the expiration date for the given expiration,
+// which is zero if there is no expiration
+func expiresAt(now time.Time, expiration time.Duration) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return now.Add(expiration)
+}
+
+// isExpired returns true if the given expiration date is set and already passed
+func isExpired(expiresAt time.Time, now time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
+
+// get returns the entry of the given key, marking it as the most recently used one.
+// Expired entries are removed and nil is returned. The lock must be held by the caller.
+func (s *memoryShard) get(key string, now time.Time) *memoryEntry {
+	el, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if entry.expired(now) {
+		s.remove(el)
+		return nil
+	}
+	s.order.MoveToFront(el)
+	return entry
+}
+
+// set stores the given key as the most recently used one, evicting the least
+// recently used key if the shard is full. The lock must be held by the caller.
+func (s *memoryShard) set(key string, value string, expiresAt time.Time) *memoryEntry {
+	if el, ok := s.entries[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value = value
+		entry.members = nil
+		entry.expiresAt = expiresAt
+		s.order.MoveToFront(el)
+		return entry
+	}
+	entry := &memoryEntry{
+		key:       key,
+		value:     value,
+		pinned:    !IsLinkKey(key),
+		expiresAt: expiresAt,
+	}
+	if entry.pinned {
+		s.entries[key] = s.pinned.PushFront(entry)
+	} else {
+		if s.maxEntries > 0 && s.order.Len() >= s.maxEntries {
+			s.remove(s.order.Back())
+		}
+		s.entries[key] = s.order.PushFront(entry)
+	}
+	i := sort.SearchStrings(s.keys, key)
+	s.keys = append(s.keys, "")
+	copy(s.keys[i+1:], s.keys[i:])
+	s.keys[i] = key
+	return entry
+}
+
+// remove deletes the given element from the shard. The lock must be held by the caller.
+func (s *memoryShard) remove(el *list.Element) {
+	entry := el.Value.(*memoryEntry)
+	if entry.pinned {
+		s.pinned.Remove(el)
+	} else {
+		s.order.Remove(el)
+	}
+	delete(s.entries, entry.key)
+	i := sort.SearchStrings(s.keys, entry.key)
+	s.keys = append(s.keys[:i], s.keys[i+1:]...)
+}
+
+func (s *memoryShard) removeExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, el := range s.entries {
+		if el.Value.(*memoryEntry).expired(now) {
+			s.remove(el)
+		}
+	}
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return isExpired(e.expiresAt, now)
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMemoryTest(storage config.StorageValues) *memory {
+	return NewMemory(context.Background(), &config.Values{Storage: storage}).(*memory)
+}
+
+func TestNewMemory(t *testing.T) {
+	m := newMemoryTest(config.StorageValues{MaxEntries: 100, Shards: 8})
+	assert.Len(t, m.shards, 8)
+	assert.Equal(t, 13, m.shards[0].maxEntries)
+}
+
+func TestNewMemory_Defaults(t *testing.T) {
+	m := newMemoryTest(config.StorageValues{})
+	assert.Len(t, m.shards, defaultMemoryShards)
+	assert.Equal(t, 0, m.shards[0].maxEntries)
+}
+
+func TestMemoryIncrement_NotAnInteger(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryTest(config.StorageValues{})
+	assert.NoError(t, m.Set(ctx, "counter3", "value", 0))
+	_, err := m.Increment(ctx, "counter3")
+	assert.Equal(t, errNotInteger, err)
+}
+
+func TestMemory_LRUEviction(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryTest(config.StorageValues{MaxEntries: 2, Shards: 1})
+	assert.NoError(t, m.Set(ctx, "key1", "val1", 0))
+	assert.NoError(t, m.Set(ctx, "key2", "val2", 0))
+	// Reading key1 makes key2 the least recently used key
+	m.Get(ctx, "key1")
+	assert.NoError(t, m.Set(ctx, "key3", "val3", 0))
+	val, _ := m.Get(ctx, "key2")
+	assert.Equal(t, "", val)
+	val, _ = m.Get(ctx, "key1")
+	assert.Equal(t, "val1", val)
+	val, _ = m.Get(ctx, "key3")
+	assert.Equal(t, "val3", val)
+}
+
+func TestMemory_LRUEvictionServiceKeys(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryTest(config.StorageValues{MaxEntries: 1, Shards: 1})
+	assert.NoError(t, m.Set(ctx, "apikey:hash", "owner", 0))
+	assert.NoError(t, m.Set(ctx, "key1", "val1", 0))
+	_, err := m.Increment(ctx, "counter:slug")
+	assert.NoError(t, err)
+	assert.NoError(t, m.Set(ctx, "key2", "val2", 0))
+	// Only the short urls are evicted, and counted by the maximum entries
+	val, _ := m.Get(ctx, "key1")
+	assert.Equal(t, "", val)
+	val, _ = m.Get(ctx, "key2")
+	assert.Equal(t, "val2", val)
+	val, _ = m.Get(ctx, "apikey:hash")
+	assert.Equal(t, "owner", val)
+	val, _ = m.Get(ctx, "counter:slug")
+	assert.Equal(t, "1", val)
+	assert.Equal(t, []string{"apikey:hash", "counter:slug", "key2"}, m.shards[0].keys)
+}
+
+func TestMemory_RemoveExpired(t *testing.T) {
+	ctx := context.Background()
+	m := newMemoryTest(config.StorageValues{Shards: 1})
+	assert.NoError(t, m.Set(ctx, "key1", "val1", time.Minute))
+	assert.NoError(t, m.Set(ctx, "key2", "val2", 0))
+	m.shards[0].removeExpired(time.Now().Add(time.Hour))
+	assert.Len(t, m.shards[0].entries, 1)
+	assert.Equal(t, 1, m.shards[0].order.Len())
+	assert.Equal(t, []string{"key2"}, m.shards[0].keys)
+}
+
+func TestMemory_Janitor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &memory{shards: []*memoryShard{newMemoryTest(config.StorageValues{}).shards[0]}}
+	assert.NoError(t, m.Set(ctx, "key1", "val1", time.Millisecond))
+	done := make(chan struct{})
+	go func() {
+		m.janitor(ctx, time.Millisecond)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		m.shards[0].mu.Lock()
+		defer m.shards[0].mu.Unlock()
+		return len(m.shards[0].entries) == 0
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// A KeyPrefixMigration summarizes the keys moved by MigrateKeyPrefix
+type KeyPrefixMigration struct {
+	// Migrated is the number of keys that were renamed (or that would be renamed in a dry run)
+	Migrated int
+	// Conflicts are the keys that were not renamed, as their prefixed key already exists
+	Conflicts []string
+}
+
+// MigrateKeyPrefix renames the unprefixed keys stored by previous versions of the service
+// to their namespaced key (e.g. "abc123" to "shortesturl:abc123").
+// As redis can be shared with other services, only the keys accepted by the owned function
+// are renamed. The value of the keys that do not hold a string is empty. A prefixed key is
+// never overwritten, and a dry run only reports the keys that would be renamed.
+func MigrateKeyPrefix(
+	ctx context.Context, c Cache, owned func(key string, value string) bool, dryRun bool,
+) (KeyPrefixMigration, error) {
+	var migration KeyPrefixMigration
+	rc, ok := c.(*cache)
+	if !ok {
+		return migration, errors.New(
+			"the key prefix migration is only supported by the redis storage backends")
+	}
+	if rc.keyPrefix == "" {
+		return migration, errors.New("the key prefix is not configured")
+	}
+
+	// The masters of a Cluster are scanned one after the other, like in Scan
+	nodes, err := rc.scanNodes(ctx)
+	if err != nil {
+		return migration, err
+	}
+	for _, node := range nodes {
+		iter := node.Scan(ctx, 0, "*", 1000).Iterator()
+		for iter.Next(ctx) {
+			key := iter.Val()
+			if strings.HasPrefix(key, rc.keyPrefix+":") {
+				continue
+			}
+			value, err := rc.client.Get(ctx, key).Result()
+			if err == redis.Nil {
+				// The key was removed after the scan
+				continue
+			}
+			if err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE") {
+				// The key is not a string, thus only its name tells whether it is ours
+				value, err = "", nil
+			}
+			if err != nil {
+				return migration, err
+			}
+			if !owned(key, value) {
+				continue
+			}
+			var renamed bool
+			if dryRun {
+				var exists int64
+				exists, err = rc.client.Exists(ctx, rc.key(key)).Result()
+				renamed = exists == 0
+			} else {
+				renamed, err = rc.renameNX(ctx, key)
+			}
+			if err != nil {
+				return migration, err
+			}
+			if !renamed {
+				migration.Conflicts = append(migration.Conflicts, key)
+				continue
+			}
+			migration.Migrated++
+		}
+		if err := iter.Err(); err != nil {
+			return migration, err
+		}
+	}
+	return migration, nil
+}
+
+// renameNX renames the given key to its prefixed key if the latter does not exist, like RENAMENX.
+// The keys of a Cluster usually hash to different slots, which RENAMENX rejects (CROSSSLOT), thus
+// they are copied with their expiration and then deleted. As the copy is not atomic, the service
+// should be stopped while the keys of a Cluster are migrated.
+func (c cache) renameNX(ctx context.Context, key string) (bool, error) {
+	if _, ok := c.client.(*redis.ClusterClient); !ok {
+		return c.client.RenameNX(ctx, key, c.key(key)).Result()
+	}
+	ttl, err := c.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	if ttl < 0 {
+		// The key has no expiration
+		ttl = 0
+	}
+	kind, err := c.client.Type(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	var copied bool
+	switch kind {
+	case "string":
+		var value string
+		if value, err = c.client.Get(ctx, key).Result(); err == nil {
+			copied, err = c.client.SetNX(ctx, c.key(key), value, ttl).Result()
+		}
+	case "zset":
+		copied, err = c.copyMembersNX(ctx, key, ttl)
+	default:
+		return false, fmt.Errorf("unable to migrate key %q of type %s", key, kind)
+	}
+	if err != nil || !copied {
+		return false, err
+	}
+	return true, c.client.Del(ctx, key).Err()
+}
+
+// copyMembersNX copies the member counters of the given key to its prefixed key
+// if the latter does not exist, returning false if it does
+func (c cache) copyMembersNX(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	exists, err := c.client.Exists(ctx, c.key(key)).Result()
+	if err != nil || exists > 0 {
+		return false, err
+	}
+	members, err := c.client.ZRangeWithScores(ctx, key, 0, -1).Result()
+	if err != nil {
+		return false, err
+	}
+	counters := make([]*redis.Z, len(members))
+	for i := range members {
+		counters[i] = &members[i]
+	}
+	_, err = c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, c.key(key), counters...)
+		if ttl > 0 {
+			pipe.PExpire(ctx, c.key(key), ttl)
+		}
+		return nil
+	})
+	return err == nil, err
+}
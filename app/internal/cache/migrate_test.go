@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func isTestURL(key string, value string) bool {
+	return strings.HasPrefix(value, "https://")
+}
+
+// isTestKey accepts the test urls and the click keys, whatever their type
+func isTestKey(key string, value string) bool {
+	return isTestURL(key, value) || strings.HasPrefix(key, "clicks:")
+}
+
+func TestMigrateKeyPrefix(t *testing.T) {
+	mr, c := NewMiniredis()
+	defer mr.Close()
+	mr.Set("abc123", "https://darioblanco.com")
+	mr.SetTTL("abc123", time.Hour)
+	mr.Set("conflict", "https://github.com/darioblanco")
+	mr.Set("test:conflict", "https://darioblanco.com")
+	mr.Set("test:migrated", "https://darioblanco.com")
+	mr.Set("other", "not a url")
+	mr.HSet("hash", "field", "https://darioblanco.com")
+
+	migration, err := MigrateKeyPrefix(context.Background(), c, isTestURL, false)
+	assert.NoError(t, err)
+	assert.Equal(t, KeyPrefixMigration{Migrated: 1, Conflicts: []string{"conflict"}}, migration)
+	assert.False(t, mr.Exists("abc123"))
+	val, _ := mr.Get("test:abc123")
+	assert.Equal(t, "https://darioblanco.com", val)
+	assert.Equal(t, time.Hour, mr.TTL("test:abc123"))
+	val, _ = mr.Get("test:conflict")
+	assert.Equal(t, "https://darioblanco.com", val)
+	assert.True(t, mr.Exists("conflict"))
+	assert.True(t, mr.Exists("other"))
+	assert.True(t, mr.Exists("hash"))
+
+	// The migration is idempotent
+	migration, err = MigrateKeyPrefix(context.Background(), c, isTestURL, false)
+	assert.NoError(t, err)
+	assert.Equal(t, KeyPrefixMigration{Conflicts: []string{"conflict"}}, migration)
+}
+
+func TestMigrateKeyPrefix_Members(t *testing.T) {
+	mr, c := NewMiniredis()
+	defer mr.Close()
+	mr.ZAdd("clicks:abc123:referers", 2, "github.com")
+	mr.SetTTL("clicks:abc123:referers", time.Hour)
+	mr.ZAdd("other:referers", 1, "github.com")
+
+	migration, err := MigrateKeyPrefix(context.Background(), c, isTestKey, false)
+	assert.NoError(t, err)
+	assert.Equal(t, KeyPrefixMigration{Migrated: 1}, migration)
+	assert.False(t, mr.Exists("clicks:abc123:referers"))
+	members, _ := mr.ZMembers("test:clicks:abc123:referers")
+	assert.Equal(t, []string{"github.com"}, members)
+	assert.Equal(t, time.Hour, mr.TTL("test:clicks:abc123:referers"))
+	assert.True(t, mr.Exists("other:referers"))
+}
+
+func TestMigrateKeyPrefix_Cluster(t *testing.T) {
+	ctx := context.Background()
+	nodes, c := NewMiniredisCluster()
+	for _, mr := range nodes {
+		defer mr.Close()
+	}
+	// The unprefixed keys are written through the Cluster, so they are stored in their node
+	client := c.(*cache).client
+	var keys []string
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("slug%d", i)
+		assert.NoError(t, client.Set(ctx, key, "https://darioblanco.com", time.Hour).Err())
+		keys = append(keys, key)
+	}
+	assert.NoError(t, client.ZIncrBy(ctx, "clicks:slug0:referers", 2, "github.com").Err())
+	assert.NoError(t, client.Set(ctx, "conflict", "https://github.com/darioblanco", 0).Err())
+	assert.NoError(t, c.Set(ctx, "conflict", "https://darioblanco.com", 0))
+	assert.NoError(t, client.ZIncrBy(ctx, "clicks:conflict:referers", 1, "github.com").Err())
+	_, err := c.IncrementMember(ctx, "clicks:conflict:referers", "darioblanco.com", 0)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Set(ctx, "other", "not a url", 0).Err())
+	// Both masters hold keys to migrate
+	for _, mr := range nodes {
+		assert.NotEmpty(t, mr.Keys())
+	}
+
+	migration, err := MigrateKeyPrefix(ctx, c, isTestKey, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 11, migration.Migrated)
+	assert.ElementsMatch(t, []string{"conflict", "clicks:conflict:referers"}, migration.Conflicts)
+	for _, key := range keys {
+		value, err := c.Get(ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://darioblanco.com", value)
+		ttl, err := c.TTL(ctx, key)
+		assert.NoError(t, err)
+		assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+		assert.Zero(t, client.Exists(ctx, key).Val())
+	}
+	members, err := c.TopMembers(ctx, "clicks:slug0:referers", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []MemberCount{{Member: "github.com", Count: 2}}, members)
+	assert.Zero(t, client.Exists(ctx, "clicks:slug0:referers").Val())
+	// The conflicts keep both keys
+	members, err = c.TopMembers(ctx, "clicks:conflict:referers", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []MemberCount{{Member: "darioblanco.com", Count: 1}}, members)
+	assert.Equal(t, int64(1), client.Exists(ctx, "conflict").Val())
+	assert.Equal(t, int64(1), client.Exists(ctx, "other").Val())
+}
+
+func TestMigrateKeyPrefix_DryRun(t *testing.T) {
+	mr, c := NewMiniredis()
+	defer mr.Close()
+	mr.Set("abc123", "https://darioblanco.com")
+	mr.Set("conflict", "https://github.com/darioblanco")
+	mr.Set("test:conflict", "https://darioblanco.com")
+
+	migration, err := MigrateKeyPrefix(context.Background(), c, isTestURL, true)
+	assert.NoError(t, err)
+	assert.Equal(t, KeyPrefixMigration{Migrated: 1, Conflicts: []string{"conflict"}}, migration)
+	assert.True(t, mr.Exists("abc123"))
+	assert.False(t, mr.Exists("test:abc123"))
+}
+
+func TestMigrateKeyPrefix_Error(t *testing.T) {
+	mr, c := NewMiniredis()
+	defer mr.Close()
+	mr.SetError("mock error")
+
+	_, err := MigrateKeyPrefix(context.Background(), c, isTestURL, false)
+	assert.EqualError(t, err, "mock error")
+}
+
+func TestMigrateKeyPrefix_NoPrefix(t *testing.T) {
+	mr, c := NewMiniredis()
+	defer mr.Close()
+	c.(*cache).keyPrefix = ""
+
+	_, err := MigrateKeyPrefix(context.Background(), c, isTestURL, false)
+	assert.EqualError(t, err, "the key prefix is not configured")
+}
+
+func TestMigrateKeyPrefix_NotRedis(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := MigrateKeyPrefix(ctx, NewMemory(ctx, &config.Values{}), isTestURL, false)
+	assert.EqualError(t, err,
+		"the key prefix migration is only supported by the redis storage backends")
+}
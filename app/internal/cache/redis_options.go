@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/go-redis/redis/v8"
+)
+
+// redisOptions builds the options of a redis universal client from the config.
+// Without addresses, the single node given by the redis host and port is used.
+// A master name creates a Sentinel (failover) client, and more than one address
+// creates a Cluster client.
+func redisOptions(conf *config.Values) (*redis.UniversalOptions, error) {
+	addrs := conf.Redis.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf("%s:%s", conf.RedisHost, conf.RedisPort)}
+	}
+	tlsConfig, err := redisTLSConfig(conf.Redis.TLS)
+	if err != nil {
+		return nil, err
+	}
+	return &redis.UniversalOptions{
+		Addrs:            addrs,
+		DB:               conf.Redis.DB,
+		DialTimeout:      time.Duration(conf.Redis.DialTimeoutInMilliseconds) * time.Millisecond,
+		MasterName:       conf.Redis.MasterName,
+		Password:         conf.Redis.Password,
+		PoolSize:         conf.Redis.PoolSize,
+		ReadTimeout:      time.Duration(conf.Redis.ReadTimeoutInMilliseconds) * time.Millisecond,
+		SentinelPassword: conf.Redis.SentinelPassword,
+		TLSConfig:        tlsConfig,
+		Username:         conf.Redis.Username,
+		WriteTimeout:     time.Duration(conf.Redis.WriteTimeoutInMilliseconds) * time.Millisecond,
+	}, nil
+}
+
+// redisTLSConfig returns the TLS config for the redis connections,
+// or nil if TLS is not enabled
+func redisTLSConfig(conf config.RedisTLSValues) (*tls.Config, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if conf.CAFile != "" {
+		ca, err := ioutil.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the redis tls ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no valid certificates found in the redis tls ca file %q", conf.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		if conf.CertFile == "" || conf.KeyFile == "" {
+			return nil, errors.New("the redis tls cert and key files have to be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the redis tls key pair: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
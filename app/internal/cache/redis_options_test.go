@@ -0,0 +1,313 @@
+package cache
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCertificate holds a PEM encoded certificate and its key, signed by the parent (or itself)
+type testCertificate struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certPEM  []byte
+	keyPEM   []byte
+	keyPair  tls.Certificate
+	certFile string
+	keyFile  string
+}
+
+func newTestCertificate(
+	t *testing.T, name string, template *x509.Certificate, parent *testCertificate,
+) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signerCert, signerKey := template, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	c := &testCertificate{
+		cert:     cert,
+		key:      key,
+		certPEM:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:   pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		certFile: filepath.Join(t.TempDir(), name+".crt"),
+		keyFile:  filepath.Join(t.TempDir(), name+".key"),
+	}
+	c.keyPair, err = tls.X509KeyPair(c.certPEM, c.keyPEM)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(c.certFile, c.certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(c.keyFile, c.keyPEM, 0600))
+	return c
+}
+
+// newTestPKI returns a certificate authority, and a server and client certificates signed by it
+func newTestPKI(t *testing.T) (ca, server, client *testCertificate) {
+	ca = newTestCertificate(t, "ca", &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	server = newTestCertificate(t, "server", &x509.Certificate{
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	}, ca)
+	client = newTestCertificate(t, "client", &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	}, ca)
+	return ca, server, client
+}
+
+func TestRedisOptions(t *testing.T) {
+	opts, err := redisOptions(&config.Values{
+		Redis: config.RedisValues{
+			Addrs:                      []string{"sentinel1:26379", "sentinel2:26379"},
+			DB:                         3,
+			DialTimeoutInMilliseconds:  1000,
+			MasterName:                 "shortesturl",
+			Password:                   "secret",
+			PoolSize:                   20,
+			ReadTimeoutInMilliseconds:  500,
+			SentinelPassword:           "sentinelSecret",
+			Username:                   "shortesturl",
+			WriteTimeoutInMilliseconds: 250,
+		},
+		RedisHost: "localhost",
+		RedisPort: "6379",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &redis.UniversalOptions{
+		Addrs:            []string{"sentinel1:26379", "sentinel2:26379"},
+		DB:               3,
+		DialTimeout:      time.Second,
+		MasterName:       "shortesturl",
+		Password:         "secret",
+		PoolSize:         20,
+		ReadTimeout:      500 * time.Millisecond,
+		SentinelPassword: "sentinelSecret",
+		Username:         "shortesturl",
+		WriteTimeout:     250 * time.Millisecond,
+	}, opts)
+	assert.IsType(t, &redis.Client{}, redis.NewUniversalClient(opts))
+}
+
+func TestRedisOptions_HostAndPort(t *testing.T) {
+	opts, err := redisOptions(&config.Values{RedisHost: "localhost", RedisPort: "6379"})
+	assert.NoError(t, err)
+	assert.Equal(t, &redis.UniversalOptions{Addrs: []string{"localhost:6379"}}, opts)
+}
+
+func TestRedisOptions_Cluster(t *testing.T) {
+	opts, err := redisOptions(&config.Values{
+		Redis: config.RedisValues{Addrs: []string{"node1:6379", "node2:6379", "node3:6379"}},
+	})
+	assert.NoError(t, err)
+	assert.IsType(t, &redis.ClusterClient{}, redis.NewUniversalClient(opts))
+}
+
+func TestRedisOptions_TLSError(t *testing.T) {
+	opts, err := redisOptions(&config.Values{
+		Redis: config.RedisValues{TLS: config.RedisTLSValues{Enabled: true, CertFile: "redis.crt"}},
+	})
+	assert.EqualError(t, err, "the redis tls cert and key files have to be set together")
+	assert.Nil(t, opts)
+}
+
+func TestRedisTLSConfig(t *testing.T) {
+	ca, _, client := newTestPKI(t)
+	tlsConfig, err := redisTLSConfig(config.RedisTLSValues{
+		CAFile:   ca.certFile,
+		CertFile: client.certFile,
+		Enabled:  true,
+		KeyFile:  client.keyFile,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.Equal(t, []tls.Certificate{client.keyPair}, tlsConfig.Certificates)
+}
+
+func TestRedisTLSConfig_Disabled(t *testing.T) {
+	tlsConfig, err := redisTLSConfig(config.RedisTLSValues{CAFile: "ca.crt"})
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+}
+
+func TestRedisTLSConfig_SystemRoots(t *testing.T) {
+	tlsConfig, err := redisTLSConfig(config.RedisTLSValues{Enabled: true})
+	assert.NoError(t, err)
+	assert.Equal(t, &tls.Config{MinVersion: tls.VersionTLS12}, tlsConfig)
+}
+
+func TestRedisTLSConfig_Errors(t *testing.T) {
+	ca, _, client := newTestPKI(t)
+	invalidCA := filepath.Join(t.TempDir(), "invalid.crt")
+	assert.NoError(t, ioutil.WriteFile(invalidCA, []byte("invalid"), 0600))
+
+	tests := []struct {
+		name          string
+		conf          config.RedisTLSValues
+		expectedError string
+	}{
+		{
+			name:          "missing ca file",
+			conf:          config.RedisTLSValues{CAFile: "missing.crt"},
+			expectedError: "unable to read the redis tls ca file: open missing.crt: no such file or directory",
+		},
+		{
+			name: "invalid ca file",
+			conf: config.RedisTLSValues{CAFile: invalidCA},
+			expectedError: fmt.Sprintf(
+				"no valid certificates found in the redis tls ca file %q", invalidCA,
+			),
+		},
+		{
+			name:          "key without cert",
+			conf:          config.RedisTLSValues{KeyFile: client.keyFile},
+			expectedError: "the redis tls cert and key files have to be set together",
+		},
+		{
+			name:          "mismatching key pair",
+			conf:          config.RedisTLSValues{CertFile: client.certFile, KeyFile: ca.keyFile},
+			expectedError: "unable to load the redis tls key pair: tls: private key does not match public key",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			tt.conf.Enabled = true
+			tlsConfig, err := redisTLSConfig(tt.conf)
+			assert.EqualError(t, err, tt.expectedError)
+			assert.Nil(t, tlsConfig)
+		})
+	}
+}
+
+func TestNew_RedisAuth(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	mr.RequireUserAuth("shortesturl", "secret")
+	c, err := New(
+		context.Background(),
+		&config.Values{
+			Redis: config.RedisValues{
+				Addrs:    []string{mr.Addr()},
+				DB:       2,
+				Password: "secret",
+				Username: "shortesturl",
+			},
+			Storage: config.StorageValues{Backend: "redis"},
+		},
+		logging.NewTest(t),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Set(context.Background(), "key", "value", 0))
+	val, err := mr.DB(2).Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+}
+
+func TestNew_RedisAuthError(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	mr.RequireUserAuth("shortesturl", "secret")
+	c, err := New(
+		context.Background(),
+		&config.Values{
+			Redis: config.RedisValues{
+				Addrs:    []string{mr.Addr()},
+				Password: "wrong",
+				Username: "shortesturl",
+			},
+			Storage: config.StorageValues{Backend: "redis"},
+		},
+		logging.NewTest(t),
+	)
+	assert.Error(t, err)
+	assert.Nil(t, c)
+}
+
+func TestNew_RedisTLS(t *testing.T) {
+	ca, server, client := newTestPKI(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	mr, err := miniredis.RunTLS(&tls.Config{
+		Certificates: []tls.Certificate{server.keyPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	require.NoError(t, err)
+	defer mr.Close()
+
+	c, err := New(
+		context.Background(),
+		&config.Values{
+			Redis: config.RedisValues{
+				Addrs: []string{mr.Addr()},
+				TLS: config.RedisTLSValues{
+					CAFile:   ca.certFile,
+					CertFile: client.certFile,
+					Enabled:  true,
+					KeyFile:  client.keyFile,
+				},
+			},
+			Storage: config.StorageValues{Backend: "redis"},
+		},
+		logging.NewTest(t),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Set(context.Background(), "key", "value", 0))
+	val, err := mr.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+}
+
+func TestNew_RedisTLSError(t *testing.T) {
+	c, err := New(
+		context.Background(),
+		&config.Values{
+			Redis: config.RedisValues{
+				TLS: config.RedisTLSValues{CAFile: "missing.crt", Enabled: true},
+			},
+			Storage: config.StorageValues{Backend: "redis"},
+		},
+		logging.NewTest(t),
+	)
+	assert.EqualError(t, err,
+		"unable to read the redis tls ca file: open missing.crt: no such file or directory")
+	assert.Nil(t, c)
+}
@@ -0,0 +1,452 @@
+package clicks
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/oschwald/geoip2-golang"
+)
+
+const (
+	// defaultBufferSize is the click buffer size used when the configuration does not define it
+	defaultBufferSize = 1024
+	// defaultWorkers is the number of workers used when the configuration does not define it
+	defaultWorkers = 1
+	// hourlyBuckets is the number of hours returned in the hourly series of the stats
+	hourlyBuckets = 24
+	// dailyBuckets is the number of days returned in the daily series of the stats
+	dailyBuckets = 30
+	// hourlyRetention and dailyRetention expire the buckets of the series once they are
+	// out of the stats, counting from their first click
+	hourlyRetention = hourlyBuckets * time.Hour
+	dailyRetention  = dailyBuckets * 24 * time.Hour
+	// statsRetention expires the total, the last click and the top members of a slug once it
+	// is not clicked for that long, as they would outlive the slug otherwise
+	statsRetention = 365 * 24 * time.Hour
+	// maxMembers bounds the referers, user agents and countries stored for a slug, as the
+	// clients choose their headers
+	maxMembers = 1000
+	// topLimit is the number of referers, user agents and countries returned in the stats
+	topLimit = 10
+	// maxUserAgentLength truncates the user agents, as they are stored as counter members
+	maxUserAgentLength = 256
+	// hourFormat and dayFormat are the suffixes of the keys of the hourly and daily buckets (in UTC)
+	hourFormat = "2006-01-02T15"
+	dayFormat  = "2006-01-02"
+)
+
+// A Click is a resolution of a slug, either by a redirect or by a decode
+type Click struct {
+	Slug      string
+	Time      time.Time
+	Referer   string
+	UserAgent string
+	// IP is the address of the client, with or without port
+	IP        string
+	RequestID string
+}
+
+// A Tracker records the clicks of the short urls and aggregates them into stats
+type Tracker interface {
+	// Track queues the given click to be recorded in the background, thus it never blocks.
+	// If the buffer is full, the click is dropped.
+	Track(click Click)
+	// Stats returns the aggregated stats of the given slug
+	Stats(ctx context.Context, slug string) (*Stats, error)
+	// Delete removes the stats of the given slug, so they are not inherited if it is reused.
+	// The clicks that are still queued are recorded afterwards.
+	Delete(ctx context.Context, slug string) error
+}
+
+// Stats holds the aggregated clicks of a slug
+type Stats struct {
+	Total int64
+	// LastClickAt is nil if the slug was never clicked
+	LastClickAt *time.Time
+	// LastRequestID identifies the request of the last click, being empty if it is unknown
+	LastRequestID string
+	// Hourly holds the clicks of the last 24 hours, and Daily the clicks of the last 30 days
+	Hourly        []Bucket
+	Daily         []Bucket
+	TopReferers   []cache.MemberCount
+	TopUserAgents []cache.MemberCount
+	TopCountries  []cache.MemberCount
+}
+
+// A Bucket holds the clicks of a time period, which starts at the given time
+type Bucket struct {
+	Time   time.Time
+	Clicks int64
+}
+
+// countryReader resolves the country of an IP address (e.g. a GeoIP database)
+type countryReader interface {
+	Country(ip net.IP) (*geoip2.Country, error)
+}
+
+type tracker struct {
+	cache     cache.Cache
+	clicks    chan Click
+	countries countryReader
+	logger    logging.Logger
+	now       func() time.Time
+}
+
+// New creates a tracker whose workers record the clicks until the given context is done.
+// If a GeoIP database is configured, it is used to resolve the country of each click.
+func New(
+	ctx context.Context, conf *config.Values, c cache.Cache, logger logging.Logger,
+) (Tracker, error) {
+	bufferSize := conf.Clicks.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	workers := conf.Clicks.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	t := &tracker{
+		cache:  c,
+		clicks: make(chan Click, bufferSize),
+		logger: logger,
+		now:    time.Now,
+	}
+	var geoIP *geoip2.Reader
+	if conf.Clicks.GeoIPDatabase != "" {
+		var err error
+		if geoIP, err = geoip2.Open(conf.Clicks.GeoIPDatabase); err != nil {
+			return nil, err
+		}
+		t.countries = geoIP
+		logger.Info("Loaded GeoIP database", "path", conf.Clicks.GeoIPDatabase)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.work(ctx)
+		}()
+	}
+	if geoIP != nil {
+		// The database can only be closed once no worker is using it
+		go func() {
+			wg.Wait()
+			geoIP.Close()
+		}()
+	}
+	return t, nil
+}
+
+func (t *tracker) Track(click Click) {
+	select {
+	case t.clicks <- click:
+	default:
+		t.logger.Warn("Dropped click, the click buffer is full",
+			"slug", click.Slug,
+			"requestId", click.RequestID,
+		)
+	}
+}
+
+// work records the queued clicks until the given context is done
+func (t *tracker) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case click := <-t.clicks:
+			if err := t.record(ctx, click); err != nil {
+				// The clicks are best effort, thus the worker carries on with the next one
+				t.logger.Warn("unable to record click",
+					"slug", click.Slug,
+					"requestId", click.RequestID,
+					"error", err,
+				)
+			}
+		}
+	}
+}
+
+// record aggregates the given click into the counters of its slug
+func (t *tracker) record(ctx context.Context, click Click) error {
+	clickTime := click.Time.UTC()
+	if _, err := t.cache.Increment(ctx, totalKey(click.Slug)); err != nil {
+		return err
+	}
+	last, err := json.Marshal(lastClick{Time: clickTime, RequestID: click.RequestID})
+	if err != nil {
+		return err
+	}
+	if err := t.cache.Set(ctx, lastClickKey(click.Slug), string(last), statsRetention); err != nil {
+		return err
+	}
+	// Each bucket of the series is a key of its own, so it expires once it is not needed
+	if _, err := t.cache.IncrementWithExpiration(
+		ctx, hourlyKey(click.Slug, clickTime), 1, hourlyRetention,
+	); err != nil {
+		return err
+	}
+	if _, err := t.cache.IncrementWithExpiration(
+		ctx, dailyKey(click.Slug, clickTime), 1, dailyRetention,
+	); err != nil {
+		return err
+	}
+	members := map[string]string{}
+	referer := refererHost(click.Referer)
+	if referer != "" {
+		members[referersKey(click.Slug)] = referer
+	}
+	userAgent := click.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	if userAgent != "" {
+		members[userAgentsKey(click.Slug)] = userAgent
+	}
+	country := t.country(click.IP)
+	if country != "" {
+		members[countriesKey(click.Slug)] = country
+	}
+	for key, member := range members {
+		if _, err := t.cache.IncrementMember(ctx, key, member, maxMembers); err != nil {
+			return err
+		}
+	}
+	// The retention of the stats slides with every click, like the last click
+	keys := []string{totalKey(click.Slug), referersKey(click.Slug),
+		userAgentsKey(click.Slug), countriesKey(click.Slug)}
+	for _, key := range keys {
+		if _, err := t.cache.Expire(ctx, key, statsRetention); err != nil {
+			return err
+		}
+	}
+	t.logger.Debug("Recorded click",
+		"slug", click.Slug,
+		"time", clickTime,
+		"referer", click.Referer,
+		"userAgent", click.UserAgent,
+		"country", country,
+		"requestId", click.RequestID,
+	)
+	return nil
+}
+
+// country returns the ISO code of the country of the given address,
+// being empty if there is no GeoIP database or the country is unknown
+func (t *tracker) country(address string) string {
+	if t.countries == nil || address == "" {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	record, err := t.countries.Country(ip)
+	if err != nil {
+		t.logger.Debug("Unable to resolve country", "ip", address, "error", err)
+		return ""
+	}
+	return record.Country.IsoCode
+}
+
+func (t *tracker) Stats(ctx context.Context, slug string) (*Stats, error) {
+	total, err := t.cache.Get(ctx, totalKey(slug))
+	if err != nil {
+		return nil, err
+	}
+	stats := &Stats{}
+	// The total is empty if the slug was never clicked
+	if total != "" {
+		if stats.Total, err = parseCount(total); err != nil {
+			return nil, err
+		}
+	}
+	last, err := t.cache.Get(ctx, lastClickKey(slug))
+	if err != nil {
+		return nil, err
+	}
+	if last != "" {
+		click, err := parseLastClick(last)
+		if err != nil {
+			return nil, err
+		}
+		stats.LastClickAt = &click.Time
+		stats.LastRequestID = click.RequestID
+	}
+
+	now := t.now()
+	if stats.Hourly, err = t.series(ctx, slug, hourStarts(now), hourlyKey); err != nil {
+		return nil, err
+	}
+	if stats.Daily, err = t.series(ctx, slug, dayStarts(now), dailyKey); err != nil {
+		return nil, err
+	}
+	if stats.TopReferers, err = t.cache.TopMembers(ctx, referersKey(slug), topLimit); err != nil {
+		return nil, err
+	}
+	if stats.TopUserAgents, err = t.cache.TopMembers(ctx, userAgentsKey(slug), topLimit); err != nil {
+		return nil, err
+	}
+	if stats.TopCountries, err = t.cache.TopMembers(ctx, countriesKey(slug), topLimit); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (t *tracker) Delete(ctx context.Context, slug string) error {
+	keys := []string{
+		totalKey(slug),
+		lastClickKey(slug),
+		referersKey(slug),
+		userAgentsKey(slug),
+		countriesKey(slug),
+	}
+	// The older buckets of the series already expired
+	now := t.now()
+	for _, start := range hourStarts(now) {
+		keys = append(keys, hourlyKey(slug, start))
+	}
+	for _, start := range dayStarts(now) {
+		keys = append(keys, dailyKey(slug, start))
+	}
+	for _, key := range keys {
+		if _, err := t.cache.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// series returns the buckets of the given slug that start at the given times, including
+// the ones without clicks. The clicks of each bucket are read with a single pipeline from
+// the keys of the given function.
+func (t *tracker) series(
+	ctx context.Context,
+	slug string,
+	starts []time.Time,
+	bucketKey func(slug string, start time.Time) string,
+) ([]Bucket, error) {
+	keys := make([]string, len(starts))
+	for i, start := range starts {
+		keys[i] = bucketKey(slug, start)
+	}
+	values, err := t.cache.GetAll(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	series := make([]Bucket, len(starts))
+	for i, start := range starts {
+		series[i] = Bucket{Time: start}
+		// The buckets without clicks do not exist
+		if values[i] == "" {
+			continue
+		}
+		if series[i].Clicks, err = parseCount(values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return series, nil
+}
+
+// hourStarts returns the starts of the buckets of the hourly series, which ends with the
+// current hour (in UTC)
+func hourStarts(now time.Time) []time.Time {
+	hour := now.UTC().Truncate(time.Hour)
+	starts := make([]time.Time, hourlyBuckets)
+	for i := range starts {
+		starts[i] = hour.Add(time.Duration(i-hourlyBuckets+1) * time.Hour)
+	}
+	return starts
+}
+
+// dayStarts returns the starts of the buckets of the daily series, which ends with the
+// current day (in UTC)
+func dayStarts(now time.Time) []time.Time {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	starts := make([]time.Time, dailyBuckets)
+	for i := range starts {
+		starts[i] = today.AddDate(0, 0, i-dailyBuckets+1)
+	}
+	return starts
+}
+
+// refererHost returns the host of the given referer, so the referers of the same site
+// are aggregated together. It is empty if the referer is not an absolute url.
+func refererHost(referer string) string {
+	u, err := url.Parse(referer)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// A lastClick is the record of the last click of a slug
+type lastClick struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId,omitempty"`
+}
+
+// parseLastClick decodes the record of the last click of a slug, which was only its time
+// (in RFC3339) in previous versions of the service
+func parseLastClick(value string) (lastClick, error) {
+	var click lastClick
+	if !strings.HasPrefix(value, "{") {
+		var err error
+		click.Time, err = time.Parse(time.RFC3339Nano, value)
+		return click, err
+	}
+	err := json.Unmarshal([]byte(value), &click)
+	return click, err
+}
+
+// parseCount parses a counter stored by the cache
+func parseCount(value string) (int64, error) {
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// KeyPrefix namespaces the keys of the counters of a slug, as slugs and aliases can not
+// contain colons
+const KeyPrefix = "clicks:"
+
+func totalKey(slug string) string {
+	return KeyPrefix + slug
+}
+
+func lastClickKey(slug string) string {
+	return KeyPrefix + slug + ":last"
+}
+
+func hourlyKey(slug string, hour time.Time) string {
+	return KeyPrefix + slug + ":hourly:" + hour.UTC().Format(hourFormat)
+}
+
+func dailyKey(slug string, day time.Time) string {
+	return KeyPrefix + slug + ":daily:" + day.UTC().Format(dayFormat)
+}
+
+func referersKey(slug string) string {
+	return KeyPrefix + slug + ":referers"
+}
+
+func userAgentsKey(slug string) string {
+	return KeyPrefix + slug + ":userAgents"
+}
+
+func countriesKey(slug string) string {
+	return KeyPrefix + slug + ":countries"
+}
@@ -0,0 +1,25 @@
+package clicks
+
+import (
+	"context"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+)
+
+// A testTracker records the clicks synchronously, so they can be asserted right away
+type testTracker struct {
+	*tracker
+}
+
+// NewTest returns a Tracker instance that records the clicks as soon as they are tracked
+func NewTest(c cache.Cache, logger logging.Logger) Tracker {
+	return testTracker{&tracker{cache: c, logger: logger, now: time.Now}}
+}
+
+func (t testTracker) Track(click Click) {
+	if err := t.record(context.Background(), click); err != nil {
+		t.logger.Warn("unable to record click", "slug", click.Slug, "error", err)
+	}
+}
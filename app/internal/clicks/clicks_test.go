@@ -0,0 +1,401 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/stretchr/testify/assert"
+)
+
+// countryReaderStub resolves the countries of the IPs of the map
+type countryReaderStub map[string]string
+
+func (s countryReaderStub) Country(ip net.IP) (*geoip2.Country, error) {
+	code, ok := s[ip.String()]
+	if !ok {
+		return nil, errors.New("ip not found")
+	}
+	record := &geoip2.Country{}
+	record.Country.IsoCode = code
+	return record, nil
+}
+
+func newTestTracker(t *testing.T) *tracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &tracker{
+		cache:     cache.NewMemory(ctx, &config.Values{}),
+		countries: countryReaderStub{"81.2.69.142": "GB", "2001:db8::1": "ES"},
+		logger:    logging.NewTest(t),
+		now: func() time.Time {
+			return time.Date(2026, 10, 17, 15, 30, 0, 0, time.UTC)
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tr, err := New(ctx, &config.Values{}, cache.NewMemory(ctx, &config.Values{}), logging.NewTest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, defaultBufferSize, cap(tr.(*tracker).clicks))
+	assert.Nil(t, tr.(*tracker).countries)
+}
+
+func TestNew_GeoIPError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tr, err := New(
+		ctx,
+		&config.Values{Clicks: config.ClicksValues{GeoIPDatabase: "missing.mmdb"}},
+		cache.NewMemory(ctx, &config.Values{}),
+		logging.NewTest(t),
+	)
+	assert.Error(t, err)
+	assert.Nil(t, tr)
+}
+
+func TestTrack(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tr, err := New(
+		ctx,
+		&config.Values{Clicks: config.ClicksValues{BufferSize: 10, Workers: 2}},
+		cache.NewMemory(ctx, &config.Values{}),
+		logging.NewTest(t),
+	)
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		tr.Track(Click{Slug: "64fc5e", Time: time.Now()})
+	}
+	// The clicks are recorded in the background
+	assert.Eventually(t, func() bool {
+		stats, err := tr.Stats(ctx, "64fc5e")
+		return err == nil && stats.Total == 5
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTrack_BufferFull(t *testing.T) {
+	tr := newTestTracker(t)
+	tr.clicks = make(chan Click, 1)
+	tr.Track(Click{Slug: "64fc5e"})
+	// There are no workers, thus the second click does not block and it is dropped
+	tr.Track(Click{Slug: "64fc5e"})
+	assert.Len(t, tr.clicks, 1)
+}
+
+func TestWork_RecordError(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	defer mr.Close()
+	mr.SetError("mock error")
+	tr := newTestTracker(t)
+	tr.cache = c
+	tr.clicks = make(chan Click, 1)
+	tr.clicks <- Click{Slug: "64fc5e"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		tr.work(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return len(tr.clicks) == 0 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
+
+func TestStats(t *testing.T) {
+	tr := newTestTracker(t)
+	ctx := context.Background()
+	clicks := []Click{
+		{
+			Time:      time.Date(2026, 10, 17, 15, 10, 0, 0, time.UTC),
+			Referer:   "https://github.com/darioblanco",
+			UserAgent: "curl/7.79.1",
+			IP:        "81.2.69.142:54321",
+		},
+		{
+			Time:      time.Date(2026, 10, 17, 15, 20, 0, 0, time.UTC),
+			Referer:   "https://github.com/",
+			UserAgent: "curl/7.79.1",
+			IP:        "2001:db8::1",
+		},
+		{
+			Time:      time.Date(2026, 10, 17, 14, 59, 0, 0, time.UTC),
+			Referer:   "https://news.ycombinator.com/item?id=1",
+			UserAgent: "Mozilla/5.0",
+			IP:        "81.2.69.142",
+		},
+		{
+			// Out of the hourly series, but in the daily one
+			Time: time.Date(2026, 10, 10, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			// Out of both series
+			Time:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			RequestID: "host/abc-000005",
+		},
+	}
+	for _, click := range clicks {
+		click.Slug = "64fc5e"
+		assert.NoError(t, tr.record(ctx, click))
+	}
+
+	stats, err := tr.Stats(ctx, "64fc5e")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), stats.Total)
+	// The last recorded click
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), *stats.LastClickAt)
+	assert.Equal(t, "host/abc-000005", stats.LastRequestID)
+
+	assert.Len(t, stats.Hourly, hourlyBuckets)
+	assert.Equal(t, time.Date(2026, 10, 16, 16, 0, 0, 0, time.UTC), stats.Hourly[0].Time)
+	assert.Equal(t, Bucket{Time: time.Date(2026, 10, 17, 14, 0, 0, 0, time.UTC), Clicks: 1},
+		stats.Hourly[hourlyBuckets-2])
+	assert.Equal(t, Bucket{Time: time.Date(2026, 10, 17, 15, 0, 0, 0, time.UTC), Clicks: 2},
+		stats.Hourly[hourlyBuckets-1])
+
+	assert.Len(t, stats.Daily, dailyBuckets)
+	assert.Equal(t, time.Date(2026, 9, 18, 0, 0, 0, 0, time.UTC), stats.Daily[0].Time)
+	assert.Equal(t, Bucket{Time: time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC), Clicks: 1},
+		stats.Daily[dailyBuckets-8])
+	assert.Equal(t, Bucket{Time: time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), Clicks: 3},
+		stats.Daily[dailyBuckets-1])
+
+	assert.Equal(t, []cache.MemberCount{
+		{Member: "github.com", Count: 2},
+		{Member: "news.ycombinator.com", Count: 1},
+	}, stats.TopReferers)
+	assert.Equal(t, []cache.MemberCount{
+		{Member: "curl/7.79.1", Count: 2},
+		{Member: "Mozilla/5.0", Count: 1},
+	}, stats.TopUserAgents)
+	assert.Equal(t, []cache.MemberCount{
+		{Member: "GB", Count: 2},
+		{Member: "ES", Count: 1},
+	}, stats.TopCountries)
+}
+
+func TestStats_NeverClicked(t *testing.T) {
+	tr := newTestTracker(t)
+	stats, err := tr.Stats(context.Background(), "64fc5e")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Total)
+	assert.Nil(t, stats.LastClickAt)
+	assert.Empty(t, stats.LastRequestID)
+	assert.Len(t, stats.Hourly, hourlyBuckets)
+	assert.Len(t, stats.Daily, dailyBuckets)
+	assert.Empty(t, stats.TopReferers)
+}
+
+func TestStats_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		keys map[string]string
+	}{
+		{name: "invalid total", keys: map[string]string{"clicks:64fc5e": "invalid"}},
+		{name: "invalid last click", keys: map[string]string{
+			"clicks:64fc5e":      "1",
+			"clicks:64fc5e:last": "invalid",
+		}},
+		{name: "invalid last click record", keys: map[string]string{
+			"clicks:64fc5e":      "1",
+			"clicks:64fc5e:last": "{invalid",
+		}},
+		{name: "invalid bucket", keys: map[string]string{
+			"clicks:64fc5e":                      "1",
+			"clicks:64fc5e:hourly:2026-10-17T15": "invalid",
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTracker(t)
+			for key, value := range tt.keys {
+				assert.NoError(t, tr.cache.Set(context.Background(), key, value, 0))
+			}
+			stats, err := tr.Stats(context.Background(), "64fc5e")
+			assert.Error(t, err)
+			assert.Nil(t, stats)
+		})
+	}
+}
+
+func TestStats_LegacyLastClick(t *testing.T) {
+	tr := newTestTracker(t)
+	ctx := context.Background()
+	// Previous versions of the service only stored the time of the last click
+	assert.NoError(t, tr.cache.Set(ctx, "clicks:64fc5e", "1", 0))
+	assert.NoError(t, tr.cache.Set(ctx, "clicks:64fc5e:last", "2026-10-17T15:04:05Z", 0))
+	stats, err := tr.Stats(ctx, "64fc5e")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 17, 15, 4, 5, 0, time.UTC), *stats.LastClickAt)
+	assert.Empty(t, stats.LastRequestID)
+}
+
+func TestStats_CacheError(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	defer mr.Close()
+	tr := newTestTracker(t)
+	tr.cache = c
+	assert.NoError(t, tr.record(context.Background(), Click{Slug: "64fc5e", Time: time.Now()}))
+	mr.SetError("mock error")
+
+	stats, err := tr.Stats(context.Background(), "64fc5e")
+	assert.EqualError(t, err, "mock error")
+	assert.Nil(t, stats)
+}
+
+func TestDelete(t *testing.T) {
+	tr := newTestTracker(t)
+	ctx := context.Background()
+	assert.NoError(t, tr.record(ctx, Click{
+		Slug:      "64fc5e",
+		Time:      tr.now(),
+		Referer:   "https://github.com/darioblanco",
+		UserAgent: "curl/7.79.1",
+		IP:        "81.2.69.142",
+	}))
+	assert.NoError(t, tr.record(ctx, Click{Slug: "104b16", Time: tr.now()}))
+
+	assert.NoError(t, tr.Delete(ctx, "64fc5e"))
+	keys, _, err := tr.cache.Scan(ctx, "", "clicks:64fc5e*", 100)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+	// The stats of other slugs are kept
+	stats, err := tr.Stats(ctx, "104b16")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Total)
+}
+
+func TestRecord_Expiration(t *testing.T) {
+	tr := newTestTracker(t)
+	ctx := context.Background()
+	assert.NoError(t, tr.record(ctx, Click{
+		Slug:      "64fc5e",
+		Time:      tr.now(),
+		Referer:   "https://github.com/darioblanco",
+		UserAgent: "curl/7.79.1",
+		IP:        "81.2.69.142",
+	}))
+	// The buckets of the series expire once they are out of the stats
+	ttl, err := tr.cache.TTL(ctx, "clicks:64fc5e:hourly:2026-10-17T15")
+	assert.NoError(t, err)
+	assert.InDelta(t, hourlyRetention.Seconds(), ttl.Seconds(), 2)
+	ttl, err = tr.cache.TTL(ctx, "clicks:64fc5e:daily:2026-10-17")
+	assert.NoError(t, err)
+	assert.InDelta(t, dailyRetention.Seconds(), ttl.Seconds(), 2)
+	// The other stats expire once the slug is not clicked for the retention period
+	for _, key := range []string{
+		"clicks:64fc5e",
+		"clicks:64fc5e:last",
+		"clicks:64fc5e:referers",
+		"clicks:64fc5e:userAgents",
+		"clicks:64fc5e:countries",
+	} {
+		ttl, err = tr.cache.TTL(ctx, key)
+		assert.NoError(t, err)
+		assert.InDelta(t, statsRetention.Seconds(), ttl.Seconds(), 2, key)
+	}
+}
+
+func TestRecord_MaxMembers(t *testing.T) {
+	tr := newTestTracker(t)
+	ctx := context.Background()
+	for i := 0; i < maxMembers+10; i++ {
+		assert.NoError(t, tr.record(ctx, Click{
+			Slug:      "64fc5e",
+			Time:      tr.now(),
+			UserAgent: fmt.Sprintf("agent/%d", i),
+		}))
+	}
+	members, err := tr.cache.TopMembers(ctx, "clicks:64fc5e:userAgents", maxMembers+10)
+	assert.NoError(t, err)
+	assert.Len(t, members, maxMembers)
+}
+
+func TestDelete_CacheError(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	defer mr.Close()
+	mr.SetError("mock error")
+	tr := newTestTracker(t)
+	tr.cache = c
+	assert.EqualError(t, tr.Delete(context.Background(), "64fc5e"), "mock error")
+}
+
+func TestRecord_CacheError(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	defer mr.Close()
+	mr.SetError("mock error")
+	tr := newTestTracker(t)
+	tr.cache = c
+	assert.EqualError(t, tr.record(context.Background(), Click{Slug: "64fc5e"}), "mock error")
+}
+
+func TestRecord_LongUserAgent(t *testing.T) {
+	tr := newTestTracker(t)
+	ctx := context.Background()
+	userAgent := strings.Repeat("a", maxUserAgentLength+1)
+	assert.NoError(t, tr.record(ctx, Click{Slug: "64fc5e", UserAgent: userAgent}))
+	stats, err := tr.Stats(ctx, "64fc5e")
+	assert.NoError(t, err)
+	assert.Equal(t, []cache.MemberCount{
+		{Member: userAgent[:maxUserAgentLength], Count: 1},
+	}, stats.TopUserAgents)
+}
+
+func TestCountry(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  string
+		expected string
+	}{
+		{name: "ipv4", address: "81.2.69.142", expected: "GB"},
+		{name: "ipv4 with port", address: "81.2.69.142:54321", expected: "GB"},
+		{name: "ipv6 with port", address: "[2001:db8::1]:54321", expected: "ES"},
+		{name: "unknown ip", address: "127.0.0.1"},
+		{name: "invalid ip", address: "localhost"},
+		{name: "empty", address: ""},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, newTestTracker(t).country(tt.address))
+		})
+	}
+}
+
+func TestCountry_NoDatabase(t *testing.T) {
+	tr := newTestTracker(t)
+	tr.countries = nil
+	assert.Equal(t, "", tr.country("81.2.69.142"))
+}
+
+func TestRefererHost(t *testing.T) {
+	tests := []struct {
+		referer  string
+		expected string
+	}{
+		{referer: "https://github.com/darioblanco", expected: "github.com"},
+		{referer: "http://localhost:3000/docs", expected: "localhost"},
+		{referer: "android-app://com.slack/", expected: "com.slack"},
+		{referer: "github.com/darioblanco", expected: ""},
+		{referer: "", expected: ""},
+		{referer: "%", expected: ""},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.referer, func(t *testing.T) {
+			assert.Equal(t, tt.expected, refererHost(tt.referer))
+		})
+	}
+}
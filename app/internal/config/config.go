@@ -1,8 +1,12 @@
 package config
 
 import (
+	"reflect"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
@@ -11,45 +15,281 @@ const AppName = "shortesturl"
 
 // A Values struct that holds all the loaded configuration variables for the app
 type Values struct {
-	Environment          string
-	HttpHost             string
-	HttpPort             int
-	HttpScheme           string
-	IsDevelopment        bool
-	RedisHost            string
-	RedisPort            string
-	UrlLength            int
-	UrlExpirationInHours time.Duration
-	Version              string
-}
-
-// New loads config variables from file paths
+	Alias                        AliasValues
+	Auth                         AuthValues
+	Batch                        BatchValues
+	Canonicalize                 CanonicalizeValues
+	Clicks                       ClicksValues
+	Environment                  string
+	HttpHost                     string
+	HttpPort                     int
+	HttpScheme                   string
+	IsDevelopment                bool `mapstructure:"-"`
+	LogLevel                     string
+	Metrics                      MetricsValues
+	RateLimit                    RateLimitValues
+	Redis                        RedisValues
+	RedisHost                    string
+	RedisPort                    string
+	RedirectMaxAge               int
+	RedirectStatusCode           int
+	Safety                       SafetyValues
+	Slug                         SlugValues
+	Storage                      StorageValues
+	Tracing                      TracingValues
+	UrlLength                    int
+	UrlExpirationInHours         time.Duration
+	UrlMaxExpirationInHours      time.Duration
+	UrlTombstoneRetentionInHours time.Duration
+	Version                      string
+	WatchConfig                  bool
+	// live holds the reloadable values applied by a Watcher, being nil if the
+	// configuration is not watched
+	live *atomic.Value
+}
+
+// AliasValues holds the policy that custom (vanity) slugs have to follow
+type AliasValues struct {
+	MinLength int
+	MaxLength int
+	Pattern   string
+	Reserved  []string
+}
+
+// AuthValues configures the authentication of the API
+type AuthValues struct {
+	Enabled bool
+}
+
+// BatchValues configures the batch endpoints
+type BatchValues struct {
+	MaxSize int
+}
+
+// CanonicalizeValues configures how the long urls are normalized before they are shortened
+type CanonicalizeValues struct {
+	Enabled             bool
+	StripTrackingParams bool
+}
+
+// ClicksValues configures how the resolutions of the short urls are tracked
+type ClicksValues struct {
+	BufferSize    int
+	GeoIPDatabase string
+	Workers       int
+}
+
+// MetricsValues configures the Prometheus metrics endpoint
+type MetricsValues struct {
+	Enabled bool
+	Path    string
+}
+
+// RateLimitValues holds the request limits of each client (API key or IP address) per route
+type RateLimitValues struct {
+	Decode RateLimitRouteValues
+	Encode RateLimitRouteValues
+	Links  RateLimitRouteValues
+	QR     RateLimitRouteValues
+}
+
+// RateLimitRouteValues limits the requests of a route in a sliding window.
+// A zero limit means the route is not limited.
+type RateLimitRouteValues struct {
+	Limit           int
+	WindowInSeconds int
+}
+
+// RedisValues configures the client of the redis storage backend.
+// A master name connects through Sentinel, and more than one address connects to a Cluster.
+type RedisValues struct {
+	Addrs                      []string
+	DB                         int
+	DialTimeoutInMilliseconds  int
+	KeyPrefix                  string
+	MasterName                 string
+	Password                   string `secret:"true"`
+	PoolSize                   int
+	ReadTimeoutInMilliseconds  int
+	SentinelPassword           string `secret:"true"`
+	TLS                        RedisTLSValues
+	Username                   string
+	WriteTimeoutInMilliseconds int
+}
+
+// RedisTLSValues configures the TLS connections to redis
+type RedisTLSValues struct {
+	CAFile   string
+	CertFile string
+	Enabled  bool
+	KeyFile  string
+}
+
+// SafetyValues holds the policy that the destination (long) urls have to follow
+type SafetyValues struct {
+	AllowDomains                     []string
+	BlockPrivateNetworks             bool
+	BlocklistFile                    string
+	BlocklistReloadIntervalInSeconds int
+	DenyDomains                      []string
+	MaxURLLength                     int `mapstructure:"maxUrlLength"`
+}
+
+// SlugValues selects how the slugs of the short urls are generated
+type SlugValues struct {
+	Generator string
+	Secret    string `secret:"true"`
+}
+
+// StorageValues selects and configures the store backend of the cache
+type StorageValues struct {
+	Backend                  string
+	JanitorIntervalInSeconds int
+	MaxEntries               int
+	Path                     string
+	Shards                   int
+}
+
+// TracingValues configures the OpenTelemetry traces of the requests
+type TracingValues struct {
+	Enabled     bool
+	Endpoint    string
+	Exporter    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+// New loads config variables from file paths, returning an error that reports
+// every invalid value if the configuration does not pass its validation
 func New(configName string, configPaths ...string) (*Values, error) {
+	v := newViper(configName, configPaths...)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return validated(unmarshal(v))
+}
+
+// validated returns the loaded values if they pass their validation
+func validated(conf *Values, err error) (*Values, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// unmarshal decodes the config variables read by viper
+func unmarshal(v *viper.Viper) (*Values, error) {
+	var conf Values
+	if err := v.Unmarshal(&conf, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		hoursHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))); err != nil {
+		return nil, err
+	}
+	// Dynamic load of configuration variables
+	conf.IsDevelopment = conf.Environment == "dev"
+	return &conf, nil
+}
+
+// hoursHook decodes the plain numbers of the environment variables of the *InHours
+// durations, which are multiplied by an hour when they are read, as it happens with
+// the numbers of the config file. Strings with a unit (e.g. 1h) are left to the
+// duration hook.
+func hoursHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+	if n, err := strconv.ParseInt(data.(string), 10, 64); err == nil {
+		return time.Duration(n), nil
+	}
+	return data, nil
+}
+
+// newViper creates the viper instance that reads the config file and environment variables
+func newViper(configName string, configPaths ...string) *viper.Viper {
 	v := viper.New()
 	v.SetConfigName(configName)
-	v.SetConfigType("yaml")
 	v.SetEnvPrefix(AppName)
 	v.AutomaticEnv()
 	// Bind multi-word environment variables
+	v.BindEnv("alias.minLength", "SHORTESTURL_ALIAS_MIN_LENGTH")
+	v.BindEnv("alias.maxLength", "SHORTESTURL_ALIAS_MAX_LENGTH")
+	v.BindEnv("alias.pattern", "SHORTESTURL_ALIAS_PATTERN")
+	v.BindEnv("alias.reserved", "SHORTESTURL_ALIAS_RESERVED")
+	v.BindEnv("auth.enabled", "SHORTESTURL_AUTH_ENABLED")
+	v.BindEnv("batch.maxSize", "SHORTESTURL_BATCH_MAX_SIZE")
+	v.BindEnv("canonicalize.enabled", "SHORTESTURL_CANONICALIZE_ENABLED")
+	v.BindEnv("canonicalize.stripTrackingParams", "SHORTESTURL_CANONICALIZE_STRIP_TRACKING_PARAMS")
+	v.BindEnv("clicks.bufferSize", "SHORTESTURL_CLICKS_BUFFER_SIZE")
+	v.BindEnv("clicks.geoIPDatabase", "SHORTESTURL_CLICKS_GEOIP_DATABASE")
+	v.BindEnv("clicks.workers", "SHORTESTURL_CLICKS_WORKERS")
 	v.BindEnv("environment", "SHORTESTURL_ENVIRONMENT")
 	v.BindEnv("httpHost", "SHORTESTURL_HTTP_HOST")
 	v.BindEnv("httpPort", "SHORTESTURL_HTTP_PORT")
 	v.BindEnv("httpScheme", "SHORTESTURL_HTTP_SCHEME")
+	v.BindEnv("logLevel", "SHORTESTURL_LOG_LEVEL")
+	v.BindEnv("metrics.enabled", "SHORTESTURL_METRICS_ENABLED")
+	v.BindEnv("metrics.path", "SHORTESTURL_METRICS_PATH")
+	v.BindEnv("rateLimit.decode.limit", "SHORTESTURL_RATE_LIMIT_DECODE_LIMIT")
+	v.BindEnv("rateLimit.decode.windowInSeconds", "SHORTESTURL_RATE_LIMIT_DECODE_WINDOW_IN_SECONDS")
+	v.BindEnv("rateLimit.encode.limit", "SHORTESTURL_RATE_LIMIT_ENCODE_LIMIT")
+	v.BindEnv("rateLimit.encode.windowInSeconds", "SHORTESTURL_RATE_LIMIT_ENCODE_WINDOW_IN_SECONDS")
+	v.BindEnv("rateLimit.links.limit", "SHORTESTURL_RATE_LIMIT_LINKS_LIMIT")
+	v.BindEnv("rateLimit.links.windowInSeconds", "SHORTESTURL_RATE_LIMIT_LINKS_WINDOW_IN_SECONDS")
+	v.BindEnv("rateLimit.qr.limit", "SHORTESTURL_RATE_LIMIT_QR_LIMIT")
+	v.BindEnv("rateLimit.qr.windowInSeconds", "SHORTESTURL_RATE_LIMIT_QR_WINDOW_IN_SECONDS")
+	v.BindEnv("redis.addrs", "SHORTESTURL_REDIS_ADDRS")
+	v.BindEnv("redis.db", "SHORTESTURL_REDIS_DB")
+	v.BindEnv("redis.dialTimeoutInMilliseconds", "SHORTESTURL_REDIS_DIAL_TIMEOUT_IN_MILLISECONDS")
+	v.BindEnv("redis.keyPrefix", "SHORTESTURL_REDIS_KEY_PREFIX")
+	v.BindEnv("redis.masterName", "SHORTESTURL_REDIS_MASTER_NAME")
+	v.BindEnv("redis.password", "SHORTESTURL_REDIS_PASSWORD")
+	v.BindEnv("redis.poolSize", "SHORTESTURL_REDIS_POOL_SIZE")
+	v.BindEnv("redis.readTimeoutInMilliseconds", "SHORTESTURL_REDIS_READ_TIMEOUT_IN_MILLISECONDS")
+	v.BindEnv("redis.sentinelPassword", "SHORTESTURL_REDIS_SENTINEL_PASSWORD")
+	v.BindEnv("redis.tls.caFile", "SHORTESTURL_REDIS_TLS_CA_FILE")
+	v.BindEnv("redis.tls.certFile", "SHORTESTURL_REDIS_TLS_CERT_FILE")
+	v.BindEnv("redis.tls.enabled", "SHORTESTURL_REDIS_TLS_ENABLED")
+	v.BindEnv("redis.tls.keyFile", "SHORTESTURL_REDIS_TLS_KEY_FILE")
+	v.BindEnv("redis.username", "SHORTESTURL_REDIS_USERNAME")
+	v.BindEnv("redis.writeTimeoutInMilliseconds", "SHORTESTURL_REDIS_WRITE_TIMEOUT_IN_MILLISECONDS")
 	v.BindEnv("redisHost", "SHORTESTURL_REDIS_HOST")
 	v.BindEnv("redisPort", "SHORTESTURL_REDIS_PORT")
+	v.BindEnv("redirectMaxAge", "SHORTESTURL_REDIRECT_MAX_AGE")
+	v.BindEnv("redirectStatusCode", "SHORTESTURL_REDIRECT_STATUS_CODE")
+	v.BindEnv("safety.allowDomains", "SHORTESTURL_SAFETY_ALLOW_DOMAINS")
+	v.BindEnv("safety.blockPrivateNetworks", "SHORTESTURL_SAFETY_BLOCK_PRIVATE_NETWORKS")
+	v.BindEnv("safety.blocklistFile", "SHORTESTURL_SAFETY_BLOCKLIST_FILE")
+	v.BindEnv(
+		"safety.blocklistReloadIntervalInSeconds",
+		"SHORTESTURL_SAFETY_BLOCKLIST_RELOAD_INTERVAL_IN_SECONDS",
+	)
+	v.BindEnv("safety.denyDomains", "SHORTESTURL_SAFETY_DENY_DOMAINS")
+	v.BindEnv("safety.maxUrlLength", "SHORTESTURL_SAFETY_MAX_URL_LENGTH")
+	v.BindEnv("slug.generator", "SHORTESTURL_SLUG_GENERATOR")
+	v.BindEnv("slug.secret", "SHORTESTURL_SLUG_SECRET")
+	v.BindEnv("storage.backend", "SHORTESTURL_STORAGE_BACKEND")
+	v.BindEnv("storage.janitorIntervalInSeconds", "SHORTESTURL_STORAGE_JANITOR_INTERVAL_IN_SECONDS")
+	v.BindEnv("storage.maxEntries", "SHORTESTURL_STORAGE_MAX_ENTRIES")
+	v.BindEnv("storage.path", "SHORTESTURL_STORAGE_PATH")
+	v.BindEnv("storage.shards", "SHORTESTURL_STORAGE_SHARDS")
+	v.BindEnv("tracing.enabled", "SHORTESTURL_TRACING_ENABLED")
+	v.BindEnv("tracing.endpoint", "SHORTESTURL_TRACING_ENDPOINT")
+	v.BindEnv("tracing.exporter", "SHORTESTURL_TRACING_EXPORTER")
+	v.BindEnv("tracing.insecure", "SHORTESTURL_TRACING_INSECURE")
+	v.BindEnv("tracing.sampleRatio", "SHORTESTURL_TRACING_SAMPLE_RATIO")
 	v.BindEnv("urlLength", "SHORTESTURL_URL_LENGTH")
-	v.BindEnv("urlLength", "SHORTESTURL_URL_EXPIRATION_IN_HOURS")
+	v.BindEnv("urlExpirationInHours", "SHORTESTURL_URL_EXPIRATION_IN_HOURS")
+	v.BindEnv("urlMaxExpirationInHours", "SHORTESTURL_URL_MAX_EXPIRATION_IN_HOURS")
+	v.BindEnv("urlTombstoneRetentionInHours", "SHORTESTURL_URL_TOMBSTONE_RETENTION_IN_HOURS")
 	v.BindEnv("version", "SHORTESTURL_VERSION")
+	v.BindEnv("watchConfig", "SHORTESTURL_WATCH_CONFIG")
 	for _, path := range configPaths {
 		v.AddConfigPath(path)
 	}
-	if err := v.ReadInConfig(); err != nil {
-		return nil, err
-	} else {
-		var conf Values
-		err := v.Unmarshal(&conf)
-		// Dynamic load of configuration variables
-		conf.IsDevelopment = conf.Environment == "dev"
-		return &conf, err
-	}
+	return v
 }
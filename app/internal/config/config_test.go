@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -11,16 +12,98 @@ func TestNew(t *testing.T) {
 	assert.NoError(t, err)
 
 	assert.Equal(t, Values{
-		Environment:          "dev",
-		HttpHost:             "localhost",
-		HttpPort:             3000,
-		HttpScheme:           "http",
-		IsDevelopment:        true,
-		RedisHost:            "localhost",
-		RedisPort:            "6379",
-		UrlLength:            6,
-		UrlExpirationInHours: 0,
-		Version:              "unknown",
+		Alias: AliasValues{
+			MinLength: 4,
+			MaxLength: 64,
+			Pattern:   "^[a-zA-Z0-9_-]+$",
+			Reserved:  []string{"docs", "health", "encode", "decode", "links", "metrics"},
+		},
+		Auth: AuthValues{
+			Enabled: false,
+		},
+		Batch: BatchValues{
+			MaxSize: 1000,
+		},
+		Canonicalize: CanonicalizeValues{
+			Enabled:             true,
+			StripTrackingParams: false,
+		},
+		Clicks: ClicksValues{
+			BufferSize:    1024,
+			GeoIPDatabase: "",
+			Workers:       1,
+		},
+		Environment:   "dev",
+		HttpHost:      "localhost",
+		HttpPort:      3000,
+		HttpScheme:    "http",
+		IsDevelopment: true,
+		LogLevel:      "",
+		Metrics: MetricsValues{
+			Enabled: true,
+			Path:    "/metrics",
+		},
+		RateLimit: RateLimitValues{
+			Decode: RateLimitRouteValues{WindowInSeconds: 60},
+			Encode: RateLimitRouteValues{Limit: 60, WindowInSeconds: 60},
+			Links:  RateLimitRouteValues{WindowInSeconds: 60},
+			QR:     RateLimitRouteValues{Limit: 60, WindowInSeconds: 60},
+		},
+		Redis: RedisValues{
+			Addrs:                     []string{},
+			DB:                        0,
+			DialTimeoutInMilliseconds: 0,
+			KeyPrefix:                 "shortesturl",
+			MasterName:                "",
+			Password:                  "",
+			PoolSize:                  0,
+			ReadTimeoutInMilliseconds: 0,
+			SentinelPassword:          "",
+			TLS: RedisTLSValues{
+				CAFile:   "",
+				CertFile: "",
+				Enabled:  false,
+				KeyFile:  "",
+			},
+			Username:                   "",
+			WriteTimeoutInMilliseconds: 0,
+		},
+		RedisHost:          "localhost",
+		RedisPort:          "6379",
+		RedirectMaxAge:     0,
+		RedirectStatusCode: 302,
+		Safety: SafetyValues{
+			AllowDomains:                     []string{},
+			BlockPrivateNetworks:             true,
+			BlocklistFile:                    "",
+			BlocklistReloadIntervalInSeconds: 60,
+			DenyDomains:                      []string{},
+			MaxURLLength:                     2048,
+		},
+		Slug: SlugValues{
+			Generator: "md5",
+			Secret:    "",
+		},
+		Storage: StorageValues{
+			Backend:                  "",
+			JanitorIntervalInSeconds: 60,
+			MaxEntries:               0,
+			Path:                     "shortesturl.db",
+			Shards:                   16,
+		},
+		Tracing: TracingValues{
+			Enabled:     false,
+			Endpoint:    "localhost:4318",
+			Exporter:    "otlp",
+			Insecure:    true,
+			SampleRatio: 1,
+		},
+		UrlLength:                    6,
+		UrlExpirationInHours:         0,
+		UrlMaxExpirationInHours:      0,
+		UrlTombstoneRetentionInHours: 720,
+		Version:                      "unknown",
+		WatchConfig:                  false,
 	}, *conf)
 }
 
@@ -29,3 +112,24 @@ func TestNew_ReadError(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, conf)
 }
+
+func TestNew_Env(t *testing.T) {
+	t.Setenv("SHORTESTURL_URL_LENGTH", "8")
+	t.Setenv("SHORTESTURL_URL_EXPIRATION_IN_HOURS", "24")
+	t.Setenv("SHORTESTURL_LOG_LEVEL", "warn")
+	conf, err := New("config.default", "../../../cmd")
+	assert.NoError(t, err)
+	assert.Equal(t, 8, conf.UrlLength)
+	assert.Equal(t, time.Duration(24), conf.UrlExpirationInHours)
+	assert.Equal(t, "warn", conf.LogLevel)
+}
+
+func TestNew_ValidationError(t *testing.T) {
+	t.Setenv("SHORTESTURL_URL_LENGTH", "40")
+	t.Setenv("SHORTESTURL_HTTP_PORT", "0")
+	conf, err := New("config.default", "../../../cmd")
+	assert.EqualError(t, err, "invalid configuration: "+
+		"httpPort must be between 1 and 65535 (got 0); "+
+		"urlLength must be between 1 and 32 for the md5 slug generator (got 40)")
+	assert.Nil(t, conf)
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Redacted replaces the values of the secrets in the output of Values.Redacted
+const Redacted = "[REDACTED]"
+
+// durationType is the type of the durations, which are printed as a number of hours
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Redacted returns the values keyed by their config keys, so they can be printed.
+// The fields tagged as secret are replaced with Redacted if they are set.
+func (c *Values) Redacted() map[string]interface{} {
+	return redact(reflect.ValueOf(*c))
+}
+
+// redact returns the config keys of the fields of the given struct and their values
+func redact(v reflect.Value) map[string]interface{} {
+	values := make(map[string]interface{}, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := field.Tag.Get("mapstructure")
+		if field.PkgPath != "" || key == "-" {
+			// Unexported and derived values are not part of the config files
+			continue
+		}
+		if key == "" {
+			key = configKey(field.Name)
+		}
+		value := v.Field(i)
+		switch {
+		case field.Tag.Get("secret") == "true" && !value.IsZero():
+			values[key] = Redacted
+		case value.Kind() == reflect.Struct:
+			values[key] = redact(value)
+		case value.Type() == durationType:
+			values[key] = value.Int()
+		default:
+			values[key] = value.Interface()
+		}
+	}
+	return values
+}
+
+// configKey returns the config key of a field, which lowers the case of its first
+// word (e.g. UrlLength is urlLength, and CAFile is caFile)
+func configKey(name string) string {
+	runes := []rune(name)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	if upper > 1 && upper < len(runes) {
+		// The last upper case letter starts the next word
+		upper--
+	}
+	return strings.ToLower(string(runes[:upper])) + string(runes[upper:])
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValues_Redacted(t *testing.T) {
+	conf := Values{
+		HttpPort:      3000,
+		IsDevelopment: true,
+		Redis: RedisValues{
+			Addrs:    []string{"localhost:6379"},
+			Password: "password",
+			TLS:      RedisTLSValues{CAFile: "ca.pem"},
+		},
+		Safety:                  SafetyValues{MaxURLLength: 2048},
+		Slug:                    SlugValues{Generator: "counter", Secret: "secret"},
+		UrlMaxExpirationInHours: 24,
+	}
+	redacted := conf.Redacted()
+
+	assert.Equal(t, 3000, redacted["httpPort"])
+	assert.NotContains(t, redacted, "isDevelopment")
+	assert.NotContains(t, redacted, "live")
+	assert.Equal(t, int64(24), redacted["urlMaxExpirationInHours"])
+	redis := redacted["redis"].(map[string]interface{})
+	assert.Equal(t, []string{"localhost:6379"}, redis["addrs"])
+	assert.Equal(t, Redacted, redis["password"])
+	// The secrets that are not set are printed as they are
+	assert.Equal(t, "", redis["sentinelPassword"])
+	assert.Equal(t, map[string]interface{}{
+		"caFile": "ca.pem", "certFile": "", "enabled": false, "keyFile": "",
+	}, redis["tls"])
+	assert.Equal(t, 2048, redacted["safety"].(map[string]interface{})["maxUrlLength"])
+	assert.Equal(t, map[string]interface{}{
+		"generator": "counter", "secret": Redacted,
+	}, redacted["slug"])
+}
+
+func TestConfigKey(t *testing.T) {
+	tests := map[string]string{
+		"UrlLength":     "urlLength",
+		"HttpPort":      "httpPort",
+		"DB":            "db",
+		"TLS":           "tls",
+		"CAFile":        "caFile",
+		"GeoIPDatabase": "geoIPDatabase",
+	}
+	for name, expected := range tests {
+		assert.Equal(t, expected, configKey(name), name)
+	}
+}
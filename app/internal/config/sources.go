@@ -0,0 +1,184 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultConfigName is the name (without extension) of the default config file
+const DefaultConfigName = "config.default"
+
+// configExtensions are the supported formats of the config files
+var configExtensions = []string{"yaml", "yml", "json", "toml"}
+
+// Sources locates the layers of a configuration, which are merged in this order,
+// each one overriding the previous ones:
+//
+//  1. The default file (config.default.*) of the config directory
+//  2. The file of the environment (e.g. config.prod.*) of the config directory, if it exists
+//  3. The config file, if any
+//  4. The environment variables
+//  5. The overrides
+//
+// The files can be written in YAML, JSON or TOML, as told by their extension.
+type Sources struct {
+	// Dir is the config directory. If empty, the cmd folder of the working directory,
+	// the cmd folder of the executable and the folder of the executable are tried.
+	Dir string
+	// File is a config file overlaid on top of the ones of the config directory
+	File string
+	// Overrides are key=value pairs (e.g. rateLimit.encode.limit=10) that take
+	// precedence over the rest of the layers
+	Overrides []string
+}
+
+// RegisterFlags defines the flags that locate the configuration in the given flag set,
+// returning the sources that they fill once it is parsed
+func RegisterFlags(fs *flag.FlagSet) *Sources {
+	sources := &Sources{}
+	fs.StringVar(&sources.Dir, "config-dir", "",
+		"the directory of the default and environment config files (default: cmd)")
+	fs.StringVar(&sources.File, "config", "",
+		"a config file (yaml, json or toml) overlaid on top of the ones of the config directory")
+	fs.Var((*overrides)(&sources.Overrides), "set",
+		"a key=value pair that overrides the config files and environment variables (repeatable)")
+	return sources
+}
+
+// overrides collects the values of a repeated flag
+type overrides []string
+
+func (o *overrides) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *overrides) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// Load loads the configuration of the sources, returning an error that reports
+// every invalid value if it does not pass its validation
+func Load(sources Sources) (*Values, error) {
+	conf, _, err := sources.load()
+	return validated(conf, err)
+}
+
+// load merges the layers of the sources without validating them, returning the
+// config files that were read
+func (s Sources) load() (*Values, []string, error) {
+	dir, defaultFile, err := s.defaultFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	settings := map[string]interface{}{}
+	if err := mergeConfigFile(settings, defaultFile); err != nil {
+		return nil, nil, err
+	}
+	files := []string{defaultFile}
+	// The environment can be set by the environment variables too
+	v := newViper(DefaultConfigName)
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, nil, err
+	}
+	if environment := v.GetString("environment"); environment != "" {
+		if file := findConfigFile(dir, "config."+environment); file != "" {
+			files = append(files, file)
+		}
+	}
+	if s.File != "" {
+		files = append(files, s.File)
+	}
+	for _, file := range files[1:] {
+		if err := mergeConfigFile(settings, file); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	v = newViper(DefaultConfigName)
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, nil, err
+	}
+	for _, override := range s.Overrides {
+		pair := strings.SplitN(override, "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			return nil, nil, fmt.Errorf("invalid config override %q (expected key=value)", override)
+		}
+		v.Set(pair[0], pair[1])
+	}
+	conf, err := unmarshal(v)
+	return conf, files, err
+}
+
+// defaultFile returns the config directory and its default config file, looking for
+// them in the candidate directories if the directory is not set
+func (s Sources) defaultFile() (string, string, error) {
+	candidates := []string{s.Dir}
+	if s.Dir == "" {
+		candidates = []string{"cmd"}
+		if executable, err := os.Executable(); err == nil {
+			candidates = append(candidates,
+				filepath.Join(filepath.Dir(executable), "cmd"),
+				filepath.Dir(executable),
+			)
+		}
+	}
+	for _, dir := range candidates {
+		if file := findConfigFile(dir, DefaultConfigName); file != "" {
+			return dir, file, nil
+		}
+	}
+	return "", "", fmt.Errorf(
+		"unable to find the %s config file in %s", DefaultConfigName, strings.Join(candidates, ", "),
+	)
+}
+
+// mergeConfigFile reads the given config file, overriding the settings with its values.
+// The files are not merged by viper, as it keeps the previous value when the types of
+// both differ, which is common between formats (e.g. the numbers of JSON are floats).
+func mergeConfigFile(settings map[string]interface{}, file string) error {
+	v := newFileViper(file)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("unable to read config file %s: %w", file, err)
+	}
+	mergeSettings(settings, v.AllSettings())
+	return nil
+}
+
+// mergeSettings overrides the settings with the given ones, merging the nested ones
+func mergeSettings(settings map[string]interface{}, overrides map[string]interface{}) {
+	for key, value := range overrides {
+		nested, ok := value.(map[string]interface{})
+		current, currentOk := settings[key].(map[string]interface{})
+		if ok && currentOk {
+			mergeSettings(current, nested)
+			continue
+		}
+		settings[key] = value
+	}
+}
+
+// findConfigFile returns the path of the config file with the given name in the directory,
+// being empty if there is no file with a supported extension
+func findConfigFile(dir string, name string) string {
+	for _, extension := range configExtensions {
+		path := filepath.Join(dir, name+"."+extension)
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+	}
+	return ""
+}
+
+// newFileViper creates the viper instance that reads a single config file
+func newFileViper(file string) *viper.Viper {
+	v := viper.New()
+	v.SetConfigFile(file)
+	return v
+}
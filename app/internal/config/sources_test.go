@@ -0,0 +1,158 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile writes the given content in the file of the directory, returning its path
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	conf, err := Load(Sources{Dir: "../../../cmd"})
+	require.NoError(t, err)
+	expected, err := New(DefaultConfigName, "../../../cmd")
+	require.NoError(t, err)
+	assert.Equal(t, expected, conf)
+}
+
+func TestLoad_Layers(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.default.yaml", `
+environment: stage
+httpHost: default
+httpPort: 3000
+httpScheme: http
+urlLength: 6
+redirectMaxAge: 60
+rateLimit:
+  encode:
+    limit: 10
+    windowInSeconds: 60
+`)
+	writeFile(t, dir, "config.stage.json", `{
+  "httpHost": "stage",
+  "httpPort": 4000,
+  "rateLimit": {"encode": {"limit": 20}}
+}`)
+	file := writeFile(t, t.TempDir(), "overlay.toml", `
+httpPort = 5000
+urlLength = 7
+
+[slug]
+generator = "sha256"
+`)
+	t.Setenv("SHORTESTURL_URL_LENGTH", "8")
+	t.Setenv("SHORTESTURL_REDIRECT_MAX_AGE", "120")
+
+	conf, err := Load(Sources{
+		Dir:       dir,
+		File:      file,
+		Overrides: []string{"urlLength=9", "alias.reserved=docs,health"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "stage", conf.Environment)
+	// The environment file overrides the default one, keeping its missing values
+	assert.Equal(t, "stage", conf.HttpHost)
+	assert.Equal(t, RateLimitRouteValues{Limit: 20, WindowInSeconds: 60}, conf.RateLimit.Encode)
+	// The config file overrides the files of the directory
+	assert.Equal(t, 5000, conf.HttpPort)
+	assert.Equal(t, "sha256", conf.Slug.Generator)
+	// The environment variables override the files
+	assert.Equal(t, 120, conf.RedirectMaxAge)
+	// The overrides take precedence over everything
+	assert.Equal(t, 9, conf.UrlLength)
+	assert.Equal(t, []string{"docs", "health"}, conf.Alias.Reserved)
+}
+
+func TestLoad_EnvironmentFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.default.yaml", "httpPort: 3000\nhttpScheme: http\nurlLength: 6\n")
+	writeFile(t, dir, "config.prod.yml", "urlLength: 10\nurlExpirationInHours: 24\n")
+	t.Setenv("SHORTESTURL_ENVIRONMENT", "prod")
+	conf, err := Load(Sources{Dir: dir})
+	require.NoError(t, err)
+	assert.Equal(t, 10, conf.UrlLength)
+	assert.Equal(t, time.Duration(24), conf.UrlExpirationInHours)
+}
+
+func TestLoad_Error(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.default.yaml", "httpPort: 3000\nhttpScheme: http\nurlLength: 6\n")
+	invalid := writeFile(t, dir, "invalid.json", "{")
+	tests := []struct {
+		name          string
+		sources       Sources
+		expectedError string
+	}{
+		{
+			"missing directory",
+			Sources{Dir: filepath.Join(dir, "missing")},
+			"unable to find the config.default config file in " + filepath.Join(dir, "missing"),
+		},
+		{
+			"missing file",
+			Sources{Dir: dir, File: filepath.Join(dir, "missing.yaml")},
+			"unable to read config file " + filepath.Join(dir, "missing.yaml") + ": " +
+				"open " + filepath.Join(dir, "missing.yaml") + ": no such file or directory",
+		},
+		{
+			"invalid file",
+			Sources{Dir: dir, File: invalid},
+			"unable to read config file " + invalid + ": " +
+				"While parsing config: unexpected end of JSON input",
+		},
+		{
+			"invalid override",
+			Sources{Dir: dir, Overrides: []string{"urlLength"}},
+			`invalid config override "urlLength" (expected key=value)`,
+		},
+		{
+			"invalid values",
+			Sources{Dir: dir, Overrides: []string{"urlLength=0"}},
+			"invalid configuration: " +
+				"urlLength must be between 1 and 32 for the md5 slug generator (got 0)",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := Load(tt.sources)
+			assert.EqualError(t, err, tt.expectedError)
+			assert.Nil(t, conf)
+		})
+	}
+}
+
+func TestLoad_DefaultDirNotFound(t *testing.T) {
+	_, err := Load(Sources{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to find the config.default config file in cmd, ")
+}
+
+func TestRegisterFlags(t *testing.T) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	sources := RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{
+		"--config-dir", "/etc/shortesturl",
+		"--config", "overlay.toml",
+		"--set", "urlLength=8",
+		"--set", "logLevel=warn",
+	}))
+	assert.Equal(t, &Sources{
+		Dir:       "/etc/shortesturl",
+		File:      "overlay.toml",
+		Overrides: []string{"urlLength=8", "logLevel=warn"},
+	}, sources)
+	assert.Equal(t, "urlLength=8,logLevel=warn", fs.Lookup("set").Value.String())
+}
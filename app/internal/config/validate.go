@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// AliasSeparators are the characters that an alias can never contain, whatever its pattern,
+// as they namespace the keys of the service (:) and split the paths of the short urls (/ and .)
+const AliasSeparators = ":/."
+
+// slugMaxLengths are the maximum url lengths of the slug generators, being zero if the
+// generator has no maximum. They mirror the digest widths of the slug package.
+var slugMaxLengths = map[string]int{
+	"md5":     32,
+	"sha256":  43,
+	"xxhash":  11,
+	"random":  0,
+	"counter": 0,
+}
+
+// A ValidationError reports every invalid value of a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// validator collects the problems of the validated values
+type validator struct {
+	problems []string
+}
+
+// check records the given problem if the condition is not met
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.problems = append(v.problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// nonNegative records a problem if the given value of the key is negative
+func (v *validator) nonNegative(key string, value int64) {
+	v.check(value >= 0, "%s must not be negative (got %d)", key, value)
+}
+
+// oneOf records a problem if the given value of the key is not one of the allowed ones
+func (v *validator) oneOf(key string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.check(false, "%s %q is invalid (allowed: %s)", key, value, strings.Join(allowed, ", "))
+}
+
+// Validate checks that the values can be used by the service, returning
+// a ValidationError that reports all of their problems at once
+func (c *Values) Validate() error {
+	v := &validator{}
+
+	v.nonNegative("alias.minLength", int64(c.Alias.MinLength))
+	v.check(c.Alias.MaxLength >= c.Alias.MinLength,
+		"alias.maxLength must be at least alias.minLength %d (got %d)",
+		c.Alias.MinLength, c.Alias.MaxLength)
+	if re, err := regexp.Compile(c.Alias.Pattern); err != nil {
+		v.check(false, "alias.pattern is invalid: %v", err)
+	} else {
+		parsed, _ := syntax.Parse(re.String(), syntax.Perl)
+		v.check(!matchesSeparator(parsed),
+			"alias.pattern must not match any of %q (got %q)", AliasSeparators, c.Alias.Pattern)
+	}
+	v.nonNegative("batch.maxSize", int64(c.Batch.MaxSize))
+	v.nonNegative("clicks.bufferSize", int64(c.Clicks.BufferSize))
+	v.nonNegative("clicks.workers", int64(c.Clicks.Workers))
+	v.check(c.HttpPort >= 1 && c.HttpPort <= 65535,
+		"httpPort must be between 1 and 65535 (got %d)", c.HttpPort)
+	v.oneOf("httpScheme", c.HttpScheme, "http", "https")
+	v.oneOf("logLevel", c.LogLevel, "", "debug", "info", "warn", "error")
+	if c.Metrics.Enabled {
+		v.check(strings.HasPrefix(c.Metrics.Path, "/"),
+			"metrics.path must start with / (got %q)", c.Metrics.Path)
+	}
+	for _, route := range []struct {
+		name   string
+		limits RateLimitRouteValues
+	}{
+		{"decode", c.RateLimit.Decode},
+		{"encode", c.RateLimit.Encode},
+		{"links", c.RateLimit.Links},
+		{"qr", c.RateLimit.QR},
+	} {
+		v.nonNegative("rateLimit."+route.name+".limit", int64(route.limits.Limit))
+		v.nonNegative(
+			"rateLimit."+route.name+".windowInSeconds", int64(route.limits.WindowInSeconds),
+		)
+	}
+	v.nonNegative("redirectMaxAge", int64(c.RedirectMaxAge))
+	switch c.RedirectStatusCode {
+	case 0, 301, 302, 307, 308:
+	default:
+		v.check(false, "redirectStatusCode %d is invalid (allowed: 301, 302, 307, 308)",
+			c.RedirectStatusCode)
+	}
+	v.nonNegative("safety.blocklistReloadIntervalInSeconds",
+		int64(c.Safety.BlocklistReloadIntervalInSeconds))
+	v.nonNegative("safety.maxUrlLength", int64(c.Safety.MaxURLLength))
+	generator := c.Slug.Generator
+	if generator == "" {
+		generator = "md5"
+	}
+	maxLength, ok := slugMaxLengths[generator]
+	v.check(ok, "slug.generator %q is invalid (allowed: md5, sha256, xxhash, random, counter)",
+		c.Slug.Generator)
+	v.check(generator != "counter" || c.Slug.Secret != "",
+		"slug.secret is required by the counter slug generator")
+	v.oneOf("storage.backend", c.Storage.Backend, "", "bolt", "memory", "miniredis", "redis")
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1,
+		"tracing.sampleRatio must be between 0 and 1 (got %g)", c.Tracing.SampleRatio)
+	if c.Tracing.Enabled {
+		v.oneOf("tracing.exporter", c.Tracing.Exporter, "otlp", "stdout")
+	}
+	if maxLength > 0 {
+		v.check(c.UrlLength >= 1 && c.UrlLength <= maxLength,
+			"urlLength must be between 1 and %d for the %s slug generator (got %d)",
+			maxLength, generator, c.UrlLength)
+	} else {
+		v.check(c.UrlLength >= 1, "urlLength must be at least 1 (got %d)", c.UrlLength)
+	}
+	v.nonNegative("urlExpirationInHours", int64(c.UrlExpirationInHours))
+	v.nonNegative("urlMaxExpirationInHours", int64(c.UrlMaxExpirationInHours))
+	v.nonNegative("urlTombstoneRetentionInHours", int64(c.UrlTombstoneRetentionInHours))
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// matchesSeparator reports whether the given regular expression can match an alias separator.
+// It is conservative, as any wildcard, literal or class of the expression with a separator counts.
+func matchesSeparator(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return true
+	case syntax.OpLiteral:
+		if strings.ContainsAny(string(re.Rune), AliasSeparators) {
+			return true
+		}
+	case syntax.OpCharClass:
+		// The class is a list of inclusive ranges
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for _, sep := range AliasSeparators {
+				if re.Rune[i] <= sep && sep <= re.Rune[i+1] {
+					return true
+				}
+			}
+		}
+	}
+	for _, sub := range re.Sub {
+		if matchesSeparator(sub) {
+			return true
+		}
+	}
+	return false
+}
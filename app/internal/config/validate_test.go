@@ -0,0 +1,184 @@
+package config
+
+import (
+	"regexp/syntax"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// validValues returns the minimal values that pass the validation
+func validValues() Values {
+	return Values{HttpPort: 3000, HttpScheme: "http", UrlLength: 6}
+}
+
+func TestValidate(t *testing.T) {
+	conf, err := New("config.default", "../../../cmd")
+	assert.NoError(t, err)
+	assert.NoError(t, conf.Validate())
+	valid := validValues()
+	assert.NoError(t, valid.Validate())
+}
+
+func TestValidate_Invalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		update        func(c *Values)
+		expectedError string
+	}{
+		{
+			"alias",
+			func(c *Values) {
+				c.Alias = AliasValues{MinLength: 8, MaxLength: 4, Pattern: "[a-"}
+			},
+			"invalid configuration: " +
+				"alias.maxLength must be at least alias.minLength 8 (got 4); " +
+				"alias.pattern is invalid: error parsing regexp: " +
+				"missing closing ]: `[a-`",
+		},
+		{
+			"alias separators",
+			func(c *Values) { c.Alias.Pattern = "^[a-z:]+$" },
+			"invalid configuration: " +
+				`alias.pattern must not match any of ":/." (got "^[a-z:]+$")`,
+		},
+		{
+			"http",
+			func(c *Values) {
+				c.HttpPort = 70000
+				c.HttpScheme = "ftp"
+			},
+			"invalid configuration: " +
+				"httpPort must be between 1 and 65535 (got 70000); " +
+				`httpScheme "ftp" is invalid (allowed: http, https)`,
+		},
+		{
+			"log level",
+			func(c *Values) { c.LogLevel = "verbose" },
+			"invalid configuration: " +
+				`logLevel "verbose" is invalid (allowed: , debug, info, warn, error)`,
+		},
+		{
+			"metrics",
+			func(c *Values) { c.Metrics = MetricsValues{Enabled: true, Path: "metrics"} },
+			"invalid configuration: " +
+				`metrics.path must start with / (got "metrics")`,
+		},
+		{
+			"rate limit",
+			func(c *Values) {
+				c.RateLimit.Encode = RateLimitRouteValues{Limit: -1, WindowInSeconds: -60}
+			},
+			"invalid configuration: " +
+				"rateLimit.encode.limit must not be negative (got -1); " +
+				"rateLimit.encode.windowInSeconds must not be negative (got -60)",
+		},
+		{
+			"redirect",
+			func(c *Values) { c.RedirectStatusCode = 303 },
+			"invalid configuration: " +
+				"redirectStatusCode 303 is invalid (allowed: 301, 302, 307, 308)",
+		},
+		{
+			"slug generator",
+			func(c *Values) { c.Slug.Generator = "sha1" },
+			"invalid configuration: " +
+				`slug.generator "sha1" is invalid (allowed: md5, sha256, xxhash, random, counter)`,
+		},
+		{
+			"counter secret",
+			func(c *Values) { c.Slug.Generator = "counter" },
+			"invalid configuration: " +
+				"slug.secret is required by the counter slug generator",
+		},
+		{
+			"storage",
+			func(c *Values) { c.Storage.Backend = "postgres" },
+			"invalid configuration: " +
+				`storage.backend "postgres" is invalid ` +
+				"(allowed: , bolt, memory, miniredis, redis)",
+		},
+		{
+			"tracing",
+			func(c *Values) {
+				c.Tracing = TracingValues{Enabled: true, Exporter: "jaeger", SampleRatio: 2}
+			},
+			"invalid configuration: " +
+				"tracing.sampleRatio must be between 0 and 1 (got 2); " +
+				`tracing.exporter "jaeger" is invalid (allowed: otlp, stdout)`,
+		},
+		{
+			"url length zero",
+			func(c *Values) { c.UrlLength = 0 },
+			"invalid configuration: " +
+				"urlLength must be between 1 and 32 for the md5 slug generator (got 0)",
+		},
+		{
+			"url length of generator",
+			func(c *Values) {
+				c.Slug.Generator = "xxhash"
+				c.UrlLength = 12
+			},
+			"invalid configuration: " +
+				"urlLength must be between 1 and 11 for the xxhash slug generator (got 12)",
+		},
+		{
+			"url length without maximum",
+			func(c *Values) {
+				c.Slug.Generator = "random"
+				c.UrlLength = -1
+			},
+			"invalid configuration: urlLength must be at least 1 (got -1)",
+		},
+		{
+			"expirations",
+			func(c *Values) {
+				c.UrlExpirationInHours = -1
+				c.UrlMaxExpirationInHours = -2
+				c.UrlTombstoneRetentionInHours = -3
+			},
+			"invalid configuration: " +
+				"urlExpirationInHours must not be negative (got -1); " +
+				"urlMaxExpirationInHours must not be negative (got -2); " +
+				"urlTombstoneRetentionInHours must not be negative (got -3)",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			conf := validValues()
+			tt.update(&conf)
+			err := conf.Validate()
+			assert.EqualError(t, err, tt.expectedError)
+			assert.IsType(t, &ValidationError{}, err)
+		})
+	}
+}
+
+func TestMatchesSeparator(t *testing.T) {
+	t.Parallel()
+	tests := map[string]bool{
+		"":                 false,
+		"^[a-zA-Z0-9_-]+$": false,
+		"^(?i)[a-z]+$":     false,
+		"^launch-[0-9]+$":  false,
+		"^[a-z:]+$":        true,
+		"^[!-~]+$":         true,
+		"^[^a-z]+$":        true,
+		"^.+$":             true,
+		"^a/b$":            true,
+		`^v\.[0-9]$`:       true,
+		"^(a|b.c)$":        true,
+	}
+	for pattern, expected := range tests {
+		pattern, expected := pattern, expected // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(pattern, func(t *testing.T) {
+			t.Parallel()
+			re, err := syntax.Parse(pattern, syntax.Perl)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, matchesSeparator(re))
+		})
+	}
+}
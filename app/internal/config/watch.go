@@ -0,0 +1,148 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ReloadableValues are the values that can be changed while the service runs.
+// The rest of the values are only read once, so changing them requires a restart.
+type ReloadableValues struct {
+	LogLevel                string
+	RateLimit               RateLimitValues
+	UrlExpirationInHours    time.Duration
+	UrlLength               int
+	UrlMaxExpirationInHours time.Duration
+}
+
+// Reloadable returns the reloadable values that are currently applied, which are the
+// ones of the last reload if the configuration is watched
+func (c *Values) Reloadable() ReloadableValues {
+	if c.live != nil {
+		return c.live.Load().(ReloadableValues)
+	}
+	return ReloadableValues{
+		LogLevel:                c.LogLevel,
+		RateLimit:               c.RateLimit,
+		UrlExpirationInHours:    c.UrlExpirationInHours,
+		UrlLength:               c.UrlLength,
+		UrlMaxExpirationInHours: c.UrlMaxExpirationInHours,
+	}
+}
+
+// apply replaces the reloadable values with the given ones
+func (c *Values) apply(reloadable ReloadableValues) {
+	c.LogLevel = reloadable.LogLevel
+	c.RateLimit = reloadable.RateLimit
+	c.UrlExpirationInHours = reloadable.UrlExpirationInHours
+	c.UrlLength = reloadable.UrlLength
+	c.UrlMaxExpirationInHours = reloadable.UrlMaxExpirationInHours
+}
+
+// A Watcher reloads the configuration while the service runs, swapping atomically
+// the reloadable values of the Values it returns
+type Watcher struct {
+	// files are the config files of the sources, which are watched for changes
+	files []string
+	// mu serializes the reloads
+	mu      sync.Mutex
+	sources Sources
+	values  *Values
+}
+
+// NewWatcher loads the configuration of the sources like Load, keeping it ready
+// to be reloaded
+func NewWatcher(sources Sources) (*Watcher, error) {
+	conf, files, err := sources.load()
+	if conf, err = validated(conf, err); err != nil {
+		return nil, err
+	}
+	live := &atomic.Value{}
+	live.Store(conf.Reloadable())
+	conf.live = live
+	return &Watcher{
+		files:   files,
+		sources: sources,
+		values:  conf,
+	}, nil
+}
+
+// Files returns the config files that were merged in the watched configuration
+func (w *Watcher) Files() []string {
+	return w.files
+}
+
+// Values returns the watched configuration. Its reloadable values have to be read
+// with Reloadable, as the fields keep the values loaded at startup.
+func (w *Watcher) Values() *Values {
+	return w.values
+}
+
+// Reload reads the config variables again and applies their reloadable values.
+// The configuration that results from the reload is validated as a whole, and the
+// current values are kept if it is invalid.
+func (w *Watcher) Reload() (ReloadableValues, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	loaded, _, err := w.sources.load()
+	if err != nil {
+		return w.values.Reloadable(), err
+	}
+	reloaded := *w.values
+	reloaded.live = nil
+	reloaded.apply(loaded.Reloadable())
+	if err := reloaded.Validate(); err != nil {
+		return w.values.Reloadable(), err
+	}
+	w.values.live.Store(reloaded.Reloadable())
+	return reloaded.Reloadable(), nil
+}
+
+// Watch reloads the configuration every time the process receives a SIGHUP and,
+// if WatchConfig is enabled, every time one of its config files changes. The outcome of
+// each reload is given to the callback. It blocks until the context is done.
+func (w *Watcher) Watch(ctx context.Context, onReload func(ReloadableValues, error)) error {
+	reloads := make(chan struct{}, 1)
+	trigger := func() {
+		select {
+		case reloads <- struct{}{}:
+		default:
+			// A reload is already pending
+		}
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	defer signal.Stop(sig)
+
+	if w.values.WatchConfig {
+		// The viper instances are only used to be notified of the changes, each reload
+		// reads the files again so the watcher goroutines of viper are never raced
+		for _, file := range w.files {
+			v := newFileViper(file)
+			if err := v.ReadInConfig(); err != nil {
+				return err
+			}
+			v.OnConfigChange(func(fsnotify.Event) { trigger() })
+			v.WatchConfig()
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sig:
+			trigger()
+		case <-reloads:
+			onReload(w.Reload())
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a valid config file, with the given extra values, in the given directory
+func writeConfig(t *testing.T, dir string, extra string) {
+	content := "httpPort: 3000\nhttpScheme: http\nslug:\n  generator: xxhash\n" + extra
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.default.yaml"), []byte(content), 0600))
+}
+
+func TestValues_Reloadable(t *testing.T) {
+	conf := Values{
+		LogLevel:                "info",
+		RateLimit:               RateLimitValues{Encode: RateLimitRouteValues{Limit: 10}},
+		UrlExpirationInHours:    1,
+		UrlLength:               6,
+		UrlMaxExpirationInHours: 2,
+	}
+	assert.Equal(t, ReloadableValues{
+		LogLevel:                "info",
+		RateLimit:               RateLimitValues{Encode: RateLimitRouteValues{Limit: 10}},
+		UrlExpirationInHours:    1,
+		UrlLength:               6,
+		UrlMaxExpirationInHours: 2,
+	}, conf.Reloadable())
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "urlLength: 6\n")
+	watcher, err := NewWatcher(Sources{Dir: dir})
+	require.NoError(t, err)
+	conf := watcher.Values()
+	assert.Equal(t, 6, conf.Reloadable().UrlLength)
+
+	writeConfig(t, dir, "urlLength: 8\nurlExpirationInHours: 24\nlogLevel: warn\n"+
+		"rateLimit:\n  encode:\n    limit: 5\nhttpPort: 4000\n")
+	reloadable, err := watcher.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, ReloadableValues{
+		LogLevel:             "warn",
+		RateLimit:            RateLimitValues{Encode: RateLimitRouteValues{Limit: 5}},
+		UrlExpirationInHours: 24,
+		UrlLength:            8,
+	}, reloadable)
+	assert.Equal(t, reloadable, conf.Reloadable())
+	// The values that are not reloadable keep the ones loaded at startup
+	assert.Equal(t, 3000, conf.HttpPort)
+	assert.Equal(t, 6, conf.UrlLength)
+}
+
+func TestWatcher_ReloadInvalid(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "urlLength: 6\n")
+	watcher, err := NewWatcher(Sources{Dir: dir})
+	require.NoError(t, err)
+
+	// The url length is validated against the slug generator that is running,
+	// even if the file changes it too
+	writeConfig(t, dir, "urlLength: 20\nslug:\n  generator: md5\n")
+	reloadable, err := watcher.Reload()
+	assert.EqualError(t, err, "invalid configuration: "+
+		"urlLength must be between 1 and 11 for the xxhash slug generator (got 20)")
+	assert.Equal(t, 6, reloadable.UrlLength)
+	assert.Equal(t, 6, watcher.Values().Reloadable().UrlLength)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "config.default.yaml")))
+	_, err = watcher.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, 6, watcher.Values().Reloadable().UrlLength)
+}
+
+func TestNewWatcher_Error(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "urlLength: 0\n")
+	watcher, err := NewWatcher(Sources{Dir: dir})
+	assert.EqualError(t, err, "invalid configuration: "+
+		"urlLength must be between 1 and 11 for the xxhash slug generator (got 0)")
+	assert.Nil(t, watcher)
+}
+
+// watch runs the watcher until the test ends, returning the channel of its reloads
+func watch(t *testing.T, watcher *Watcher) <-chan ReloadableValues {
+	ctx, cancel := context.WithCancel(context.Background())
+	reloads := make(chan ReloadableValues, 10)
+	done := make(chan error)
+	go func() {
+		done <- watcher.Watch(ctx, func(reloadable ReloadableValues, err error) {
+			assert.NoError(t, err)
+			reloads <- reloadable
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	return reloads
+}
+
+func TestWatcher_WatchSignal(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "urlLength: 6\n")
+	watcher, err := NewWatcher(Sources{Dir: dir})
+	require.NoError(t, err)
+	// The process is not terminated by the signals sent before the watcher listens to them
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	defer signal.Stop(sig)
+	reloads := watch(t, watcher)
+
+	writeConfig(t, dir, "urlLength: 7\n")
+	assert.Eventually(t, func() bool {
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+		select {
+		case reloadable := <-reloads:
+			return reloadable.UrlLength == 7
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, 20*time.Millisecond)
+	assert.Equal(t, 7, watcher.Values().Reloadable().UrlLength)
+}
+
+func TestWatcher_WatchFile(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "urlLength: 6\nwatchConfig: true\n")
+	watcher, err := NewWatcher(Sources{Dir: dir})
+	require.NoError(t, err)
+	reloads := watch(t, watcher)
+
+	assert.Eventually(t, func() bool {
+		writeConfig(t, dir, "urlLength: 9\nwatchConfig: true\n")
+		select {
+		case reloadable := <-reloads:
+			return reloadable.UrlLength == 9
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, 20*time.Millisecond)
+	assert.Equal(t, 9, watcher.Values().Reloadable().UrlLength)
+}
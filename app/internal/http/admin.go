@@ -0,0 +1,173 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+)
+
+// adminScanCount is the page size of the scans of the admin
+const adminScanCount = 1000
+
+var (
+	// ErrLinkNotFound is returned by the admin when the slug never had a link
+	ErrLinkNotFound = errors.New("link not found")
+	// ErrLinkExpired is returned by the admin when the link of the slug expired or it was deleted
+	ErrLinkExpired = errors.New("link expired")
+)
+
+// An Admin manages the short urls in the store directly, without serving the API.
+// It follows the same rules as the API, but it can manage the links of every API key.
+type Admin struct {
+	rs api
+}
+
+// NewAdmin creates an admin with the services of the given configuration
+func NewAdmin(
+	ctx context.Context, conf *config.Values, logger logging.Logger, cache cache.Cache,
+) (*Admin, error) {
+	rs, err := newAPI(ctx, conf, logger, cache)
+	if err != nil {
+		return nil, err
+	}
+	return &Admin{rs: rs}, nil
+}
+
+// Create shortens the long url of the given request like /encode, returning its link
+func (a *Admin) Create(ctx context.Context, data *URLPayload) (*Link, error) {
+	data.canonicalizer = a.rs.canonicalizer
+	if err := data.Bind(nil); err != nil {
+		return nil, err
+	}
+	if err := a.rs.destinations.Check(&data.ParsedURL); err != nil {
+		return nil, err
+	}
+	if data.Alias != "" {
+		if err := a.rs.aliases.Validate(data.Alias); err != nil {
+			return nil, err
+		}
+	}
+	slug, _, err := a.rs.encode(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return a.Get(ctx, slug)
+}
+
+// Get returns the link of the given slug, failing with ErrLinkNotFound or
+// ErrLinkExpired if it does not exist
+func (a *Admin) Get(ctx context.Context, slug string) (*Link, error) {
+	if slug == "" || !cache.IsLinkKey(slug) {
+		return nil, ErrLinkNotFound
+	}
+	link, err := a.rs.getLink(ctx, slug)
+	if err != nil || link != nil {
+		return link, err
+	}
+	expired, err := a.rs.isExpired(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, ErrLinkExpired
+	}
+	return nil, ErrLinkNotFound
+}
+
+// Delete removes the link of the given slug and its click stats like DELETE /links/{slug}
+func (a *Admin) Delete(ctx context.Context, slug string) error {
+	link, err := a.Get(ctx, slug)
+	if err != nil {
+		return err
+	}
+	return a.rs.deleteLink(ctx, link)
+}
+
+// List calls the given function with every link whose slug starts with the given prefix,
+// stopping at the first error. Like GET /links, a link can be listed more than once.
+func (a *Admin) List(ctx context.Context, prefix string, fn func(*Link) error) error {
+	cursor := ""
+	for {
+		keys, next, err := a.rs.cache.Scan(ctx, cursor, escapeGlob(prefix)+"*", adminScanCount)
+		if err != nil {
+			return err
+		}
+		links, err := a.rs.pageLinks(ctx, keys)
+		if err != nil {
+			return err
+		}
+		for _, link := range links {
+			if err := fn(link); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Expire changes the expiration of the link of the given slug, which never expires if the
+// expiration is zero. Unlike the API, the expiration is not capped by the configuration.
+func (a *Admin) Expire(ctx context.Context, slug string, expiration time.Duration) (*Link, error) {
+	if expiration < 0 {
+		return nil, errors.New("the expiration must not be negative")
+	}
+	link, err := a.Get(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	stored := *link.stored
+	stored.Version = cache.LinkVersion
+	stored.ExpiresAt = nil
+	if expiration > 0 {
+		expiresAt := time.Now().Add(expiration).UTC().Round(time.Second)
+		stored.ExpiresAt = &expiresAt
+	}
+	success, err := a.rs.cache.UpdateLink(ctx, slug, &stored, expiration)
+	if err != nil {
+		return nil, err
+	}
+	if !success {
+		// The link expired after it was read
+		return nil, ErrLinkExpired
+	}
+	// The tombstone follows the changed expiration of the link
+	if _, err := a.rs.storeTombstone(ctx, slug); err != nil {
+		return nil, err
+	}
+	link = a.rs.newLink(slug, &stored)
+	a.rs.logger.Info("Changed link expiration", "slug", slug, "expiresAt", link.ExpiresAt)
+	return link, nil
+}
+
+// Stats returns the click stats of the link of the given slug
+func (a *Admin) Stats(ctx context.Context, slug string) (*LinkStats, error) {
+	link, err := a.Get(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := a.rs.clicks.Stats(ctx, link.Slug)
+	if err != nil {
+		return nil, err
+	}
+	return newLinkStats(link.Slug, stats), nil
+}
+
+// escapeGlob escapes the special characters of the glob-style patterns of Scan
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,223 @@
+package http
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/clicks"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAdmin(t *testing.T, c cache.Cache) *Admin {
+	admin, err := NewAdmin(
+		context.Background(),
+		&config.Values{
+			Alias:                        config.AliasValues{MinLength: 4, MaxLength: 64},
+			HttpScheme:                   "http",
+			HttpHost:                     "localhost",
+			HttpPort:                     80,
+			Safety:                       config.SafetyValues{BlockPrivateNetworks: true},
+			UrlLength:                    6,
+			UrlTombstoneRetentionInHours: 1,
+		},
+		logging.NewTest(t),
+		c,
+	)
+	require.NoError(t, err)
+	return admin
+}
+
+func TestNewAdmin_Error(t *testing.T) {
+	_, err := NewAdmin(
+		context.Background(),
+		&config.Values{RedirectStatusCode: 200},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	assert.Error(t, err)
+}
+
+func TestAdmin_Create(t *testing.T) {
+	ctx := context.Background()
+	admin := newTestAdmin(t, cache.NewTest())
+
+	link, err := admin.Create(ctx, &URLPayload{
+		URL:       "https://github.com/darioblanco",
+		Title:     "Dario Blanco",
+		ExpiresIn: "1h",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "64fc5e", link.Slug)
+	assert.Equal(t, "http://localhost/64fc5e", link.ShortURL)
+	assert.Equal(t, "Dario Blanco", link.Title)
+	require.NotNil(t, link.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *link.ExpiresAt, 2*time.Second)
+
+	// The existing short url is reused, like in the API
+	again, err := admin.Create(ctx, &URLPayload{URL: "https://github.com/darioblanco"})
+	require.NoError(t, err)
+	assert.Equal(t, link.Slug, again.Slug)
+
+	alias, err := admin.Create(ctx, &URLPayload{
+		URL:   "https://darioblanco.com",
+		Alias: "dario-blanco",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "dario-blanco", alias.Slug)
+
+	tests := map[string]struct {
+		payload  *URLPayload
+		expected string
+	}{
+		"invalid url": {
+			payload:  &URLPayload{URL: "darioblanco.com"},
+			expected: "invalid http/https url format",
+		},
+		"unsafe url": {
+			payload:  &URLPayload{URL: "http://127.0.0.1/admin"},
+			expected: "not a public address",
+		},
+		"invalid alias": {
+			payload:  &URLPayload{URL: "https://darioblanco.com", Alias: "a"},
+			expected: "alias",
+		},
+		"alias in use": {
+			payload:  &URLPayload{URL: "https://github.com", Alias: "dario-blanco"},
+			expected: "alias is already in use",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(name, func(t *testing.T) {
+			_, err := admin.Create(ctx, tt.payload)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestAdmin_Get(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewTest()
+	admin := newTestAdmin(t, c)
+	created, err := admin.Create(ctx, &URLPayload{URL: "https://github.com/darioblanco"})
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, tombstoneKey("expired"), "2026-01-01T00:00:00Z", 0))
+
+	link, err := admin.Get(ctx, created.Slug)
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/darioblanco", link.URL)
+
+	_, err = admin.Get(ctx, "expired")
+	assert.ErrorIs(t, err, ErrLinkExpired)
+	_, err = admin.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrLinkNotFound)
+	// Auxiliary keys are not links
+	_, err = admin.Get(ctx, tombstoneKey("expired"))
+	assert.ErrorIs(t, err, ErrLinkNotFound)
+}
+
+func TestAdmin_Delete(t *testing.T) {
+	ctx := context.Background()
+	admin := newTestAdmin(t, cache.NewTest())
+	created, err := admin.Create(ctx, &URLPayload{URL: "https://github.com/darioblanco"})
+	require.NoError(t, err)
+
+	require.NoError(t, admin.Delete(ctx, created.Slug))
+	_, err = admin.Get(ctx, created.Slug)
+	assert.ErrorIs(t, err, ErrLinkExpired)
+	assert.ErrorIs(t, admin.Delete(ctx, "missing"), ErrLinkNotFound)
+}
+
+func TestAdmin_List(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewTest()
+	admin := newTestAdmin(t, c)
+	for _, alias := range []string{"docs", "docs-api", "blog"} {
+		_, err := admin.Create(ctx, &URLPayload{
+			URL:   "https://darioblanco.com/" + alias,
+			Alias: alias,
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, c.Set(ctx, tombstoneKey("docs-old"), "2026-01-01T00:00:00Z", 0))
+
+	tests := map[string]struct {
+		prefix   string
+		expected []string
+	}{
+		"all":          {prefix: "", expected: []string{"blog", "docs", "docs-api"}},
+		"prefix":       {prefix: "docs", expected: []string{"docs", "docs-api"}},
+		"glob pattern": {prefix: "*", expected: nil},
+		"no match":     {prefix: "shop", expected: nil},
+	}
+	for name, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(name, func(t *testing.T) {
+			var slugs []string
+			require.NoError(t, admin.List(ctx, tt.prefix, func(link *Link) error {
+				slugs = append(slugs, link.Slug)
+				return nil
+			}))
+			assert.ElementsMatch(t, tt.expected, slugs)
+		})
+	}
+}
+
+func TestAdmin_Expire(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewTest()
+	admin := newTestAdmin(t, c)
+	created, err := admin.Create(ctx, &URLPayload{URL: "https://github.com/darioblanco"})
+	require.NoError(t, err)
+	assert.Nil(t, created.ExpiresAt)
+
+	link, err := admin.Expire(ctx, created.Slug, 48*time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, link.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), *link.ExpiresAt, 2*time.Second)
+	ttl, err := c.TTL(ctx, created.Slug)
+	require.NoError(t, err)
+	assert.InDelta(t, (48 * time.Hour).Seconds(), ttl.Seconds(), 2)
+	ttl, err = c.TTL(ctx, tombstoneKey(created.Slug))
+	require.NoError(t, err)
+	assert.InDelta(t, (49 * time.Hour).Seconds(), ttl.Seconds(), 2)
+
+	link, err = admin.Expire(ctx, created.Slug, 0)
+	require.NoError(t, err)
+	assert.Nil(t, link.ExpiresAt)
+	ttl, err = c.TTL(ctx, created.Slug)
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+
+	_, err = admin.Expire(ctx, created.Slug, -time.Hour)
+	assert.Error(t, err)
+	_, err = admin.Expire(ctx, "missing", time.Hour)
+	assert.ErrorIs(t, err, ErrLinkNotFound)
+}
+
+func TestAdmin_Stats(t *testing.T) {
+	ctx := context.Background()
+	admin := newTestAdmin(t, cache.NewTest())
+	created, err := admin.Create(ctx, &URLPayload{URL: "https://github.com/darioblanco"})
+	require.NoError(t, err)
+	// The clicks are recorded in the background
+	admin.rs.clicks.Track(clicks.Click{Slug: created.Slug, Time: time.Now()})
+	assert.Eventually(t, func() bool {
+		stats, err := admin.Stats(ctx, created.Slug)
+		return err == nil && stats.Slug == created.Slug && stats.Total == 1
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = admin.Stats(ctx, "missing")
+	assert.ErrorIs(t, err, ErrLinkNotFound)
+}
+
+func TestEscapeGlob(t *testing.T) {
+	assert.Equal(t, "docs", escapeGlob("docs"))
+	assert.Equal(t, `a\*b\?\[c\]\\`, escapeGlob(`a*b?[c]\`))
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+)
+
+// defaultAliasPattern is used when the configuration does not define a pattern,
+// as an alias is part of the short url path it should never contain separators
+const defaultAliasPattern = "^[a-zA-Z0-9_-]+$"
+
+// An aliasPolicy validates the custom slugs (vanity urls) requested by clients
+type aliasPolicy struct {
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp
+	reserved  map[string]bool
+}
+
+// newAliasPolicy creates an alias policy from the application config
+func newAliasPolicy(conf config.AliasValues) (*aliasPolicy, error) {
+	pattern := conf.Pattern
+	if pattern == "" {
+		pattern = defaultAliasPattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alias pattern: %w", err)
+	}
+	reserved := make(map[string]bool, len(conf.Reserved))
+	for _, word := range conf.Reserved {
+		reserved[strings.ToLower(word)] = true
+	}
+	return &aliasPolicy{
+		minLength: conf.MinLength,
+		maxLength: conf.MaxLength,
+		pattern:   re,
+		reserved:  reserved,
+	}, nil
+}
+
+// Validate returns an error, that can be shown to the end user, if the alias
+// does not fulfill the policy
+func (p *aliasPolicy) Validate(alias string) error {
+	if len(alias) < p.minLength {
+		return fmt.Errorf("alias must have at least %d characters", p.minLength)
+	}
+	if p.maxLength > 0 && len(alias) > p.maxLength {
+		return fmt.Errorf("alias must have at most %d characters", p.maxLength)
+	}
+	// The separators are rejected even if the pattern allows them, as they would collide with
+	// the keys of the service and the routes of the short urls
+	if strings.ContainsAny(alias, config.AliasSeparators) {
+		return fmt.Errorf("alias must not contain any of %q", config.AliasSeparators)
+	}
+	if !p.pattern.MatchString(alias) {
+		return fmt.Errorf("alias must match the pattern %s", p.pattern.String())
+	}
+	if p.reserved[strings.ToLower(alias)] {
+		return fmt.Errorf("alias %q is reserved", alias)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAliasPolicy(t *testing.T) {
+	p, err := newAliasPolicy(config.AliasValues{
+		MinLength: 4,
+		MaxLength: 8,
+		Reserved:  []string{"Docs"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, p.minLength)
+	assert.Equal(t, 8, p.maxLength)
+	assert.Equal(t, defaultAliasPattern, p.pattern.String())
+	assert.Equal(t, map[string]bool{"docs": true}, p.reserved)
+}
+
+func TestNewAliasPolicy_InvalidPattern(t *testing.T) {
+	p, err := newAliasPolicy(config.AliasValues{Pattern: "^[a-z"})
+	assert.Error(t, err)
+	assert.Nil(t, p)
+}
+
+func TestAliasPolicy_Validate(t *testing.T) {
+	p, _ := newAliasPolicy(config.AliasValues{
+		MinLength: 4,
+		MaxLength: 12,
+		Pattern:   "^[a-z0-9-]+$",
+		Reserved:  []string{"docs", "encode"},
+	})
+	t.Parallel()
+	tests := []struct {
+		alias         string
+		expectedError string
+	}{
+		{"launch-2026", ""},
+		{"abc", "alias must have at least 4 characters"},
+		{"launch-2026-extended", "alias must have at most 12 characters"},
+		{"Launch", "alias must match the pattern ^[a-z0-9-]+$"},
+		{"with/slash", `alias must not contain any of ":/."`},
+		{"docs", "alias \"docs\" is reserved"},
+		{"url:1a2b3c", `alias must not contain any of ":/."`},
+		{"with.dot", `alias must not contain any of ":/."`},
+		{"encode", "alias \"encode\" is reserved"},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.alias, func(t *testing.T) {
+			t.Parallel()
+			err := p.Validate(tt.alias)
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedError)
+			}
+		})
+	}
+}
@@ -1,89 +1,327 @@
 package http
 
 import (
-	"crypto/md5"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
+	"github.com/darioblanco/shortesturl/app/internal/auth"
 	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/clicks"
 	"github.com/darioblanco/shortesturl/app/internal/config"
 	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/darioblanco/shortesturl/app/internal/metrics"
+	"github.com/darioblanco/shortesturl/app/internal/qr"
+	"github.com/darioblanco/shortesturl/app/internal/ratelimit"
+	"github.com/darioblanco/shortesturl/app/internal/safety"
+	"github.com/darioblanco/shortesturl/app/internal/slug"
+	"github.com/darioblanco/shortesturl/app/internal/tracing"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// maxEncodeAttempts is the maximum number of slug candidates generated for a
+// single url before giving up, as a safeguard against a saturated keyspace
+const maxEncodeAttempts = 100
+
 type api struct {
-	cache  cache.Cache
-	config *config.Values
+	aliases *aliasPolicy
+	cache   cache.Cache
+	// canonicalizer rewrites the long urls in their canonical form, being nil if it is disabled
+	canonicalizer *urlCanonicalizer
+	clicks        clicks.Tracker
+	config        *config.Values
+	// destinations is the safety policy that the long urls have to follow
+	destinations *safety.Policy
+	// keys validates the API keys of the callers, being nil if authentication is disabled
+	keys   auth.KeyStore
 	logger logging.Logger
+	// qrCodes keeps the most recently rendered QR codes
+	qrCodes *qr.Cache
+	slugs   slug.Generator
 }
 
 func (rs api) Router() chi.Router {
 	r := chi.NewRouter()
 
-	r.Post("/encode", rs.Encode)
-	r.Post("/decode", rs.Decode)
+	r.Group(func(r chi.Router) {
+		if rs.keys != nil {
+			r.Use(AuthMW(rs.keys, rs.logger))
+		}
+		// The limits are checked after the authentication, so they apply per API key
+		r.With(rs.rateLimit("encode")).Post("/encode", rs.Encode)
+		r.With(rs.rateLimit("decode")).Post("/decode", rs.Decode)
+		// The batches share the limits of the single url routes, each url counting as a request
+		r.With(rs.rateLimit("encode")).Post("/encode/batch", rs.EncodeBatch)
+		r.With(rs.rateLimit("decode")).Post("/decode/batch", rs.DecodeBatch)
+		r.Route("/links", func(r chi.Router) {
+			r.Use(rs.rateLimit("links"))
+			r.Get("/", rs.ListLinks)
+			r.Get("/{slug}", rs.GetLink)
+			r.Patch("/{slug}", rs.UpdateLink)
+			r.Delete("/{slug}", rs.DeleteLink)
+			r.Get("/{slug}/stats", rs.Stats)
+		})
+	})
+	// Short urls are public
+	r.Get("/{slug}", rs.Redirect)
+	r.Head("/{slug}", rs.Redirect)
+	// The QR codes are public too, but they are limited as rendering them is expensive
+	r.With(rs.rateLimit("qr")).Get("/{slug}/qr", rs.QR)
+	r.With(rs.rateLimit("qr")).Head("/{slug}/qr", rs.QR)
 
 	return r
 }
 
+// rateLimit returns the middleware that limits the requests of each client in the given
+// route (encode, decode, links or qr), following the reloads of the configuration
+func (rs api) rateLimit(route string) func(next http.Handler) http.Handler {
+	limiter := ratelimit.NewReloadable(rs.cache, route, func() config.RateLimitRouteValues {
+		limits := rs.config.Reloadable().RateLimit
+		switch route {
+		case "decode":
+			return limits.Decode
+		case "links":
+			return limits.Links
+		case "qr":
+			return limits.QR
+		}
+		return limits.Encode
+	})
+	return RateLimitMW(limiter, rs.logger)
+}
+
 // Encode
 // @Summary Encodes a URL to a shortened URL
-// @Description Shorten a given URL, which can be decoded later using /decode
+// @Description Shorten a given URL, which can be decoded later using /decode.
+// @Description A custom alias can be requested instead of the generated slug.
+// @Description The existing short URL of the long URL is returned, unless reuse is false.
+// @Description The expiration can be set with either expiresIn or expiresAt, and it is capped by the server.
+// @Description The QR code of the short URL is included as a data URI if qr is png or svg.
 // @ID encode
 // @Tags Shortener
 // @Accept json
 // @Produce json
-// @Param url body LongURL true "The url to encode"
+// @Security ApiKeyAuth
+// @Param url body EncodeRequest true "The url to encode"
 // @Success 200 {object} ShortURL "Long URL encoded successfully"
-// @Failure 400 {object} BadRequest "Long URL has a wrong format"
+// @Failure 400 {object} BadRequest "Long URL or alias have a wrong format"
+// @Failure 401 {object} Unauthorized "Missing or invalid API key (if authentication is enabled)"
+// @Failure 429 {object} TooManyRequests "Rate limit exceeded (if the route is limited)"
+// @Failure 409 {object} Conflict "Alias is already used by a different long URL"
+// @Failure 422 {object} UnprocessableEntity "Long URL is not allowed by the safety policy"
 // @Failure 500 {object} InternalServerError "Unexpected error in the backend"
 // @Router /encode [post]
 func (rs api) Encode(w http.ResponseWriter, r *http.Request) {
-	data := &URLPayload{}
+	data := &URLPayload{canonicalizer: rs.canonicalizer}
 	if err := render.Bind(r, data); err != nil {
-		rs.logger.Warn("long URL has a wrong format", "error", err)
+		rs.log(r.Context()).Warn("long URL has a wrong format", "error", err)
 		render.Render(w, r, ErrBadRequest(err))
 		return
 	}
 
-	// Generate MD5 from url
-	md5Url := fmt.Sprintf("%x", md5.Sum([]byte(data.URL)))
-	rs.logger.Debug("MD5 encoded url",
-		"url", data.URL,
-		"MD5", md5Url,
-	)
+	if errResponse := rs.checkDestination(r.Context(), &data.ParsedURL); errResponse != nil {
+		render.Render(w, r, errResponse)
+		return
+	}
 
-	// Shorten URL to the length defined in the application config
-	success := false
-	attempts := 0
-	var err error
-	var shortURLSlug string
 	ctx := r.Context()
-	for !success {
-		shortURLSlug = md5Url[attempts : rs.config.UrlLength+attempts]
-		// We could also send metrics to trigger a threshold if attempts get out of hand
-		rs.logger.Debug("Attempting to store shortened url",
-			"attempts", attempts,
-			"shortUrlSlug", shortURLSlug,
-		)
-		// If success is false, it indicates a collision and the md5 has to be shifted
-		success, err = rs.cache.SetIfNotExists(
-			ctx,
-			shortURLSlug,
-			data.URL,
-			time.Hour*rs.config.UrlExpirationInHours,
-		)
-		if err != nil {
-			rs.logger.Error("unable to retrieve/store shortened url in cache", "error", err)
+	if data.Alias != "" {
+		if err := rs.aliases.Validate(data.Alias); err != nil {
+			rs.log(ctx).Warn("alias has a wrong format", "alias", data.Alias, "error", err)
+			render.Render(w, r, ErrBadRequest(err))
+			return
+		}
+	}
+	shortURLSlug, expiresAt, err := rs.encode(ctx, data)
+	if errors.Is(err, errAliasInUse) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	response := &URLPayload{URL: rs.shortURL(shortURLSlug), ExpiresAt: expiresAt}
+	if data.QR != "" {
+		if response.QRCode, err = qrDataURI(response.URL, data.QR); err != nil {
+			rs.log(ctx).Error("unable to render QR code", "error", err)
 			render.Render(w, r, ErrInternalServerError(err))
 			return
 		}
-		attempts += 1
 	}
+	render.Render(w, r, response)
+}
+
+// errAliasInUse is returned by encode when the requested alias holds a different long url
+var errAliasInUse = errors.New("alias is already in use")
+
+// encode stores the link of the given encode request, whose url and alias are already
+// validated, returning its slug and its expiration date (nil if it never expires)
+func (rs api) encode(ctx context.Context, data *URLPayload) (string, *time.Time, error) {
+	expiration := rs.expiration(data.Expiration)
+	link := rs.encodedLink(ctx, data, expiration)
+	var shortURLSlug string
+	// indexed is true if the slug was found in the reverse index
+	indexed := false
+	if data.Alias != "" {
+		// The alias is not shifted in case of collision, as the client explicitly requested it
+		success, err := rs.cache.SetLinkIfNotExists(ctx, data.Alias, link, expiration)
+		if err != nil {
+			rs.log(ctx).Error("unable to retrieve/store alias in cache", "error", err)
+			return "", nil, err
+		}
+		if !success {
+			rs.log(ctx).Warn("alias is already in use", "alias", data.Alias)
+			return "", nil, errAliasInUse
+		}
+		shortURLSlug = data.Alias
+	} else {
+		var err error
+		if data.reusable() {
+			// The url might have been shortened with a slug that the generator does not
+			// return anymore (e.g. after a collision or with the random generator)
+			shortURLSlug, err = rs.indexedSlug(ctx, link)
+			if err != nil {
+				rs.log(ctx).Error("unable to retrieve shortened url from index", "error", err)
+				return "", nil, err
+			}
+			indexed = shortURLSlug != ""
+		}
+		// Shorten URL with the slug generator defined in the application config
+		success := indexed
+		for attempts := 0; !success; attempts++ {
+			if attempts == maxEncodeAttempts {
+				metrics.EncodeAttempts.Observe(float64(attempts))
+				err = fmt.Errorf("no free slug found after %d attempts", attempts)
+				rs.log(ctx).Error("unable to generate shortened url", "error", err)
+				return "", nil, err
+			}
+			// If success is false, it indicates a collision and a new candidate is needed
+			shortURLSlug, success, err = rs.encodeAttempt(ctx, data, link, expiration, attempts)
+			if err != nil {
+				return "", nil, err
+			}
+			if success {
+				// A high number of attempts reveals a keyspace that is getting saturated
+				metrics.EncodeAttempts.Observe(float64(attempts + 1))
+			}
+		}
+	}
+	// If the url was already shortened, its original expiration is kept
+	expiresAt, err := rs.storeTombstone(ctx, shortURLSlug)
+	if err != nil {
+		rs.log(ctx).Error("unable to store tombstone of shortened url in cache", "error", err)
+		return "", nil, err
+	}
+	if data.reusable() && !indexed {
+		entry := urlIndex(link, shortURLSlug, expiresAt)
+		if err := rs.cache.Set(ctx, entry.Key, entry.Value, entry.Expiration); err != nil {
+			rs.log(ctx).Error("unable to store shortened url in index", "error", err)
+			return "", nil, err
+		}
+	}
+	rs.log(ctx).Info("Encoded url",
+		"longUrl", data.URL,
+		"shortUrl", rs.shortURL(shortURLSlug),
+		"expiresAt", expiresAt,
+	)
+	return shortURLSlug, expiresAt, nil
+}
+
+// checkDestination returns the error to render if the given long url is not allowed
+// by the safety policy, being nil if it is allowed
+func (rs api) checkDestination(ctx context.Context, u *url.URL) render.Renderer {
+	err := rs.destinations.Check(u)
+	if err == nil {
+		return nil
+	}
+	var violation *safety.Violation
+	if !errors.As(err, &violation) {
+		rs.log(ctx).Error("unable to check long url safety", "error", err)
+		return ErrInternalServerError(err)
+	}
+	rs.log(ctx).Warn("long url is not allowed", "longUrl", u.String(), "code", violation.Code)
+	return ErrUnprocessableEntity(violation, violation.Code)
+}
+
+// encodeAttempt generates the slug candidate of the given attempt and stores the link in it,
+// returning false if it collides with a different link. Each attempt is traced in its own
+// span, so the collisions of a slow encode can be told apart from the latency of the cache.
+func (rs api) encodeAttempt(
+	ctx context.Context, data *URLPayload, link *cache.Link, expiration time.Duration, attempts int,
+) (slug string, success bool, err error) {
+	ctx, span := tracing.Start(ctx, "encode.attempt",
+		trace.WithAttributes(attribute.Int("attempt", attempts)))
+	defer func() {
+		span.SetAttributes(attribute.String("slug", slug), attribute.Bool("collision", !success))
+		tracing.End(span, err)
+	}()
+	slug, err = rs.slugs.Generate(ctx, data.URL, attempts)
+	if err != nil {
+		rs.log(ctx).Error("unable to generate shortened url", "error", err)
+		return "", false, err
+	}
+	rs.log(ctx).Debug("Attempting to store shortened url",
+		"attempts", attempts,
+		"shortUrlSlug", slug,
+	)
+	success, err = rs.setLink(ctx, slug, link, expiration, data.unique())
+	if err != nil {
+		rs.log(ctx).Error("unable to retrieve/store shortened url in cache", "error", err)
+	}
+	return slug, success, err
+}
+
+// setLink stores the link in the given slug candidate like SetLinkIfNotExists,
+// unless it is unique, which does not share the slug with a link to the same url
+func (rs api) setLink(
+	ctx context.Context, slug string, link *cache.Link, expiration time.Duration, unique bool,
+) (bool, error) {
+	if !unique {
+		return rs.cache.SetLinkIfNotExists(ctx, slug, link, expiration)
+	}
+	success, err := rs.cache.SetLinksIfNotExists(ctx, []cache.LinkEntry{
+		{Key: slug, Link: link, Expiration: expiration, Unique: true},
+	})
+	if err != nil {
+		return false, err
+	}
+	return success[0], nil
+}
+
+// encodedLink returns the link record of the given encode request. The API key of the caller
+// (if any) is recorded as its creator, which is the only one that can manage it.
+func (rs api) encodedLink(
+	ctx context.Context, data *URLPayload, expiration time.Duration,
+) *cache.Link {
+	now := time.Now().UTC().Round(time.Second)
+	link := &cache.Link{
+		Version:   cache.LinkVersion,
+		URL:       data.URL,
+		CreatedAt: &now,
+		Title:     data.Title,
+		Tags:      data.Tags,
+	}
+	if identity := GetIdentity(ctx); identity != nil {
+		link.CreatedBy = identity.KeyID
+	}
+	if expiration > 0 {
+		expiresAt := time.Now().Add(expiration).UTC().Round(time.Second)
+		link.ExpiresAt = &expiresAt
+	}
+	return link
+}
+
+// shortURL builds the public short url for the given slug
+func (rs api) shortURL(slug string) string {
 	var host string
 	if (rs.config.HttpScheme == "https" && rs.config.HttpPort == 443) ||
 		(rs.config.HttpScheme == "http" && rs.config.HttpPort == 80) {
@@ -94,52 +332,240 @@ func (rs api) Encode(w http.ResponseWriter, r *http.Request) {
 	shortURL := url.URL{
 		Scheme: rs.config.HttpScheme,
 		Host:   host,
-		Path:   string(shortURLSlug),
+		Path:   slug,
 	}
-	shortURLString := shortURL.String()
-	rs.logger.Info("Encoded url",
-		"longUrl", data.URL,
-		"shortUrl", shortURLString,
-	)
-	render.Render(w, r, &URLPayload{URL: shortURLString})
+	return shortURL.String()
 }
 
 // Decode
 // @Summary Decodes a URL to a shortened URL
-// @Description Revert a shortened URL to its original form
+// @Description Revert a shortened URL to its original form, along with the metadata of the short URL
 // @ID decode
 // @Tags Shortener
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param url body ShortURL true "The url to decode"
 // @Success 200 {object} LongURL "Short URL decoded successfully"
 // @Failure 400 {object} BadRequest "Short URL has a wrong format"
-// @Failure 404 {object} NotFound "Short URL has not a related long URL"
+// @Failure 401 {object} Unauthorized "Missing or invalid API key (if authentication is enabled)"
+// @Failure 429 {object} TooManyRequests "Rate limit exceeded (if the route is limited)"
+// @Failure 404 {object} NotFound "Short URL has not a related long URL or it is disabled"
+// @Failure 410 {object} Gone "Short URL has expired"
 // @Failure 500 {object} InternalServerError "Unexpected error in the backend"
 // @Router /decode [post]
 func (rs api) Decode(w http.ResponseWriter, r *http.Request) {
 	data := &URLPayload{}
 	if err := render.Bind(r, data); err != nil {
-		rs.logger.Warn("short URL has a wrong format", "error", err)
+		rs.log(r.Context()).Warn("short URL has a wrong format", "error", err)
 		render.Render(w, r, ErrBadRequest(err))
 		return
 	}
-	urlID := data.ParsedURL.Path[1:] // Removes the first / from path
-	longUrl, err := rs.cache.Get(r.Context(), urlID)
-	if err != nil {
-		rs.logger.Error("unable to retrieve long url from cache", "error", err)
-		render.Render(w, r, ErrInternalServerError(err))
+	urlID := strings.TrimPrefix(data.ParsedURL.Path, "/")
+	if urlID == "" {
+		err := errors.New("short url has no slug")
+		rs.log(r.Context()).Warn("short URL has a wrong format", "error", err)
+		render.Render(w, r, ErrBadRequest(err))
 		return
 	}
-	if longUrl == "" {
-		rs.logger.Warn("unable to find long url in cache", "error", err)
-		render.Render(w, r, ErrNotFound(errors.New("long url not found")))
+	link, ok := rs.resolve(w, r, urlID)
+	if !ok {
 		return
 	}
-	rs.logger.Info("Decoded url",
+	rs.log(r.Context()).Info("Decoded url",
 		"shortUrl", data.URL,
 		"urlId", urlID,
-		"longUrl", longUrl,
+		"longUrl", link.URL,
+	)
+	rs.trackClick(r, urlID)
+	render.Render(w, r, newLongURL(link))
+}
+
+// Redirect
+// @Summary Redirects a short URL to its long URL
+// @Description Resolve the slug of a shortened URL and redirect the client to the original URL
+// @ID redirect
+// @Tags Shortener
+// @Produce json
+// @Param slug path string true "The slug of the short url" example(64fc5e)
+// @Success 302 {string} string "Redirect to the long URL (the status code is configurable)"
+// @Failure 404 {object} NotFound "Short URL has not a related long URL or it is disabled"
+// @Failure 410 {object} Gone "Short URL has expired"
+// @Failure 500 {object} InternalServerError "Unexpected error in the backend"
+// @Router /{slug} [get]
+// @Router /{slug} [head]
+func (rs api) Redirect(w http.ResponseWriter, r *http.Request) {
+	urlID := chi.URLParam(r, "slug")
+	link, ok := rs.resolve(w, r, urlID)
+	if !ok {
+		return
+	}
+	statusCode := redirectStatusCode(rs.config)
+	w.Header().Set("Cache-Control", redirectCacheControl(statusCode, rs.maxAge(link)))
+	rs.log(r.Context()).Info("Redirected url",
+		"urlId", urlID,
+		"longUrl", link.URL,
+		"status", statusCode,
+	)
+	rs.trackClick(r, urlID)
+	metrics.RedirectHits.Inc()
+	http.Redirect(w, r, link.URL, statusCode)
+}
+
+// Stats
+// @Summary Returns the click stats of a short URL
+// @Description Aggregated resolutions (redirects and decodes) of a short URL: the total clicks,
+// @Description the clicks of the last 24 hours and 30 days, and the top referers, user agents and countries.
+// @ID stats
+// @Tags Links
+// @Produce json
+// @Security ApiKeyAuth
+// @Param slug path string true "The slug of the short url" example(64fc5e)
+// @Success 200 {object} LinkStats "Click stats of the short URL"
+// @Failure 401 {object} Unauthorized "Missing or invalid API key (if authentication is enabled)"
+// @Failure 403 {object} Forbidden "Short URL is owned by a different API key"
+// @Failure 429 {object} TooManyRequests "Rate limit exceeded (if the route is limited)"
+// @Failure 404 {object} NotFound "Short URL has not a related long URL"
+// @Failure 410 {object} Gone "Short URL has expired"
+// @Failure 500 {object} InternalServerError "Unexpected error in the backend"
+// @Router /links/{slug}/stats [get]
+func (rs api) Stats(w http.ResponseWriter, r *http.Request) {
+	link, ok := rs.manageableLink(w, r)
+	if !ok {
+		return
+	}
+	stats, err := rs.clicks.Stats(r.Context(), link.Slug)
+	if err != nil {
+		rs.log(r.Context()).Error("unable to retrieve click stats from cache", "error", err)
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.Render(w, r, newLinkStats(link.Slug, stats))
+}
+
+// trackClick queues the resolution of the given slug, without delaying the response
+func (rs api) trackClick(r *http.Request, slug string) {
+	rs.clicks.Track(clicks.Click{
+		Slug:      slug,
+		Time:      time.Now(),
+		Referer:   r.Header.Get("Referer"),
+		UserAgent: r.Header.Get("User-Agent"),
+		IP:        r.RemoteAddr,
+		RequestID: middleware.GetReqID(r.Context()),
+	})
+}
+
+// resolve returns the link of the given slug, rendering an error (and returning false)
+// if it does not exist, it expired or it is disabled
+func (rs api) resolve(
+	w http.ResponseWriter, r *http.Request, urlID string,
+) (*cache.Link, bool) {
+	if !cache.IsLinkKey(urlID) {
+		// The service keys (e.g. the reverse index) are never read as a link
+		rs.renderNotFound(w, r, urlID)
+		return nil, false
+	}
+	link, err := rs.loadLink(r.Context(), urlID)
+	if err != nil {
+		rs.log(r.Context()).Error("unable to retrieve long url from cache", "error", err)
+		render.Render(w, r, ErrInternalServerError(err))
+		return nil, false
+	}
+	if link == nil {
+		rs.renderMissing(w, r, urlID)
+		return nil, false
+	}
+	if link.Disabled {
+		rs.log(r.Context()).Warn("long url is disabled", "urlId", urlID)
+		render.Render(w, r, ErrNotFound(errors.New("long url disabled")))
+		return nil, false
+	}
+	return link, true
+}
+
+// renderMissing renders a 410 if the given slug (that is not in the cache) expired,
+// or a 404 if it never existed
+func (rs api) renderMissing(w http.ResponseWriter, r *http.Request, urlID string) {
+	expired, err := rs.isExpired(r.Context(), urlID)
+	if err != nil {
+		rs.log(r.Context()).Error("unable to retrieve tombstone from cache", "error", err)
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if expired {
+		rs.log(r.Context()).Warn("long url expired", "urlId", urlID)
+		render.Render(w, r, ErrGone(errors.New("long url expired")))
+		return
+	}
+	rs.renderNotFound(w, r, urlID)
+}
+
+// renderNotFound renders a 404 for the given slug
+func (rs api) renderNotFound(w http.ResponseWriter, r *http.Request, urlID string) {
+	rs.log(r.Context()).Warn("unable to find long url in cache", "urlId", urlID)
+	render.Render(w, r, ErrNotFound(errors.New("long url not found")))
+}
+
+// redirectStatusCode returns the configured redirect status code, which
+// defaults to a temporary redirect (302) if it is not set
+func redirectStatusCode(conf *config.Values) int {
+	if conf.RedirectStatusCode == 0 {
+		return http.StatusFound
+	}
+	return conf.RedirectStatusCode
+}
+
+// validateRedirectStatusCode checks that the configured redirect status code
+// is one of the supported HTTP redirections
+func validateRedirectStatusCode(conf *config.Values) error {
+	switch redirectStatusCode(conf) {
+	case http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+		return nil
+	}
+	return fmt.Errorf(
+		"invalid redirect status code %d (allowed: 301, 302, 307, 308)",
+		conf.RedirectStatusCode,
 	)
-	render.Render(w, r, &URLPayload{URL: longUrl})
+}
+
+// redirectCacheControl builds the Cache-Control header for a redirect.
+// Permanent redirects can be cached by shared caches (e.g. CDNs), while temporary
+// ones are only cacheable by the client. A max age of 0 forces clients to
+// revalidate every time, thus every request will always reach the service.
+func redirectCacheControl(statusCode int, maxAge int) string {
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+	visibility := "private"
+	if statusCode == http.StatusMovedPermanently ||
+		statusCode == http.StatusPermanentRedirect {
+		visibility = "public"
+	}
+	return fmt.Sprintf("%s, max-age=%d", visibility, maxAge)
+}
+
+// maxAge returns the seconds that the responses of the given link can be cached
+// by the clients, which should not cache them beyond the expiration of the link
+func (rs api) maxAge(link *cache.Link) int {
+	maxAge := rs.config.RedirectMaxAge
+	if maxAge > 0 && link.ExpiresAt != nil {
+		// The expiration date is rounded to the second
+		ttl := time.Until(*link.ExpiresAt).Round(time.Second)
+		if ttl < time.Duration(maxAge)*time.Second {
+			maxAge = int(ttl / time.Second)
+		}
+	}
+	return maxAge
+}
+
+// log returns the logger of the request of the given context, which adds the trace
+// of the request to its entries, or the logger of the api if there is no request
+func (rs api) log(ctx context.Context) logging.Logger {
+	if logger := GetLogger(ctx); logger != nil {
+		return logger
+	}
+	return rs.logger
 }
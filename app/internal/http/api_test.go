@@ -7,12 +7,18 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/darioblanco/shortesturl/app/internal/auth"
 	"github.com/darioblanco/shortesturl/app/internal/cache"
 	"github.com/darioblanco/shortesturl/app/internal/config"
 	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/darioblanco/shortesturl/app/internal/metrics"
+	"github.com/darioblanco/shortesturl/app/internal/slug"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func createRequest(method, path string, input interface{}) *http.Request {
@@ -56,6 +62,20 @@ func testRequest(
 	}
 }
 
+// testDecode decodes the given short url, expecting the given long url and metadata.
+// The creation date is only checked to be recent, as it depends on the clock.
+func testDecode(t *testing.T, handler http.Handler, shortURL string, expected LongURL) {
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, createRequest(http.MethodPost, "/decode", URLPayload{URL: shortURL}))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var longURL LongURL
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &longURL))
+	require.NotNil(t, longURL.CreatedAt)
+	assert.WithinDuration(t, time.Now(), *longURL.CreatedAt, 2*time.Second)
+	longURL.CreatedAt = nil
+	assert.Equal(t, expected, longURL)
+}
+
 func TestEncodeAndDecode(t *testing.T) {
 	r, _ := NewRouter(
 		context.Background(),
@@ -110,24 +130,18 @@ func TestEncodeAndDecode(t *testing.T) {
 				http.StatusOK,
 				URLPayload{URL: tt.shortUrl},
 			)
-			testRequest(t, r,
-				http.MethodPost,
-				"/decode",
-				URLPayload{URL: tt.shortUrl},
-				http.StatusOK,
-				URLPayload{URL: tt.longUrl},
-			)
+			testDecode(t, r, tt.shortUrl, LongURL{URL: tt.longUrl})
 		})
 	}
 }
 
 func TestEncode_OKWithCollisions(t *testing.T) {
 	mr, client := cache.NewMiniredis()
-	mr.Set("64fc5e4dfd2a", "val1")
-	mr.Set("4fc5e4dfd2a0", "val2")
-	mr.Set("fc5e4dfd2a0f", "val3")
-	mr.Set("c5e4dfd2a0ff", "val4")
-	mr.Set("5e4dfd2a0ff5", "val5")
+	mr.Set("test:64fc5e4dfd2a", "val1")
+	mr.Set("test:4fc5e4dfd2a0", "val2")
+	mr.Set("test:fc5e4dfd2a0f", "val3")
+	mr.Set("test:c5e4dfd2a0ff", "val4")
+	mr.Set("test:5e4dfd2a0ff5", "val5")
 	r, _ := NewRouter(
 		context.Background(),
 		&config.Values{
@@ -140,6 +154,8 @@ func TestEncode_OKWithCollisions(t *testing.T) {
 		logging.NewTest(t),
 		client,
 	)
+	count := metrics.SampleCount(metrics.EncodeAttempts)
+	sum := metrics.SampleSum(metrics.EncodeAttempts)
 	testRequest(t, r,
 		http.MethodPost,
 		"/encode",
@@ -147,6 +163,410 @@ func TestEncode_OKWithCollisions(t *testing.T) {
 		http.StatusOK,
 		URLPayload{URL: "http://localhost:3000/e4dfd2a0ff5e"},
 	)
+	// The sixth candidate was stored
+	assert.Equal(t, count+1, metrics.SampleCount(metrics.EncodeAttempts))
+	assert.Equal(t, sum+6, metrics.SampleSum(metrics.EncodeAttempts))
+}
+
+func TestEncode_Generators(t *testing.T) {
+	t.Parallel()
+	for _, generator := range []string{"md5", "sha256", "xxhash", "random", "counter"} {
+		generator := generator // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(generator, func(t *testing.T) {
+			t.Parallel()
+			r, err := NewRouter(
+				context.Background(),
+				&config.Values{
+					HttpScheme: "http",
+					HttpHost:   "localhost",
+					HttpPort:   80,
+					Slug:       config.SlugValues{Generator: generator, Secret: "secret"},
+					UrlLength:  8,
+				},
+				logging.NewTest(t),
+				cache.NewTest(),
+			)
+			assert.NoError(t, err)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, createRequest(
+				http.MethodPost, "/encode", URLPayload{URL: "https://github.com/darioblanco"},
+			))
+			assert.Equal(t, http.StatusOK, rr.Code)
+			var shortURL URLPayload
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shortURL))
+			assert.Regexp(t, "^http://localhost/[0-9a-zA-Z]{8}$", shortURL.URL)
+			testDecode(t, r, shortURL.URL, LongURL{URL: "https://github.com/darioblanco"})
+		})
+	}
+}
+
+func TestEncode_MaxAttempts(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	// Every possible slug of length 1 is already taken
+	for _, c := range "0123456789abcdef" {
+		mr.Set("test:"+string(c), "https://darioblanco.com")
+	}
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{UrlLength: 1},
+		logging.NewTest(t),
+		client,
+	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco"},
+		http.StatusInternalServerError,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusInternalServerError),
+			ErrorText:  "oops, something went wrong in our side",
+		},
+	)
+}
+
+func TestEncode_GeneratorError(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.Set("test:"+slug.CounterKey, "not a number")
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{Slug: config.SlugValues{Generator: "counter", Secret: "secret"}},
+		logging.NewTest(t),
+		client,
+	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco"},
+		http.StatusInternalServerError,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusInternalServerError),
+			ErrorText:  "oops, something went wrong in our side",
+		},
+	)
+}
+
+func TestEncode_Alias(t *testing.T) {
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{
+			Alias: config.AliasValues{
+				MinLength: 4,
+				MaxLength: 64,
+				Reserved:  []string{"docs", "health", "encode", "decode"},
+			},
+			HttpScheme: "http",
+			HttpHost:   "localhost",
+			HttpPort:   3000,
+		},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco", Alias: "launch-2026"},
+		http.StatusOK,
+		URLPayload{URL: "http://localhost:3000/launch-2026"},
+	)
+	// Requesting the same alias for the same long url is idempotent
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco", Alias: "launch-2026"},
+		http.StatusOK,
+		URLPayload{URL: "http://localhost:3000/launch-2026"},
+	)
+	testDecode(t, r,
+		"http://localhost:3000/launch-2026",
+		LongURL{URL: "https://github.com/darioblanco"},
+	)
+}
+
+func TestEncode_Metadata(t *testing.T) {
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{HttpScheme: "http", HttpHost: "localhost", HttpPort: 80, UrlLength: 6},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	expiresAt := time.Now().Add(72 * time.Hour).UTC().Round(time.Second)
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{
+			URL:       "https://github.com/darioblanco",
+			Title:     "Dario Blanco",
+			Tags:      []string{"profile", "github"},
+			ExpiresAt: &expiresAt,
+		},
+		http.StatusOK,
+		URLPayload{URL: "http://localhost/64fc5e", ExpiresAt: &expiresAt},
+	)
+	testDecode(t, r, "http://localhost/64fc5e", LongURL{
+		URL:       "https://github.com/darioblanco",
+		Title:     "Dario Blanco",
+		Tags:      []string{"profile", "github"},
+		ExpiresAt: &expiresAt,
+	})
+}
+
+func TestDecode_Legacy(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	// Previous versions of the service stored the long url as a plain string
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	mr.SetTTL("test:64fc5e", time.Hour)
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{},
+		logging.NewTest(t),
+		client,
+	)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, createRequest(
+		http.MethodPost, "/decode", URLPayload{URL: "http://localhost:3000/64fc5e"},
+	))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var longURL LongURL
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &longURL))
+	assert.Equal(t, "https://github.com/darioblanco", longURL.URL)
+	assert.Nil(t, longURL.CreatedAt)
+	require.NotNil(t, longURL.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *longURL.ExpiresAt, 2*time.Second)
+}
+
+func TestDecode_InvalidLink(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	mr.Set("test:64fc5e", `{"v":99,"url":"https://github.com/darioblanco"}`)
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{},
+		logging.NewTest(t),
+		client,
+	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/decode",
+		URLPayload{URL: "http://localhost:3000/64fc5e"},
+		http.StatusInternalServerError,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusInternalServerError),
+			ErrorText:  "oops, something went wrong in our side",
+		},
+	)
+}
+
+func TestEncode_AliasConflict(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.Set("test:launch-2026", "https://darioblanco.com")
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{},
+		logging.NewTest(t),
+		client,
+	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco", Alias: "launch-2026"},
+		http.StatusConflict,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusConflict),
+			ErrorText:  "alias is already in use",
+		},
+	)
+}
+
+func TestEncode_AliasBadRequest(t *testing.T) {
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{
+			Alias: config.AliasValues{Reserved: []string{"docs"}},
+		},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco", Alias: "docs"},
+		http.StatusBadRequest,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusBadRequest),
+			ErrorText:  "alias \"docs\" is reserved",
+		},
+	)
+}
+
+func TestEncode_AliasInternalServerError(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.SetError("mock error")
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{},
+		logging.NewTest(t),
+		client,
+	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco", Alias: "launch-2026"},
+		http.StatusInternalServerError,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusInternalServerError),
+			ErrorText:  "oops, something went wrong in our side",
+		},
+	)
+}
+
+func TestEncode_UnprocessableEntity(t *testing.T) {
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{
+			Safety: config.SafetyValues{
+				BlockPrivateNetworks: true,
+				DenyDomains:          []string{".evil.example"},
+				MaxURLLength:         64,
+			},
+		},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	tests := []struct {
+		url             string
+		expectedAppCode string
+		expectedError   string
+	}{
+		{"http://127.0.0.1/admin", "private_network", "host 127.0.0.1 is not a public address"},
+		{"http://intranet/", "private_network", "host intranet is not a public address"},
+		{"https://login.evil.example/", "domain_denied", "domain login.evil.example is not allowed"},
+		{
+			"https://github.com/darioblanco?page=" + strings.Repeat("1", 32),
+			"url_too_long",
+			"url must have at most 64 characters",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.expectedAppCode, func(t *testing.T) {
+			testRequest(t, r,
+				http.MethodPost,
+				"/encode",
+				URLPayload{URL: tt.url},
+				http.StatusUnprocessableEntity,
+				ErrHTTPResponse{
+					StatusText: http.StatusText(http.StatusUnprocessableEntity),
+					AppCode:    tt.expectedAppCode,
+					ErrorText:  tt.expectedError,
+				},
+			)
+		})
+	}
+}
+
+func TestEncode_Expiration(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{
+			HttpScheme:              "http",
+			HttpHost:                "localhost",
+			HttpPort:                80,
+			UrlMaxExpirationInHours: 24,
+		},
+		logging.NewTest(t),
+		client,
+	)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, createRequest(
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco", ExpiresIn: "48h"},
+	))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var shortURL URLPayload
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shortURL))
+	assert.Equal(t, "http://localhost/64fc5e", shortURL.URL)
+	// The requested expiration is capped by the server
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *shortURL.ExpiresAt, 2*time.Second)
+	assert.Equal(t, 24*time.Hour, mr.TTL("test:64fc5e"))
+	assert.True(t, mr.Exists("test:tombstone:64fc5e"))
+
+	// Encoding the same url keeps the original expiration
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, createRequest(
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco", ExpiresIn: "1h"},
+	))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shortURL))
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *shortURL.ExpiresAt, 2*time.Second)
+
+	// Once expired, the short url is gone
+	mr.FastForward(24 * time.Hour)
+	testRequest(t, r,
+		http.MethodPost,
+		"/decode",
+		URLPayload{URL: "http://localhost/64fc5e"},
+		http.StatusGone,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusGone),
+			ErrorText:  "long url expired",
+		},
+	)
+	testRequest(t, r,
+		http.MethodGet,
+		"/64fc5e",
+		nil,
+		http.StatusGone,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusGone),
+			ErrorText:  "long url expired",
+		},
+	)
+}
+
+func TestDecode_TombstoneInternalServerError(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	// Tombstones are stored as strings, thus a hash in the same key triggers an error
+	mr.HSet("test:tombstone:64fc5e", "field", "value")
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{},
+		logging.NewTest(t),
+		client,
+	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/decode",
+		URLPayload{URL: "http://localhost:3000/64fc5e"},
+		http.StatusInternalServerError,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusInternalServerError),
+			ErrorText:  "oops, something went wrong in our side",
+		},
+	)
+}
+
+func TestRedirect_MaxAgeCappedByExpiration(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	mr.SetTTL("test:64fc5e", time.Minute)
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{RedirectMaxAge: 3600},
+		logging.NewTest(t),
+		client,
+	)
+	testRedirectRequest(t, r,
+		http.MethodGet,
+		"/64fc5e",
+		http.StatusFound,
+		"https://github.com/darioblanco",
+		"private, max-age=60",
+	)
 }
 
 func TestEncode_BadRequest(t *testing.T) {
@@ -231,6 +651,16 @@ func TestDecode_BadRequest(t *testing.T) {
 			ErrorText:  "invalid http/https url format",
 		},
 	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/decode",
+		URLPayload{URL: "http://localhost:3000"},
+		http.StatusBadRequest,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusBadRequest),
+			ErrorText:  "short url has no slug",
+		},
+	)
 }
 
 func TestDecode_InternalServerError(t *testing.T) {
@@ -253,3 +683,429 @@ func TestDecode_InternalServerError(t *testing.T) {
 		},
 	)
 }
+
+func testRedirectRequest(
+	t *testing.T,
+	handler http.Handler,
+	method,
+	path string,
+	expectedStatusCode int,
+	expectedLocation string,
+	expectedCacheControl string,
+) {
+	req, _ := http.NewRequest(method, path, nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, expectedStatusCode, rr.Code)
+	assert.Equal(t, expectedLocation, rr.Header().Get("Location"))
+	assert.Equal(t, expectedCacheControl, rr.Header().Get("Cache-Control"))
+}
+
+func TestRedirect(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	t.Parallel()
+	tests := []struct {
+		name                 string
+		method               string
+		statusCode           int
+		maxAge               int
+		expectedStatusCode   int
+		expectedCacheControl string
+	}{
+		{"default", http.MethodGet, 0, 0, http.StatusFound, "no-cache"},
+		{"head", http.MethodHead, 0, 0, http.StatusFound, "no-cache"},
+		{"moved permanently", http.MethodGet, 301, 3600, http.StatusMovedPermanently, "public, max-age=3600"},
+		{"found", http.MethodGet, 302, 60, http.StatusFound, "private, max-age=60"},
+		{"temporary redirect", http.MethodGet, 307, 60, http.StatusTemporaryRedirect, "private, max-age=60"},
+		{"permanent redirect", http.MethodGet, 308, 60, http.StatusPermanentRedirect, "public, max-age=60"},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r, err := NewRouter(
+				context.Background(),
+				&config.Values{
+					RedirectMaxAge:     tt.maxAge,
+					RedirectStatusCode: tt.statusCode,
+				},
+				logging.NewTest(t),
+				client,
+			)
+			assert.NoError(t, err)
+			testRedirectRequest(t, r,
+				tt.method,
+				"/64fc5e",
+				tt.expectedStatusCode,
+				"https://github.com/darioblanco",
+				tt.expectedCacheControl,
+			)
+		})
+	}
+}
+
+func TestRedirect_NotFound(t *testing.T) {
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	testRequest(t, r,
+		http.MethodGet,
+		"/abcdef",
+		nil,
+		http.StatusNotFound,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusNotFound),
+			ErrorText:  "long url not found",
+		},
+	)
+}
+
+func TestRedirect_ServiceKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		input  interface{}
+	}{
+		{"redirect url index", http.MethodGet, "/url:f65befd02eecc4abdac29c9b3ffe6e13494ce622", nil},
+		{"redirect tombstone", http.MethodGet, "/tombstone:64fc5e", nil},
+		{"qr clicks", http.MethodGet, "/clicks:64fc5e/qr", nil},
+		{"decode api key", http.MethodPost, "/decode",
+			URLPayload{URL: "http://localhost/apikey:1a2b3c"}},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			mr, client := cache.NewMiniredis()
+			defer mr.Close()
+			r, _ := NewRouter(context.Background(), &config.Values{}, logging.NewTest(t), client)
+			// The service keys are rejected before touching the cache
+			mr.SetError("mock error")
+			testRequest(t, r,
+				tt.method,
+				tt.path,
+				tt.input,
+				http.StatusNotFound,
+				ErrHTTPResponse{
+					StatusText: http.StatusText(http.StatusNotFound),
+					ErrorText:  "long url not found",
+				},
+			)
+		})
+	}
+}
+
+func TestRedirect_InternalServerError(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.SetError("mock error")
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{},
+		logging.NewTest(t),
+		client,
+	)
+	testRequest(t, r,
+		http.MethodGet,
+		"/64fc5e",
+		nil,
+		http.StatusInternalServerError,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusInternalServerError),
+			ErrorText:  "oops, something went wrong in our side",
+		},
+	)
+}
+
+func TestStats(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{},
+		logging.NewTest(t),
+		client,
+	)
+	assert.NoError(t, err)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	// Two redirects and one decode
+	noRedirectClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/64fc5e", nil)
+		req.Header.Set("Referer", "https://news.ycombinator.com/item?id=1")
+		req.Header.Set("User-Agent", "test-agent")
+		resp, err := noRedirectClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+	}
+	testRequest(t, r,
+		http.MethodPost,
+		"/decode",
+		URLPayload{URL: "http://localhost:3000/64fc5e"},
+		http.StatusOK,
+		LongURL{URL: "https://github.com/darioblanco"},
+	)
+
+	// The clicks are recorded in the background
+	var stats LinkStats
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get(ts.URL + "/links/64fc5e/stats")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		stats = LinkStats{}
+		json.NewDecoder(resp.Body).Decode(&stats)
+		return resp.StatusCode == http.StatusOK && len(stats.TopUserAgents) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "64fc5e", stats.Slug)
+	assert.Equal(t, int64(3), stats.Total)
+	assert.NotNil(t, stats.LastClickAt)
+	// The request id of the last click is recorded to correlate it with the logs
+	assert.NotEmpty(t, stats.LastRequestID)
+	assert.Len(t, stats.Hourly, 24)
+	assert.Len(t, stats.Daily, 30)
+	var hourlyClicks int64
+	for _, bucket := range stats.Hourly {
+		hourlyClicks += bucket.Clicks
+	}
+	assert.Equal(t, int64(3), hourlyClicks)
+	assert.Equal(t, []TopClicks{{Value: "news.ycombinator.com", Clicks: 2}}, stats.TopReferers)
+	assert.Equal(t, []TopClicks{
+		{Value: "test-agent", Clicks: 2},
+		{Value: "Go-http-client/1.1", Clicks: 1},
+	}, stats.TopUserAgents)
+	assert.Equal(t, []TopClicks{}, stats.TopCountries)
+}
+
+func TestStats_NotFound(t *testing.T) {
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	testRequest(t, r,
+		http.MethodGet,
+		"/links/abcdef/stats",
+		nil,
+		http.StatusNotFound,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusNotFound),
+			ErrorText:  "long url not found",
+		},
+	)
+}
+
+func TestStats_Gone(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.Set("test:tombstone:64fc5e", "2026-01-01T00:00:00Z")
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{},
+		logging.NewTest(t),
+		client,
+	)
+	testRequest(t, r,
+		http.MethodGet,
+		"/links/64fc5e/stats",
+		nil,
+		http.StatusGone,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusGone),
+			ErrorText:  "long url expired",
+		},
+	)
+}
+
+func TestStats_InternalServerError(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       map[string]string
+		cacheError bool
+	}{
+		{name: "long url", cacheError: true},
+		{name: "click stats", keys: map[string]string{
+			"test:64fc5e":        "https://github.com/darioblanco",
+			"test:clicks:64fc5e": "invalid",
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			mr, client := cache.NewMiniredis()
+			for key, value := range tt.keys {
+				mr.Set(key, value)
+			}
+			if tt.cacheError {
+				mr.SetError("mock error")
+			}
+			r, _ := NewRouter(
+				context.Background(),
+				&config.Values{},
+				logging.NewTest(t),
+				client,
+			)
+			testRequest(t, r,
+				http.MethodGet,
+				"/links/64fc5e/stats",
+				nil,
+				http.StatusInternalServerError,
+				ErrHTTPResponse{
+					StatusText: http.StatusText(http.StatusInternalServerError),
+					ErrorText:  "oops, something went wrong in our side",
+				},
+			)
+		})
+	}
+}
+
+func TestEncode_Auth(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	token, identity, err := auth.NewKeyStore(client).Create(context.Background(), "ci")
+	require.NoError(t, err)
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{
+			Auth:                 config.AuthValues{Enabled: true},
+			HttpScheme:           "http",
+			HttpHost:             "localhost",
+			HttpPort:             80,
+			UrlLength:            6,
+			UrlExpirationInHours: 1,
+		},
+		logging.NewTest(t),
+		client,
+	)
+	require.NoError(t, err)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	// Without api key
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco"},
+		http.StatusUnauthorized,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusUnauthorized),
+			ErrorText:  "missing bearer api key",
+		},
+	)
+	assert.False(t, mr.Exists("test:64fc5e"))
+
+	// With api key
+	req := createRequest(http.MethodPost, ts.URL+"/encode",
+		URLPayload{URL: "https://github.com/darioblanco"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	link, err := client.GetLink(context.Background(), "64fc5e")
+	require.NoError(t, err)
+	require.NotNil(t, link)
+	assert.Equal(t, identity.KeyID, link.CreatedBy)
+
+	// The short urls are public
+	noRedirectClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err = noRedirectClient.Get(ts.URL + "/64fc5e")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+}
+
+func TestEncode_RateLimit(t *testing.T) {
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{
+			HttpScheme: "http",
+			HttpHost:   "localhost",
+			HttpPort:   80,
+			RateLimit: config.RateLimitValues{
+				Encode: config.RateLimitRouteValues{Limit: 1, WindowInSeconds: 3600},
+			},
+			UrlLength: 6,
+		},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco"},
+		http.StatusOK,
+		URLPayload{URL: "http://localhost/64fc5e"},
+	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco"},
+		http.StatusTooManyRequests,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusTooManyRequests),
+			ErrorText:  "rate limit exceeded",
+		},
+	)
+	// The rest of the routes are not limited
+	testDecode(t, r, "http://localhost/64fc5e", LongURL{URL: "https://github.com/darioblanco"})
+}
+
+func TestEncodeBatch_RateLimit(t *testing.T) {
+	c := cache.NewTest()
+	r, _ := NewRouter(
+		context.Background(),
+		&config.Values{
+			HttpScheme: "http",
+			HttpHost:   "localhost",
+			HttpPort:   80,
+			RateLimit: config.RateLimitValues{
+				Encode: config.RateLimitRouteValues{Limit: 3, WindowInSeconds: 3600},
+			},
+			UrlLength: 6,
+		},
+		logging.NewTest(t),
+		c,
+	)
+	batch := func(urls ...string) *httptest.ResponseRecorder {
+		data := BatchRequest{}
+		for _, u := range urls {
+			data.URLs = append(data.URLs, &URLPayload{URL: u})
+		}
+		return serveRequest(r, http.MethodPost, "/encode/batch", "", data)
+	}
+
+	w := batch("https://github.com/darioblanco", "https://darioblanco.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	// Each url counts as a request, thus a batch larger than the remaining quota is rejected
+	w = batch("https://example.com", "https://example.org")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.JSONEq(t, `{"status":"Too Many Requests","error":"rate limit exceeded"}`, w.Body.String())
+	// The urls of the rejected batch are not encoded
+	keys, _, err := c.Scan(context.Background(), "", "*", 100)
+	assert.NoError(t, err)
+	var links int
+	for _, key := range keys {
+		if cache.IsLinkKey(key) {
+			links++
+		}
+	}
+	assert.Equal(t, 2, links)
+}
@@ -0,0 +1,390 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/metrics"
+	"github.com/darioblanco/shortesturl/app/internal/tracing"
+	"github.com/go-chi/render"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultBatchMaxSize is the maximum number of urls of a batch when the configuration does not define it
+const defaultBatchMaxSize = 1000
+
+// An encodeItem is a valid url of a batch that is being encoded
+type encodeItem struct {
+	// index is the position of the url in the batch
+	index      int
+	data       *URLPayload
+	link       *cache.Link
+	expiration time.Duration
+	slug       string
+	// indexed is true if the slug was found in the reverse index
+	indexed bool
+}
+
+// EncodeBatch
+// @Summary Encodes several URLs to shortened URLs at once
+// @Description Shorten several URLs with a single request, each one like /encode. The cache operations
+// @Description of the whole batch are pipelined, and each URL has its own result (in the same order),
+// @Description thus an invalid or unsafe URL or an alias conflict does not fail the rest of the batch.
+// @ID encodeBatch
+// @Tags Shortener
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param urls body EncodeBatchRequest true "The urls to encode"
+// @Success 200 {object} BatchResponse "The short URL or the error of each URL"
+// @Failure 400 {object} BadRequest "The batch is empty, too large or it has a wrong format"
+// @Failure 401 {object} Unauthorized "Missing or invalid API key (if authentication is enabled)"
+// @Failure 429 {object} TooManyRequests "Rate limit exceeded, each URL counting as a request (if the route is limited)"
+// @Router /encode/batch [post]
+func (rs api) EncodeBatch(w http.ResponseWriter, r *http.Request) {
+	data, ok := rs.bindBatch(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	results := make([]*BatchResult, len(data.URLs))
+	items := make([]*encodeItem, 0, len(data.URLs))
+	for i, u := range data.URLs {
+		u.canonicalizer = rs.canonicalizer
+		if err := u.Bind(r); err != nil {
+			results[i] = newBatchError(ErrBadRequest(err))
+			continue
+		}
+		if errResponse := rs.checkDestination(ctx, &u.ParsedURL); errResponse != nil {
+			results[i] = newBatchError(errResponse)
+			continue
+		}
+		if u.Alias != "" {
+			if err := rs.aliases.Validate(u.Alias); err != nil {
+				results[i] = newBatchError(ErrBadRequest(err))
+				continue
+			}
+		}
+		expiration := rs.expiration(u.Expiration)
+		items = append(items, &encodeItem{
+			index:      i,
+			data:       u,
+			link:       rs.encodedLink(ctx, u, expiration),
+			expiration: expiration,
+		})
+	}
+	indexed, pending := rs.lookupBatch(ctx, items, results)
+	stored := append(indexed, rs.storeBatch(ctx, pending, results)...)
+	rs.completeBatch(ctx, stored, results)
+	rs.log(ctx).Info("Encoded url batch", "urls", len(results), "encoded", len(stored))
+	render.Render(w, r, &BatchResponse{URLs: results})
+}
+
+// lookupBatch looks up the reusable items in the reverse index, returning the ones that were
+// found, which do not need to be stored, and the ones that still need a slug
+func (rs api) lookupBatch(
+	ctx context.Context, items []*encodeItem, results []*BatchResult,
+) (indexed []*encodeItem, pending []*encodeItem) {
+	var reusable []*encodeItem
+	var keys []string
+	for _, item := range items {
+		if item.data.reusable() {
+			reusable = append(reusable, item)
+			keys = append(keys, urlIndexKey(item.link))
+		} else {
+			pending = append(pending, item)
+		}
+	}
+	if len(reusable) == 0 {
+		return nil, pending
+	}
+	slugs, err := rs.cache.GetAll(ctx, keys)
+	if err != nil {
+		rs.log(ctx).Error("unable to retrieve shortened urls from index", "error", err)
+		setBatchErrors(reusable, results, err)
+		return nil, pending
+	}
+	var found []*encodeItem
+	var foundSlugs []string
+	for i, item := range reusable {
+		if slugs[i] == "" {
+			pending = append(pending, item)
+			continue
+		}
+		item.slug = slugs[i]
+		found = append(found, item)
+		foundSlugs = append(foundSlugs, slugs[i])
+	}
+	links, err := rs.cache.GetLinks(ctx, foundSlugs)
+	if err != nil {
+		rs.log(ctx).Error("unable to retrieve shortened urls from cache", "error", err)
+		setBatchErrors(found, results, err)
+		return nil, pending
+	}
+	for i, item := range found {
+		if isReusable(links[i], item.link) {
+			item.indexed = true
+			indexed = append(indexed, item)
+		} else {
+			pending = append(pending, item)
+		}
+	}
+	return indexed, pending
+}
+
+// storeBatch stores the links of the given items, returning the ones that were stored.
+// Every round stores the next slug candidate of the items that collided in the previous
+// one with a single pipeline, and the result of the items that failed is set.
+func (rs api) storeBatch(
+	ctx context.Context, items []*encodeItem, results []*BatchResult,
+) []*encodeItem {
+	var stored []*encodeItem
+	pending := items
+	for attempts := 0; len(pending) > 0; attempts++ {
+		var entries []cache.LinkEntry
+		var candidates []*encodeItem
+		for _, item := range pending {
+			// The alias is not shifted in case of collision, thus it is only attempted once
+			item.slug = item.data.Alias
+			if item.slug == "" {
+				if attempts == maxEncodeAttempts {
+					metrics.EncodeAttempts.Observe(float64(attempts))
+					err := fmt.Errorf("no free slug found after %d attempts", attempts)
+					rs.log(ctx).Error("unable to generate shortened url", "error", err)
+					results[item.index] = newBatchError(ErrInternalServerError(err))
+					continue
+				}
+				var err error
+				if item.slug, err = rs.slugs.Generate(ctx, item.data.URL, attempts); err != nil {
+					rs.log(ctx).Error("unable to generate shortened url", "error", err)
+					results[item.index] = newBatchError(ErrInternalServerError(err))
+					continue
+				}
+			}
+			entries = append(entries, cache.LinkEntry{
+				Key:        item.slug,
+				Link:       item.link,
+				Expiration: item.expiration,
+				Unique:     item.data.unique(),
+			})
+			candidates = append(candidates, item)
+		}
+		// Each round is traced in its own span, like the attempts of a single url
+		roundCtx, span := tracing.Start(ctx, "encode.attempt", trace.WithAttributes(
+			attribute.Int("attempt", attempts),
+			attribute.Int("candidates", len(entries)),
+		))
+		success, err := rs.cache.SetLinksIfNotExists(roundCtx, entries)
+		if err != nil {
+			rs.log(ctx).Error("unable to retrieve/store shortened urls in cache", "error", err)
+			for _, item := range candidates {
+				results[item.index] = newBatchError(ErrInternalServerError(err))
+			}
+			tracing.End(span, err)
+			break
+		}
+		pending = nil
+		for i, item := range candidates {
+			switch {
+			case success[i]:
+				if item.data.Alias == "" {
+					metrics.EncodeAttempts.Observe(float64(attempts + 1))
+				}
+				stored = append(stored, item)
+			case item.data.Alias != "":
+				rs.log(ctx).Warn("alias is already in use", "alias", item.data.Alias)
+				results[item.index] = newBatchError(ErrConflict(errors.New("alias is already in use")))
+			default:
+				// Collision, a new candidate is needed
+				pending = append(pending, item)
+			}
+		}
+		span.SetAttributes(attribute.Int("collisions", len(pending)))
+		tracing.End(span, nil)
+	}
+	return stored
+}
+
+// completeBatch sets the result of the given stored items, storing their tombstones
+// and their reverse index entries.
+// If an url was already shortened, its original expiration is kept, thus the expiration
+// is read back from the stored links.
+func (rs api) completeBatch(ctx context.Context, stored []*encodeItem, results []*BatchResult) {
+	slugs := make([]string, len(stored))
+	for i, item := range stored {
+		slugs[i] = item.slug
+	}
+	links, err := rs.cache.GetLinks(ctx, slugs)
+	if err != nil {
+		rs.log(ctx).Error("unable to retrieve shortened urls from cache", "error", err)
+		setBatchErrors(stored, results, err)
+		return
+	}
+	var entries []cache.Entry
+	completed := make([]*BatchResult, len(stored))
+	for i, item := range stored {
+		link := links[i]
+		if link != nil && link.Version == 0 {
+			// The url was shortened by a previous version of the service
+			if link, err = rs.loadLink(ctx, item.slug); err != nil {
+				rs.log(ctx).Error("unable to retrieve shortened url from cache", "error", err)
+				completed[i] = newBatchError(ErrInternalServerError(err))
+				continue
+			}
+		}
+		if link == nil {
+			// The link was removed right after it was stored
+			link = item.link
+		}
+		completed[i] = newBatchResult(rs.shortURL(item.slug))
+		if link.ExpiresAt != nil {
+			completed[i].ExpiresAt = link.ExpiresAt
+			entries = append(entries,
+				rs.tombstone(item.slug, *link.ExpiresAt, time.Until(*link.ExpiresAt)))
+		}
+		if item.data.reusable() && !item.indexed {
+			entries = append(entries, urlIndex(item.link, item.slug, link.ExpiresAt))
+		}
+	}
+	if err := rs.cache.SetAll(ctx, entries); err != nil {
+		rs.log(ctx).Error("unable to store tombstones and index of shortened urls in cache",
+			"error", err)
+		setBatchErrors(stored, results, err)
+		return
+	}
+	for i, item := range stored {
+		results[item.index] = completed[i]
+	}
+}
+
+// setBatchErrors sets an internal server error as the result of the given items
+func setBatchErrors(items []*encodeItem, results []*BatchResult, err error) {
+	for _, item := range items {
+		results[item.index] = newBatchError(ErrInternalServerError(err))
+	}
+}
+
+// DecodeBatch
+// @Summary Decodes several shortened URLs at once
+// @Description Revert several shortened URLs with a single request, each one like /decode. The cache
+// @Description operations of the whole batch are pipelined, and each URL has its own result (in the
+// @Description same order), thus an unknown or expired URL does not fail the rest of the batch.
+// @ID decodeBatch
+// @Tags Shortener
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param urls body DecodeBatchRequest true "The urls to decode"
+// @Success 200 {object} BatchResponse "The long URL and its metadata, or the error of each URL"
+// @Failure 400 {object} BadRequest "The batch is empty, too large or it has a wrong format"
+// @Failure 401 {object} Unauthorized "Missing or invalid API key (if authentication is enabled)"
+// @Failure 429 {object} TooManyRequests "Rate limit exceeded, each URL counting as a request (if the route is limited)"
+// @Router /decode/batch [post]
+func (rs api) DecodeBatch(w http.ResponseWriter, r *http.Request) {
+	data, ok := rs.bindBatch(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	results := make([]*BatchResult, len(data.URLs))
+	var slugs []string
+	var indexes []int
+	for i, u := range data.URLs {
+		if err := u.Bind(r); err != nil {
+			results[i] = newBatchError(ErrBadRequest(err))
+			continue
+		}
+		slugs = append(slugs, strings.TrimPrefix(u.ParsedURL.Path, "/"))
+		indexes = append(indexes, i)
+	}
+	links, err := rs.cache.GetLinks(ctx, slugs)
+	if err != nil {
+		rs.log(ctx).Error("unable to retrieve long urls from cache", "error", err)
+		for _, i := range indexes {
+			results[i] = newBatchError(ErrInternalServerError(err))
+		}
+		render.Render(w, r, &BatchResponse{URLs: results})
+		return
+	}
+	// The missing slugs are resolved together once the rest are known
+	var missing []int
+	for j, link := range links {
+		i := indexes[j]
+		switch {
+		case link == nil:
+			missing = append(missing, j)
+		case link.Disabled:
+			results[i] = newBatchError(ErrNotFound(errors.New("long url disabled")))
+		default:
+			results[i] = newBatchResult(link.URL)
+			results[i].Title = link.Title
+			results[i].Tags = link.Tags
+			results[i].CreatedAt = link.CreatedAt
+			results[i].ExpiresAt = link.ExpiresAt
+			rs.trackClick(r, slugs[j])
+		}
+	}
+	rs.resolveMissing(ctx, slugs, indexes, missing, results)
+	rs.log(ctx).Info("Decoded url batch", "urls", len(results))
+	render.Render(w, r, &BatchResponse{URLs: results})
+}
+
+// resolveMissing sets the result of the given missing slugs: a 410 if they expired,
+// or a 404 if they never existed
+func (rs api) resolveMissing(
+	ctx context.Context, slugs []string, indexes []int, missing []int, results []*BatchResult,
+) {
+	keys := make([]string, len(missing))
+	for k, j := range missing {
+		keys[k] = tombstoneKey(slugs[j])
+	}
+	tombstones, err := rs.cache.GetAll(ctx, keys)
+	if err != nil {
+		rs.log(ctx).Error("unable to retrieve tombstones from cache", "error", err)
+	}
+	for k, j := range missing {
+		i := indexes[j]
+		switch {
+		case err != nil:
+			results[i] = newBatchError(ErrInternalServerError(err))
+		case tombstones[k] != "":
+			results[i] = newBatchError(ErrGone(errors.New("long url expired")))
+		default:
+			results[i] = newBatchError(ErrNotFound(errors.New("long url not found")))
+		}
+	}
+}
+
+// bindBatch returns the batch of the request, rendering an error (and returning false)
+// if it has a wrong format, it is empty, or it exceeds the maximum size or the rate limit
+func (rs api) bindBatch(w http.ResponseWriter, r *http.Request) (*BatchRequest, bool) {
+	data := &BatchRequest{}
+	if err := render.Bind(r, data); err != nil {
+		rs.log(r.Context()).Warn("batch has a wrong format", "error", err)
+		render.Render(w, r, ErrBadRequest(err))
+		return nil, false
+	}
+	maxSize := rs.config.Batch.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultBatchMaxSize
+	}
+	if len(data.URLs) > maxSize {
+		rs.log(r.Context()).Warn("batch is too large", "urls", len(data.URLs), "maxSize", maxSize)
+		render.Render(w, r, ErrBadRequest(fmt.Errorf("a batch can have at most %d urls", maxSize)))
+		return nil, false
+	}
+	// Each url counts as a request, and the first one was already counted by RateLimitMW
+	if !chargeRateLimit(w, r, len(data.URLs)-1, rs.log(r.Context())) {
+		rs.log(r.Context()).Warn("batch exceeds the rate limit", "urls", len(data.URLs))
+		return nil, false
+	}
+	return data, true
+}
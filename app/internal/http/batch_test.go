@@ -0,0 +1,239 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchTestRouter(t *testing.T, c cache.Cache, maxSize int) http.Handler {
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{
+			Batch:                        config.BatchValues{MaxSize: maxSize},
+			HttpScheme:                   "http",
+			HttpHost:                     "localhost",
+			HttpPort:                     80,
+			UrlLength:                    6,
+			UrlTombstoneRetentionInHours: 1,
+		},
+		logging.NewTest(t),
+		c,
+	)
+	require.NoError(t, err)
+	return r
+}
+
+// serveBatch serves a batch request to the given path, returning the results of its urls
+func serveBatch(
+	t *testing.T, handler http.Handler, path string, urls []*URLPayload,
+) []*BatchResult {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, createRequest(http.MethodPost, path, BatchRequest{URLs: urls}))
+	require.Equal(t, http.StatusOK, w.Code)
+	response := &BatchResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), response))
+	require.Len(t, response.URLs, len(urls))
+	return response.URLs
+}
+
+func TestEncodeBatch(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	mr.Set("test:taken", "https://darioblanco.com")
+	// The first candidate of the second url is taken
+	mr.Set("test:104b16", "https://example.com")
+	r := newBatchTestRouter(t, client, 0)
+
+	results := serveBatch(t, r, "/encode/batch", []*URLPayload{
+		{URL: "https://github.com/darioblanco", ExpiresIn: "1h"},
+		{URL: "https://darioblanco.com/?url=https%3A%2F%2Fgithub.com%2Fdarioblanco"},
+		{URL: "invalid"},
+		{URL: "https://github.com/darioblanco", Alias: "launch-2026"},
+		{URL: "https://github.com/darioblanco", Alias: "taken"},
+		{URL: "https://github.com/darioblanco", Alias: "no/slash"},
+		// The same url is encoded once
+		{URL: "https://github.com/darioblanco"},
+	})
+
+	require.NotNil(t, results[0].ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *results[0].ExpiresAt, 2*time.Second)
+	assert.Equal(t, time.Hour, mr.TTL("test:64fc5e"))
+	assert.InDelta(t, 2*time.Hour, mr.TTL("test:tombstone:64fc5e"), float64(2*time.Second))
+	assert.Equal(t, results[0].ExpiresAt, results[6].ExpiresAt)
+	for _, result := range results {
+		result.ExpiresAt = nil
+	}
+	ok := func(url string) *BatchResult {
+		return &BatchResult{Code: http.StatusOK, StatusText: "OK", URL: url}
+	}
+	assert.Equal(t, []*BatchResult{
+		ok("http://localhost/64fc5e"),
+		ok("http://localhost/04b16e"),
+		{Code: http.StatusBadRequest, StatusText: "Bad Request", ErrorText: "invalid http/https url format"},
+		ok("http://localhost/launch-2026"),
+		{Code: http.StatusConflict, StatusText: "Conflict", ErrorText: "alias is already in use"},
+		{
+			Code:       http.StatusBadRequest,
+			StatusText: "Bad Request",
+			ErrorText:  `alias must not contain any of ":/."`,
+		},
+		ok("http://localhost/64fc5e"),
+	}, results)
+
+	// Every encoded url can be decoded
+	results = serveBatch(t, r, "/decode/batch", []*URLPayload{
+		{URL: "http://localhost/64fc5e"},
+		{URL: "http://localhost/04b16e"},
+		{URL: "http://localhost/launch-2026"},
+	})
+	assert.Equal(t, "https://github.com/darioblanco", results[0].URL)
+	assert.Equal(t,
+		"https://darioblanco.com/?url=https%3A%2F%2Fgithub.com%2Fdarioblanco", results[1].URL)
+	assert.Equal(t, "https://github.com/darioblanco", results[2].URL)
+}
+
+func TestDecodeBatch(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	r := newBatchTestRouter(t, client, 0)
+	w := serveRequest(r, http.MethodPost, "/encode", "", URLPayload{
+		URL:   "https://github.com/darioblanco",
+		Title: "Dario Blanco",
+		Tags:  []string{"profile"},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	mr.Set("test:legacy", "https://darioblanco.com")
+	mr.Set("test:disabled", `{"v":1,"url":"https://darioblanco.com","disabled":true}`)
+	mr.Set("test:tombstone:104b16", "2026-01-01T00:00:00Z")
+
+	results := serveBatch(t, r, "/decode/batch", []*URLPayload{
+		{URL: "http://localhost/64fc5e"},
+		{URL: "http://localhost/legacy"},
+		{URL: "http://localhost/disabled"},
+		{URL: "http://localhost/104b16"},
+		{URL: "http://localhost/unknown"},
+		{URL: "invalid"},
+		nil,
+	})
+	require.NotNil(t, results[0].CreatedAt)
+	results[0].CreatedAt = nil
+	assert.Equal(t, []*BatchResult{
+		{
+			Code:       http.StatusOK,
+			StatusText: "OK",
+			URL:        "https://github.com/darioblanco",
+			Title:      "Dario Blanco",
+			Tags:       []string{"profile"},
+		},
+		{Code: http.StatusOK, StatusText: "OK", URL: "https://darioblanco.com"},
+		{Code: http.StatusNotFound, StatusText: "Not Found", ErrorText: "long url disabled"},
+		{Code: http.StatusGone, StatusText: "Gone", ErrorText: "long url expired"},
+		{Code: http.StatusNotFound, StatusText: "Not Found", ErrorText: "long url not found"},
+		{Code: http.StatusBadRequest, StatusText: "Bad Request", ErrorText: "invalid http/https url format"},
+		{Code: http.StatusBadRequest, StatusText: "Bad Request", ErrorText: "invalid http/https url format"},
+	}, results)
+}
+
+func TestEncodeBatch_UnprocessableEntity(t *testing.T) {
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{
+			HttpScheme: "http",
+			HttpHost:   "localhost",
+			HttpPort:   80,
+			Safety:     config.SafetyValues{BlockPrivateNetworks: true},
+			UrlLength:  6,
+		},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	require.NoError(t, err)
+
+	results := serveBatch(t, r, "/encode/batch", []*URLPayload{
+		{URL: "http://192.168.1.1/"},
+		{URL: "https://github.com/darioblanco"},
+	})
+	assert.Equal(t, []*BatchResult{
+		{
+			Code:       http.StatusUnprocessableEntity,
+			StatusText: "Unprocessable Entity",
+			AppCode:    "private_network",
+			ErrorText:  "host 192.168.1.1 is not a public address",
+		},
+		{Code: http.StatusOK, StatusText: "OK", URL: "http://localhost/64fc5e"},
+	}, results)
+}
+
+func TestBatch_BadRequest(t *testing.T) {
+	r := newBatchTestRouter(t, cache.NewTest(), 2)
+	tests := []struct {
+		name          string
+		input         interface{}
+		expectedError string
+	}{
+		{
+			name:          "empty",
+			input:         BatchRequest{},
+			expectedError: "urls can not be empty",
+		},
+		{
+			name: "too large",
+			input: BatchRequest{URLs: []*URLPayload{
+				{URL: "https://github.com/darioblanco"},
+				{URL: "https://darioblanco.com"},
+				{URL: "https://example.com"},
+			}},
+			expectedError: "a batch can have at most 2 urls",
+		},
+		{
+			name:          "wrong format",
+			input:         map[string]string{"urls": "https://github.com/darioblanco"},
+			expectedError: "json: cannot unmarshal string into Go struct field BatchRequest.urls of type []*http.URLPayload",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		for _, path := range []string{"/encode/batch", "/decode/batch"} {
+			t.Run(tt.name+path, func(t *testing.T) {
+				testRequest(t, r, http.MethodPost, path, tt.input, http.StatusBadRequest, ErrHTTPResponse{
+					StatusText: http.StatusText(http.StatusBadRequest),
+					ErrorText:  tt.expectedError,
+				})
+			})
+		}
+	}
+}
+
+func TestBatch_InternalServerError(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	r := newBatchTestRouter(t, client, 0)
+	mr.SetError("mock error")
+	internalServerError := &BatchResult{
+		Code:       http.StatusInternalServerError,
+		StatusText: "Internal Server Error",
+		ErrorText:  "oops, something went wrong in our side",
+	}
+	badRequest := &BatchResult{
+		Code:       http.StatusBadRequest,
+		StatusText: "Bad Request",
+		ErrorText:  "invalid http/https url format",
+	}
+	for _, path := range []string{"/encode/batch", "/decode/batch"} {
+		results := serveBatch(t, r, path, []*URLPayload{
+			{URL: "https://github.com/darioblanco"},
+			{URL: "invalid"},
+		})
+		assert.Equal(t, []*BatchResult{internalServerError, badRequest}, results, path)
+	}
+}
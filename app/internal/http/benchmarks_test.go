@@ -8,9 +8,11 @@ import (
 	"github.com/darioblanco/shortesturl/app/internal/cache"
 	"github.com/darioblanco/shortesturl/app/internal/config"
 	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/darioblanco/shortesturl/app/internal/slug"
 )
 
 func createAPI(b *testing.B, urlLength int) api {
+	slugs, _ := slug.NewMD5(urlLength)
 	return api{
 		cache: cache.NewTest(),
 		config: &config.Values{
@@ -21,6 +23,7 @@ func createAPI(b *testing.B, urlLength int) api {
 			UrlExpirationInHours: 0,
 		},
 		logger: logging.NewTest(b),
+		slugs:  slugs,
 	}
 }
 
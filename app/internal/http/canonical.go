@@ -0,0 +1,121 @@
+package http
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"golang.org/x/net/idna"
+)
+
+// trackingParamPrefix is the prefix of the query params that only track campaigns
+const trackingParamPrefix = "utm_"
+
+// defaultPorts are stripped from the host of the urls with the given scheme
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// hostProfile converts the internationalized hosts to punycode. Hosts that are not
+// valid domain names for DNS lookups (e.g. with underscores) are still allowed.
+var hostProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+)
+
+// An urlCanonicalizer rewrites the long urls in their canonical form,
+// so equivalent urls are shortened with the same slug
+type urlCanonicalizer struct {
+	stripTrackingParams bool
+}
+
+// newURLCanonicalizer creates an url canonicalizer from the application config,
+// being nil if the long urls are kept as they are sent
+func newURLCanonicalizer(conf config.CanonicalizeValues) *urlCanonicalizer {
+	if !conf.Enabled {
+		return nil
+	}
+	return &urlCanonicalizer{stripTrackingParams: conf.StripTrackingParams}
+}
+
+// Canonicalize returns the canonical form of the given absolute url, returning an error,
+// that can be shown to the end user, if its host is not valid. The scheme and the host
+// are lowercased, the host is converted to punycode and the default port is stripped.
+// The dot segments of the path are resolved and the query params are sorted by key.
+func (c *urlCanonicalizer) Canonicalize(u *url.URL) (*url.URL, error) {
+	canonical := *u
+	canonical.Scheme = strings.ToLower(u.Scheme)
+	host, err := canonicalHost(u.Hostname())
+	if err != nil {
+		return nil, errors.New("invalid url host")
+	}
+	port := u.Port()
+	if port == defaultPorts[canonical.Scheme] {
+		port = ""
+	}
+	switch {
+	case port != "":
+		canonical.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		canonical.Host = "[" + host + "]"
+	default:
+		canonical.Host = host
+	}
+	// Resolving the url itself removes its dot segments (RFC 3986), keeping its escaping
+	resolved := u.ResolveReference(&url.URL{})
+	canonical.Path = resolved.Path
+	canonical.RawPath = resolved.RawPath
+	if canonical.Path == "" {
+		// The empty path of an http url is equivalent to the root path
+		canonical.Path = "/"
+	}
+	canonical.RawQuery = c.canonicalQuery(u.RawQuery)
+	canonical.ForceQuery = false
+	return &canonical, nil
+}
+
+// canonicalHost returns the given host in lowercase, converted to punycode
+// if it is an internationalized domain name
+func canonicalHost(host string) (string, error) {
+	if host == "" {
+		return "", errors.New("empty host")
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return strings.ToLower(host), nil
+	}
+	return hostProfile.ToASCII(host)
+}
+
+// canonicalQuery sorts the params of the given raw query by key, removing the empty ones.
+// The params are not decoded, thus their escaping and the order of the values of the same
+// key are kept.
+func (c *urlCanonicalizer) canonicalQuery(rawQuery string) string {
+	var params []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" || (c.stripTrackingParams && isTrackingParam(param)) {
+			continue
+		}
+		params = append(params, param)
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		return queryKey(params[i]) < queryKey(params[j])
+	})
+	return strings.Join(params, "&")
+}
+
+// isTrackingParam returns true if the given raw query param tracks a campaign (utm_*)
+func isTrackingParam(param string) bool {
+	key, err := url.QueryUnescape(queryKey(param))
+	return err == nil && strings.HasPrefix(strings.ToLower(key), trackingParamPrefix)
+}
+
+// queryKey returns the raw key of the given raw query param
+func queryKey(param string) string {
+	if i := strings.Index(param, "="); i >= 0 {
+		return param[:i]
+	}
+	return param
+}
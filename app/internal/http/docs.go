@@ -29,6 +29,10 @@ type docs struct {
 // @host localhost
 // @BasePath /
 // @query.collection.format multi
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
 func (rs docs) Router() chi.Router {
 	r := chi.NewRouter()
 	swaggerJSONUrl := url.URL{
@@ -2,29 +2,128 @@ package http
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/clicks"
+	"github.com/darioblanco/shortesturl/app/internal/qr"
 	"github.com/go-chi/render"
 )
 
 // A URLPayload defines the JSON payload for sending and receiving urls
 type URLPayload struct {
-	URL       string  `json:"url"`
-	ParsedURL url.URL `json:"-"`
+	URL        string        `json:"url"`
+	Alias      string        `json:"alias,omitempty"`
+	Title      string        `json:"title,omitempty"`
+	Tags       []string      `json:"tags,omitempty"`
+	ExpiresIn  string        `json:"expiresIn,omitempty"`
+	ExpiresAt  *time.Time    `json:"expiresAt,omitempty"`
+	Reuse      *bool         `json:"reuse,omitempty"`
+	QR         string        `json:"qr,omitempty"`
+	QRCode     string        `json:"qrCode,omitempty"`
+	ParsedURL  url.URL       `json:"-"`
+	Expiration time.Duration `json:"-"`
+	// canonicalizer rewrites the url in its canonical form, being nil if it is kept as it is
+	canonicalizer *urlCanonicalizer
 }
 
+const (
+	// maxTitleLength caps the length of the title of a short url
+	maxTitleLength = 256
+	// maxTags caps the number of tags of a short url
+	maxTags = 20
+	// maxTagLength caps the length of each tag of a short url
+	maxTagLength = 64
+)
+
 // Bind validates the incoming request payload
 func (ur *URLPayload) Bind(r *http.Request) error {
-	u, err := url.Parse(ur.URL)
+	u, err := parseHTTPURL(ur.URL)
+	if err != nil {
+		return err
+	}
+	if ur.canonicalizer != nil {
+		if u, err = ur.canonicalizer.Canonicalize(u); err != nil {
+			return err
+		}
+		ur.URL = u.String()
+	}
+	ur.ParsedURL = *u
+	if err := validateMetadata(ur.Title, ur.Tags); err != nil {
+		return err
+	}
+	if ur.QR != "" && ur.QR != qr.FormatPNG && ur.QR != qr.FormatSVG {
+		return fmt.Errorf("invalid qr %q (allowed: png, svg)", ur.QR)
+	}
+	ur.Expiration, err = parseExpiration(ur.ExpiresIn, ur.ExpiresAt)
+	return err
+}
+
+// validateMetadata checks the length of the title and the tags of a short url
+func validateMetadata(title string, tags []string) error {
+	if len(title) > maxTitleLength {
+		return fmt.Errorf("title must have at most %d characters", maxTitleLength)
+	}
+	if len(tags) > maxTags {
+		return fmt.Errorf("at most %d tags can be set", maxTags)
+	}
+	for _, tag := range tags {
+		if tag == "" || len(tag) > maxTagLength {
+			return fmt.Errorf("tags must have between 1 and %d characters", maxTagLength)
+		}
+	}
+	return nil
+}
+
+// parseHTTPURL parses the given absolute url, which must have an http or https scheme
+func parseHTTPURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
 	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
 		// I have considered that the url shortener should only accept HTTP or HTTPS
 		// schemes, because I think that the next step would be to perform an
 		// automatic redirect of these endpoints
-		return errors.New("invalid http/https url format")
+		return nil, errors.New("invalid http/https url format")
 	}
-	ur.ParsedURL = *u
-	return nil
+	return u, nil
+}
+
+// parseExpiration returns the expiration requested with either a duration or a date,
+// being zero if none of them is set. The requested expiration is relative to the moment
+// the request is received.
+func parseExpiration(expiresIn string, expiresAt *time.Time) (time.Duration, error) {
+	if expiresIn != "" && expiresAt != nil {
+		return 0, errors.New("expiresIn and expiresAt can not be set at the same time")
+	}
+	if expiresIn != "" {
+		expiration, err := time.ParseDuration(expiresIn)
+		if err != nil || expiration <= 0 {
+			return 0, errors.New("expiresIn must be a positive duration (e.g. 72h)")
+		}
+		return expiration, nil
+	}
+	if expiresAt != nil {
+		expiration := time.Until(*expiresAt)
+		if expiration <= 0 {
+			return 0, errors.New("expiresAt must be in the future")
+		}
+		return expiration, nil
+	}
+	return 0, nil
+}
+
+// reusable returns true if the existing short url of the long url can be returned
+// (unless reuse is false), instead of generating a fresh one. Aliases are never
+// looked up nor indexed.
+func (ur *URLPayload) reusable() bool {
+	return ur.Alias == "" && (ur.Reuse == nil || *ur.Reuse)
+}
+
+// unique returns true if the long url requires a fresh short url, that is not shared
+func (ur *URLPayload) unique() bool {
+	return ur.Alias == "" && ur.Reuse != nil && !*ur.Reuse
 }
 
 // Render defines the HTTP status code to 200
@@ -33,14 +132,305 @@ func (ur *URLPayload) Render(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-// A LongURL struct for the Swagger documentation
+// A LongURL defines the JSON payload of a decoded short url and its metadata
 type LongURL struct {
-	URL string `json:"url" example:"https://github.com/darioblanco"`
+	URL       string     `json:"url" example:"https://github.com/darioblanco"`
+	Title     string     `json:"title,omitempty" example:"Dario Blanco"`
+	Tags      []string   `json:"tags,omitempty" example:"profile,github"`
+	CreatedAt *time.Time `json:"createdAt,omitempty" example:"2026-10-17T15:04:05Z"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" example:"2026-12-31T23:59:59Z"`
+}
+
+func newLongURL(link *cache.Link) *LongURL {
+	return &LongURL{
+		URL:       link.URL,
+		Title:     link.Title,
+		Tags:      link.Tags,
+		CreatedAt: link.CreatedAt,
+		ExpiresAt: link.ExpiresAt,
+	}
+}
+
+// Render defines the HTTP status code to 200
+func (lu *LongURL) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, http.StatusOK)
+	return nil
+}
+
+// A Link defines the JSON payload of a short url and its settings
+type Link struct {
+	Slug     string `json:"slug" example:"64fc5e"`
+	URL      string `json:"url" example:"https://github.com/darioblanco"`
+	ShortURL string `json:"shortUrl" example:"http://localhost:3000/64fc5e"`
+	// CreatedAt is not known for the short urls encoded by previous versions of the service
+	CreatedAt *time.Time `json:"createdAt,omitempty" example:"2026-10-17T15:04:05Z"`
+	Title     string     `json:"title,omitempty" example:"Dario Blanco"`
+	Tags      []string   `json:"tags,omitempty" example:"profile,github"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" example:"2026-12-31T23:59:59Z"`
+	Disabled  bool       `json:"disabled" example:"false"`
+	// Owner is the ID of the API key that encoded the short url (if any)
+	Owner string `json:"owner,omitempty" example:"0123456789ab"`
+	// stored is the link record the payload was built from
+	stored *cache.Link
+}
+
+// Render defines the HTTP status code to 200
+func (l *Link) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, http.StatusOK)
+	return nil
+}
+
+// A LinkList defines the JSON payload of a page of short urls
+type LinkList struct {
+	Links []*Link `json:"links"`
+	// Cursor is used to request the next page, being empty in the last one
+	Cursor string `json:"cursor,omitempty" example:"17"`
+}
+
+// Render defines the HTTP status code to 200
+func (ll *LinkList) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, http.StatusOK)
+	return nil
+}
+
+// A LinkUpdate defines the JSON payload for changing the settings of a short url.
+// The settings that are not set are kept.
+type LinkUpdate struct {
+	URL   *string `json:"url,omitempty"`
+	Title *string `json:"title,omitempty"`
+	// Tags replace the current tags if they are set, thus an empty list removes them
+	Tags       []string      `json:"tags,omitempty"`
+	ExpiresIn  string        `json:"expiresIn,omitempty"`
+	ExpiresAt  *time.Time    `json:"expiresAt,omitempty"`
+	Disabled   *bool         `json:"disabled,omitempty"`
+	ParsedURL  *url.URL      `json:"-"`
+	Expiration time.Duration `json:"-"`
+	// canonicalizer rewrites the url in its canonical form, being nil if it is kept as it is
+	canonicalizer *urlCanonicalizer
+}
+
+// Bind validates the incoming request payload
+func (lu *LinkUpdate) Bind(r *http.Request) error {
+	if lu.URL == nil && lu.Title == nil && lu.Tags == nil &&
+		lu.ExpiresIn == "" && lu.ExpiresAt == nil && lu.Disabled == nil {
+		return errors.New(
+			"at least one of url, title, tags, expiresIn, expiresAt or disabled has to be set",
+		)
+	}
+	if lu.URL != nil {
+		u, err := parseHTTPURL(*lu.URL)
+		if err != nil {
+			return err
+		}
+		if lu.canonicalizer != nil {
+			if u, err = lu.canonicalizer.Canonicalize(u); err != nil {
+				return err
+			}
+			canonicalURL := u.String()
+			lu.URL = &canonicalURL
+		}
+		lu.ParsedURL = u
+	}
+	var title string
+	if lu.Title != nil {
+		title = *lu.Title
+	}
+	if err := validateMetadata(title, lu.Tags); err != nil {
+		return err
+	}
+	var err error
+	lu.Expiration, err = parseExpiration(lu.ExpiresIn, lu.ExpiresAt)
+	return err
+}
+
+// apply returns a copy of the given link record with the changed settings, in the current
+// version of the records. The expiration is not applied, as it is capped by the server.
+func (lu *LinkUpdate) apply(stored *cache.Link) *cache.Link {
+	link := *stored
+	link.Version = cache.LinkVersion
+	if lu.URL != nil {
+		link.URL = *lu.URL
+	}
+	if lu.Title != nil {
+		link.Title = *lu.Title
+	}
+	if lu.Tags != nil {
+		link.Tags = lu.Tags
+		if len(lu.Tags) == 0 {
+			link.Tags = nil
+		}
+	}
+	if lu.Disabled != nil {
+		link.Disabled = *lu.Disabled
+	}
+	return &link
+}
+
+// A BatchRequest defines the JSON payload of a batch of urls to encode or decode
+type BatchRequest struct {
+	URLs []*URLPayload `json:"urls"`
+}
+
+// Bind validates that the batch is not empty. Each url is validated on its own later,
+// as an invalid url does not fail the whole batch.
+func (br *BatchRequest) Bind(r *http.Request) error {
+	if len(br.URLs) == 0 {
+		return errors.New("urls can not be empty")
+	}
+	for i, u := range br.URLs {
+		if u == nil {
+			// It fails its own validation
+			br.URLs[i] = &URLPayload{}
+		}
+	}
+	return nil
+}
+
+// A BatchResponse defines the JSON payload of the results of a batch,
+// which are in the same order as the requested urls
+type BatchResponse struct {
+	URLs []*BatchResult `json:"urls"`
+}
+
+// Render defines the HTTP status code to 200, as each url has its own status
+func (br *BatchResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, http.StatusOK)
+	return nil
+}
+
+// A BatchResult defines the result of a url of a batch, with the same status and error of
+// the single url endpoints. The url and its metadata are only set if it succeeded.
+type BatchResult struct {
+	Code       int        `json:"code" example:"200"`
+	StatusText string     `json:"status" example:"OK"`
+	AppCode    string     `json:"appCode,omitempty" example:"private_network"`
+	ErrorText  string     `json:"error,omitempty" example:"invalid http/https url format"`
+	URL        string     `json:"url,omitempty" example:"http://localhost:3000/64fc5e"`
+	Title      string     `json:"title,omitempty" example:"Dario Blanco"`
+	Tags       []string   `json:"tags,omitempty" example:"profile,github"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty" example:"2026-10-17T15:04:05Z"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty" example:"2026-12-31T23:59:59Z"`
+}
+
+// newBatchResult returns a successful result with the given url
+func newBatchResult(url string) *BatchResult {
+	return &BatchResult{
+		Code:       http.StatusOK,
+		StatusText: http.StatusText(http.StatusOK),
+		URL:        url,
+	}
+}
+
+// newBatchError returns a failed result with the status and the
+// error of the given error response (e.g. ErrBadRequest)
+func newBatchError(errResponse render.Renderer) *BatchResult {
+	e := errResponse.(*ErrHTTPResponse)
+	return &BatchResult{
+		Code:       e.HTTPStatusCode,
+		StatusText: e.StatusText,
+		AppCode:    e.AppCode,
+		ErrorText:  e.ErrorText,
+	}
+}
+
+// LinkStats defines the JSON payload with the click stats of a short url
+type LinkStats struct {
+	Slug          string        `json:"slug" example:"64fc5e"`
+	Total         int64         `json:"total" example:"42"`
+	LastClickAt   *time.Time    `json:"lastClickAt,omitempty" example:"2026-10-17T15:04:05Z"`
+	LastRequestID string        `json:"lastRequestId,omitempty" example:"shortesturl/Xk3JCDnHVc-000042"`
+	Hourly        []ClickBucket `json:"hourly"`
+	Daily         []ClickBucket `json:"daily"`
+	TopReferers   []TopClicks   `json:"topReferers"`
+	TopUserAgents []TopClicks   `json:"topUserAgents"`
+	TopCountries  []TopClicks   `json:"topCountries"`
+}
+
+// A ClickBucket holds the clicks of the hour or day that starts at the given time
+type ClickBucket struct {
+	Time   time.Time `json:"time" example:"2026-10-17T15:00:00Z"`
+	Clicks int64     `json:"clicks" example:"3"`
+}
+
+// TopClicks holds the clicks of a referer (host), user agent or country (ISO code)
+type TopClicks struct {
+	Value  string `json:"value" example:"github.com"`
+	Clicks int64  `json:"clicks" example:"12"`
+}
+
+func newLinkStats(slug string, stats *clicks.Stats) *LinkStats {
+	return &LinkStats{
+		Slug:          slug,
+		Total:         stats.Total,
+		LastClickAt:   stats.LastClickAt,
+		LastRequestID: stats.LastRequestID,
+		Hourly:        newClickBuckets(stats.Hourly),
+		Daily:         newClickBuckets(stats.Daily),
+		TopReferers:   newTopClicks(stats.TopReferers),
+		TopUserAgents: newTopClicks(stats.TopUserAgents),
+		TopCountries:  newTopClicks(stats.TopCountries),
+	}
+}
+
+func newClickBuckets(buckets []clicks.Bucket) []ClickBucket {
+	series := make([]ClickBucket, len(buckets))
+	for i, bucket := range buckets {
+		series[i] = ClickBucket{Time: bucket.Time, Clicks: bucket.Clicks}
+	}
+	return series
+}
+
+func newTopClicks(counts []cache.MemberCount) []TopClicks {
+	top := make([]TopClicks, len(counts))
+	for i, count := range counts {
+		top[i] = TopClicks{Value: count.Member, Clicks: count.Count}
+	}
+	return top
+}
+
+// Render defines the HTTP status code to 200
+func (ls *LinkStats) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, http.StatusOK)
+	return nil
+}
+
+// An EncodeRequest struct for the Swagger documentation
+type EncodeRequest struct {
+	URL       string   `json:"url" example:"https://github.com/darioblanco"`
+	Alias     string   `json:"alias,omitempty" example:"launch-2026"`
+	Title     string   `json:"title,omitempty" example:"Dario Blanco"`
+	Tags      []string `json:"tags,omitempty" example:"profile,github"`
+	ExpiresIn string   `json:"expiresIn,omitempty" example:"72h"`
+	ExpiresAt string   `json:"expiresAt,omitempty" example:"2026-12-31T23:59:59Z"`
+	Reuse     *bool    `json:"reuse,omitempty" example:"true"`
+	QR        string   `json:"qr,omitempty" enums:"png,svg" example:"svg"`
+}
+
+// An EncodeBatchRequest struct for the Swagger documentation
+type EncodeBatchRequest struct {
+	URLs []EncodeRequest `json:"urls"`
+}
+
+// A DecodeBatchRequest struct for the Swagger documentation
+type DecodeBatchRequest struct {
+	URLs []ShortURL `json:"urls"`
+}
+
+// An UpdateLinkRequest struct for the Swagger documentation
+type UpdateLinkRequest struct {
+	URL       string   `json:"url,omitempty" example:"https://github.com/darioblanco/shortesturl"`
+	Title     string   `json:"title,omitempty" example:"Shortest URL"`
+	Tags      []string `json:"tags,omitempty" example:"project"`
+	ExpiresIn string   `json:"expiresIn,omitempty" example:"72h"`
+	ExpiresAt string   `json:"expiresAt,omitempty" example:"2026-12-31T23:59:59Z"`
+	Disabled  bool     `json:"disabled,omitempty" example:"true"`
 }
 
 // A ShortURL struct for the Swagger documentation
 type ShortURL struct {
-	URL string `json:"url" example:"http://localhost:3000/64fc5e"`
+	URL       string `json:"url" example:"http://localhost:3000/64fc5e"`
+	ExpiresAt string `json:"expiresAt,omitempty" example:"2026-12-31T23:59:59Z"`
+	QRCode    string `json:"qrCode,omitempty" example:"data:image/svg+xml;base64,PHN2ZyB4bWxucz0i..."`
 }
 
 // A BadRequest error struct for the Swagger documentation
@@ -49,12 +439,49 @@ type BadRequest struct {
 	ErrorText  string `json:"error,omitempty" example:"invalid http/https url format"`
 }
 
+// An Unauthorized error struct for the Swagger documentation
+type Unauthorized struct {
+	StatusText string `json:"status" example:"Unauthorized"`
+	ErrorText  string `json:"error,omitempty" example:"invalid api key"`
+}
+
+// A Forbidden error struct for the Swagger documentation
+type Forbidden struct {
+	StatusText string `json:"status" example:"Forbidden"`
+	ErrorText  string `json:"error,omitempty" example:"the link is owned by a different api key"`
+}
+
 // A NotFound error struct for the Swagger documentation
 type NotFound struct {
 	StatusText string `json:"status" example:"Not Found"`
 	ErrorText  string `json:"error,omitempty" example:"long url not found"`
 }
 
+// A Conflict error struct for the Swagger documentation
+type Conflict struct {
+	StatusText string `json:"status" example:"Conflict"`
+	ErrorText  string `json:"error,omitempty" example:"alias is already in use"`
+}
+
+// A Gone error struct for the Swagger documentation
+type Gone struct {
+	StatusText string `json:"status" example:"Gone"`
+	ErrorText  string `json:"error,omitempty" example:"long url expired"`
+}
+
+// An UnprocessableEntity error struct for the Swagger documentation
+type UnprocessableEntity struct {
+	StatusText string `json:"status" example:"Unprocessable Entity"`
+	AppCode    string `json:"appCode,omitempty" example:"private_network"`
+	ErrorText  string `json:"error,omitempty" example:"host 127.0.0.1 is not a public address"`
+}
+
+// A TooManyRequests error struct for the Swagger documentation
+type TooManyRequests struct {
+	StatusText string `json:"status" example:"Too Many Requests"`
+	ErrorText  string `json:"error,omitempty" example:"rate limit exceeded"`
+}
+
 // A InternalServerError error struct for the Swagger documentation
 type InternalServerError struct {
 	StatusText string `json:"status" example:"Internal Server Error"`
@@ -3,8 +3,11 @@ package http
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/darioblanco/shortesturl/app/internal/cache"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,6 +38,103 @@ func TestURLPayload_Bind_InvalidURL(t *testing.T) {
 	}
 }
 
+func TestURLPayload_Bind_Expiration(t *testing.T) {
+	data := &URLPayload{URL: "http://valid.com", ExpiresIn: "72h"}
+	assert.NoError(t, data.Bind(&http.Request{}))
+	assert.Equal(t, 72*time.Hour, data.Expiration)
+
+	expiresAt := time.Now().Add(time.Hour)
+	data = &URLPayload{URL: "http://valid.com", ExpiresAt: &expiresAt}
+	assert.NoError(t, data.Bind(&http.Request{}))
+	assert.InDelta(t, time.Hour, data.Expiration, float64(time.Second))
+}
+
+func TestURLPayload_Bind_InvalidExpiration(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	t.Parallel()
+	tests := []struct {
+		name          string
+		expiresIn     string
+		expiresAt     *time.Time
+		expectedError string
+	}{
+		{"both", "1h", &future, "expiresIn and expiresAt can not be set at the same time"},
+		{"invalid duration", "1 week", nil, "expiresIn must be a positive duration (e.g. 72h)"},
+		{"negative duration", "-1h", nil, "expiresIn must be a positive duration (e.g. 72h)"},
+		{"past", "", &past, "expiresAt must be in the future"},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			data := &URLPayload{
+				URL:       "http://valid.com",
+				ExpiresIn: tt.expiresIn,
+				ExpiresAt: tt.expiresAt,
+			}
+			assert.EqualError(t, data.Bind(&http.Request{}), tt.expectedError)
+		})
+	}
+}
+
+func TestURLPayload_Bind_InvalidMetadata(t *testing.T) {
+	t.Parallel()
+	tooManyTags := make([]string, 21)
+	for i := range tooManyTags {
+		tooManyTags[i] = "tag"
+	}
+	tests := []struct {
+		name          string
+		title         string
+		tags          []string
+		expectedError string
+	}{
+		{"long title", strings.Repeat("a", 257), nil, "title must have at most 256 characters"},
+		{"too many tags", "", tooManyTags, "at most 20 tags can be set"},
+		{"empty tag", "", []string{""}, "tags must have between 1 and 64 characters"},
+		{
+			"long tag",
+			"",
+			[]string{strings.Repeat("a", 65)},
+			"tags must have between 1 and 64 characters",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			data := &URLPayload{URL: "http://valid.com", Title: tt.title, Tags: tt.tags}
+			assert.EqualError(t, data.Bind(&http.Request{}), tt.expectedError)
+		})
+	}
+}
+
+func TestURLPayload_Reuse(t *testing.T) {
+	t.Parallel()
+	reuse, noReuse := true, false
+	tests := []struct {
+		name             string
+		data             URLPayload
+		expectedReusable bool
+		expectedUnique   bool
+	}{
+		{"default", URLPayload{}, true, false},
+		{"reuse", URLPayload{Reuse: &reuse}, true, false},
+		{"no reuse", URLPayload{Reuse: &noReuse}, false, true},
+		{"alias", URLPayload{Alias: "launch-2026"}, false, false},
+		{"alias without reuse", URLPayload{Alias: "launch-2026", Reuse: &noReuse}, false, false},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expectedReusable, tt.data.reusable())
+			assert.Equal(t, tt.expectedUnique, tt.data.unique())
+		})
+	}
+}
+
 func TestURLPayload_Render(t *testing.T) {
 	data := &URLPayload{URL: "http://valid.com"}
 	rr := httptest.NewRecorder()
@@ -42,3 +142,77 @@ func TestURLPayload_Render(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rr.Code)
 }
+
+func TestLinkUpdate_Bind(t *testing.T) {
+	longURL := "https://darioblanco.com"
+	disabled := true
+	data := &LinkUpdate{URL: &longURL, ExpiresIn: "72h", Disabled: &disabled}
+	assert.NoError(t, data.Bind(&http.Request{}))
+	assert.Equal(t, 72*time.Hour, data.Expiration)
+
+	data = &LinkUpdate{Disabled: &disabled}
+	assert.NoError(t, data.Bind(&http.Request{}))
+	assert.Equal(t, time.Duration(0), data.Expiration)
+
+	// An empty list of tags removes them
+	data = &LinkUpdate{Tags: []string{}}
+	assert.NoError(t, data.Bind(&http.Request{}))
+}
+
+func TestLinkUpdate_Apply(t *testing.T) {
+	longURL := "https://darioblanco.com"
+	title := "Dario Blanco"
+	disabled := true
+	stored := &cache.Link{URL: "https://github.com/darioblanco", Tags: []string{"profile"}}
+	link := (&LinkUpdate{URL: &longURL, Title: &title, Disabled: &disabled}).apply(stored)
+	assert.Equal(t, &cache.Link{
+		Version:  cache.LinkVersion,
+		URL:      "https://darioblanco.com",
+		Title:    "Dario Blanco",
+		Tags:     []string{"profile"},
+		Disabled: true,
+	}, link)
+	// The stored link is not changed
+	assert.Equal(t, 0, stored.Version)
+
+	link = (&LinkUpdate{Tags: []string{}}).apply(stored)
+	assert.Nil(t, link.Tags)
+}
+
+func TestLinkUpdate_Bind_Invalid(t *testing.T) {
+	invalidURL := "weird://weirdscheme.com"
+	longTitle := strings.Repeat("a", 257)
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name          string
+		data          *LinkUpdate
+		expectedError string
+	}{
+		{
+			"empty",
+			&LinkUpdate{},
+			"at least one of url, title, tags, expiresIn, expiresAt or disabled has to be set",
+		},
+		{"long title", &LinkUpdate{Title: &longTitle}, "title must have at most 256 characters"},
+		{"invalid url", &LinkUpdate{URL: &invalidURL}, "invalid http/https url format"},
+		{"past", &LinkUpdate{ExpiresAt: &past}, "expiresAt must be in the future"},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, tt.data.Bind(&http.Request{}), tt.expectedError)
+		})
+	}
+}
+
+func TestLink_Render(t *testing.T) {
+	rr := httptest.NewRecorder()
+	assert.NoError(t, (&Link{Slug: "64fc5e"}).Render(rr, &http.Request{}))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestLinkList_Render(t *testing.T) {
+	rr := httptest.NewRecorder()
+	assert.NoError(t, (&LinkList{}).Render(rr, &http.Request{}))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -15,8 +15,9 @@ type ErrHTTPResponse struct {
 	Err            error `json:"-"` // low-level runtime error
 	HTTPStatusCode int   `json:"-"` // http response status code
 
-	StatusText string `json:"status"`          // user-level status message
-	ErrorText  string `json:"error,omitempty"` // application-level error message
+	StatusText string `json:"status"`            // user-level status message
+	AppCode    string `json:"appCode,omitempty"` // application-specific error code
+	ErrorText  string `json:"error,omitempty"`   // application-level error message
 }
 
 // Render defines the HTTP status code based on its inherent error
@@ -36,6 +37,28 @@ func ErrBadRequest(err error) render.Renderer {
 	}
 }
 
+// ErrUnauthorized returns a 401 and the error message
+// The message from the error passed as parameter IS shown to the end user
+func ErrUnauthorized(err error) render.Renderer {
+	return &ErrHTTPResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusUnauthorized,
+		StatusText:     http.StatusText(http.StatusUnauthorized),
+		ErrorText:      err.Error(),
+	}
+}
+
+// ErrForbidden returns a 403 and the error message
+// The message from the error passed as parameter IS shown to the end user
+func ErrForbidden(err error) render.Renderer {
+	return &ErrHTTPResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusForbidden,
+		StatusText:     http.StatusText(http.StatusForbidden),
+		ErrorText:      err.Error(),
+	}
+}
+
 // ErrNotFound returns a 404 and the error message
 // The message from the error passed as parameter IS shown to the end user
 func ErrNotFound(err error) render.Renderer {
@@ -47,6 +70,51 @@ func ErrNotFound(err error) render.Renderer {
 	}
 }
 
+// ErrConflict returns a 409 and the error message
+// The message from the error passed as parameter IS shown to the end user
+func ErrConflict(err error) render.Renderer {
+	return &ErrHTTPResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusConflict,
+		StatusText:     http.StatusText(http.StatusConflict),
+		ErrorText:      err.Error(),
+	}
+}
+
+// ErrGone returns a 410 and the error message
+// The message from the error passed as parameter IS shown to the end user
+func ErrGone(err error) render.Renderer {
+	return &ErrHTTPResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusGone,
+		StatusText:     http.StatusText(http.StatusGone),
+		ErrorText:      err.Error(),
+	}
+}
+
+// ErrUnprocessableEntity returns a 422, the application-specific error code and the error message
+// The message from the error passed as parameter IS shown to the end user
+func ErrUnprocessableEntity(err error, appCode string) render.Renderer {
+	return &ErrHTTPResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusUnprocessableEntity,
+		StatusText:     http.StatusText(http.StatusUnprocessableEntity),
+		AppCode:        appCode,
+		ErrorText:      err.Error(),
+	}
+}
+
+// ErrTooManyRequests returns a 429 and the error message
+// The message from the error passed as parameter IS shown to the end user
+func ErrTooManyRequests(err error) render.Renderer {
+	return &ErrHTTPResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusTooManyRequests,
+		StatusText:     http.StatusText(http.StatusTooManyRequests),
+		ErrorText:      err.Error(),
+	}
+}
+
 // ErrInternalServerError returns a 500 and a generic message
 // The message from the error passed as parameter IS NOT shown to the end user
 func ErrInternalServerError(err error) render.Renderer {
@@ -35,6 +35,28 @@ func TestErrBadRequest(t *testing.T) {
 	}, res)
 }
 
+func TestErrUnauthorized(t *testing.T) {
+	err := errors.New("Unknown error")
+	res := ErrUnauthorized(err)
+	assert.Equal(t, &ErrHTTPResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusUnauthorized,
+		StatusText:     http.StatusText(http.StatusUnauthorized),
+		ErrorText:      err.Error(),
+	}, res)
+}
+
+func TestErrForbidden(t *testing.T) {
+	err := errors.New("Unknown error")
+	res := ErrForbidden(err)
+	assert.Equal(t, &ErrHTTPResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusForbidden,
+		StatusText:     http.StatusText(http.StatusForbidden),
+		ErrorText:      err.Error(),
+	}, res)
+}
+
 func TestErrNotFound(t *testing.T) {
 	err := errors.New("Unknown error")
 	res := ErrNotFound(err)
@@ -46,6 +68,39 @@ func TestErrNotFound(t *testing.T) {
 	}, res)
 }
 
+func TestErrConflict(t *testing.T) {
+	err := errors.New("Unknown error")
+	res := ErrConflict(err)
+	assert.Equal(t, &ErrHTTPResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusConflict,
+		StatusText:     http.StatusText(http.StatusConflict),
+		ErrorText:      err.Error(),
+	}, res)
+}
+
+func TestErrGone(t *testing.T) {
+	err := errors.New("Unknown error")
+	res := ErrGone(err)
+	assert.Equal(t, &ErrHTTPResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusGone,
+		StatusText:     http.StatusText(http.StatusGone),
+		ErrorText:      err.Error(),
+	}, res)
+}
+
+func TestErrTooManyRequests(t *testing.T) {
+	err := errors.New("Unknown error")
+	res := ErrTooManyRequests(err)
+	assert.Equal(t, &ErrHTTPResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusTooManyRequests,
+		StatusText:     http.StatusText(http.StatusTooManyRequests),
+		ErrorText:      err.Error(),
+	}, res)
+}
+
 func TestErrInternalServerError(t *testing.T) {
 	err := errors.New("something really bad")
 	res := ErrInternalServerError(err)
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+)
+
+// tombstoneKeyPrefix namespaces the tombstones of the expired slugs
+const tombstoneKeyPrefix = "tombstone:"
+
+// defaultTombstoneRetention is used when the retention is not configured, as the
+// tombstones of every expired or deleted slug would be kept forever otherwise
+const defaultTombstoneRetention = 720 * time.Hour
+
+// tombstoneKey returns the cache key that remembers that the given slug existed
+// after it expired, so expired urls can be told apart from the ones that never existed
+func tombstoneKey(slug string) string {
+	return tombstoneKeyPrefix + slug
+}
+
+// expiration returns the effective expiration of a short url.
+// The global expiration is used if the client did not request any, and the
+// result is always capped to the maximum expiration (if set).
+func (rs api) expiration(requested time.Duration) time.Duration {
+	reloadable := rs.config.Reloadable()
+	expiration := requested
+	if expiration == 0 {
+		expiration = time.Hour * reloadable.UrlExpirationInHours
+	}
+	max := time.Hour * reloadable.UrlMaxExpirationInHours
+	if max > 0 && (expiration == 0 || expiration > max) {
+		expiration = max
+	}
+	return expiration
+}
+
+// storeTombstone reads the time to live of the given slug and keeps a tombstone that will
+// outlive it by the configured retention. The expiration date of the slug is returned,
+// being nil if the slug never expires.
+func (rs api) storeTombstone(ctx context.Context, slug string) (*time.Time, error) {
+	ttl, err := rs.cache.TTL(ctx, slug)
+	if err != nil || ttl == 0 {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(ttl).UTC().Round(time.Second)
+	tombstone := rs.tombstone(slug, expiresAt, ttl)
+	if err := rs.cache.Set(ctx, tombstone.Key, tombstone.Value, tombstone.Expiration); err != nil {
+		return nil, err
+	}
+	return &expiresAt, nil
+}
+
+// tombstone returns the tombstone of the given slug, which expires at the given date
+// (in the given time to live)
+func (rs api) tombstone(slug string, expiresAt time.Time, ttl time.Duration) cache.Entry {
+	return cache.Entry{
+		Key:        tombstoneKey(slug),
+		Value:      expiresAt.Format(time.RFC3339),
+		Expiration: ttl + rs.tombstoneRetention(),
+	}
+}
+
+// tombstoneRetention returns how long the tombstones outlive their slugs
+func (rs api) tombstoneRetention() time.Duration {
+	if rs.config.UrlTombstoneRetentionInHours <= 0 {
+		return defaultTombstoneRetention
+	}
+	return time.Hour * rs.config.UrlTombstoneRetentionInHours
+}
+
+// isExpired returns true if the given slug that is no longer in the cache has
+// a tombstone, which means that it expired
+func (rs api) isExpired(ctx context.Context, slug string) (bool, error) {
+	tombstone, err := rs.cache.Get(ctx, tombstoneKey(slug))
+	return tombstone != "", err
+}
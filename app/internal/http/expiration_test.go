@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTombstoneKey(t *testing.T) {
+	assert.Equal(t, "tombstone:64fc5e", tombstoneKey("64fc5e"))
+}
+
+func TestExpiration(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		global    time.Duration
+		max       time.Duration
+		requested time.Duration
+		expected  time.Duration
+	}{
+		{"forever", 0, 0, 0, 0},
+		{"global", 24, 0, 0, 24 * time.Hour},
+		{"requested", 24, 0, time.Minute, time.Minute},
+		{"requested over global", 24, 0, 48 * time.Hour, 48 * time.Hour},
+		{"capped forever", 0, 72, 0, 72 * time.Hour},
+		{"capped global", 96, 72, 0, 72 * time.Hour},
+		{"capped requested", 0, 72, 96 * time.Hour, 72 * time.Hour},
+		{"requested under max", 0, 72, time.Hour, time.Hour},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rs := api{config: &config.Values{
+				UrlExpirationInHours:    tt.global,
+				UrlMaxExpirationInHours: tt.max,
+			}}
+			assert.Equal(t, tt.expected, rs.expiration(tt.requested))
+		})
+	}
+}
+
+func TestStoreTombstone(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	mr.SetTTL("test:64fc5e", time.Hour)
+	rs := api{cache: c, config: &config.Values{UrlTombstoneRetentionInHours: 24}}
+	expiresAt, err := rs.storeTombstone(context.Background(), "64fc5e")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *expiresAt, 2*time.Second)
+	tombstone, _ := mr.Get("test:tombstone:64fc5e")
+	assert.Equal(t, expiresAt.Format(time.RFC3339), tombstone)
+	assert.Equal(t, 25*time.Hour, mr.TTL("test:tombstone:64fc5e"))
+}
+
+func TestStoreTombstone_DefaultRetention(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	mr.SetTTL("test:64fc5e", time.Hour)
+	rs := api{cache: c, config: &config.Values{}}
+	_, err := rs.storeTombstone(context.Background(), "64fc5e")
+	assert.NoError(t, err)
+	assert.True(t, mr.Exists("test:tombstone:64fc5e"))
+	// The tombstones are never kept forever
+	assert.Equal(t, time.Hour+defaultTombstoneRetention, mr.TTL("test:tombstone:64fc5e"))
+}
+
+func TestStoreTombstone_NoExpiration(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	rs := api{cache: c, config: &config.Values{}}
+	expiresAt, err := rs.storeTombstone(context.Background(), "64fc5e")
+	assert.NoError(t, err)
+	assert.Nil(t, expiresAt)
+	assert.False(t, mr.Exists("test:tombstone:64fc5e"))
+}
+
+func TestIsExpired(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	mr.Set("test:tombstone:64fc5e", "2026-01-01T00:00:00Z")
+	rs := api{cache: c, config: &config.Values{}}
+	expired, err := rs.isExpired(context.Background(), "64fc5e")
+	assert.NoError(t, err)
+	assert.True(t, expired)
+	expired, err = rs.isExpired(context.Background(), "abcdef")
+	assert.NoError(t, err)
+	assert.False(t, expired)
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+)
+
+// urlIndexKeyPrefix namespaces the reverse index of the shortened urls
+const urlIndexKeyPrefix = "url:"
+
+// urlIndexKey returns the cache key that holds the slug of the long url of the given link.
+// The url is hashed, as it can be much longer than a slug and hold any character. The index
+// of each API key is scoped by its ID (url:{keyId}:{hash}), as a short url is only shared
+// within the API key that encoded it.
+func urlIndexKey(link *cache.Link) string {
+	sum := sha256.Sum256([]byte(link.URL))
+	if link.CreatedBy == "" {
+		return urlIndexKeyPrefix + hex.EncodeToString(sum[:])
+	}
+	return urlIndexKeyPrefix + link.CreatedBy + ":" + hex.EncodeToString(sum[:])
+}
+
+// indexedSlug returns the slug of the long url of the given link in the reverse index,
+// being empty if the url was not shortened yet or its link can not be reused
+func (rs api) indexedSlug(ctx context.Context, link *cache.Link) (string, error) {
+	slug, err := rs.cache.Get(ctx, urlIndexKey(link))
+	if err != nil || slug == "" {
+		return "", err
+	}
+	indexed, err := rs.cache.GetLink(ctx, slug)
+	if err != nil || !isReusable(indexed, link) {
+		return "", err
+	}
+	return slug, nil
+}
+
+// isReusable returns true if the indexed link can be returned again for the given link.
+// The index is not updated when a link changes its url or it is deleted, thus it is only
+// trusted if the link still exists and it holds the same long url and API key.
+func isReusable(indexed *cache.Link, link *cache.Link) bool {
+	return indexed != nil && indexed.URL == link.URL && indexed.CreatedBy == link.CreatedBy &&
+		!indexed.Disabled
+}
+
+// urlIndex returns the reverse index entry of the long url of the given link,
+// which expires with the slug that it points to
+func urlIndex(link *cache.Link, slug string, expiresAt *time.Time) cache.Entry {
+	var expiration time.Duration
+	if expiresAt != nil {
+		// A zero expiration would keep the entry forever
+		if expiration = time.Until(*expiresAt); expiration < time.Second {
+			expiration = time.Second
+		}
+	}
+	return cache.Entry{Key: urlIndexKey(link), Value: slug, Expiration: expiration}
+}
@@ -0,0 +1,276 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/auth"
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// indexKey is the reverse index key of https://github.com/darioblanco
+const indexKey = "test:url:f65befd02eecc4abdac29c9b3ffe6e13494ce622ee5c41240ef13722a50d8925"
+
+func newIndexTestRouter(t *testing.T, c cache.Cache, generator string) http.Handler {
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{
+			HttpScheme: "http",
+			HttpHost:   "localhost",
+			HttpPort:   80,
+			Slug:       config.SlugValues{Generator: generator},
+			UrlLength:  6,
+		},
+		logging.NewTest(t),
+		c,
+	)
+	require.NoError(t, err)
+	return r
+}
+
+// encode encodes the given url, returning its short url
+func encode(t *testing.T, handler http.Handler, data URLPayload) string {
+	w := serveRequest(handler, http.MethodPost, "/encode", "", data)
+	require.Equal(t, http.StatusOK, w.Code)
+	var shortURL URLPayload
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shortURL))
+	return shortURL.URL
+}
+
+func TestURLIndexKey(t *testing.T) {
+	assert.Equal(t,
+		"url:f65befd02eecc4abdac29c9b3ffe6e13494ce622ee5c41240ef13722a50d8925",
+		urlIndexKey(&cache.Link{URL: "https://github.com/darioblanco"}),
+	)
+	// The index of each API key is scoped by its ID
+	assert.Equal(t,
+		"url:0123456789ab:f65befd02eecc4abdac29c9b3ffe6e13494ce622ee5c41240ef13722a50d8925",
+		urlIndexKey(&cache.Link{URL: "https://github.com/darioblanco", CreatedBy: "0123456789ab"}),
+	)
+}
+
+func TestIsReusable(t *testing.T) {
+	link := &cache.Link{URL: "https://github.com/darioblanco", CreatedBy: "0123456789ab"}
+	tests := []struct {
+		name     string
+		indexed  *cache.Link
+		expected bool
+	}{
+		{"same link", &cache.Link{URL: link.URL, CreatedBy: link.CreatedBy}, true},
+		{"missing", nil, false},
+		{"different url", &cache.Link{URL: "https://darioblanco.com", CreatedBy: link.CreatedBy}, false},
+		{"different api key", &cache.Link{URL: link.URL, CreatedBy: "ba9876543210"}, false},
+		{"without api key", &cache.Link{URL: link.URL}, false},
+		{"disabled", &cache.Link{URL: link.URL, CreatedBy: link.CreatedBy, Disabled: true}, false},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isReusable(tt.indexed, link))
+		})
+	}
+}
+
+func TestURLIndex(t *testing.T) {
+	link := &cache.Link{URL: "https://github.com/darioblanco"}
+	entry := urlIndex(link, "64fc5e", nil)
+	assert.Equal(t, cache.Entry{
+		Key:   "url:f65befd02eecc4abdac29c9b3ffe6e13494ce622ee5c41240ef13722a50d8925",
+		Value: "64fc5e",
+	}, entry)
+	expiresAt := time.Now().Add(time.Hour)
+	entry = urlIndex(link, "64fc5e", &expiresAt)
+	assert.InDelta(t, time.Hour, entry.Expiration, float64(time.Second))
+	// The entry never outlives an expired slug
+	expiresAt = time.Now().Add(-time.Hour)
+	entry = urlIndex(link, "64fc5e", &expiresAt)
+	assert.Equal(t, time.Second, entry.Expiration)
+}
+
+func TestEncode_ReverseIndex(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	mr.Set("test:64fc5e", "https://darioblanco.com")
+	r := newIndexTestRouter(t, client, "md5")
+
+	// The collision shifts the window of the hash
+	assert.Equal(t, "http://localhost/4fc5e4",
+		encode(t, r, URLPayload{URL: "https://github.com/darioblanco"}))
+	slug, _ := mr.Get(indexKey)
+	assert.Equal(t, "4fc5e4", slug)
+	// The first candidate is free again, but the existing slug is found in the index
+	mr.Del("test:64fc5e")
+	assert.Equal(t, "http://localhost/4fc5e4",
+		encode(t, r, URLPayload{URL: "https://github.com/darioblanco"}))
+	assert.False(t, mr.Exists("test:64fc5e"))
+}
+
+func TestEncode_ReverseIndexRandom(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	r := newIndexTestRouter(t, client, "random")
+
+	shortURL := encode(t, r, URLPayload{URL: "https://github.com/darioblanco"})
+	assert.Equal(t, shortURL, encode(t, r, URLPayload{URL: "https://github.com/darioblanco"}))
+	noReuse := false
+	fresh := encode(t, r, URLPayload{URL: "https://github.com/darioblanco", Reuse: &noReuse})
+	assert.NotEqual(t, shortURL, fresh)
+	testDecode(t, r, fresh, LongURL{URL: "https://github.com/darioblanco"})
+	// The fresh short url is not indexed
+	assert.Equal(t, shortURL, encode(t, r, URLPayload{URL: "https://github.com/darioblanco"}))
+}
+
+func TestEncode_NoReuse(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	r := newIndexTestRouter(t, client, "md5")
+
+	noReuse := false
+	assert.Equal(t, "http://localhost/64fc5e",
+		encode(t, r, URLPayload{URL: "https://github.com/darioblanco"}))
+	// The slug of the same url is a collision too
+	assert.Equal(t, "http://localhost/4fc5e4",
+		encode(t, r, URLPayload{URL: "https://github.com/darioblanco", Reuse: &noReuse}))
+	assert.Equal(t, "http://localhost/fc5e4d",
+		encode(t, r, URLPayload{URL: "https://github.com/darioblanco", Reuse: &noReuse}))
+	slug, _ := mr.Get(indexKey)
+	assert.Equal(t, "64fc5e", slug)
+}
+
+func TestEncode_StaleIndex(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+	}{
+		{"missing", ""},
+		{"different url", `{"v":1,"url":"https://darioblanco.com"}`},
+		{"disabled", `{"v":1,"url":"https://github.com/darioblanco","disabled":true}`},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			mr, client := cache.NewMiniredis()
+			defer mr.Close()
+			mr.Set(indexKey, "stale1")
+			if tt.link != "" {
+				mr.Set("test:stale1", tt.link)
+			}
+			r := newIndexTestRouter(t, client, "random")
+			shortURL := encode(t, r, URLPayload{URL: "https://github.com/darioblanco"})
+			assert.NotEqual(t, "http://localhost/stale1", shortURL)
+			slug, _ := mr.Get(indexKey)
+			assert.Equal(t, "http://localhost/"+slug, shortURL)
+		})
+	}
+}
+
+func TestEncode_ReverseIndexOwner(t *testing.T) {
+	c := cache.NewTest()
+	keys := auth.NewKeyStore(c)
+	token1, identity1, err := keys.Create(context.Background(), "ci")
+	require.NoError(t, err)
+	token2, _, err := keys.Create(context.Background(), "deploy")
+	require.NoError(t, err)
+	r := newLinksTestRouter(t, c, true)
+	encodeWith := func(token string) string {
+		w := serveRequest(r, http.MethodPost, "/encode", token,
+			URLPayload{URL: "https://github.com/darioblanco"})
+		require.Equal(t, http.StatusOK, w.Code)
+		var shortURL URLPayload
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shortURL))
+		return shortURL.URL
+	}
+
+	shortURL := encodeWith(token1)
+	assert.Equal(t, shortURL, encodeWith(token1))
+	// The short url of an API key is never shared with another one
+	other := encodeWith(token2)
+	assert.NotEqual(t, shortURL, other)
+	assert.Equal(t, other, encodeWith(token2))
+	slug, err := c.Get(context.Background(), urlIndexKey(&cache.Link{
+		URL: "https://github.com/darioblanco", CreatedBy: identity1.KeyID,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost/"+slug, shortURL)
+
+	w := serveRequest(r, http.MethodPost, "/encode/batch", token2,
+		BatchRequest{URLs: []*URLPayload{{URL: "https://github.com/darioblanco"}}})
+	require.Equal(t, http.StatusOK, w.Code)
+	response := &BatchResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), response))
+	require.Len(t, response.URLs, 1)
+	assert.Equal(t, other, response.URLs[0].URL)
+}
+
+func TestEncode_ReverseIndexExpiration(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	r := newIndexTestRouter(t, client, "md5")
+	encode(t, r, URLPayload{URL: "https://github.com/darioblanco", ExpiresIn: "1h"})
+	assert.InDelta(t, time.Hour, mr.TTL(indexKey), float64(2*time.Second))
+}
+
+func TestEncodeBatch_ReverseIndex(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	r := newIndexTestRouter(t, client, "random")
+	shortURL := encode(t, r, URLPayload{URL: "https://github.com/darioblanco"})
+
+	noReuse := false
+	results := serveBatch(t, r, "/encode/batch", []*URLPayload{
+		{URL: "https://github.com/darioblanco"},
+		{URL: "https://github.com/darioblanco", Reuse: &noReuse},
+		{URL: "https://darioblanco.com"},
+	})
+	assert.Equal(t, shortURL, results[0].URL)
+	assert.NotEqual(t, shortURL, results[1].URL)
+	assert.Equal(t, http.StatusOK, results[2].Code)
+	slug, _ := mr.Get("test:" + urlIndexKey(&cache.Link{URL: "https://darioblanco.com"}))
+	assert.Equal(t, "http://localhost/"+slug, results[2].URL)
+
+	// The batch urls are found in the index afterwards
+	results = serveBatch(t, r, "/encode/batch", []*URLPayload{
+		{URL: "https://darioblanco.com"},
+		{URL: "https://github.com/darioblanco"},
+	})
+	assert.Equal(t, "http://localhost/"+slug, results[0].URL)
+	assert.Equal(t, shortURL, results[1].URL)
+}
+
+func TestEncodeBatch_StaleIndex(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	mr.Set(indexKey, "stale1")
+	mr.Set("test:stale1", "https://darioblanco.com")
+	r := newIndexTestRouter(t, client, "md5")
+	results := serveBatch(t, r, "/encode/batch", []*URLPayload{
+		{URL: "https://github.com/darioblanco"},
+	})
+	assert.Equal(t, "http://localhost/64fc5e", results[0].URL)
+	slug, _ := mr.Get(indexKey)
+	assert.Equal(t, "64fc5e", slug)
+}
+
+func TestEncode_IndexInternalServerError(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	r := newIndexTestRouter(t, client, "md5")
+	mr.SetError("mock error")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, createRequest(
+		http.MethodPost, "/encode", URLPayload{URL: "https://github.com/darioblanco"},
+	))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	results := serveBatch(t, r, "/encode/batch", []*URLPayload{
+		{URL: "https://github.com/darioblanco"},
+	})
+	assert.Equal(t, http.StatusInternalServerError, results[0].Code)
+}
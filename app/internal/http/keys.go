@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/darioblanco/shortesturl/app/internal/auth"
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/clicks"
+	"github.com/darioblanco/shortesturl/app/internal/ratelimit"
+	"github.com/darioblanco/shortesturl/app/internal/slug"
+)
+
+// serviceKeyPrefixes are the namespaces of the auxiliary keys of the service
+var serviceKeyPrefixes = []string{
+	auth.KeyPrefix,
+	clicks.KeyPrefix,
+	ratelimit.KeyPrefix,
+	tombstoneKeyPrefix,
+	urlIndexKeyPrefix,
+}
+
+// IsServiceKey reports whether the given key (holding the given value) was stored by the service.
+// It tells apart the keys of a store shared with other services: the auxiliary keys and the slug
+// counter have a known prefix, while slugs never contain a colon and always hold a link to an
+// absolute url (or the url itself, if it was stored by a previous version of the service).
+// The value of the keys that do not hold a string (e.g. the click members) is empty.
+func IsServiceKey(key string, value string) bool {
+	if key == slug.CounterKey {
+		return true
+	}
+	for _, prefix := range serviceKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	if !cache.IsLinkKey(key) {
+		return false
+	}
+	link, err := cache.ParseLink(value)
+	if err != nil {
+		return false
+	}
+	u, err := url.ParseRequestURI(link.URL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsServiceKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		value    string
+		expected bool
+	}{
+		{name: "slug", key: "64fc5e", value: "https://github.com/darioblanco", expected: true},
+		{name: "alias", key: "launch-2026", value: "http://darioblanco.com", expected: true},
+		{
+			name:     "link",
+			key:      "64fc5e",
+			value:    `{"v":1,"url":"https://github.com/darioblanco","title":"Dario Blanco"}`,
+			expected: true,
+		},
+		{name: "invalid link", key: "64fc5e", value: `{"v":1,"url":`},
+		{name: "link to a relative url", key: "64fc5e", value: `{"v":1,"url":"/darioblanco"}`},
+		{name: "tombstone", key: "tombstone:64fc5e", value: "2026-01-01T00:00:00Z", expected: true},
+		{name: "counter", key: "counter:slug", value: "42", expected: true},
+		{name: "url index", key: "url:1a2b3c", value: "64fc5e", expected: true},
+		{name: "api key", key: "apikey:1a2b3c", value: `{"keyId":"ci"}`, expected: true},
+		{name: "clicks", key: "clicks:64fc5e", value: "42", expected: true},
+		{name: "click members", key: "clicks:64fc5e:referers", expected: true},
+		{name: "rate limit", key: "ratelimit:encode:ip:127.0.0.1:1792540800", value: "1", expected: true},
+		{name: "other service url", key: "session:64fc5e", value: "https://darioblanco.com"},
+		{name: "not a url", key: "64fc5e", value: "42"},
+		{name: "relative url", key: "64fc5e", value: "/darioblanco"},
+		{name: "not an http url", key: "64fc5e", value: "ftp://darioblanco.com"},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsServiceKey(tt.key, tt.value))
+		})
+	}
+}
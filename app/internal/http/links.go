@@ -0,0 +1,364 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+const (
+	// defaultListLimit is the number of links of a page when the client does not request any
+	defaultListLimit = 50
+	// maxListLimit caps the number of links of a page
+	maxListLimit = 1000
+	// maxListScans caps the number of store pages that are scanned for a page of links,
+	// so a keyspace with few links does not make a single request scan all of it
+	maxListScans = 10
+)
+
+// ListLinks
+// @Summary Lists the short URLs
+// @Description Paginated list of the short URLs. If authentication is enabled, only the ones
+// @Description encoded by the API key of the caller are listed.
+// @Description The limit is approximate, as pages can have slightly more or fewer links (even none),
+// @Description and the listing is complete once the returned cursor is empty.
+// @ID listLinks
+// @Tags Links
+// @Produce json
+// @Security ApiKeyAuth
+// @Param cursor query string false "The cursor returned by the previous page"
+// @Param limit query int false "The number of links of the page (default 50, max 1000)"
+// @Success 200 {object} LinkList "A page of short URLs"
+// @Failure 400 {object} BadRequest "Invalid cursor or limit"
+// @Failure 401 {object} Unauthorized "Missing or invalid API key (if authentication is enabled)"
+// @Failure 429 {object} TooManyRequests "Rate limit exceeded (if the route is limited)"
+// @Failure 500 {object} InternalServerError "Unexpected error in the backend"
+// @Router /links [get]
+func (rs api) ListLinks(w http.ResponseWriter, r *http.Request) {
+	limit := defaultListLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			rs.log(r.Context()).Warn("invalid list limit", "limit", value)
+			render.Render(w, r, ErrBadRequest(
+				fmt.Errorf("limit must be a number between 1 and %d", maxListLimit),
+			))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	list := &LinkList{Links: []*Link{}, Cursor: r.URL.Query().Get("cursor")}
+	// The links share the keyspace with the rest of keys of the service, thus several
+	// pages can be scanned until the limit is reached. The cursor is returned as soon
+	// as too many pages are scanned, even if the page of links is short.
+	for scans := 0; scans < maxListScans; scans++ {
+		keys, next, err := rs.cache.Scan(ctx, list.Cursor, "*", limit)
+		if errors.Is(err, cache.ErrInvalidCursor) {
+			rs.log(ctx).Warn("invalid list cursor", "cursor", list.Cursor)
+			render.Render(w, r, ErrBadRequest(err))
+			return
+		}
+		if err != nil {
+			rs.log(ctx).Error("unable to scan links in cache", "error", err)
+			render.Render(w, r, ErrInternalServerError(err))
+			return
+		}
+		links, err := rs.pageLinks(ctx, keys)
+		if err != nil {
+			rs.log(ctx).Error("unable to retrieve links from cache", "error", err)
+			render.Render(w, r, ErrInternalServerError(err))
+			return
+		}
+		for _, link := range links {
+			if canManage(ctx, link) {
+				list.Links = append(list.Links, link)
+			}
+		}
+		list.Cursor = next
+		if list.Cursor == "" || len(list.Links) >= limit {
+			break
+		}
+	}
+	render.Render(w, r, list)
+}
+
+// GetLink
+// @Summary Returns a short URL
+// @Description The long URL, metadata, expiration, status and owner of a short URL
+// @ID getLink
+// @Tags Links
+// @Produce json
+// @Security ApiKeyAuth
+// @Param slug path string true "The slug of the short url" example(64fc5e)
+// @Success 200 {object} Link "The short URL"
+// @Failure 401 {object} Unauthorized "Missing or invalid API key (if authentication is enabled)"
+// @Failure 403 {object} Forbidden "Short URL is owned by a different API key"
+// @Failure 429 {object} TooManyRequests "Rate limit exceeded (if the route is limited)"
+// @Failure 404 {object} NotFound "Short URL has not a related long URL"
+// @Failure 410 {object} Gone "Short URL has expired"
+// @Failure 500 {object} InternalServerError "Unexpected error in the backend"
+// @Router /links/{slug} [get]
+func (rs api) GetLink(w http.ResponseWriter, r *http.Request) {
+	link, ok := rs.manageableLink(w, r)
+	if !ok {
+		return
+	}
+	render.Render(w, r, link)
+}
+
+// UpdateLink
+// @Summary Changes a short URL
+// @Description Change the long URL, the title, the tags, the expiration or the status of a short URL.
+// @Description The settings that are not set are kept, and the expiration is capped by the server.
+// @Description Disabled short URLs are kept, but they can not be resolved until they are enabled again.
+// @ID updateLink
+// @Tags Links
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param slug path string true "The slug of the short url" example(64fc5e)
+// @Param link body UpdateLinkRequest true "The settings to change"
+// @Success 200 {object} Link "The updated short URL"
+// @Failure 400 {object} BadRequest "Long URL or expiration have a wrong format"
+// @Failure 401 {object} Unauthorized "Missing or invalid API key (if authentication is enabled)"
+// @Failure 403 {object} Forbidden "Short URL is owned by a different API key"
+// @Failure 429 {object} TooManyRequests "Rate limit exceeded (if the route is limited)"
+// @Failure 404 {object} NotFound "Short URL has not a related long URL"
+// @Failure 410 {object} Gone "Short URL has expired"
+// @Failure 422 {object} UnprocessableEntity "Long URL is not allowed by the safety policy"
+// @Failure 500 {object} InternalServerError "Unexpected error in the backend"
+// @Router /links/{slug} [patch]
+func (rs api) UpdateLink(w http.ResponseWriter, r *http.Request) {
+	data := &LinkUpdate{canonicalizer: rs.canonicalizer}
+	if err := render.Bind(r, data); err != nil {
+		rs.log(r.Context()).Warn("link update has a wrong format", "error", err)
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	if data.ParsedURL != nil {
+		if errResponse := rs.checkDestination(r.Context(), data.ParsedURL); errResponse != nil {
+			render.Render(w, r, errResponse)
+			return
+		}
+	}
+	link, ok := rs.manageableLink(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	stored := data.apply(link.stored)
+	expiration := cache.KeepTTL
+	if data.Expiration > 0 {
+		expiration = rs.expiration(data.Expiration)
+		stored.ExpiresAt = nil
+		if expiration > 0 {
+			expiresAt := time.Now().Add(expiration).UTC().Round(time.Second)
+			stored.ExpiresAt = &expiresAt
+		}
+	}
+	success, err := rs.cache.UpdateLink(ctx, link.Slug, stored, expiration)
+	if err != nil {
+		rs.log(ctx).Error("unable to update link in cache", "error", err)
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if !success {
+		// The link expired after it was read
+		rs.renderMissing(w, r, link.Slug)
+		return
+	}
+	if expiration != cache.KeepTTL {
+		// The tombstone follows the changed expiration of the link
+		if _, err := rs.storeTombstone(ctx, link.Slug); err != nil {
+			rs.log(ctx).Error("unable to store tombstone in cache", "error", err)
+			render.Render(w, r, ErrInternalServerError(err))
+			return
+		}
+	}
+	link = rs.newLink(link.Slug, stored)
+	rs.log(ctx).Info("Updated link",
+		"slug", link.Slug,
+		"longUrl", link.URL,
+		"expiresAt", link.ExpiresAt,
+		"disabled", link.Disabled,
+	)
+	render.Render(w, r, link)
+}
+
+// DeleteLink
+// @Summary Deletes a short URL
+// @Description Delete a short URL and its click stats. The short URL is reported as expired afterwards.
+// @ID deleteLink
+// @Tags Links
+// @Produce json
+// @Security ApiKeyAuth
+// @Param slug path string true "The slug of the short url" example(64fc5e)
+// @Success 204 "Short URL deleted successfully"
+// @Failure 401 {object} Unauthorized "Missing or invalid API key (if authentication is enabled)"
+// @Failure 403 {object} Forbidden "Short URL is owned by a different API key"
+// @Failure 429 {object} TooManyRequests "Rate limit exceeded (if the route is limited)"
+// @Failure 404 {object} NotFound "Short URL has not a related long URL"
+// @Failure 410 {object} Gone "Short URL has expired"
+// @Failure 500 {object} InternalServerError "Unexpected error in the backend"
+// @Router /links/{slug} [delete]
+func (rs api) DeleteLink(w http.ResponseWriter, r *http.Request) {
+	link, ok := rs.manageableLink(w, r)
+	if !ok {
+		return
+	}
+	if err := rs.deleteLink(r.Context(), link); err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	render.NoContent(w, r)
+}
+
+// deleteLink removes the given link and its click stats, keeping a tombstone
+// so the deleted link is reported as expired
+func (rs api) deleteLink(ctx context.Context, link *Link) error {
+	if _, err := rs.cache.Delete(ctx, link.Slug); err != nil {
+		rs.log(ctx).Error("unable to delete link from cache", "error", err)
+		return err
+	}
+	if err := rs.clicks.Delete(ctx, link.Slug); err != nil {
+		rs.log(ctx).Error("unable to delete click stats from cache", "error", err)
+		return err
+	}
+	// The deleted link is reported as expired, like the links that reach their expiration
+	if err := rs.cache.Set(
+		ctx,
+		tombstoneKey(link.Slug),
+		time.Now().UTC().Round(time.Second).Format(time.RFC3339),
+		rs.tombstoneRetention(),
+	); err != nil {
+		rs.log(ctx).Error("unable to store tombstone of deleted link in cache", "error", err)
+		return err
+	}
+	rs.log(ctx).Info("Deleted link", "slug", link.Slug, "longUrl", link.URL)
+	return nil
+}
+
+// manageableLink returns the link of the slug of the request, rendering an error (and returning
+// false) if it does not exist or it can not be managed by the caller
+func (rs api) manageableLink(w http.ResponseWriter, r *http.Request) (*Link, bool) {
+	urlID := chi.URLParam(r, "slug")
+	if !cache.IsLinkKey(urlID) {
+		// The service keys (e.g. the tombstones) are never managed as a link
+		rs.renderNotFound(w, r, urlID)
+		return nil, false
+	}
+	link, err := rs.getLink(r.Context(), urlID)
+	if err != nil {
+		rs.log(r.Context()).Error("unable to retrieve link from cache", "error", err)
+		render.Render(w, r, ErrInternalServerError(err))
+		return nil, false
+	}
+	if link == nil {
+		rs.renderMissing(w, r, urlID)
+		return nil, false
+	}
+	if !canManage(r.Context(), link) {
+		rs.log(r.Context()).Warn("link is owned by a different api key", "urlId", urlID)
+		render.Render(w, r, ErrForbidden(errors.New("the link is owned by a different api key")))
+		return nil, false
+	}
+	return link, true
+}
+
+// getLink returns the link of the given slug, being nil if it does not exist
+func (rs api) getLink(ctx context.Context, slug string) (*Link, error) {
+	stored, err := rs.loadLink(ctx, slug)
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	return rs.newLink(slug, stored), nil
+}
+
+// newLink returns the link payload of the given stored link
+func (rs api) newLink(slug string, stored *cache.Link) *Link {
+	return &Link{
+		Slug:      slug,
+		URL:       stored.URL,
+		ShortURL:  rs.shortURL(slug),
+		CreatedAt: stored.CreatedAt,
+		Title:     stored.Title,
+		Tags:      stored.Tags,
+		ExpiresAt: stored.ExpiresAt,
+		Disabled:  stored.Disabled,
+		Owner:     stored.CreatedBy,
+		stored:    stored,
+	}
+}
+
+// loadLink returns the stored link of the given slug, being nil if it does not exist
+func (rs api) loadLink(ctx context.Context, slug string) (*cache.Link, error) {
+	link, err := rs.cache.GetLink(ctx, slug)
+	if err != nil || link == nil {
+		return link, err
+	}
+	return link, rs.loadLegacyExpiration(ctx, slug, link)
+}
+
+// loadLegacyExpiration sets the expiration of the given link if it is a legacy one,
+// which only holds the long url, from the expiration of its key
+func (rs api) loadLegacyExpiration(ctx context.Context, slug string, link *cache.Link) error {
+	if link.Version > 0 {
+		return nil
+	}
+	ttl, err := rs.cache.TTL(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl).UTC().Round(time.Second)
+		link.ExpiresAt = &expiresAt
+	}
+	return nil
+}
+
+// pageLinks returns the links of the given scanned keys, which are read with a single
+// pipeline, skipping the auxiliary keys and the links that expired after the scan
+func (rs api) pageLinks(ctx context.Context, keys []string) ([]*Link, error) {
+	var slugs []string
+	for _, key := range keys {
+		if cache.IsLinkKey(key) {
+			slugs = append(slugs, key)
+		}
+	}
+	if len(slugs) == 0 {
+		return nil, nil
+	}
+	stored, err := rs.cache.GetLinks(ctx, slugs)
+	if err != nil {
+		return nil, err
+	}
+	links := make([]*Link, 0, len(slugs))
+	for i, slug := range slugs {
+		link := stored[i]
+		if link == nil {
+			continue
+		}
+		if err := rs.loadLegacyExpiration(ctx, slug, link); err != nil {
+			return nil, err
+		}
+		links = append(links, rs.newLink(slug, link))
+	}
+	return links, nil
+}
+
+// canManage returns true if the caller can manage the given link: API keys can only manage
+// the links they encoded, while every link can be managed if authentication is disabled
+func canManage(ctx context.Context, link *Link) bool {
+	identity := GetIdentity(ctx)
+	return identity == nil || identity.KeyID == link.Owner
+}
@@ -0,0 +1,514 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/auth"
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveRequest serves a request with the given API key (if any), returning its recorded response
+func serveRequest(
+	handler http.Handler, method, path, token string, input interface{},
+) *httptest.ResponseRecorder {
+	req := createRequest(method, path, input)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func newLinksTestRouter(t *testing.T, c cache.Cache, authEnabled bool) http.Handler {
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{
+			Auth:                         config.AuthValues{Enabled: authEnabled},
+			HttpScheme:                   "http",
+			HttpHost:                     "localhost",
+			HttpPort:                     80,
+			UrlLength:                    6,
+			UrlTombstoneRetentionInHours: 1,
+		},
+		logging.NewTest(t),
+		c,
+	)
+	require.NoError(t, err)
+	return r
+}
+
+func decodeLink(t *testing.T, w *httptest.ResponseRecorder) *Link {
+	link := &Link{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), link))
+	return link
+}
+
+func TestListLinks(t *testing.T) {
+	c := cache.NewTest()
+	r := newLinksTestRouter(t, c, false)
+	var expected []string
+	for _, longURL := range []string{
+		"https://github.com/darioblanco",
+		"https://darioblanco.com",
+		"https://github.com/darioblanco/shortesturl",
+	} {
+		w := serveRequest(r, http.MethodPost, "/encode", "", URLPayload{URL: longURL})
+		require.Equal(t, http.StatusOK, w.Code)
+		expected = append(expected, longURL)
+	}
+	// Auxiliary keys are not listed
+	assert.NoError(t, c.Set(context.Background(), "tombstone:abcdef", "2026-01-01T00:00:00Z", 0))
+
+	var found []string
+	cursor := ""
+	for pages := 0; pages == 0 || cursor != ""; pages++ {
+		require.Less(t, pages, 10)
+		w := serveRequest(r, http.MethodGet, "/links?limit=1&cursor="+cursor, "", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		list := &LinkList{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), list))
+		for _, link := range list.Links {
+			assert.Equal(t, "http://localhost/"+link.Slug, link.ShortURL)
+			found = append(found, link.URL)
+		}
+		cursor = list.Cursor
+	}
+	sort.Strings(expected)
+	sort.Strings(found)
+	assert.Equal(t, expected, found)
+}
+
+func TestListLinks_BoundedScan(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemory(ctx, &config.Values{Storage: config.StorageValues{Shards: 1}})
+	r := newLinksTestRouter(t, c, false)
+	// The tombstones are sorted before the link, thus the pages of one key scan them first
+	for i := 0; i < 2*maxListScans-1; i++ {
+		key := fmt.Sprintf("tombstone:%02d", i)
+		assert.NoError(t, c.Set(ctx, key, "2026-01-01T00:00:00Z", 0))
+	}
+	assert.NoError(t, c.Set(ctx, "zzz", "https://github.com/darioblanco", 0))
+
+	w := serveRequest(r, http.MethodGet, "/links?limit=1", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	list := &LinkList{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), list))
+	// The page is empty, but the listing goes on from its cursor
+	assert.Empty(t, list.Links)
+	assert.Equal(t, fmt.Sprintf("tombstone:%02d", maxListScans-1), list.Cursor)
+
+	w = serveRequest(r, http.MethodGet, "/links?limit=1&cursor="+list.Cursor, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	list = &LinkList{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), list))
+	require.Len(t, list.Links, 1)
+	assert.Equal(t, "zzz", list.Links[0].Slug)
+}
+
+func TestListLinks_Owner(t *testing.T) {
+	c := cache.NewTest()
+	keys := auth.NewKeyStore(c)
+	token1, identity1, err := keys.Create(context.Background(), "ci")
+	require.NoError(t, err)
+	token2, _, err := keys.Create(context.Background(), "deploy")
+	require.NoError(t, err)
+	r := newLinksTestRouter(t, c, true)
+	w := serveRequest(r, http.MethodPost, "/encode", token1,
+		URLPayload{URL: "https://github.com/darioblanco"})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = serveRequest(r, http.MethodPost, "/encode", token2,
+		URLPayload{URL: "https://darioblanco.com"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serveRequest(r, http.MethodGet, "/links", token1, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	list := &LinkList{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), list))
+	require.Len(t, list.Links, 1)
+	require.NotNil(t, list.Links[0].CreatedAt)
+	list.Links[0].CreatedAt = nil
+	assert.Equal(t, &LinkList{Links: []*Link{{
+		Slug:     "64fc5e",
+		URL:      "https://github.com/darioblanco",
+		ShortURL: "http://localhost/64fc5e",
+		Owner:    identity1.KeyID,
+	}}}, list)
+
+	w = serveRequest(r, http.MethodGet, "/links", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestListLinks_Empty(t *testing.T) {
+	r := newLinksTestRouter(t, cache.NewTest(), false)
+	w := serveRequest(r, http.MethodGet, "/links", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"links":[]}`, w.Body.String())
+}
+
+func TestListLinks_BadRequest(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	r := newLinksTestRouter(t, client, false)
+	tests := []struct {
+		path          string
+		expectedError string
+	}{
+		{path: "/links?limit=0", expectedError: "limit must be a number between 1 and 1000"},
+		{path: "/links?limit=1001", expectedError: "limit must be a number between 1 and 1000"},
+		{path: "/links?limit=all", expectedError: "limit must be a number between 1 and 1000"},
+		{path: "/links?cursor=invalid", expectedError: "invalid scan cursor"},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.path, func(t *testing.T) {
+			testRequest(t, r, http.MethodGet, tt.path, nil, http.StatusBadRequest, ErrHTTPResponse{
+				StatusText: http.StatusText(http.StatusBadRequest),
+				ErrorText:  tt.expectedError,
+			})
+		})
+	}
+}
+
+func TestListLinks_InternalServerError(t *testing.T) {
+	tests := []struct {
+		name       string
+		cacheError bool
+	}{
+		{name: "scan", cacheError: true},
+		{name: "link"},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			mr, client := cache.NewMiniredis()
+			defer mr.Close()
+			// The link holds an invalid record, which can not be parsed
+			mr.Set("test:64fc5e", "{invalid")
+			r := newLinksTestRouter(t, client, false)
+			if tt.cacheError {
+				mr.SetError("mock error")
+			}
+			testRequest(t, r, http.MethodGet, "/links", nil, http.StatusInternalServerError,
+				ErrHTTPResponse{
+					StatusText: http.StatusText(http.StatusInternalServerError),
+					ErrorText:  "oops, something went wrong in our side",
+				},
+			)
+		})
+	}
+}
+
+func TestGetLink(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	mr.SetTTL("test:64fc5e", time.Hour)
+	r := newLinksTestRouter(t, client, false)
+
+	w := serveRequest(r, http.MethodGet, "/links/64fc5e", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	link := decodeLink(t, w)
+	require.NotNil(t, link.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *link.ExpiresAt, 2*time.Second)
+	link.ExpiresAt = nil
+	assert.Equal(t, &Link{
+		Slug:     "64fc5e",
+		URL:      "https://github.com/darioblanco",
+		ShortURL: "http://localhost/64fc5e",
+	}, link)
+}
+
+func TestGetLink_Metadata(t *testing.T) {
+	r := newLinksTestRouter(t, cache.NewTest(), false)
+	w := serveRequest(r, http.MethodPost, "/encode", "", URLPayload{
+		URL:   "https://github.com/darioblanco",
+		Title: "Dario Blanco",
+		Tags:  []string{"profile"},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serveRequest(r, http.MethodGet, "/links/64fc5e", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	link := decodeLink(t, w)
+	require.NotNil(t, link.CreatedAt)
+	assert.WithinDuration(t, time.Now(), *link.CreatedAt, 2*time.Second)
+	link.CreatedAt = nil
+	assert.Equal(t, &Link{
+		Slug:     "64fc5e",
+		URL:      "https://github.com/darioblanco",
+		ShortURL: "http://localhost/64fc5e",
+		Title:    "Dario Blanco",
+		Tags:     []string{"profile"},
+	}, link)
+}
+
+func TestGetLink_Missing(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	mr.Set("test:tombstone:104b16", "2026-01-01T00:00:00Z")
+	r := newLinksTestRouter(t, client, false)
+	testRequest(t, r, http.MethodGet, "/links/64fc5e", nil, http.StatusNotFound, ErrHTTPResponse{
+		StatusText: http.StatusText(http.StatusNotFound),
+		ErrorText:  "long url not found",
+	})
+	testRequest(t, r, http.MethodGet, "/links/104b16", nil, http.StatusGone, ErrHTTPResponse{
+		StatusText: http.StatusText(http.StatusGone),
+		ErrorText:  "long url expired",
+	})
+}
+
+func TestManageLink_ServiceKey(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	r := newLinksTestRouter(t, client, false)
+	// The service keys are rejected before touching the cache
+	mr.SetError("mock error")
+	for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+		w := serveRequest(r, method, "/links/tombstone:64fc5e", "", map[string]bool{"disabled": true})
+		assert.Equal(t, http.StatusNotFound, w.Code, method)
+	}
+	w := serveRequest(r, http.MethodGet, "/links/url:f65befd02eecc4ab/stats", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetLink_Forbidden(t *testing.T) {
+	c := cache.NewTest()
+	token, _, err := auth.NewKeyStore(c).Create(context.Background(), "ci")
+	require.NoError(t, err)
+	assert.NoError(t, c.Set(context.Background(), "64fc5e", "https://github.com/darioblanco", 0))
+	r := newLinksTestRouter(t, c, true)
+
+	// Links without owner can only be managed when the authentication is disabled
+	for _, path := range []string{"/links/64fc5e", "/links/64fc5e/stats"} {
+		w := serveRequest(r, http.MethodGet, path, token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t,
+			`{"status":"Forbidden","error":"the link is owned by a different api key"}`,
+			w.Body.String(),
+		)
+	}
+}
+
+func TestGetLink_InternalServerError(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	r := newLinksTestRouter(t, client, false)
+	mr.SetError("mock error")
+	w := serveRequest(r, http.MethodGet, "/links/64fc5e", "", nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestUpdateLink(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	mr.SetTTL("test:64fc5e", time.Hour)
+	r := newLinksTestRouter(t, client, false)
+
+	// The long url is changed, keeping the expiration of the legacy link
+	w := serveRequest(r, http.MethodPatch, "/links/64fc5e", "",
+		map[string]string{"url": "https://darioblanco.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	link := decodeLink(t, w)
+	assert.Equal(t, "https://darioblanco.com", link.URL)
+	require.NotNil(t, link.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *link.ExpiresAt, 2*time.Second)
+	assert.Equal(t, time.Hour, mr.TTL("test:64fc5e"))
+	w = serveRequest(r, http.MethodGet, "/64fc5e", "", nil)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://darioblanco.com", w.Header().Get("Location"))
+
+	// The title and the tags are changed
+	w = serveRequest(r, http.MethodPatch, "/links/64fc5e", "", map[string]interface{}{
+		"title": "Dario Blanco",
+		"tags":  []string{"profile"},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	link = decodeLink(t, w)
+	assert.Equal(t, "Dario Blanco", link.Title)
+	assert.Equal(t, []string{"profile"}, link.Tags)
+	assert.Equal(t, "https://darioblanco.com", link.URL)
+
+	// The link is disabled
+	w = serveRequest(r, http.MethodPatch, "/links/64fc5e", "", map[string]bool{"disabled": true})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, decodeLink(t, w).Disabled)
+	assert.Equal(t, time.Hour, mr.TTL("test:64fc5e"))
+	testRequest(t, r, http.MethodGet, "/64fc5e", nil, http.StatusNotFound, ErrHTTPResponse{
+		StatusText: http.StatusText(http.StatusNotFound),
+		ErrorText:  "long url disabled",
+	})
+	testRequest(t, r, http.MethodPost, "/decode", URLPayload{URL: "http://localhost/64fc5e"},
+		http.StatusNotFound, ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusNotFound),
+			ErrorText:  "long url disabled",
+		},
+	)
+
+	// The expiration is changed, together with the one of the tombstone
+	w = serveRequest(r, http.MethodPatch, "/links/64fc5e", "", map[string]string{"expiresIn": "72h"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	link = decodeLink(t, w)
+	require.NotNil(t, link.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(72*time.Hour), *link.ExpiresAt, 2*time.Second)
+	assert.True(t, link.Disabled)
+	assert.Equal(t, 72*time.Hour, mr.TTL("test:64fc5e"))
+	assert.Equal(t, 73*time.Hour, mr.TTL("test:tombstone:64fc5e"))
+
+	// The link is enabled again
+	w = serveRequest(r, http.MethodPatch, "/links/64fc5e", "", map[string]bool{"disabled": false})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, decodeLink(t, w).Disabled)
+	w = serveRequest(r, http.MethodGet, "/64fc5e", "", nil)
+	assert.Equal(t, http.StatusFound, w.Code)
+}
+
+func TestUpdateLink_BadRequest(t *testing.T) {
+	r := newLinksTestRouter(t, cache.NewTest(), false)
+	tests := []struct {
+		name          string
+		input         interface{}
+		expectedError string
+	}{
+		{
+			name:          "empty",
+			input:         map[string]string{},
+			expectedError: "at least one of url, title, tags, expiresIn, expiresAt or disabled has to be set",
+		},
+		{
+			name:          "invalid tags",
+			input:         map[string][]string{"tags": {""}},
+			expectedError: "tags must have between 1 and 64 characters",
+		},
+		{
+			name:          "invalid url",
+			input:         map[string]string{"url": "invalid"},
+			expectedError: "invalid http/https url format",
+		},
+		{
+			name:          "invalid expiration",
+			input:         map[string]string{"expiresIn": "1 week"},
+			expectedError: "expiresIn must be a positive duration (e.g. 72h)",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, r, http.MethodPatch, "/links/64fc5e", tt.input, http.StatusBadRequest,
+				ErrHTTPResponse{
+					StatusText: http.StatusText(http.StatusBadRequest),
+					ErrorText:  tt.expectedError,
+				},
+			)
+		})
+	}
+}
+
+func TestUpdateLink_UnprocessableEntity(t *testing.T) {
+	c := cache.NewTest()
+	assert.NoError(t, c.Set(context.Background(), "64fc5e", "https://github.com/darioblanco", 0))
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{Safety: config.SafetyValues{BlockPrivateNetworks: true}},
+		logging.NewTest(t),
+		c,
+	)
+	require.NoError(t, err)
+	testRequest(t, r, http.MethodPatch, "/links/64fc5e", map[string]string{"url": "http://localhost:8080/"},
+		http.StatusUnprocessableEntity, ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusUnprocessableEntity),
+			AppCode:    "private_network",
+			ErrorText:  "host localhost is not a public address",
+		},
+	)
+	link, err := c.GetLink(context.Background(), "64fc5e")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/darioblanco", link.URL)
+}
+
+func TestUpdateLink_NotFound(t *testing.T) {
+	r := newLinksTestRouter(t, cache.NewTest(), false)
+	testRequest(t, r, http.MethodPatch, "/links/64fc5e", map[string]bool{"disabled": true},
+		http.StatusNotFound, ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusNotFound),
+			ErrorText:  "long url not found",
+		},
+	)
+}
+
+func TestUpdateLink_Forbidden(t *testing.T) {
+	c := cache.NewTest()
+	token, _, err := auth.NewKeyStore(c).Create(context.Background(), "ci")
+	require.NoError(t, err)
+	owned := &cache.Link{
+		Version:   cache.LinkVersion,
+		URL:       "https://github.com/darioblanco",
+		CreatedBy: "0123456789ab",
+	}
+	assert.NoError(t, c.Set(context.Background(), "64fc5e", owned.String(), 0))
+	r := newLinksTestRouter(t, c, true)
+	w := serveRequest(r, http.MethodPatch, "/links/64fc5e", token, map[string]bool{"disabled": true})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	link, err := c.GetLink(context.Background(), "64fc5e")
+	assert.NoError(t, err)
+	assert.False(t, link.Disabled)
+}
+
+func TestDeleteLink(t *testing.T) {
+	c := cache.NewTest()
+	token, _, err := auth.NewKeyStore(c).Create(context.Background(), "ci")
+	require.NoError(t, err)
+	r := newLinksTestRouter(t, c, true)
+	w := serveRequest(r, http.MethodPost, "/encode", token,
+		URLPayload{URL: "https://github.com/darioblanco"})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = serveRequest(r, http.MethodGet, "/64fc5e", "", nil)
+	require.Equal(t, http.StatusFound, w.Code)
+
+	w = serveRequest(r, http.MethodDelete, "/links/64fc5e", token, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+	keys, _, err := c.Scan(context.Background(), "", "*64fc5e*", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tombstone:64fc5e"}, keys)
+
+	// The deleted link is reported as expired
+	w = serveRequest(r, http.MethodGet, "/64fc5e", "", nil)
+	assert.Equal(t, http.StatusGone, w.Code)
+	w = serveRequest(r, http.MethodDelete, "/links/64fc5e", token, nil)
+	assert.Equal(t, http.StatusGone, w.Code)
+}
+
+func TestDeleteLink_Forbidden(t *testing.T) {
+	c := cache.NewTest()
+	token, _, err := auth.NewKeyStore(c).Create(context.Background(), "ci")
+	require.NoError(t, err)
+	assert.NoError(t, c.Set(context.Background(), "64fc5e", "https://github.com/darioblanco", 0))
+	r := newLinksTestRouter(t, c, true)
+	w := serveRequest(r, http.MethodDelete, "/links/64fc5e", token, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	longURL, err := c.Get(context.Background(), "64fc5e")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/darioblanco", longURL)
+}
+
+func TestDeleteLink_InternalServerError(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	r := newLinksTestRouter(t, client, false)
+	mr.SetError("mock error")
+	w := serveRequest(r, http.MethodDelete, "/links/64fc5e", "", nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
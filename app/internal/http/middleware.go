@@ -2,11 +2,27 @@ package http
 
 import (
 	"context"
+	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/darioblanco/shortesturl/app/internal/auth"
+	"github.com/darioblanco/shortesturl/app/internal/config"
 	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/darioblanco/shortesturl/app/internal/metrics"
+	"github.com/darioblanco/shortesturl/app/internal/ratelimit"
+	"github.com/darioblanco/shortesturl/app/internal/tracing"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ContextKey is a value for use with context.WithValue. It's used as
@@ -20,15 +36,36 @@ type ContextKey struct {
 // loggerCtxKey is the key that holds the logger information in a request context.
 var loggerCtxKey = &ContextKey{Name: "Logger"}
 
-// LoggerMW middleware is used to call the injected logger in each request
+// identityCtxKey is the key that holds the identity of the caller in a request context.
+var identityCtxKey = &ContextKey{Name: "Identity"}
+
+// rateLimitCtxKey is the key that holds the rate limiter of the route in a request context.
+var rateLimitCtxKey = &ContextKey{Name: "RateLimit"}
+
+// An identitySlot holds the identity of the caller once AuthMW validates its API key.
+// LoggerMW adds an empty slot to the context, so the access log can include the key ID
+// even if the identity is only known by an inner middleware.
+type identitySlot struct {
+	identity *auth.Identity
+}
+
+// LoggerMW middleware is used to call the injected logger in each request.
+// The trace of the request (see TracingMW) is added to the entries of the logger.
 func LoggerMW(logger logging.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
+			logger := logging.WithTrace(r.Context(), logger)
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			slot := &identitySlot{}
 			t1 := time.Now()
 			defer func() {
+				var keyID string
+				if slot.identity != nil {
+					keyID = slot.identity.KeyID
+				}
 				logger.Info("Served",
 					"ip", r.RemoteAddr,
+					"keyId", keyID,
 					"latency", time.Since(t1),
 					"path", r.URL.Path,
 					"protocol", r.Proto,
@@ -39,14 +76,218 @@ func LoggerMW(logger logging.Logger) func(next http.Handler) http.Handler {
 					"userAgent", r.Header.Get("User-Agent"),
 				)
 			}()
+			r = r.WithContext(context.WithValue(r.Context(), identityCtxKey, slot))
 			next.ServeHTTP(ww, WithLoggerMW(r, logger))
 		}
 		return http.HandlerFunc(fn)
 	}
 }
 
+// MetricsMW middleware records the count and the latency of the requests per method,
+// route pattern and status code. It reuses the response writer wrapped by LoggerMW, so it
+// has to be set after it, otherwise it wraps the response writer itself.
+func MetricsMW(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ww, ok := w.(middleware.WrapResponseWriter)
+		if !ok {
+			ww = middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		}
+		t1 := time.Now()
+		defer func() {
+			status := strconv.Itoa(ww.Status())
+			route := routePattern(r)
+			metrics.HTTPRequests.WithLabelValues(r.Method, route, status).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(r.Method, route, status).
+				Observe(time.Since(t1).Seconds())
+		}()
+		next.ServeHTTP(ww, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// routePattern returns the pattern of the route that served a request (e.g. /links/{slug}),
+// so the metrics of every short url are aggregated in the same route. The requests that
+// did not match any route (e.g. /health) return "unmatched".
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return "unmatched"
+	}
+	pattern := rctx.RoutePattern()
+	if pattern == "" || pattern == "/*" {
+		return "unmatched"
+	}
+	return pattern
+}
+
+// TracingMW middleware traces each request in a server span, which continues the trace of
+// the caller if the request has a W3C traceparent header. The span is named after the
+// route pattern (e.g. GET /links/{slug}) once the request is routed.
+func TracingMW(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(
+				config.AppName, "", r)...),
+		)
+		defer span.End()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		route := routePattern(r)
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRouteKey.String(route))
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(ww.Status())...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(
+			ww.Status(), trace.SpanKindServer))
+	}
+	return http.HandlerFunc(fn)
+}
+
 // WithAdminMW sets the in-context logger for a request.
 func WithLoggerMW(r *http.Request, logger logging.Logger) *http.Request {
 	r = r.WithContext(context.WithValue(r.Context(), loggerCtxKey, logger))
 	return r
 }
+
+// AuthMW middleware requires a valid API key, sent with the Bearer scheme in the
+// Authorization header. The identity of the caller is set in the request context.
+func AuthMW(keys auth.KeyStore, logger logging.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="shortesturl"`)
+				render.Render(w, r, ErrUnauthorized(errors.New("missing bearer api key")))
+				return
+			}
+			identity, err := keys.Authenticate(r.Context(), token)
+			if err != nil {
+				logger.Error("unable to retrieve api key from cache", "error", err)
+				render.Render(w, r, ErrInternalServerError(err))
+				return
+			}
+			if identity == nil {
+				logger.Warn("invalid api key", "ip", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="shortesturl", error="invalid_token"`)
+				render.Render(w, r, ErrUnauthorized(errors.New("invalid api key")))
+				return
+			}
+			next.ServeHTTP(w, WithIdentityMW(r, identity))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// WithIdentityMW sets the in-context identity of the caller for a request.
+func WithIdentityMW(r *http.Request, identity *auth.Identity) *http.Request {
+	if slot, ok := r.Context().Value(identityCtxKey).(*identitySlot); ok {
+		slot.identity = identity
+		return r
+	}
+	return r.WithContext(context.WithValue(
+		r.Context(), identityCtxKey, &identitySlot{identity: identity},
+	))
+}
+
+// GetIdentity returns the identity of the caller of a request,
+// being nil if the request is not authenticated
+func GetIdentity(ctx context.Context) *auth.Identity {
+	if slot, ok := ctx.Value(identityCtxKey).(*identitySlot); ok {
+		return slot.identity
+	}
+	return nil
+}
+
+// bearerToken returns the token of the Authorization header with the Bearer scheme
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(prefix):])
+	return token, token != ""
+}
+
+// RateLimitMW middleware limits the requests of each client, identified by its API key
+// or, if the request is not authenticated, by its IP address (see middleware.RealIP).
+// The quota of the client is returned in the RateLimit-* headers. If the limiter is nil,
+// or it does not return a decision, the requests are not limited. The limiter is kept in
+// the request context, so the handler can charge more requests (see chargeRateLimit).
+func RateLimitMW(limiter ratelimit.Limiter, logger logging.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			decision, err := limiter.Allow(r.Context(), rateLimitClient(r))
+			if err != nil {
+				// The service stays available if the limits can not be checked
+				logger.Warn("unable to check rate limit", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if decision == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !renderRateLimit(w, r, decision) {
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), rateLimitCtxKey, limiter)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// chargeRateLimit records n more requests of the client in the rate limit of the route,
+// e.g. the rest of the urls of a batch, as RateLimitMW only records the request itself.
+// It renders a 429 (returning false) if they exceed the limit. Like RateLimitMW, the
+// requests are not limited if the route has no limiter or the limits can not be checked.
+func chargeRateLimit(w http.ResponseWriter, r *http.Request, n int, logger logging.Logger) bool {
+	limiter, ok := r.Context().Value(rateLimitCtxKey).(ratelimit.Limiter)
+	if !ok || n <= 0 {
+		return true
+	}
+	decision, err := limiter.AllowN(r.Context(), rateLimitClient(r), n)
+	if err != nil {
+		logger.Warn("unable to check rate limit", "error", err)
+		return true
+	}
+	return decision == nil || renderRateLimit(w, r, decision)
+}
+
+// renderRateLimit sets the RateLimit-* headers of the given decision, rendering
+// a 429 (and returning false) if the request is not allowed
+func renderRateLimit(w http.ResponseWriter, r *http.Request, decision *ratelimit.Decision) bool {
+	reset := strconv.Itoa(int(math.Ceil(decision.Reset.Seconds())))
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("RateLimit-Reset", reset)
+	if !decision.Allowed {
+		w.Header().Set("Retry-After", reset)
+		render.Render(w, r, ErrTooManyRequests(errors.New("rate limit exceeded")))
+		return false
+	}
+	return true
+}
+
+// rateLimitClient identifies the client of a request for the rate limits
+func rateLimitClient(r *http.Request) string {
+	if identity := GetIdentity(r.Context()); identity != nil {
+		return "key:" + identity.KeyID
+	}
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return "ip:" + ip
+}
+
+// GetLogger returns the in-context logger of a request, being nil if it is not set
+func GetLogger(ctx context.Context) logging.Logger {
+	logger, _ := ctx.Value(loggerCtxKey).(logging.Logger)
+	return logger
+}
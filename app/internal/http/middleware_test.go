@@ -0,0 +1,292 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/darioblanco/shortesturl/app/internal/auth"
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/darioblanco/shortesturl/app/internal/metrics"
+	"github.com/darioblanco/shortesturl/app/internal/ratelimit"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAuthMW(t *testing.T) {
+	keys := auth.NewKeyStore(cache.NewTest())
+	token, identity, err := keys.Create(context.Background(), "ci")
+	require.NoError(t, err)
+
+	var served *auth.Identity
+	handler := LoggerMW(logging.NewTest(t))(AuthMW(keys, logging.NewTest(t))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served = GetIdentity(r.Context())
+		}),
+	))
+
+	tests := []struct {
+		name                    string
+		authorization           string
+		expectedStatusCode      int
+		expectedWWWAuthenticate string
+	}{
+		{
+			name:               "valid api key",
+			authorization:      "Bearer " + token,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "lowercase scheme",
+			authorization:      "bearer " + token,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:                    "missing authorization",
+			expectedStatusCode:      http.StatusUnauthorized,
+			expectedWWWAuthenticate: `Bearer realm="shortesturl"`,
+		},
+		{
+			name:                    "other scheme",
+			authorization:           "Basic dXNlcjpwYXNz",
+			expectedStatusCode:      http.StatusUnauthorized,
+			expectedWWWAuthenticate: `Bearer realm="shortesturl"`,
+		},
+		{
+			name:                    "empty token",
+			authorization:           "Bearer  ",
+			expectedStatusCode:      http.StatusUnauthorized,
+			expectedWWWAuthenticate: `Bearer realm="shortesturl"`,
+		},
+		{
+			name:                    "invalid api key",
+			authorization:           "Bearer " + token + "x",
+			expectedStatusCode:      http.StatusUnauthorized,
+			expectedWWWAuthenticate: `Bearer realm="shortesturl", error="invalid_token"`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			served = nil
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatusCode, w.Code)
+			assert.Equal(t, tt.expectedWWWAuthenticate, w.Header().Get("WWW-Authenticate"))
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, identity, served)
+			} else {
+				assert.Nil(t, served)
+			}
+		})
+	}
+}
+
+func TestAuthMW_InternalServerError(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	mr.SetError("mock error")
+	handler := AuthMW(auth.NewKeyStore(client), logging.NewTest(t))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("the handler should not be served")
+		}),
+	)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer abc.def")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestWithIdentityMW(t *testing.T) {
+	identity := &auth.Identity{KeyID: "0123456789ab", Name: "ci"}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Nil(t, GetIdentity(req.Context()))
+	req = WithIdentityMW(req, identity)
+	assert.Equal(t, identity, GetIdentity(req.Context()))
+}
+
+func TestMetricsMW(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(LoggerMW(logging.NewTest(t)))
+	r.Use(MetricsMW)
+	r.Get("/links/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	requests := func(route string, status string) float64 {
+		return testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(http.MethodGet, route, status))
+	}
+	served := requests("/links/{slug}", "418")
+	unmatched := requests("unmatched", "404")
+	latencies := metrics.SampleCount(
+		metrics.HTTPRequestDuration.WithLabelValues(http.MethodGet, "/links/{slug}", "418"))
+
+	for _, path := range []string{"/links/64fc5e", "/links/4fc5e4", "/unknown/path"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Every short url is aggregated in the route pattern
+	assert.Equal(t, served+2, requests("/links/{slug}", "418"))
+	assert.Equal(t, unmatched+1, requests("unmatched", "404"))
+	assert.Equal(t, latencies+2, metrics.SampleCount(
+		metrics.HTTPRequestDuration.WithLabelValues(http.MethodGet, "/links/{slug}", "418")))
+}
+
+func TestMetricsMW_WithoutLoggerMW(t *testing.T) {
+	handler := MetricsMW(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	unmatched := testutil.ToFloat64(
+		metrics.HTTPRequests.WithLabelValues(http.MethodDelete, "unmatched", "204"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, unmatched+1, testutil.ToFloat64(
+		metrics.HTTPRequests.WithLabelValues(http.MethodDelete, "unmatched", "204")))
+}
+
+func TestRateLimitMW(t *testing.T) {
+	limiter := ratelimit.New(
+		cache.NewTest(), "encode", config.RateLimitRouteValues{Limit: 1, WindowInSeconds: 60},
+	)
+	handler := RateLimitMW(limiter, logging.NewTest(t))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/encode", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("RateLimit-Reset"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, w.Header().Get("RateLimit-Reset"), w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"status":"Too Many Requests","error":"rate limit exceeded"}`, w.Body.String())
+
+	// Authenticated clients are limited by their API key, instead of their IP address
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, WithIdentityMW(req, &auth.Identity{KeyID: "0123456789ab"}))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestChargeRateLimit(t *testing.T) {
+	limiter := ratelimit.New(
+		cache.NewTest(), "encode", config.RateLimitRouteValues{Limit: 3, WindowInSeconds: 60},
+	)
+	var charged []bool
+	handler := RateLimitMW(limiter, logging.NewTest(t))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			charged = append(charged, chargeRateLimit(w, r, 1, logging.NewTest(t)))
+		}),
+	)
+
+	// Each request is charged twice, by the middleware and by the handler
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/encode/batch", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/encode/batch", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, []bool{true, false}, charged)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestChargeRateLimit_NoLimiter(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/encode/batch", nil)
+	assert.True(t, chargeRateLimit(w, r, 10, logging.NewTest(t)))
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitMW_NoLimit(t *testing.T) {
+	handler := RateLimitMW(nil, logging.NewTest(t))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/encode", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitMW_NoDecision(t *testing.T) {
+	limiter := ratelimit.NewReloadable(cache.NewTest(), "encode", func() config.RateLimitRouteValues {
+		return config.RateLimitRouteValues{}
+	})
+	handler := RateLimitMW(limiter, logging.NewTest(t))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/encode", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitMW_CacheError(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	mr.SetError("mock error")
+	limiter := ratelimit.New(client, "encode", config.RateLimitRouteValues{Limit: 1})
+	handler := RateLimitMW(limiter, logging.NewTest(t))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	// The request is served without limits
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/encode", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+// failingLimiter is a rate limiter that can never check the limits
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string) (*ratelimit.Decision, error) {
+	return nil, errors.New("mock error")
+}
+
+func (failingLimiter) AllowN(context.Context, string, int) (*ratelimit.Decision, error) {
+	return nil, errors.New("mock error")
+}
+
+func TestRateLimitMW_LimiterError(t *testing.T) {
+	// The development loggers panic on the errors, which would break the fail open
+	logger := logging.NewLoggerWithCore(zaptest.NewLogger(t, zaptest.WrapOptions(zap.Development())))
+	called := false
+	handler := RateLimitMW(failingLimiter{}, logger)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }),
+	)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/encode", nil))
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitClient(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/encode", nil)
+	req.RemoteAddr = "[2001:db8::1]:54321"
+	assert.Equal(t, "ip:2001:db8::1", rateLimitClient(req))
+	// middleware.RealIP sets the address without port
+	req.RemoteAddr = "81.2.69.142"
+	assert.Equal(t, "ip:81.2.69.142", rateLimitClient(req))
+	req = WithIdentityMW(req, &auth.Identity{KeyID: "0123456789ab"})
+	assert.Equal(t, "key:0123456789ab", rateLimitClient(req))
+}
@@ -0,0 +1,128 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/qr"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// qrCacheEntries is the number of rendered QR codes that are kept in memory
+const qrCacheEntries = 256
+
+// QR
+// @Summary Renders a short URL as a QR code
+// @Description The QR code encodes the short URL, thus every scan is a click.
+// @Description The image only depends on the short URL and the query parameters, thus its ETag
+// @Description is strong and the clients can revalidate it with If-None-Match.
+// @ID qr
+// @Tags Shortener
+// @Produce png,image/svg+xml
+// @Param slug path string true "The slug of the short url" example(64fc5e)
+// @Param format query string false "The image format" Enums(png, svg) default(png)
+// @Param size query int false "The width and height of the image, in pixels" minimum(1) maximum(2048) default(256)
+// @Param level query string false "The error correction level" Enums(L, M, Q, H) default(M)
+// @Param margin query int false "The quiet zone around the code, in modules" minimum(0) maximum(16) default(4)
+// @Param fg query string false "The foreground color, in hexadecimal with an optional alpha" default(#000000)
+// @Param bg query string false "The background color, in hexadecimal with an optional alpha" default(#ffffff)
+// @Success 200 {file} file "QR code of the short URL"
+// @Success 304 "QR code not modified since the ETag of If-None-Match"
+// @Failure 400 {object} BadRequest "Options have a wrong format"
+// @Failure 404 {object} NotFound "Short URL has not a related long URL"
+// @Failure 410 {object} Gone "Short URL has expired"
+// @Failure 429 {object} TooManyRequests "Rate limit exceeded (if the route is limited)"
+// @Failure 500 {object} InternalServerError "Unexpected error in the backend"
+// @Router /{slug}/qr [get]
+func (rs api) QR(w http.ResponseWriter, r *http.Request) {
+	urlID := chi.URLParam(r, "slug")
+	opts, err := parseQROptions(r.URL.Query())
+	if err != nil {
+		rs.log(r.Context()).Warn("QR code options have a wrong format", "error", err)
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	link, ok := rs.resolve(w, r, urlID)
+	if !ok {
+		return
+	}
+	image, err := rs.qrCodes.Render(rs.shortURL(urlID), opts)
+	if err != nil {
+		// The short urls always fit in a QR code, thus only the options can be wrong
+		rs.log(r.Context()).Warn("unable to render QR code", "urlId", urlID, "error", err)
+		render.Render(w, r, ErrBadRequest(err))
+		return
+	}
+	sum := sha256.Sum256(image)
+	w.Header().Set("Content-Type", qr.ContentType(opts.Format))
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum[:16]))
+	w.Header().Set("Cache-Control", qrCacheControl(rs.maxAge(link)))
+	rs.log(r.Context()).Debug("Rendered QR code", "urlId", urlID, "format", opts.Format)
+	// The conditional (and range) requests are answered by ServeContent with the ETag
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(image))
+}
+
+// parseQROptions returns the rendering options of the given query parameters,
+// which default to a black on white PNG code
+func parseQROptions(query url.Values) (qr.Options, error) {
+	opts := qr.DefaultOptions()
+	if format := query.Get("format"); format != "" {
+		opts.Format = strings.ToLower(format)
+	}
+	if level := query.Get("level"); level != "" {
+		opts.Level = strings.ToUpper(level)
+	}
+	var err error
+	if size := query.Get("size"); size != "" {
+		if opts.Size, err = strconv.Atoi(size); err != nil {
+			return opts, fmt.Errorf("invalid size %q", size)
+		}
+	}
+	if margin := query.Get("margin"); margin != "" {
+		if opts.Margin, err = strconv.Atoi(margin); err != nil {
+			return opts, fmt.Errorf("invalid margin %q", margin)
+		}
+	}
+	if fg := query.Get("fg"); fg != "" {
+		if opts.Foreground, err = qr.ParseColor(fg); err != nil {
+			return opts, err
+		}
+	}
+	if bg := query.Get("bg"); bg != "" {
+		if opts.Background, err = qr.ParseColor(bg); err != nil {
+			return opts, err
+		}
+	}
+	return opts, opts.Validate()
+}
+
+// qrCacheControl returns the Cache-Control header of the QR codes. As they are the
+// same for every client, they can be cached by shared caches. A max age of 0 forces
+// clients to revalidate every time, which is cheap thanks to the ETag.
+func qrCacheControl(maxAge int) string {
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+	return fmt.Sprintf("public, max-age=%d", maxAge)
+}
+
+// qrDataURI renders the given short url as a QR code of the given format with the
+// default options, returning it as a data URI that can be embedded in a page
+func qrDataURI(shortURL string, format string) (string, error) {
+	opts := qr.DefaultOptions()
+	opts.Format = format
+	image, err := qr.Render(shortURL, opts)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("data:%s;base64,%s",
+		qr.ContentType(format), base64.StdEncoding.EncodeToString(image)), nil
+}
@@ -0,0 +1,252 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/darioblanco/shortesturl/app/internal/qr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newQRRouter(t *testing.T, client cache.Cache, maxAge int) http.Handler {
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{
+			HttpScheme:     "http",
+			HttpHost:       "localhost",
+			HttpPort:       3000,
+			RedirectMaxAge: maxAge,
+			UrlLength:      6,
+		},
+		logging.NewTest(t),
+		client,
+	)
+	require.NoError(t, err)
+	return r
+}
+
+// testQRRequest requests the QR code of the given path, returning the recorded response
+func testQRRequest(
+	t *testing.T, handler http.Handler, method, path string,
+) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestQR(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	r := newQRRouter(t, client, 0)
+
+	rr := testQRRequest(t, r, http.MethodGet, "/64fc5e/qr")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", rr.Header().Get("Cache-Control"))
+	img, err := png.Decode(rr.Body)
+	require.NoError(t, err)
+	assert.Equal(t, qr.DefaultSize, img.Bounds().Dx())
+	expected, err := qr.Render("http://localhost:3000/64fc5e", qr.DefaultOptions())
+	require.NoError(t, err)
+	etag := rr.Header().Get("ETag")
+	// The ETag is strong, as the image is deterministic
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+
+	rr = testQRRequest(t, r, http.MethodGet, "/64fc5e/qr")
+	assert.Equal(t, expected, rr.Body.Bytes())
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+
+	req, _ := http.NewRequest(http.MethodGet, "/64fc5e/qr", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.Bytes())
+
+	rr = testQRRequest(t, r, http.MethodGet,
+		"/64fc5e/qr?format=SVG&size=512&level=h&margin=2&fg=%231a2b3c&bg=ffffff00")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/svg+xml", rr.Header().Get("Content-Type"))
+	assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+	svg := rr.Body.String()
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" `+
+		`width="512" height="512"`))
+	assert.Contains(t, svg, `fill="#1a2b3c"`)
+	assert.Contains(t, svg, `fill="#ffffff" fill-opacity="0.000"`)
+
+	rr = testQRRequest(t, r, http.MethodHead, "/64fc5e/qr")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+}
+
+func TestQR_MaxAge(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	mr.Set("test:expiring", "https://darioblanco.com")
+	mr.SetTTL("test:expiring", time.Minute)
+	r := newQRRouter(t, client, 3600)
+
+	rr := testQRRequest(t, r, http.MethodGet, "/64fc5e/qr")
+	assert.Equal(t, "public, max-age=3600", rr.Header().Get("Cache-Control"))
+	// The QR code is not cached beyond the expiration of the short url
+	rr = testQRRequest(t, r, http.MethodGet, "/expiring/qr")
+	assert.Equal(t, "public, max-age=60", rr.Header().Get("Cache-Control"))
+}
+
+func TestQR_RateLimit(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{
+			HttpScheme: "http",
+			HttpHost:   "localhost",
+			HttpPort:   3000,
+			RateLimit: config.RateLimitValues{
+				QR: config.RateLimitRouteValues{Limit: 1, WindowInSeconds: 3600},
+			},
+			UrlLength: 6,
+		},
+		logging.NewTest(t),
+		client,
+	)
+	require.NoError(t, err)
+
+	rr := testQRRequest(t, r, http.MethodGet, "/64fc5e/qr")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Limit"))
+	rr = testQRRequest(t, r, http.MethodGet, "/64fc5e/qr")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	// The redirects are not limited
+	rr = testQRRequest(t, r, http.MethodGet, "/64fc5e")
+	assert.Equal(t, http.StatusFound, rr.Code)
+}
+
+func TestQR_BadRequest(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.Set("test:64fc5e", "https://github.com/darioblanco")
+	r := newQRRouter(t, client, 0)
+	tests := map[string]struct {
+		query    string
+		expected string
+	}{
+		"format":      {"format=gif", `invalid format "gif" (allowed: png, svg)`},
+		"size":        {"size=big", `invalid size "big"`},
+		"big size":    {"size=4096", "size must be between 1 and 2048 pixels"},
+		"small size":  {"size=20", "size must be at least 37 pixels to draw the code"},
+		"level":       {"level=X", `invalid level "X" (allowed: L, M, Q, H)`},
+		"margin":      {"margin=-1", "margin must be between 0 and 16 modules"},
+		"text margin": {"margin=wide", `invalid margin "wide"`},
+		"foreground":  {"fg=red", `invalid color "red" (e.g. #1a2b3c)`},
+		"background":  {"bg=%23ff", `invalid color "#ff" (e.g. #1a2b3c)`},
+		"same colors": {"fg=ffffff", qr.ErrSameColors.Error()},
+	}
+	for name, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(name, func(t *testing.T) {
+			testRequest(t, r,
+				http.MethodGet,
+				"/64fc5e/qr?"+tt.query,
+				nil,
+				http.StatusBadRequest,
+				ErrHTTPResponse{
+					StatusText: http.StatusText(http.StatusBadRequest),
+					ErrorText:  tt.expected,
+				},
+			)
+		})
+	}
+}
+
+func TestQR_Missing(t *testing.T) {
+	mr, client := cache.NewMiniredis()
+	mr.Set("test:tombstone:expired", "2026-01-01T00:00:00Z")
+	r := newQRRouter(t, client, 0)
+	testRequest(t, r,
+		http.MethodGet,
+		"/abcdef/qr",
+		nil,
+		http.StatusNotFound,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusNotFound),
+			ErrorText:  "long url not found",
+		},
+	)
+	testRequest(t, r,
+		http.MethodGet,
+		"/expired/qr",
+		nil,
+		http.StatusGone,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusGone),
+			ErrorText:  "long url expired",
+		},
+	)
+}
+
+func TestEncode_QR(t *testing.T) {
+	r := newQRRouter(t, cache.NewTest(), 0)
+	tests := []struct {
+		format      string
+		contentType string
+	}{
+		{qr.FormatPNG, "image/png"},
+		{qr.FormatSVG, "image/svg+xml"},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.format, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, createRequest(http.MethodPost, "/encode",
+				URLPayload{URL: "https://github.com/darioblanco", QR: tt.format}))
+			require.Equal(t, http.StatusOK, rr.Code)
+			var payload URLPayload
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &payload))
+			assert.Equal(t, "http://localhost:3000/64fc5e", payload.URL)
+
+			prefix := "data:" + tt.contentType + ";base64,"
+			require.True(t, strings.HasPrefix(payload.QRCode, prefix))
+			image, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(payload.QRCode, prefix))
+			require.NoError(t, err)
+			// The QR code is the same one that GET /{slug}/qr renders
+			rendered := testQRRequest(t, r, http.MethodGet, "/64fc5e/qr?format="+tt.format)
+			assert.Equal(t, rendered.Body.Bytes(), image)
+		})
+	}
+
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco"},
+		http.StatusOK,
+		URLPayload{URL: "http://localhost:3000/64fc5e"},
+	)
+	testRequest(t, r,
+		http.MethodPost,
+		"/encode",
+		URLPayload{URL: "https://github.com/darioblanco", QR: "gif"},
+		http.StatusBadRequest,
+		ErrHTTPResponse{
+			StatusText: http.StatusText(http.StatusBadRequest),
+			ErrorText:  `invalid qr "gif" (allowed: png, svg)`,
+		},
+	)
+}
+
+func TestQRCacheControl(t *testing.T) {
+	assert.Equal(t, "no-cache", qrCacheControl(0))
+	assert.Equal(t, "public, max-age=60", qrCacheControl(60))
+}
@@ -4,9 +4,15 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/darioblanco/shortesturl/app/internal/auth"
 	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/clicks"
 	"github.com/darioblanco/shortesturl/app/internal/config"
 	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/darioblanco/shortesturl/app/internal/metrics"
+	"github.com/darioblanco/shortesturl/app/internal/qr"
+	"github.com/darioblanco/shortesturl/app/internal/safety"
+	"github.com/darioblanco/shortesturl/app/internal/slug"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
@@ -16,18 +22,69 @@ import (
 func NewRouter(
 	ctx context.Context, conf *config.Values, logger logging.Logger, cache cache.Cache,
 ) (http.Handler, error) {
+	rs, err := newAPI(ctx, conf, logger, cache)
+	if err != nil {
+		return nil, err
+	}
+
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
+	r.Use(TracingMW)
 	r.Use(middleware.Compress(5))
 	r.Use(LoggerMW(logger))
+	r.Use(MetricsMW)
 	r.Use(middleware.URLFormat)
 	r.Use(middleware.Recoverer)
 	r.Use(render.SetContentType(render.ContentTypeJSON))
 	r.Use(middleware.Heartbeat("/health"))
 
+	if conf.Metrics.Enabled {
+		r.Method(http.MethodGet, conf.Metrics.Path, metrics.Handler())
+	}
 	r.Mount("/docs", docs{config: conf}.Router())
-	r.Mount("/", api{cache: cache, config: conf, logger: logger}.Router())
+	r.Mount("/", rs.Router())
 
 	return r, nil
 }
+
+// newAPI creates the api with the services of the given configuration
+func newAPI(
+	ctx context.Context, conf *config.Values, logger logging.Logger, cache cache.Cache,
+) (api, error) {
+	if err := validateRedirectStatusCode(conf); err != nil {
+		return api{}, err
+	}
+	aliases, err := newAliasPolicy(conf.Alias)
+	if err != nil {
+		return api{}, err
+	}
+	slugs, err := slug.NewReloadable(conf, cache)
+	if err != nil {
+		return api{}, err
+	}
+	tracker, err := clicks.New(ctx, conf, cache, logger)
+	if err != nil {
+		return api{}, err
+	}
+	destinations, err := safety.New(ctx, conf, logger)
+	if err != nil {
+		return api{}, err
+	}
+	var keys auth.KeyStore
+	if conf.Auth.Enabled {
+		keys = auth.NewKeyStore(cache)
+	}
+	return api{
+		aliases:       aliases,
+		cache:         cache,
+		canonicalizer: newURLCanonicalizer(conf.Canonicalize),
+		clicks:        tracker,
+		config:        conf,
+		destinations:  destinations,
+		keys:          keys,
+		logger:        logger,
+		qrCodes:       qr.NewCache(qrCacheEntries),
+		slugs:         slugs,
+	}, nil
+}
@@ -2,13 +2,25 @@ package http
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/darioblanco/shortesturl/app/internal/cache"
 	"github.com/darioblanco/shortesturl/app/internal/config"
 	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/darioblanco/shortesturl/app/internal/metrics"
+	"github.com/darioblanco/shortesturl/app/internal/tracing"
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestNewRouter(t *testing.T) {
@@ -23,6 +35,146 @@ func TestNewRouter(t *testing.T) {
 		cache.NewTest(),
 	)
 	assert.NoError(t, err)
-	assert.Len(t, r.(*chi.Mux).Middlewares(), 8)
+	assert.Len(t, r.(*chi.Mux).Middlewares(), 10)
 	assert.Len(t, r.(*chi.Mux).Routes(), 2)
 }
+
+func TestNewRouter_Metrics(t *testing.T) {
+	c := cache.NewTest()
+	assert.NoError(t, c.Set(context.Background(), "64fc5e", "https://github.com/darioblanco", 0))
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{Metrics: config.MetricsValues{Enabled: true, Path: "/metrics"}},
+		logging.NewTest(t),
+		c,
+	)
+	require.NoError(t, err)
+	assert.Len(t, r.(*chi.Mux).Routes(), 3)
+	hits := testutil.ToFloat64(metrics.RedirectHits)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/64fc5e", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, hits+1, testutil.ToFloat64(metrics.RedirectHits))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(),
+		`shortesturl_http_requests_total{method="GET",route="/{slug}",status="302"}`)
+	assert.Contains(t, w.Body.String(), "shortesturl_redirect_hits_total")
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+}
+
+func TestNewRouter_Tracing(t *testing.T) {
+	recorder := tracing.NewTest()
+	mr, client := cache.NewMiniredis()
+	defer mr.Close()
+	// The first slug candidate collides
+	mr.Set("test:64fc5e", "https://darioblanco.com")
+	core, logs := observer.New(zap.InfoLevel)
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{HttpScheme: "http", HttpHost: "localhost", HttpPort: 80, UrlLength: 6},
+		logging.NewLoggerWithCore(zap.New(core)),
+		cache.Instrument(client),
+	)
+	require.NoError(t, err)
+
+	req := createRequest(http.MethodPost, "/encode", URLPayload{URL: "https://github.com/darioblanco"})
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+	require.Len(t, spans["POST /encode"], 1)
+	server := spans["POST /encode"][0]
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	assert.Contains(t, server.Attributes(), semconv.HTTPRouteKey.String("/encode"))
+	assert.Contains(t, server.Attributes(), semconv.HTTPStatusCodeKey.Int(http.StatusOK))
+
+	attempts := spans["encode.attempt"]
+	require.Len(t, attempts, 2)
+	for i, expected := range []struct {
+		slug      string
+		collision bool
+	}{{"64fc5e", true}, {"4fc5e4", false}} {
+		assert.Equal(t, server.SpanContext().SpanID(), attempts[i].Parent().SpanID())
+		assert.Contains(t, attempts[i].Attributes(), attribute.Int("attempt", i))
+		assert.Contains(t, attempts[i].Attributes(), attribute.String("slug", expected.slug))
+		assert.Contains(t, attempts[i].Attributes(), attribute.Bool("collision", expected.collision))
+	}
+	require.Len(t, spans["cache.set_link_if_not_exists"], 2)
+	for i, span := range spans["cache.set_link_if_not_exists"] {
+		assert.Equal(t, attempts[i].SpanContext().SpanID(), span.Parent().SpanID())
+	}
+
+	// The logs of the request hold its trace
+	for _, message := range []string{"Encoded url", "Served"} {
+		entries := logs.FilterMessage(message).All()
+		require.Len(t, entries, 1, message)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entries[0].ContextMap()["traceId"])
+		assert.Equal(t, server.SpanContext().SpanID().String(), entries[0].ContextMap()["spanId"])
+	}
+}
+
+func TestNewRouter_InvalidRedirectStatusCode(t *testing.T) {
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{RedirectStatusCode: 200},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	assert.EqualError(t, err, "invalid redirect status code 200 (allowed: 301, 302, 307, 308)")
+	assert.Nil(t, r)
+}
+
+func TestNewRouter_InvalidAliasPattern(t *testing.T) {
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{Alias: config.AliasValues{Pattern: "^[a-z"}},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	assert.Error(t, err)
+	assert.Nil(t, r)
+}
+
+func TestNewRouter_InvalidSafetyPolicy(t *testing.T) {
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{Safety: config.SafetyValues{BlocklistFile: "missing.txt"}},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	assert.Error(t, err)
+	assert.Nil(t, r)
+}
+
+func TestNewRouter_InvalidGeoIPDatabase(t *testing.T) {
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{Clicks: config.ClicksValues{GeoIPDatabase: "missing.mmdb"}},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	assert.Error(t, err)
+	assert.Nil(t, r)
+}
+
+func TestNewRouter_InvalidSlugGenerator(t *testing.T) {
+	r, err := NewRouter(
+		context.Background(),
+		&config.Values{Slug: config.SlugValues{Generator: "sha1"}},
+		logging.NewTest(t),
+		cache.NewTest(),
+	)
+	assert.Error(t, err)
+	assert.Nil(t, r)
+}
@@ -0,0 +1,621 @@
+package http
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+)
+
+const (
+	// FormatCSV transfers the links as CSV rows, with a header that names the columns
+	FormatCSV = "csv"
+	// FormatJSONL transfers the links as JSON lines, with the payload of GET /links/{slug}
+	FormatJSONL = "jsonl"
+)
+
+const (
+	// ConflictSkip keeps the link that already holds the slug of an imported link
+	ConflictSkip = "skip"
+	// ConflictOverwrite replaces the link that already holds the slug of an imported link
+	ConflictOverwrite = "overwrite"
+	// ConflictFail stops the import at the first imported link whose slug holds a different url
+	ConflictFail = "fail"
+)
+
+// defaultImportBatchSize is the number of links whose cache operations are pipelined together
+const defaultImportBatchSize = 500
+
+// csvColumns are the columns of the exported CSV rows. Only slug and url are required
+// in the imported ones, whose columns can be in any order.
+var csvColumns = []string{
+	"slug", "url", "createdAt", "expiresAt", "title", "tags", "disabled", "owner",
+}
+
+// ErrSlugTaken is returned by an import with the fail policy when the slug
+// of an imported link already holds a different url
+var ErrSlugTaken = errors.New("slug is already taken")
+
+// ExportOptions configure an export
+type ExportOptions struct {
+	// Format is FormatJSONL (the default) or FormatCSV
+	Format string
+	// Prefix exports only the links whose slug starts with it
+	Prefix string
+	// Resume continues the export of the given progress, without writing the CSV header again
+	Resume *ExportProgress
+	// Progress is called once every page of links is written
+	Progress func(ExportProgress)
+}
+
+// ExportProgress is the state of an export, which can be resumed from it
+type ExportProgress struct {
+	Exported int `json:"exported"`
+	// Cursor is the scan cursor of the next page, being empty once the export is complete
+	Cursor string `json:"cursor"`
+}
+
+// ImportOptions configure an import
+type ImportOptions struct {
+	// Format is FormatJSONL (the default) or FormatCSV
+	Format string
+	// Conflict is the policy of the slugs that already hold a link: ConflictSkip (the default),
+	// ConflictOverwrite or ConflictFail
+	Conflict string
+	// DryRun counts the outcome of every link without storing any of them
+	DryRun bool
+	// BatchSize is the number of links whose cache operations are pipelined together
+	BatchSize int
+	// Resume continues the import of the given progress, skipping the records it processed
+	Resume *ImportProgress
+	// Progress is called once every batch of links is stored
+	Progress func(ImportProgress)
+}
+
+// ImportProgress is the state of an import, which can be resumed from it
+type ImportProgress struct {
+	// Records are the records of the input that were processed
+	Records     int `json:"records"`
+	Imported    int `json:"imported"`
+	Overwritten int `json:"overwritten"`
+	// Skipped are the links whose slug already held a link that was kept
+	Skipped int `json:"skipped"`
+	// Expired are the links that already expired, which are not stored
+	Expired int `json:"expired"`
+}
+
+// importItem is a valid link of an import, numbered by its record
+type importItem struct {
+	record int
+	slug   string
+	link   *cache.Link
+	// expired is true if the link already expired, thus it is not stored
+	expired    bool
+	expiration time.Duration
+}
+
+// Export writes the links whose slug starts with the prefix of the options, scanning the
+// store in pages whose links are read with a single pipeline. The returned progress
+// holds the number of exported links.
+func (a *Admin) Export(
+	ctx context.Context, w io.Writer, opts ExportOptions,
+) (*ExportProgress, error) {
+	progress := &ExportProgress{}
+	if opts.Resume != nil {
+		*progress = *opts.Resume
+		if progress.Cursor == "" {
+			// The resumed export was already complete
+			return progress, nil
+		}
+	}
+	writer, err := newLinkWriter(opts.Format, w, opts.Resume == nil)
+	if err != nil {
+		return progress, err
+	}
+	for {
+		keys, next, err := a.rs.cache.Scan(
+			ctx, progress.Cursor, escapeGlob(opts.Prefix)+"*", adminScanCount,
+		)
+		if err != nil {
+			return progress, err
+		}
+		links, err := a.rs.pageLinks(ctx, keys)
+		if err != nil {
+			return progress, err
+		}
+		for _, link := range links {
+			if err := writer.Write(link); err != nil {
+				return progress, err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return progress, err
+		}
+		progress.Exported += len(links)
+		progress.Cursor = next
+		if opts.Progress != nil {
+			opts.Progress(*progress)
+		}
+		if next == "" {
+			a.rs.logger.Info("Exported links", "exported", progress.Exported)
+			return progress, nil
+		}
+	}
+}
+
+// Import stores the links of the given input, as written by Export, preserving their slugs.
+// The input is streamed in batches, whose cache operations are pipelined, and the slugs that
+// already hold a link follow the conflict policy of the options. An invalid record stops
+// the import, which can be resumed from the returned progress once the input is fixed.
+// The imported links are not added to the reverse index, thus encoding their long urls
+// again generates new short urls (unless the slug generator is deterministic), and the
+// reverse index entries of the overwritten links are removed.
+func (a *Admin) Import(
+	ctx context.Context, r io.Reader, opts ImportOptions,
+) (*ImportProgress, error) {
+	progress := &ImportProgress{}
+	if opts.Resume != nil {
+		*progress = *opts.Resume
+	}
+	switch opts.Conflict {
+	case "":
+		opts.Conflict = ConflictSkip
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+	default:
+		return progress, fmt.Errorf(
+			"invalid conflict policy %q (allowed: skip, overwrite, fail)", opts.Conflict,
+		)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultImportBatchSize
+	}
+	reader, err := newLinkReader(opts.Format, r)
+	if err != nil {
+		return progress, err
+	}
+
+	batch := make([]*importItem, 0, opts.BatchSize)
+	flush := func() error {
+		if err := a.importBatch(ctx, batch, opts, progress); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		if opts.Progress != nil {
+			opts.Progress(*progress)
+		}
+		return nil
+	}
+	for record := 1; ; record++ {
+		link, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return progress, fmt.Errorf("record %d: %w", record, err)
+		}
+		if record <= progress.Records {
+			// The record was processed before the import was resumed
+			continue
+		}
+		item, err := a.newImportItem(record, link)
+		if err != nil {
+			return progress, fmt.Errorf("record %d: %w", record, err)
+		}
+		if batch = append(batch, item); len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return progress, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return progress, err
+	}
+	a.rs.logger.Info("Imported links",
+		"records", progress.Records,
+		"imported", progress.Imported,
+		"overwritten", progress.Overwritten,
+		"skipped", progress.Skipped,
+		"expired", progress.Expired,
+		"dryRun", opts.DryRun,
+	)
+	return progress, nil
+}
+
+// newImportItem validates the given imported link. Its slug follows the alias policy,
+// like the custom slugs of /encode, as it is stored as is.
+func (a *Admin) newImportItem(record int, link *Link) (*importItem, error) {
+	if link.Slug == "" || !cache.IsLinkKey(link.Slug) {
+		return nil, fmt.Errorf("invalid slug %q", link.Slug)
+	}
+	if err := a.rs.aliases.Validate(link.Slug); err != nil {
+		return nil, fmt.Errorf("invalid slug %q: %w", link.Slug, err)
+	}
+	if _, err := parseHTTPURL(link.URL); err != nil {
+		return nil, err
+	}
+	if err := validateMetadata(link.Title, link.Tags); err != nil {
+		return nil, err
+	}
+	var expiration time.Duration
+	if link.ExpiresAt != nil {
+		if expiration = time.Until(*link.ExpiresAt); expiration <= 0 {
+			return &importItem{record: record, slug: link.Slug, expired: true}, nil
+		}
+	}
+	return &importItem{
+		record: record,
+		slug:   link.Slug,
+		link: &cache.Link{
+			Version:   cache.LinkVersion,
+			URL:       link.URL,
+			CreatedAt: link.CreatedAt,
+			CreatedBy: link.Owner,
+			Title:     link.Title,
+			Tags:      link.Tags,
+			ExpiresAt: link.ExpiresAt,
+			Disabled:  link.Disabled,
+		},
+		expiration: expiration,
+	}, nil
+}
+
+// importBatch stores the given items following the conflict policy, adding their outcome
+// to the progress. The links that already hold the slugs are read with a single pipeline,
+// and the new links, the overwritten ones and their tombstones are stored with another few.
+// If an item fails, the progress counts the items that were stored before it.
+func (a *Admin) importBatch(
+	ctx context.Context, batch []*importItem, opts ImportOptions, progress *ImportProgress,
+) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	var slugs []string
+	for _, item := range batch {
+		slugs = append(slugs, item.slug)
+	}
+	existing, err := a.rs.cache.GetLinks(ctx, slugs)
+	if err != nil {
+		return err
+	}
+
+	var conflict error
+	expired := 0
+	var created, overwritten []*importItem
+	// seen are the links of the batch, as the input can repeat a slug
+	seen := make(map[string]*cache.Link, len(batch))
+	for i, item := range batch {
+		if item.expired {
+			expired++
+			continue
+		}
+		current := existing[i]
+		if link, ok := seen[item.slug]; ok {
+			current = link
+		}
+		switch {
+		case current == nil:
+			created = append(created, item)
+		case opts.Conflict == ConflictOverwrite:
+			overwritten = append(overwritten, item)
+		case opts.Conflict == ConflictFail && current.URL != item.link.URL:
+			// The items that follow the conflict are processed once the import is resumed
+			conflict = fmt.Errorf("record %d: slug %q: %w", item.record, item.slug, ErrSlugTaken)
+			batch = batch[:i]
+		}
+		if conflict != nil {
+			break
+		}
+		if current == nil || opts.Conflict == ConflictOverwrite {
+			seen[item.slug] = item.link
+		}
+	}
+	if opts.DryRun {
+		progress.Imported += len(created)
+		progress.Overwritten += len(overwritten)
+		progress.Skipped += len(batch) - len(created) - len(overwritten) - expired
+		progress.Expired += expired
+		progress.Records += len(batch)
+		return conflict
+	}
+
+	entries := make([]cache.LinkEntry, len(created))
+	for i, item := range created {
+		// The entries are unique, as a link to the same url can not be told apart
+		// from the one that was stored since the slugs were read
+		entries[i] = cache.LinkEntry{
+			Key: item.slug, Link: item.link, Expiration: item.expiration, Unique: true,
+		}
+	}
+	success, err := a.rs.cache.SetLinksIfNotExists(ctx, entries)
+	if err != nil {
+		return err
+	}
+	var stored []cache.Entry
+	// lost is the first item whose slug was taken since it was read, if it fails the import
+	var lost *importItem
+	for i, item := range created {
+		switch {
+		case success[i]:
+			stored = append(stored, a.importTombstones(item)...)
+		case opts.Conflict == ConflictOverwrite:
+			// The slug was taken since it was read
+			overwritten = append(overwritten, item)
+		case opts.Conflict == ConflictFail && lost == nil:
+			lost = item
+		}
+	}
+	overwrittenSlugs := make([]string, len(overwritten))
+	for i, item := range overwritten {
+		overwrittenSlugs[i] = item.slug
+		stored = append(stored, cache.Entry{
+			Key: item.slug, Value: item.link.String(), Expiration: item.expiration,
+		})
+		stored = append(stored, a.importTombstones(item)...)
+	}
+	// The overwritten links are read before they are replaced, to clean up after them
+	replaced, err := a.rs.cache.GetLinks(ctx, overwrittenSlugs)
+	if err != nil {
+		return err
+	}
+	if err := a.rs.cache.SetAll(ctx, stored); err != nil {
+		return err
+	}
+	if err := a.cleanReplaced(ctx, overwritten, replaced); err != nil {
+		return err
+	}
+	if lost != nil {
+		// The items that follow the conflict are processed again once the import is resumed,
+		// and the ones that were stored are skipped then, as they hold the same url
+		conflict = fmt.Errorf("record %d: slug %q: %w", lost.record, lost.slug, ErrSlugTaken)
+		expired = 0
+		for i, item := range batch {
+			if item == lost {
+				batch = batch[:i]
+				break
+			}
+			if item.expired {
+				expired++
+			}
+		}
+	}
+	imported := 0
+	for i, item := range created {
+		if success[i] && (lost == nil || item.record < lost.record) {
+			imported++
+		}
+	}
+	progress.Imported += imported
+	progress.Overwritten += len(overwritten)
+	progress.Skipped += len(batch) - imported - len(overwritten) - expired
+	progress.Expired += expired
+	progress.Records += len(batch)
+	return conflict
+}
+
+// importTombstones returns the tombstone of the given imported item, if it expires
+func (a *Admin) importTombstones(item *importItem) []cache.Entry {
+	if item.expiration == 0 {
+		return nil
+	}
+	return []cache.Entry{a.rs.tombstone(item.slug, *item.link.ExpiresAt, item.expiration)}
+}
+
+// cleanReplaced removes what the links replaced by the given overwritten items left behind:
+// the reverse index entries that still point to their slugs, unless the items hold the same
+// long url of the same API key, and the tombstones of the items that never expire, so their
+// slugs are never reported as expired while they hold a link
+func (a *Admin) cleanReplaced(
+	ctx context.Context, items []*importItem, replaced []*cache.Link,
+) error {
+	var indexKeys []string
+	var indexSlugs []string
+	for i, item := range items {
+		if item.expiration == 0 {
+			if _, err := a.rs.cache.Delete(ctx, tombstoneKey(item.slug)); err != nil {
+				return err
+			}
+		}
+		if replaced[i] == nil || urlIndexKey(replaced[i]) == urlIndexKey(item.link) {
+			continue
+		}
+		indexKeys = append(indexKeys, urlIndexKey(replaced[i]))
+		indexSlugs = append(indexSlugs, item.slug)
+	}
+	indexed, err := a.rs.cache.GetAll(ctx, indexKeys)
+	if err != nil {
+		return err
+	}
+	for i, slug := range indexed {
+		// The long url can be indexed with another slug, which is still valid
+		if slug != indexSlugs[i] {
+			continue
+		}
+		if _, err := a.rs.cache.Delete(ctx, indexKeys[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A linkWriter writes the links of an export
+type linkWriter interface {
+	Write(link *Link) error
+	// Flush writes the buffered links to the underlying writer
+	Flush() error
+}
+
+// newLinkWriter returns the writer of the given format, which starts with
+// the CSV header if it is set
+func newLinkWriter(format string, w io.Writer, header bool) (linkWriter, error) {
+	switch format {
+	case "", FormatJSONL:
+		return &jsonlWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		writer := &csvWriter{writer: csv.NewWriter(w)}
+		if header {
+			if err := writer.writer.Write(csvColumns); err != nil {
+				return nil, err
+			}
+		}
+		return writer, nil
+	}
+	return nil, fmt.Errorf("invalid format %q (allowed: csv, jsonl)", format)
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(link *Link) error {
+	return w.encoder.Encode(link)
+}
+
+func (w *jsonlWriter) Flush() error {
+	// The encoder does not buffer
+	return nil
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvWriter) Write(link *Link) error {
+	return w.writer.Write([]string{
+		link.Slug,
+		link.URL,
+		formatCSVTime(link.CreatedAt),
+		formatCSVTime(link.ExpiresAt),
+		link.Title,
+		strings.Join(link.Tags, ","),
+		strconv.FormatBool(link.Disabled),
+		link.Owner,
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// A linkReader reads the links of an import, returning io.EOF once they are all read
+type linkReader interface {
+	Read() (*Link, error)
+}
+
+// newLinkReader returns the reader of the given format
+func newLinkReader(format string, r io.Reader) (linkReader, error) {
+	switch format {
+	case "", FormatJSONL:
+		return &jsonlReader{decoder: json.NewDecoder(r)}, nil
+	case FormatCSV:
+		return &csvReader{reader: csv.NewReader(r)}, nil
+	}
+	return nil, fmt.Errorf("invalid format %q (allowed: csv, jsonl)", format)
+}
+
+type jsonlReader struct {
+	decoder *json.Decoder
+}
+
+func (r *jsonlReader) Read() (*Link, error) {
+	link := &Link{}
+	if err := r.decoder.Decode(link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	// columns are the indexes of the known columns, read from the header
+	columns map[string]int
+}
+
+func (r *csvReader) Read() (*Link, error) {
+	if r.columns == nil {
+		if err := r.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+	row, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	value := func(column string) string {
+		if i, ok := r.columns[column]; ok {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	link := &Link{
+		Slug:  value("slug"),
+		URL:   value("url"),
+		Title: value("title"),
+		Owner: value("owner"),
+	}
+	if link.CreatedAt, err = parseCSVTime("createdAt", value("createdAt")); err != nil {
+		return nil, err
+	}
+	if link.ExpiresAt, err = parseCSVTime("expiresAt", value("expiresAt")); err != nil {
+		return nil, err
+	}
+	if tags := value("tags"); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			link.Tags = append(link.Tags, strings.TrimSpace(tag))
+		}
+	}
+	if disabled := value("disabled"); disabled != "" {
+		if link.Disabled, err = strconv.ParseBool(disabled); err != nil {
+			return nil, fmt.Errorf("invalid disabled %q", disabled)
+		}
+	}
+	return link, nil
+}
+
+// readHeader reads the columns of the header, which must include the slug and the url
+func (r *csvReader) readHeader() error {
+	header, err := r.reader.Read()
+	if err == io.EOF {
+		return errors.New("missing CSV header")
+	}
+	if err != nil {
+		return err
+	}
+	r.columns = map[string]int{}
+	for i, column := range header {
+		r.columns[strings.TrimSpace(column)] = i
+	}
+	for _, column := range []string{"slug", "url"} {
+		if _, ok := r.columns[column]; !ok {
+			return fmt.Errorf("missing %s column in the CSV header", column)
+		}
+	}
+	return nil
+}
+
+// formatCSVTime formats the given time in RFC 3339, being empty if it is not set
+func formatCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseCSVTime parses the given RFC 3339 time of a column, being nil if it is empty
+func parseCSVTime(column string, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", column, value)
+	}
+	return &t, nil
+}
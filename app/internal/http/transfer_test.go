@@ -0,0 +1,449 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// importLines returns the JSON lines of the given links
+func importLines(t *testing.T, links ...*Link) string {
+	out := &bytes.Buffer{}
+	for _, link := range links {
+		require.NoError(t, json.NewEncoder(out).Encode(link))
+	}
+	return out.String()
+}
+
+func TestAdmin_Export(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewTest()
+	admin := newTestAdmin(t, c)
+	_, err := admin.Create(ctx, &URLPayload{
+		URL:   "https://github.com/darioblanco",
+		Title: "Dario, Blanco",
+		Tags:  []string{"profile", "github"},
+	})
+	require.NoError(t, err)
+	_, err = admin.Create(ctx, &URLPayload{URL: "https://darioblanco.com", Alias: "dario"})
+	require.NoError(t, err)
+	// Legacy links only hold the long url
+	require.NoError(t, c.Set(ctx, "legacy", "https://example.com", time.Hour))
+
+	out := &bytes.Buffer{}
+	var pages []ExportProgress
+	progress, err := admin.Export(ctx, out, ExportOptions{
+		Progress: func(p ExportProgress) { pages = append(pages, p) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &ExportProgress{Exported: 3}, progress)
+	require.NotEmpty(t, pages)
+	assert.Equal(t, *progress, pages[len(pages)-1])
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	links := map[string]*Link{}
+	for _, line := range lines {
+		link := &Link{}
+		require.NoError(t, json.Unmarshal([]byte(line), link))
+		links[link.Slug] = link
+	}
+	assert.Equal(t, []string{"profile", "github"}, links["64fc5e"].Tags)
+	assert.Equal(t, "https://darioblanco.com", links["dario"].URL)
+	assert.Equal(t, "https://example.com", links["legacy"].URL)
+	require.NotNil(t, links["legacy"].ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *links["legacy"].ExpiresAt, 2*time.Second)
+
+	out.Reset()
+	progress, err = admin.Export(ctx, out, ExportOptions{Format: FormatCSV, Prefix: "64"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, progress.Exported)
+	createdAt := links["64fc5e"].CreatedAt.UTC().Format(time.RFC3339)
+	assert.Equal(t,
+		"slug,url,createdAt,expiresAt,title,tags,disabled,owner\n"+
+			"64fc5e,https://github.com/darioblanco,"+createdAt+
+			`,,"Dario, Blanco","profile,github",false,`+"\n",
+		out.String(),
+	)
+
+	// A complete export has nothing left to resume
+	out.Reset()
+	progress, err = admin.Export(ctx, out, ExportOptions{Resume: &ExportProgress{Exported: 3}})
+	require.NoError(t, err)
+	assert.Equal(t, &ExportProgress{Exported: 3}, progress)
+	assert.Empty(t, out.String())
+
+	_, err = admin.Export(ctx, out, ExportOptions{Format: "xml"})
+	assert.EqualError(t, err, `invalid format "xml" (allowed: csv, jsonl)`)
+}
+
+// interruptedCache fails the scans once it is interrupted
+type interruptedCache struct {
+	cache.Cache
+	interrupted bool
+}
+
+func (c *interruptedCache) Scan(
+	ctx context.Context, cursor string, match string, count int,
+) ([]string, string, error) {
+	if c.interrupted {
+		return nil, "", errors.New("interrupted")
+	}
+	return c.Cache.Scan(ctx, cursor, match, count)
+}
+
+func TestAdmin_Export_Resume(t *testing.T) {
+	ctx := context.Background()
+	// The memory store scans pages of the requested size
+	c := &interruptedCache{Cache: cache.NewMemory(ctx, &config.Values{})}
+	admin := newTestAdmin(t, c)
+	for i := 0; i < 2500; i++ {
+		longURL := fmt.Sprintf("https://example.com/%d", i)
+		require.NoError(t, c.Set(ctx, fmt.Sprintf("slug%d", i), longURL, 0))
+	}
+
+	// The export is interrupted after its first page
+	out := &bytes.Buffer{}
+	var checkpoint ExportProgress
+	_, err := admin.Export(ctx, out, ExportOptions{
+		Format: FormatCSV,
+		Progress: func(p ExportProgress) {
+			checkpoint = p
+			c.interrupted = true
+		},
+	})
+	assert.EqualError(t, err, "interrupted")
+	require.NotEmpty(t, checkpoint.Cursor)
+	require.Positive(t, checkpoint.Exported)
+	c.interrupted = false
+
+	progress, err := admin.Export(ctx, out, ExportOptions{Format: FormatCSV, Resume: &checkpoint})
+	require.NoError(t, err)
+	assert.Equal(t, 2500, progress.Exported)
+	assert.Empty(t, progress.Cursor)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	// The header is only written once
+	assert.Len(t, lines, 2501)
+	assert.Equal(t, 1, strings.Count(out.String(), "slug,url"))
+}
+
+func TestAdmin_Import(t *testing.T) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(24 * time.Hour).UTC().Round(time.Second)
+	past := time.Now().Add(-time.Hour).UTC().Round(time.Second)
+	input := importLines(t,
+		&Link{Slug: "fresh", URL: "https://example.com/fresh", ExpiresAt: &expiresAt},
+		&Link{Slug: "taken", URL: "https://example.com/taken", Tags: []string{"imported"}},
+		&Link{Slug: "same", URL: "https://example.com/same"},
+		&Link{Slug: "stale", URL: "https://example.com/stale", ExpiresAt: &past},
+		// The input repeats a slug
+		&Link{Slug: "fresh", URL: "https://example.com/repeated"},
+	)
+
+	tests := map[string]struct {
+		opts     ImportOptions
+		expected *ImportProgress
+		err      string
+		// urls are the long urls of the slugs once the import is done
+		urls map[string]string
+	}{
+		"skip": {
+			opts:     ImportOptions{},
+			expected: &ImportProgress{Records: 5, Imported: 1, Skipped: 3, Expired: 1},
+			urls: map[string]string{
+				"fresh": "https://example.com/fresh",
+				"taken": "https://darioblanco.com",
+				"same":  "https://example.com/same",
+			},
+		},
+		"overwrite": {
+			opts:     ImportOptions{Conflict: ConflictOverwrite, BatchSize: 2},
+			expected: &ImportProgress{Records: 5, Imported: 1, Overwritten: 3, Expired: 1},
+			urls: map[string]string{
+				"fresh": "https://example.com/repeated",
+				"taken": "https://example.com/taken",
+				"same":  "https://example.com/same",
+			},
+		},
+		"fail": {
+			opts:     ImportOptions{Conflict: ConflictFail},
+			expected: &ImportProgress{Records: 1, Imported: 1},
+			err:      `record 2: slug "taken": slug is already taken`,
+			urls: map[string]string{
+				"fresh": "https://example.com/fresh",
+				"taken": "https://darioblanco.com",
+			},
+		},
+		"dry run": {
+			opts:     ImportOptions{Conflict: ConflictOverwrite, DryRun: true},
+			expected: &ImportProgress{Records: 5, Imported: 1, Overwritten: 3, Expired: 1},
+			urls: map[string]string{
+				"fresh": "",
+				"taken": "https://darioblanco.com",
+				"same":  "https://example.com/same",
+			},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(name, func(t *testing.T) {
+			c := cache.NewTest()
+			admin := newTestAdmin(t, c)
+			_, err := admin.Create(ctx, &URLPayload{URL: "https://darioblanco.com", Alias: "taken"})
+			require.NoError(t, err)
+			_, err = admin.Create(ctx, &URLPayload{URL: "https://example.com/same", Alias: "same"})
+			require.NoError(t, err)
+
+			var batches []ImportProgress
+			tt.opts.Progress = func(p ImportProgress) { batches = append(batches, p) }
+			progress, err := admin.Import(ctx, strings.NewReader(input), tt.opts)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				assert.ErrorIs(t, err, ErrSlugTaken)
+			} else {
+				require.NoError(t, err)
+				require.NotEmpty(t, batches)
+				assert.Equal(t, *progress, batches[len(batches)-1])
+			}
+			assert.Equal(t, tt.expected, progress)
+			for slug, longURL := range tt.urls {
+				link, err := c.GetLink(ctx, slug)
+				require.NoError(t, err)
+				if longURL == "" {
+					assert.Nil(t, link, slug)
+				} else {
+					require.NotNil(t, link, slug)
+					assert.Equal(t, longURL, link.URL, slug)
+				}
+			}
+			link, err := c.GetLink(ctx, "stale")
+			require.NoError(t, err)
+			assert.Nil(t, link)
+		})
+	}
+}
+
+// racingCache takes a slug with a different link right after the links of a batch are read
+type racingCache struct {
+	cache.Cache
+	slug string
+}
+
+func (c racingCache) GetLinks(ctx context.Context, keys []string) ([]*cache.Link, error) {
+	links, err := c.Cache.GetLinks(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	return links, c.Cache.Set(ctx, c.slug, "https://darioblanco.com", 0)
+}
+
+func TestAdmin_Import_FailRace(t *testing.T) {
+	ctx := context.Background()
+	c := racingCache{Cache: cache.NewTest(), slug: "bbbb"}
+	admin := newTestAdmin(t, c)
+	input := "slug,url\n" +
+		"aaaa,https://example.com/a\n" +
+		"bbbb,https://example.com/b\n" +
+		"cccc,https://example.com/c\n"
+
+	progress, err := admin.Import(ctx, strings.NewReader(input), ImportOptions{
+		Format:   FormatCSV,
+		Conflict: ConflictFail,
+	})
+	assert.EqualError(t, err, `record 2: slug "bbbb": slug is already taken`)
+	assert.ErrorIs(t, err, ErrSlugTaken)
+	assert.Equal(t, &ImportProgress{Records: 1, Imported: 1}, progress)
+	link, err := c.GetLink(ctx, "bbbb")
+	require.NoError(t, err)
+	assert.Equal(t, "https://darioblanco.com", link.URL)
+}
+
+func TestAdmin_Import_Expiration(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewTest()
+	admin := newTestAdmin(t, c)
+	expiresAt := time.Now().Add(24 * time.Hour).UTC().Round(time.Second)
+	_, err := admin.Import(ctx, strings.NewReader(
+		"slug,url,expiresAt,unknown\n"+
+			"dario,https://darioblanco.com,"+expiresAt.Format(time.RFC3339)+",ignored\n",
+	), ImportOptions{Format: FormatCSV})
+	require.NoError(t, err)
+
+	link, err := admin.Get(ctx, "dario")
+	require.NoError(t, err)
+	assert.Equal(t, &expiresAt, link.ExpiresAt)
+	ttl, err := c.TTL(ctx, "dario")
+	require.NoError(t, err)
+	assert.InDelta(t, (24 * time.Hour).Seconds(), ttl.Seconds(), 2)
+	// The imported link is reported as expired once it expires
+	ttl, err = c.TTL(ctx, tombstoneKey("dario"))
+	require.NoError(t, err)
+	assert.InDelta(t, (25 * time.Hour).Seconds(), ttl.Seconds(), 2)
+}
+
+func TestAdmin_Import_OverwriteCleanup(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewTest()
+	admin := newTestAdmin(t, c)
+	expiring, err := admin.Create(ctx, &URLPayload{URL: "https://darioblanco.com", ExpiresIn: "1h"})
+	require.NoError(t, err)
+	kept, err := admin.Create(ctx, &URLPayload{URL: "https://github.com/darioblanco"})
+	require.NoError(t, err)
+	// The long url of the legacy link is indexed with another slug
+	require.NoError(t, c.Set(ctx, "legacy", "https://example.org", 0))
+	otherIndex := urlIndexKey(&cache.Link{URL: "https://example.org"})
+	require.NoError(t, c.Set(ctx, otherIndex, "other", 0))
+
+	_, err = admin.Import(ctx, strings.NewReader(importLines(t,
+		&Link{Slug: expiring.Slug, URL: "https://example.com/new"},
+		&Link{Slug: kept.Slug, URL: "https://github.com/darioblanco", Title: "Dario Blanco"},
+		&Link{Slug: "legacy", URL: "https://example.com/legacy"},
+	)), ImportOptions{Conflict: ConflictOverwrite})
+	require.NoError(t, err)
+
+	// The index entry of the replaced link is removed, as well as its tombstone
+	slug, err := c.Get(ctx, urlIndexKey(&cache.Link{URL: "https://darioblanco.com"}))
+	require.NoError(t, err)
+	assert.Empty(t, slug)
+	expired, err := c.Get(ctx, tombstoneKey(expiring.Slug))
+	require.NoError(t, err)
+	assert.Empty(t, expired)
+	// The index entry is kept if the slug still holds the same long url
+	slug, err = c.Get(ctx, urlIndexKey(&cache.Link{URL: "https://github.com/darioblanco"}))
+	require.NoError(t, err)
+	assert.Equal(t, kept.Slug, slug)
+	// The index entries that point to other slugs are kept too
+	slug, err = c.Get(ctx, otherIndex)
+	require.NoError(t, err)
+	assert.Equal(t, "other", slug)
+}
+
+func TestAdmin_Import_Resume(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewTest()
+	admin := newTestAdmin(t, c)
+	input := "slug,url\n" +
+		"aaaa,https://example.com/a\n" +
+		"bbbb,https://example.com/b\n" +
+		"cccc,invalid\n" +
+		"dddd,https://example.com/d\n"
+
+	progress, err := admin.Import(ctx, strings.NewReader(input), ImportOptions{
+		Format:    FormatCSV,
+		BatchSize: 1,
+	})
+	assert.EqualError(t, err, "record 3: invalid http/https url format")
+	assert.Equal(t, &ImportProgress{Records: 2, Imported: 2}, progress)
+
+	// The fixed input is imported from the record that failed
+	fixed := strings.Replace(input, "cccc,invalid", "cccc,https://example.com/c", 1)
+	require.NoError(t, c.Set(ctx, "aaaa", "https://example.com/changed", 0))
+	progress, err = admin.Import(ctx, strings.NewReader(fixed), ImportOptions{
+		Format: FormatCSV,
+		Resume: progress,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &ImportProgress{Records: 4, Imported: 4}, progress)
+	link, err := c.GetLink(ctx, "aaaa")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/changed", link.URL)
+	link, err = c.GetLink(ctx, "dddd")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/d", link.URL)
+}
+
+func TestAdmin_Import_Invalid(t *testing.T) {
+	ctx := context.Background()
+	admin := newTestAdmin(t, cache.NewTest())
+	tests := map[string]struct {
+		input    string
+		opts     ImportOptions
+		expected string
+	}{
+		"invalid json": {
+			input:    `{"slug":"abcd","url":"https://github.com"}` + "\n{",
+			expected: "record 2: unexpected EOF",
+		},
+		"invalid slug": {
+			input:    `{"slug":"url:abcd","url":"https://github.com"}`,
+			expected: `record 1: invalid slug "url:abcd"`,
+		},
+		"short slug": {
+			input:    `{"slug":"abc","url":"https://github.com"}`,
+			expected: `record 1: invalid slug "abc": alias must have at least 4 characters`,
+		},
+		"invalid url": {
+			input:    `{"slug":"abcd","url":"github.com"}`,
+			expected: "record 1: invalid http/https url format",
+		},
+		"missing header": {
+			input:    "",
+			opts:     ImportOptions{Format: FormatCSV},
+			expected: "record 1: missing CSV header",
+		},
+		"missing column": {
+			input:    "slug,title\nabc,ABC\n",
+			opts:     ImportOptions{Format: FormatCSV},
+			expected: "record 1: missing url column in the CSV header",
+		},
+		"invalid time": {
+			input:    "slug,url,createdAt\nabcd,https://github.com,yesterday\n",
+			opts:     ImportOptions{Format: FormatCSV},
+			expected: `record 1: invalid createdAt "yesterday"`,
+		},
+		"invalid disabled": {
+			input:    "slug,url,disabled\nabcd,https://github.com,maybe\n",
+			opts:     ImportOptions{Format: FormatCSV},
+			expected: `record 1: invalid disabled "maybe"`,
+		},
+		"invalid format": {
+			opts:     ImportOptions{Format: "xml"},
+			expected: `invalid format "xml" (allowed: csv, jsonl)`,
+		},
+		"invalid conflict policy": {
+			opts:     ImportOptions{Conflict: "merge"},
+			expected: `invalid conflict policy "merge" (allowed: skip, overwrite, fail)`,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(name, func(t *testing.T) {
+			_, err := admin.Import(ctx, strings.NewReader(tt.input), tt.opts)
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+func TestAdmin_ExportImport_CSV(t *testing.T) {
+	ctx := context.Background()
+	source := newTestAdmin(t, cache.NewTest())
+	created, err := source.Create(ctx, &URLPayload{
+		URL:       "https://github.com/darioblanco",
+		Title:     `Dario "Blanco"`,
+		Tags:      []string{"profile", "github"},
+		ExpiresIn: "24h",
+	})
+	require.NoError(t, err)
+	out := &bytes.Buffer{}
+	_, err = source.Export(ctx, out, ExportOptions{Format: FormatCSV})
+	require.NoError(t, err)
+
+	target := newTestAdmin(t, cache.NewTest())
+	progress, err := target.Import(ctx, out, ImportOptions{Format: FormatCSV})
+	require.NoError(t, err)
+	assert.Equal(t, &ImportProgress{Records: 1, Imported: 1}, progress)
+	link, err := target.Get(ctx, created.Slug)
+	require.NoError(t, err)
+	link.stored = nil
+	created.stored = nil
+	assert.Equal(t, created, link)
+}
@@ -1,6 +1,9 @@
 package logging
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/darioblanco/shortesturl/app/internal/config"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -18,21 +21,38 @@ type Logger interface {
 
 type builtinLogger struct {
 	coreLogger *zap.Logger
-	logger     *zap.SugaredLogger
-	Preset     string
+	// level is the minimum enabled level, being nil if it can not be changed
+	level  *zap.AtomicLevel
+	logger *zap.SugaredLogger
+	Preset string
 }
 
 // NewLogger creates a logging instance
 func NewLogger(conf *config.Values) (Logger, error) {
 	var (
-		zapLogger *zap.Logger
-		err       error
+		zapConfig zap.Config
 		preset    string
 	)
 	if conf.Environment == "prod" ||
 		conf.Environment == "stage" ||
 		conf.Environment == "test" {
-		zapLogger, err = zap.NewProduction()
+		zapConfig = zap.NewProductionConfig()
+		preset = "production"
+	} else {
+		zapConfig = zap.NewDevelopmentConfig()
+		preset = "development"
+	}
+	// The level of the preset is kept if the configuration does not define one
+	if level := conf.Reloadable().LogLevel; level != "" {
+		if err := zapConfig.Level.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+		}
+	}
+	zapLogger, err := zapConfig.Build()
+	if err != nil {
+		return nil, err
+	}
+	if preset == "production" {
 		zapLogger = zapLogger.With(
 			zap.Field{
 				Key:    "environment",
@@ -45,17 +65,31 @@ func NewLogger(conf *config.Values) (Logger, error) {
 				String: conf.Version,
 			},
 		)
-		preset = "production"
-	} else {
-		zapLogger, err = zap.NewDevelopment()
-		preset = "development"
 	}
 	defer zapLogger.Sync()
 	return &builtinLogger{
 		coreLogger: zapLogger,
+		level:      &zapConfig.Level,
 		logger:     zapLogger.Sugar(),
 		Preset:     preset,
-	}, err
+	}, nil
+}
+
+// SetLevel changes the minimum level (debug, info, warn or error) of the given logger
+// and of every logger derived from it. An empty level restores the level of its preset.
+// The level of the loggers that are not created by NewLogger can not be changed.
+func SetLevel(logger Logger, level string) error {
+	l, ok := logger.(*builtinLogger)
+	if !ok || l.level == nil {
+		return errors.New("unable to change the level of the logger")
+	}
+	if level == "" {
+		level = zap.InfoLevel.String()
+		if l.Preset == "development" {
+			level = zap.DebugLevel.String()
+		}
+	}
+	return l.level.UnmarshalText([]byte(level))
 }
 
 // NewLoggerWithCore creates an abstracted logger
@@ -71,6 +105,7 @@ func NewLoggerWithCore(coreLogger *zap.Logger) *builtinLogger {
 func (l *builtinLogger) Named(name string) Logger {
 	return &builtinLogger{
 		coreLogger: l.coreLogger,
+		level:      l.level,
 		logger:     l.logger.Named(name),
 		Preset:     l.Preset,
 	}
@@ -106,7 +141,8 @@ func (l *builtinLogger) Named(name string) Logger {
 func (l *builtinLogger) With(args ...interface{}) Logger {
 	return &builtinLogger{
 		coreLogger: l.coreLogger,
-		logger:     l.logger.With(args),
+		level:      l.level,
+		logger:     l.logger.With(args...),
 		Preset:     l.Preset,
 	}
 }
@@ -22,6 +22,31 @@ func TestNewLogger_Production(t *testing.T) {
 	assert.Equal(t, "production", logger.(*builtinLogger).Preset)
 }
 
+func TestNewLogger_Level(t *testing.T) {
+	logger, err := NewLogger(&config.Values{Environment: "prod", LogLevel: "warn"})
+	assert.NoError(t, err)
+	assert.Equal(t, zap.WarnLevel, logger.(*builtinLogger).level.Level())
+
+	_, err = NewLogger(&config.Values{Environment: "prod", LogLevel: "verbose"})
+	assert.EqualError(t, err, `invalid log level "verbose": unrecognized level: "verbose"`)
+}
+
+func TestSetLevel(t *testing.T) {
+	logger, err := NewLogger(&config.Values{Environment: "dev"})
+	assert.NoError(t, err)
+	named := logger.Named("test")
+	assert.True(t, named.(*builtinLogger).coreLogger.Core().Enabled(zap.DebugLevel))
+
+	assert.NoError(t, SetLevel(logger, "error"))
+	assert.False(t, named.(*builtinLogger).coreLogger.Core().Enabled(zap.WarnLevel))
+	// The level of the preset is restored
+	assert.NoError(t, SetLevel(logger, ""))
+	assert.True(t, named.(*builtinLogger).coreLogger.Core().Enabled(zap.DebugLevel))
+
+	assert.Error(t, SetLevel(logger, "verbose"))
+	assert.EqualError(t, SetLevel(NewTest(t), "info"), "unable to change the level of the logger")
+}
+
 func TestNewLoggerWithCore(t *testing.T) {
 	zapLogger := zap.NewNop()
 	logger := NewLoggerWithCore(zapLogger)
@@ -40,7 +65,7 @@ func TestLoggerWith(t *testing.T) {
 	testLogger := zaptest.NewLogger(t)
 	logger := &builtinLogger{logger: testLogger.Sugar()}
 	newLogger := logger.With("hello", "world")
-	assert.Equal(t, newLogger, logger)
+	assert.NotEqual(t, newLogger, logger)
 }
 
 func TestLoggerGetCoreLogger(t *testing.T) {
@@ -0,0 +1,21 @@
+package logging
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTrace returns a logger that adds the trace and span IDs of the span of the given
+// context to every entry, so the logs of a request can be correlated with its trace.
+// The logger is returned as it is if the context has no span.
+func WithTrace(ctx context.Context, logger Logger) Logger {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return logger
+	}
+	return logger.With(
+		"traceId", spanContext.TraceID().String(),
+		"spanId", spanContext.SpanID().String(),
+	)
+}
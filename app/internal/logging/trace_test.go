@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithTrace(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := NewLoggerWithCore(zap.New(core))
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(
+		trace.SpanContextConfig{TraceID: traceID, SpanID: spanID},
+	))
+
+	WithTrace(ctx, logger).Info("Served")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{
+		"traceId": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"spanId":  "00f067aa0ba902b7",
+	}, logs.All()[0].ContextMap())
+}
+
+func TestWithTrace_NoSpan(t *testing.T) {
+	logger := NewTest(t)
+	assert.Same(t, logger, WithTrace(context.Background(), logger))
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the name of every metric of the service
+const namespace = "shortesturl"
+
+// Registry holds the collectors of the service, besides the Go runtime and process ones.
+// A dedicated registry is used instead of the global one, so the exposed metrics
+// do not depend on the libraries that register their own collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts the served requests per method, route pattern and status code
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests served, per method, route and status code.",
+	}, []string{"method", "route", "status"})
+	// HTTPRequestDuration observes the latency of the served requests per method,
+	// route pattern and status code
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the HTTP requests, per method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	// EncodeAttempts observes the number of slug candidates tried by each encoded url,
+	// being one if there was no collision
+	EncodeAttempts = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "encode",
+		Name:      "attempts",
+		Help:      "Number of slug candidates tried to encode a url, being more than one on collisions.",
+		Buckets:   []float64{1, 2, 3, 5, 10, 25, 50, 100},
+	})
+	// RedirectHits counts the short urls redirected to their long url
+	RedirectHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "redirect",
+		Name:      "hits_total",
+		Help:      "Number of short urls redirected to their long url.",
+	})
+	// CacheOperationDuration observes the latency of the cache operations per
+	// operation and result (ok or error)
+	CacheOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of the cache operations, per operation and result.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+	}, []string{"operation", "result"})
+	// CacheLockRetries counts the optimistic lock transactions of SetIfNotExists that
+	// were retried, as the watched key changed before they were committed
+	CacheLockRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "lock_retries_total",
+		Help:      "Number of optimistic lock transactions retried by SetIfNotExists.",
+	})
+	// CacheLockFailures counts the SetIfNotExists operations that failed after
+	// running out of optimistic lock retries
+	CacheLockFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "lock_failures_total",
+		Help:      "Number of SetIfNotExists operations that ran out of optimistic lock retries.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		EncodeAttempts,
+		RedirectHits,
+		CacheOperationDuration,
+		CacheLockRetries,
+		CacheLockFailures,
+	)
+}
+
+// Handler serves the metrics of the Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
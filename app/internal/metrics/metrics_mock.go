@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// SampleCount returns the number of observations of the given histogram, for test purposes
+func SampleCount(observer prometheus.Observer) uint64 {
+	return histogram(observer).GetSampleCount()
+}
+
+// SampleSum returns the sum of the observations of the given histogram, for test purposes
+func SampleSum(observer prometheus.Observer) float64 {
+	return histogram(observer).GetSampleSum()
+}
+
+// histogram returns the current state of the given histogram
+func histogram(observer prometheus.Observer) *dto.Histogram {
+	m := &dto.Metric{}
+	if err := observer.(prometheus.Metric).Write(m); err != nil {
+		return nil
+	}
+	return m.GetHistogram()
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	RedirectHits.Inc()
+	EncodeAttempts.Observe(2)
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE shortesturl_redirect_hits_total counter")
+	assert.Contains(t, body, `shortesturl_encode_attempts_bucket{le="2"} 1`)
+	assert.Contains(t, body, "shortesturl_cache_lock_retries_total 0")
+	assert.Contains(t, body, "shortesturl_cache_lock_failures_total 0")
+	assert.Contains(t, body, "process_start_time_seconds")
+}
+
+func TestSampleCount(t *testing.T) {
+	observer := CacheOperationDuration.WithLabelValues("get", "ok")
+	count, sum := SampleCount(observer), SampleSum(observer)
+	observer.Observe(0.5)
+	assert.Equal(t, count+1, SampleCount(observer))
+	assert.Equal(t, sum+0.5, SampleSum(observer))
+}
@@ -0,0 +1,71 @@
+package qr
+
+import (
+	"container/list"
+	"sync"
+)
+
+// A Cache keeps the most recently rendered QR codes, as rendering the big ones is expensive
+// and the same codes are usually requested again (e.g. by every reader of a page).
+// A nil Cache renders the codes every time.
+type Cache struct {
+	mu         sync.Mutex
+	entries    map[cacheKey]*list.Element
+	order      *list.List
+	maxEntries int
+}
+
+// cacheKey identifies a QR code, as the image only depends on its content and options
+type cacheKey struct {
+	content string
+	opts    Options
+}
+
+type cacheEntry struct {
+	key   cacheKey
+	image []byte
+}
+
+// NewCache returns a Cache that keeps up to the given number of QR codes,
+// evicting the least recently used one when it is full
+func NewCache(maxEntries int) *Cache {
+	return &Cache{
+		entries:    make(map[cacheKey]*list.Element),
+		order:      list.New(),
+		maxEntries: maxEntries,
+	}
+}
+
+// Render returns the cached QR code of the given content and options, rendering it
+// if it is not cached yet. The returned image is shared, thus it must not be modified.
+func (c *Cache) Render(content string, opts Options) ([]byte, error) {
+	if c == nil {
+		return Render(content, opts)
+	}
+	key := cacheKey{content: content, opts: opts}
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*cacheEntry).image, nil
+	}
+	c.mu.Unlock()
+
+	// The lock is not held while rendering, thus concurrent misses may render the same code
+	image, err := Render(content, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return image, nil
+	}
+	if c.order.Len() >= c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, image: image})
+	return image, nil
+}
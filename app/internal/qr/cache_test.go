@@ -0,0 +1,65 @@
+package qr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_Render(t *testing.T) {
+	c := NewCache(2)
+	opts := DefaultOptions()
+	expected, err := Render(testContent, opts)
+	require.NoError(t, err)
+
+	image, err := c.Render(testContent, opts)
+	require.NoError(t, err)
+	assert.Equal(t, expected, image)
+	cached, err := c.Render(testContent, opts)
+	require.NoError(t, err)
+	// The second request returns the cached image instead of rendering it again
+	assert.Same(t, &image[0], &cached[0])
+	assert.Equal(t, 1, c.order.Len())
+
+	svg := opts
+	svg.Format = FormatSVG
+	_, err = c.Render(testContent, svg)
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.order.Len())
+}
+
+func TestCache_Eviction(t *testing.T) {
+	c := NewCache(2)
+	small := DefaultOptions()
+	big := DefaultOptions()
+	big.Size = MaxSize
+	svg := DefaultOptions()
+	svg.Format = FormatSVG
+	for _, opts := range []Options{small, big, small, svg} {
+		_, err := c.Render(testContent, opts)
+		require.NoError(t, err)
+	}
+	// Rendering small again made big the least recently used code
+	assert.Len(t, c.entries, 2)
+	assert.Contains(t, c.entries, cacheKey{content: testContent, opts: small})
+	assert.Contains(t, c.entries, cacheKey{content: testContent, opts: svg})
+}
+
+func TestCache_RenderInvalid(t *testing.T) {
+	c := NewCache(2)
+	opts := DefaultOptions()
+	opts.Format = "gif"
+	_, err := c.Render(testContent, opts)
+	assert.EqualError(t, err, `invalid format "gif" (allowed: png, svg)`)
+	assert.Empty(t, c.entries)
+}
+
+func TestCache_Nil(t *testing.T) {
+	var c *Cache
+	image, err := c.Render(testContent, DefaultOptions())
+	require.NoError(t, err)
+	expected, err := Render(testContent, DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, expected, image)
+}
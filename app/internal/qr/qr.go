@@ -0,0 +1,205 @@
+// Package qr renders the short urls as QR codes, in PNG or SVG
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// FormatPNG renders the QR code as a PNG image
+	FormatPNG = "png"
+	// FormatSVG renders the QR code as an SVG image
+	FormatSVG = "svg"
+)
+
+const (
+	// DefaultSize is the default width and height of the QR codes, in pixels
+	DefaultSize = 256
+	// MaxSize caps the width and height of the QR codes, in pixels
+	MaxSize = 2048
+	// DefaultMargin is the default quiet zone around the QR codes, in modules,
+	// which is the minimum required by the specification
+	DefaultMargin = 4
+	// MaxMargin caps the quiet zone around the QR codes, in modules
+	MaxMargin = 16
+)
+
+// levels are the error correction levels, by the share of the code that can be restored
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,     // 7%
+	"M": qrcode.Medium,  // 15%
+	"Q": qrcode.High,    // 25%
+	"H": qrcode.Highest, // 30%
+}
+
+// ErrSameColors is returned when the foreground and the background can not be told apart
+var ErrSameColors = errors.New("the foreground and background colors must be different")
+
+// Options configure the rendering of a QR code
+type Options struct {
+	// Format is FormatPNG or FormatSVG
+	Format string
+	// Size is the width and height of the image, in pixels
+	Size int
+	// Level is the error correction level: L, M, Q or H
+	Level string
+	// Margin is the quiet zone around the code, in modules
+	Margin     int
+	Foreground color.NRGBA
+	Background color.NRGBA
+}
+
+// DefaultOptions returns the options of a black on white PNG code
+func DefaultOptions() Options {
+	return Options{
+		Format:     FormatPNG,
+		Size:       DefaultSize,
+		Level:      "M",
+		Margin:     DefaultMargin,
+		Foreground: color.NRGBA{A: 0xff},
+		Background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+// Validate checks that the options are within their allowed values
+func (o Options) Validate() error {
+	if o.Format != FormatPNG && o.Format != FormatSVG {
+		return fmt.Errorf("invalid format %q (allowed: png, svg)", o.Format)
+	}
+	if o.Size <= 0 || o.Size > MaxSize {
+		return fmt.Errorf("size must be between 1 and %d pixels", MaxSize)
+	}
+	if _, ok := levels[o.Level]; !ok {
+		return fmt.Errorf("invalid level %q (allowed: L, M, Q, H)", o.Level)
+	}
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return fmt.Errorf("margin must be between 0 and %d modules", MaxMargin)
+	}
+	if o.Foreground == o.Background {
+		return ErrSameColors
+	}
+	return nil
+}
+
+// ContentType returns the media type of the images of the given format
+func ContentType(format string) string {
+	if format == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// Render encodes the given content as a QR code with the given options. The image only
+// depends on its content and options, thus rendering them again returns the same bytes.
+func Render(content string, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	code, err := qrcode.New(content, levels[opts.Level])
+	if err != nil {
+		return nil, err
+	}
+	// The quiet zone is drawn with the requested margin instead
+	code.DisableBorder = true
+	bitmap := code.Bitmap()
+	modules := len(bitmap) + 2*opts.Margin
+	if opts.Format == FormatSVG {
+		return renderSVG(bitmap, modules, opts), nil
+	}
+	if opts.Size < modules {
+		return nil, fmt.Errorf("size must be at least %d pixels to draw the code", modules)
+	}
+	return renderPNG(bitmap, modules, opts)
+}
+
+// renderPNG draws every module as a square of whole pixels, centering the code
+// in the image if the size is not a multiple of the modules
+func renderPNG(bitmap [][]bool, modules int, opts Options) ([]byte, error) {
+	palette := color.Palette{opts.Background, opts.Foreground}
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), palette)
+	scale := opts.Size / modules
+	offset := (opts.Size-scale*modules)/2 + scale*opts.Margin
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex(offset+x*scale+px, offset+y*scale+py, 1)
+				}
+			}
+		}
+	}
+	out := &bytes.Buffer{}
+	if err := png.Encode(out, img); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// renderSVG draws a path with the horizontal runs of dark modules, in a view box
+// of one unit per module that is scaled to the size of the image
+func renderSVG(bitmap [][]bool, modules int, opts Options) []byte {
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" `+
+		`viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, modules, modules)
+	fmt.Fprintf(out, `<rect width="%d" height="%d"%s/>`, modules, modules, svgFill(opts.Background))
+	out.WriteString(`<path d="`)
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(out, "M%d %dh%dv1h-%dz", start+opts.Margin, y+opts.Margin, x-start, x-start)
+		}
+	}
+	fmt.Fprintf(out, `"%s/></svg>`, svgFill(opts.Foreground))
+	return out.Bytes()
+}
+
+// svgFill returns the fill attributes of the given color
+func svgFill(c color.NRGBA) string {
+	fill := fmt.Sprintf(` fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A != 0xff {
+		fill += fmt.Sprintf(` fill-opacity="%s"`,
+			strconv.FormatFloat(float64(c.A)/0xff, 'f', 3, 64))
+	}
+	return fill
+}
+
+// ParseColor parses a color in hexadecimal notation, with an optional alpha channel
+// and an optional leading # (e.g. #1a2b3c or 1a2b3c80)
+func ParseColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q (e.g. #1a2b3c)", s)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q (e.g. #1a2b3c)", s)
+	}
+	return color.NRGBA{
+		R: uint8(value >> 24),
+		G: uint8(value >> 16),
+		B: uint8(value >> 8),
+		A: uint8(value),
+	}, nil
+}
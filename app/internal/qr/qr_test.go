@@ -0,0 +1,178 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testContent = "http://localhost:3000/64fc5e"
+
+// testBitmap returns the modules of the test content, without quiet zone
+func testBitmap(t *testing.T, level qrcode.RecoveryLevel) [][]bool {
+	code, err := qrcode.New(testContent, level)
+	require.NoError(t, err)
+	code.DisableBorder = true
+	return code.Bitmap()
+}
+
+func TestRender_PNG(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Size = 300
+	opts.Foreground = color.NRGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}
+	content, err := Render(testContent, opts)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	assert.Equal(t, 300, img.Bounds().Dy())
+
+	bitmap := testBitmap(t, qrcode.Medium)
+	modules := len(bitmap) + 2*DefaultMargin
+	scale := 300 / modules
+	offset := (300-scale*modules)/2 + scale*DefaultMargin
+	// The center of every module has its color
+	for y, row := range bitmap {
+		for x, dark := range row {
+			expected := opts.Background
+			if dark {
+				expected = opts.Foreground
+			}
+			actual := color.NRGBAModel.Convert(
+				img.At(offset+x*scale+scale/2, offset+y*scale+scale/2),
+			)
+			require.Equal(t, expected, actual, "module %d,%d", x, y)
+		}
+	}
+	// The quiet zone has the background color
+	assert.Equal(t, opts.Background, color.NRGBAModel.Convert(img.At(offset-1, offset-1)))
+	assert.Equal(t, opts.Background, color.NRGBAModel.Convert(img.At(0, 0)))
+
+	again, err := Render(testContent, opts)
+	require.NoError(t, err)
+	assert.Equal(t, content, again)
+}
+
+func TestRender_SVG(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Format = FormatSVG
+	opts.Level = "H"
+	opts.Margin = 0
+	opts.Background = color.NRGBA{R: 0xff, G: 0xff, B: 0xff}
+	content, err := Render(testContent, opts)
+	require.NoError(t, err)
+
+	modules := len(testBitmap(t, qrcode.Highest))
+	svg := string(content)
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" `+
+		`width="256" height="256" viewBox="0 0 `))
+	assert.Contains(t, svg, `<rect width="`)
+	assert.Contains(t, svg, ` fill="#ffffff" fill-opacity="0.000"/>`)
+	assert.Contains(t, svg, `" fill="#000000"/></svg>`)
+	// Without margin, the top left finder pattern starts at the origin with a run of 7 modules
+	assert.Contains(t, svg, `<path d="M0 0h7v1h-7z`)
+	assert.Contains(t, svg, fmt.Sprintf(`viewBox="0 0 %d %d"`, modules, modules))
+
+	// Small sizes are scaled by the viewer
+	opts.Size = 16
+	_, err = Render(testContent, opts)
+	assert.NoError(t, err)
+}
+
+func TestRender_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		update   func(*Options)
+		expected string
+	}{
+		"format": {
+			update:   func(o *Options) { o.Format = "gif" },
+			expected: `invalid format "gif" (allowed: png, svg)`,
+		},
+		"zero size": {
+			update:   func(o *Options) { o.Size = 0 },
+			expected: "size must be between 1 and 2048 pixels",
+		},
+		"big size": {
+			update:   func(o *Options) { o.Size = 4096 },
+			expected: "size must be between 1 and 2048 pixels",
+		},
+		"small size": {
+			update:   func(o *Options) { o.Size = 20 },
+			expected: "size must be at least 37 pixels to draw the code",
+		},
+		"level": {
+			update:   func(o *Options) { o.Level = "X" },
+			expected: `invalid level "X" (allowed: L, M, Q, H)`,
+		},
+		"margin": {
+			update:   func(o *Options) { o.Margin = 17 },
+			expected: "margin must be between 0 and 16 modules",
+		},
+		"same colors": {
+			update:   func(o *Options) { o.Background = o.Foreground },
+			expected: ErrSameColors.Error(),
+		},
+	}
+	for name, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(name, func(t *testing.T) {
+			opts := DefaultOptions()
+			tt.update(&opts)
+			_, err := Render(testContent, opts)
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+func TestContentType(t *testing.T) {
+	assert.Equal(t, "image/png", ContentType(FormatPNG))
+	assert.Equal(t, "image/svg+xml", ContentType(FormatSVG))
+}
+
+func TestParseColor(t *testing.T) {
+	tests := map[string]struct {
+		value    string
+		expected color.NRGBA
+		err      string
+	}{
+		"hash": {
+			value:    "#1a2b3c",
+			expected: color.NRGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff},
+		},
+		"no hash": {
+			value:    "FF0000",
+			expected: color.NRGBA{R: 0xff, A: 0xff},
+		},
+		"alpha": {
+			value:    "#ffffff00",
+			expected: color.NRGBA{R: 0xff, G: 0xff, B: 0xff},
+		},
+		"name": {
+			value: "red",
+			err:   `invalid color "red" (e.g. #1a2b3c)`,
+		},
+		"not hexadecimal": {
+			value: "#12345g",
+			err:   `invalid color "#12345g" (e.g. #1a2b3c)`,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(name, func(t *testing.T) {
+			c, err := ParseColor(tt.value)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, c)
+		})
+	}
+}
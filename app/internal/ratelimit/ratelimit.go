@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+)
+
+// defaultWindow is the window used when the configuration of a route does not define it
+const defaultWindow = time.Minute
+
+// A Limiter counts the requests of each client, and decides if they are allowed
+type Limiter interface {
+	// Allow records a request of the given client, returning if it is within the limit.
+	// Rejected requests are recorded too, so a client that keeps retrying stays limited.
+	Allow(ctx context.Context, client string) (*Decision, error)
+	// AllowN records n requests of the given client at once like Allow, e.g. the urls of
+	// a batch, returning if all of them are within the limit
+	AllowN(ctx context.Context, client string, n int) (*Decision, error)
+}
+
+// A Decision is the outcome of a request of a client
+type Decision struct {
+	Allowed bool
+	// Limit is the number of requests allowed in each window
+	Limit int
+	// Remaining is the number of requests that the client can still perform
+	Remaining int
+	// Reset is the time until the client recovers its quota. If the request is rejected,
+	// it is the time that the client has to wait before retrying.
+	Reset time.Duration
+}
+
+type limiter struct {
+	cache  cache.Cache
+	limit  int
+	name   string
+	now    func() time.Time
+	window time.Duration
+}
+
+// New creates a limiter that keeps the counters of the given route in the cache,
+// so they are shared by every replica of the service. It returns nil if the route
+// has no limit.
+//
+// The limiter implements a sliding window counter: the requests of the current fixed
+// window are added to the requests of the previous one, weighted by the time that
+// the sliding window still overlaps with it.
+func New(c cache.Cache, name string, conf config.RateLimitRouteValues) Limiter {
+	if conf.Limit <= 0 {
+		return nil
+	}
+	window := time.Duration(conf.WindowInSeconds) * time.Second
+	if window <= 0 {
+		window = defaultWindow
+	}
+	return &limiter{
+		cache:  c,
+		limit:  conf.Limit,
+		name:   name,
+		now:    time.Now,
+		window: window,
+	}
+}
+
+// reloadable is a Limiter that builds its limiter again every time the limits
+// of its route change
+type reloadable struct {
+	cache cache.Cache
+	conf  func() config.RateLimitRouteValues
+	name  string
+	// mu guards the limiter and the limits it was built with
+	mu      sync.Mutex
+	built   bool
+	current config.RateLimitRouteValues
+	limiter Limiter
+}
+
+// NewReloadable creates a limiter that reads the limits of the given route from the
+// function on every request, so they can be changed while the service runs
+// (see config.Watcher). Its decisions are nil while the route has no limit.
+func NewReloadable(
+	c cache.Cache, name string, conf func() config.RateLimitRouteValues,
+) Limiter {
+	return &reloadable{cache: c, conf: conf, name: name}
+}
+
+func (l *reloadable) Allow(ctx context.Context, client string) (*Decision, error) {
+	return l.AllowN(ctx, client, 1)
+}
+
+func (l *reloadable) AllowN(ctx context.Context, client string, n int) (*Decision, error) {
+	conf := l.conf()
+	l.mu.Lock()
+	if !l.built || l.current != conf {
+		l.limiter = New(l.cache, l.name, conf)
+		l.built, l.current = true, conf
+	}
+	limiter := l.limiter
+	l.mu.Unlock()
+	if limiter == nil {
+		return nil, nil
+	}
+	return limiter.AllowN(ctx, client, n)
+}
+
+func (l *limiter) Allow(ctx context.Context, client string) (*Decision, error) {
+	return l.AllowN(ctx, client, 1)
+}
+
+func (l *limiter) AllowN(ctx context.Context, client string, n int) (*Decision, error) {
+	now := l.now()
+	start := now.Truncate(l.window)
+	elapsed := now.Sub(start)
+	// Each counter is needed until the end of the next window
+	current, err := l.cache.IncrementWithExpiration(
+		ctx, l.key(client, start), int64(n), 2*l.window,
+	)
+	if err != nil {
+		return nil, err
+	}
+	value, err := l.cache.Get(ctx, l.key(client, start.Add(-l.window)))
+	if err != nil {
+		return nil, err
+	}
+	var previous int64
+	if value != "" {
+		if previous, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	weight := float64(l.window-elapsed) / float64(l.window)
+	count := float64(previous)*weight + float64(current)
+	decision := &Decision{
+		Allowed:   count <= float64(l.limit),
+		Limit:     l.limit,
+		Remaining: int(math.Max(0, float64(l.limit)-math.Ceil(count))),
+		Reset:     l.window - elapsed,
+	}
+	if !decision.Allowed && int64(l.limit) >= current && previous > 0 {
+		// The request is allowed once the weight of the previous window is low enough
+		decision.Reset -= time.Duration(
+			float64(int64(l.limit)-current) / float64(previous) * float64(l.window),
+		)
+	}
+	return decision, nil
+}
+
+// KeyPrefix namespaces the cache keys of the counters of the clients
+const KeyPrefix = "ratelimit:"
+
+// key returns the cache key of the counter of the given client in the window
+// that starts at the given time
+func (l *limiter) key(client string, start time.Time) string {
+	return fmt.Sprintf("%s%s:%s:%d", KeyPrefix, l.name, client, start.Unix())
+}
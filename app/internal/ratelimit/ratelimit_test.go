@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(t *testing.T, c cache.Cache, limit int, now *time.Time) *limiter {
+	l := New(c, "encode", config.RateLimitRouteValues{Limit: limit, WindowInSeconds: 60})
+	require.NotNil(t, l)
+	l.(*limiter).now = func() time.Time { return *now }
+	return l.(*limiter)
+}
+
+func TestNew(t *testing.T) {
+	l := New(cache.NewTest(), "encode", config.RateLimitRouteValues{Limit: 10})
+	assert.Equal(t, defaultWindow, l.(*limiter).window)
+}
+
+func TestNew_NoLimit(t *testing.T) {
+	assert.Nil(t, New(cache.NewTest(), "encode", config.RateLimitRouteValues{WindowInSeconds: 60}))
+}
+
+func TestNewReloadable(t *testing.T) {
+	ctx := context.Background()
+	conf := config.RateLimitRouteValues{WindowInSeconds: 60}
+	l := NewReloadable(cache.NewTest(), "encode", func() config.RateLimitRouteValues {
+		return conf
+	})
+
+	// The route has no limit
+	decision, err := l.Allow(ctx, "ip:127.0.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, decision)
+
+	conf.Limit = 1
+	decision, err = l.Allow(ctx, "ip:127.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 1, decision.Limit)
+	decision, err = l.Allow(ctx, "ip:127.0.0.1")
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	// The counters are kept in the cache, so they survive the reloads
+	conf.Limit = 3
+	decision, err = l.Allow(ctx, "ip:127.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 3, decision.Limit)
+}
+
+func TestAllow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 17, 15, 30, 0, 0, time.UTC)
+	l := newTestLimiter(t, cache.NewTest(), 3, &now)
+
+	for i := 2; i >= 0; i-- {
+		decision, err := l.Allow(ctx, "ip:127.0.0.1")
+		assert.NoError(t, err)
+		assert.Equal(t, &Decision{Allowed: true, Limit: 3, Remaining: i, Reset: time.Minute}, decision)
+	}
+	now = now.Add(15 * time.Second)
+	decision, err := l.Allow(ctx, "ip:127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, &Decision{Limit: 3, Reset: 45 * time.Second}, decision)
+
+	// Other clients have their own quota
+	decision, err = l.Allow(ctx, "ip:10.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestAllowN(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 17, 15, 30, 0, 0, time.UTC)
+	l := newTestLimiter(t, cache.NewTest(), 5, &now)
+
+	decision, err := l.AllowN(ctx, "ip:127.0.0.1", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, &Decision{Allowed: true, Limit: 5, Remaining: 2, Reset: time.Minute}, decision)
+	// The requests are only allowed if all of them fit in the remaining quota
+	decision, err = l.AllowN(ctx, "ip:127.0.0.1", 3)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Zero(t, decision.Remaining)
+}
+
+func TestAllow_SlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 17, 15, 30, 0, 0, time.UTC)
+	l := newTestLimiter(t, cache.NewTest(), 4, &now)
+	for i := 0; i < 4; i++ {
+		_, err := l.Allow(ctx, "key:0123456789ab")
+		assert.NoError(t, err)
+	}
+
+	// A quarter of the next window still overlaps with 75% of the previous one (3 requests)
+	now = now.Add(75 * time.Second)
+	decision, err := l.Allow(ctx, "key:0123456789ab")
+	assert.NoError(t, err)
+	assert.Equal(t, &Decision{Allowed: true, Limit: 4, Remaining: 0, Reset: 45 * time.Second}, decision)
+	// The previous window weights 3 requests, so the client has to wait until it weights 2
+	decision, err = l.Allow(ctx, "key:0123456789ab")
+	assert.NoError(t, err)
+	assert.Equal(t, &Decision{Limit: 4, Reset: 15 * time.Second}, decision)
+
+	// In the next window, the two requests of the previous one fully overlap
+	now = now.Add(45 * time.Second)
+	decision, err = l.Allow(ctx, "key:0123456789ab")
+	assert.NoError(t, err)
+	assert.Equal(t, &Decision{Allowed: true, Limit: 4, Remaining: 1, Reset: time.Minute}, decision)
+}
+
+func TestAllow_CounterExpiration(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	defer mr.Close()
+	now := time.Date(2026, 10, 17, 15, 30, 0, 0, time.UTC)
+	l := newTestLimiter(t, c, 3, &now)
+	_, err := l.Allow(context.Background(), "ip:127.0.0.1")
+	assert.NoError(t, err)
+	key := "test:ratelimit:encode:ip:127.0.0.1:" + "1792251000"
+	assert.True(t, mr.Exists(key))
+	assert.Equal(t, 2*time.Minute, mr.TTL(key))
+}
+
+func TestAllow_Errors(t *testing.T) {
+	now := time.Date(2026, 10, 17, 15, 30, 0, 0, time.UTC)
+	t.Run("cache error", func(t *testing.T) {
+		mr, c := cache.NewMiniredis()
+		defer mr.Close()
+		mr.SetError("mock error")
+		decision, err := newTestLimiter(t, c, 3, &now).Allow(context.Background(), "ip:127.0.0.1")
+		assert.EqualError(t, err, "mock error")
+		assert.Nil(t, decision)
+	})
+	t.Run("invalid previous counter", func(t *testing.T) {
+		c := cache.NewTest()
+		assert.NoError(t, c.Set(
+			context.Background(), "ratelimit:encode:ip:127.0.0.1:1792250940", "invalid", 0,
+		))
+		decision, err := newTestLimiter(t, c, 3, &now).Allow(context.Background(), "ip:127.0.0.1")
+		assert.Error(t, err)
+		assert.Nil(t, decision)
+	})
+}
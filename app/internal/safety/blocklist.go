@@ -0,0 +1,171 @@
+package safety
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+)
+
+// blocklistEntries holds the blocked path prefixes of each host, being
+// an empty prefix if the whole host is blocked
+type blocklistEntries map[string][]string
+
+// A Blocklist rejects the urls listed in a local file, which is reloaded when it changes.
+// Each line of the file can either be:
+//
+//   - A hosts file entry (e.g. 0.0.0.0 phishing.example), which blocks the listed hosts
+//   - A host, optionally followed by a path prefix, as found in Safe Browsing style dumps
+//     (e.g. phishing.example or http://phishing.example/login/)
+//
+// A blocked host blocks its subdomains too. Comments start with #.
+type Blocklist struct {
+	path     string
+	interval time.Duration
+	logger   logging.Logger
+	// entries holds the blocklistEntries of the last successful load
+	entries atomic.Value
+	// modTime and size identify the version of the file that was loaded
+	modTime time.Time
+	size    int64
+}
+
+// NewBlocklist loads the blocklist of the given file, which is reloaded every time
+// it changes, checking it at the given interval until the given context is done
+func NewBlocklist(
+	ctx context.Context, path string, interval time.Duration, logger logging.Logger,
+) (*Blocklist, error) {
+	b := &Blocklist{path: path, interval: interval, logger: logger}
+	if _, err := b.reload(); err != nil {
+		return nil, fmt.Errorf("unable to load blocklist: %w", err)
+	}
+	go b.watch(ctx)
+	return b, nil
+}
+
+// Check rejects the given url if its host, or any of its parent domains, is blocked
+func (b *Blocklist) Check(u *url.URL) error {
+	entries := b.entries.Load().(blocklistEntries)
+	host := hostname(u)
+	urlPath := u.EscapedPath()
+	for domain := host; domain != ""; domain = parentDomain(domain) {
+		for _, prefix := range entries[domain] {
+			if strings.HasPrefix(urlPath, prefix) {
+				return &Violation{
+					Code:   CodeBlocklisted,
+					Reason: fmt.Sprintf("url of %s is blocklisted", host),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// watch reloads the blocklist when it changes until the given context is done.
+// If the file can not be loaded, the previous blocklist is kept.
+func (b *Blocklist) watch(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := b.reload()
+			if err != nil {
+				b.logger.Warn("unable to reload blocklist, keeping the previous one", "path", b.path, "error", err)
+			} else if reloaded {
+				b.logger.Info("Reloaded blocklist", "path", b.path)
+			}
+		}
+	}
+}
+
+// reload loads the file if it changed since the last load, returning true if it was loaded
+func (b *Blocklist) reload() (bool, error) {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return false, err
+	}
+	if b.entries.Load() != nil && info.ModTime().Equal(b.modTime) && info.Size() == b.size {
+		return false, nil
+	}
+	f, err := os.Open(b.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	entries, err := parseBlocklist(f)
+	if err != nil {
+		return false, err
+	}
+	b.entries.Store(entries)
+	b.modTime = info.ModTime()
+	b.size = info.Size()
+	return true, nil
+}
+
+// parseBlocklist reads the entries of a blocklist file
+func parseBlocklist(r io.Reader) (blocklistEntries, error) {
+	entries := blocklistEntries{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) > 1 && net.ParseIP(fields[0]) != nil:
+			for _, host := range fields[1:] {
+				entries.add(host, "")
+			}
+		case len(fields) == 1:
+			host, prefix := splitBlocklistEntry(fields[0])
+			entries.add(host, prefix)
+		default:
+			return nil, fmt.Errorf("invalid blocklist entry in line %d", line)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// splitBlocklistEntry returns the host and the path prefix of the given entry,
+// which can have a scheme
+func splitBlocklistEntry(entry string) (string, string) {
+	if i := strings.Index(entry, "://"); i >= 0 {
+		entry = entry[i+len("://"):]
+	}
+	if i := strings.Index(entry, "/"); i >= 0 && entry[i:] != "/" {
+		return entry[:i], entry[i:]
+	} else if i >= 0 {
+		return entry[:i], ""
+	}
+	return entry, ""
+}
+
+func (e blocklistEntries) add(host string, prefix string) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host != "" {
+		e[host] = append(e[host], prefix)
+	}
+}
+
+// parentDomain returns the given domain without its first label,
+// being empty if it has a single label
+func parentDomain(domain string) string {
+	if i := strings.Index(domain, "."); i >= 0 {
+		return domain[i+1:]
+	}
+	return ""
+}
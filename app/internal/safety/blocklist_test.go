@@ -0,0 +1,102 @@
+package safety
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBlocklist(t *testing.T) {
+	entries, err := parseBlocklist(strings.NewReader(`# Hosts file
+0.0.0.0 phishing.example malware.example # trailing comment
+127.0.0.1	Tracker.Example.
+
+# Safe Browsing style entries
+spam.example
+http://shared.example/login/
+https://shared.example/phish
+hosting.example/
+`))
+	require.NoError(t, err)
+	assert.Equal(t, blocklistEntries{
+		"phishing.example": {""},
+		"malware.example":  {""},
+		"tracker.example":  {""},
+		"spam.example":     {""},
+		"shared.example":   {"/login/", "/phish"},
+		"hosting.example":  {""},
+	}, entries)
+}
+
+func TestParseBlocklist_Invalid(t *testing.T) {
+	_, err := parseBlocklist(strings.NewReader("spam.example\nnot an entry\n"))
+	assert.EqualError(t, err, "invalid blocklist entry in line 2")
+}
+
+func TestBlocklist_Check(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("0.0.0.0 phishing.example\nshared.example/login/\n"), 0600))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	blocklist, err := NewBlocklist(ctx, path, time.Minute, logging.NewTest(t))
+	require.NoError(t, err)
+
+	tests := []struct {
+		url         string
+		blocklisted bool
+	}{
+		{"https://phishing.example/", true},
+		{"https://login.PHISHING.example/account", true},
+		{"https://phishing.example.com/", false},
+		{"https://shared.example/login/reset", true},
+		{"https://shared.example/", false},
+		{"https://example.com/", false},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.url, func(t *testing.T) {
+			t.Parallel()
+			expectedCode := ""
+			if tt.blocklisted {
+				expectedCode = CodeBlocklisted
+			}
+			assert.Equal(t, expectedCode, check(t, blocklist, tt.url))
+		})
+	}
+}
+
+func TestBlocklist_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("phishing.example\n"), 0600))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	blocklist, err := NewBlocklist(ctx, path, 10*time.Millisecond, logging.NewTest(t))
+	require.NoError(t, err)
+	assert.Equal(t, CodeBlocklisted, check(t, blocklist, "https://phishing.example/"))
+	assert.Equal(t, "", check(t, blocklist, "https://malware.example/"))
+
+	require.NoError(t, os.WriteFile(path, []byte("phishing.example\nmalware.example\n"), 0600))
+	assert.Eventually(t, func() bool {
+		return blocklist.Check(mustParse(t, "https://malware.example/")) != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// An invalid file keeps the previous blocklist
+	require.NoError(t, os.WriteFile(path, []byte("not an entry\n"), 0600))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, CodeBlocklisted, check(t, blocklist, "https://malware.example/"))
+}
+
+func TestNewBlocklist_Error(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("not an entry\n"), 0600))
+	_, err := NewBlocklist(context.Background(), path, time.Minute, logging.NewTest(t))
+	assert.EqualError(t, err, "unable to load blocklist: invalid blocklist entry in line 1")
+}
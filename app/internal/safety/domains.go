@@ -0,0 +1,90 @@
+package safety
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// A domainList matches hosts against domain patterns:
+//
+//   - example.com matches the domain itself
+//   - .example.com matches the domain and every subdomain (suffix)
+//   - *.example.com matches every subdomain, but not the domain itself (wildcard)
+//
+// A wildcard can be placed anywhere (e.g. cdn-*.example.com), and it matches any
+// sequence of characters, dots included.
+type domainList struct {
+	patterns []string
+}
+
+// newDomainList validates and lowercases the given patterns
+func newDomainList(patterns []string) (*domainList, error) {
+	list := &domainList{patterns: make([]string, 0, len(patterns))}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" || pattern == "." {
+			return nil, fmt.Errorf("invalid domain pattern %q", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid domain pattern %q: %w", pattern, err)
+		}
+		list.patterns = append(list.patterns, pattern)
+	}
+	return list, nil
+}
+
+// match returns true if the given lowercase host matches any pattern
+func (l *domainList) match(host string) bool {
+	for _, pattern := range l.patterns {
+		switch {
+		case strings.HasPrefix(pattern, "."):
+			if host == pattern[1:] || strings.HasSuffix(host, pattern) {
+				return true
+			}
+		case strings.Contains(pattern, "*"):
+			// The patterns were validated, thus matching can not fail
+			if matched, _ := path.Match(pattern, host); matched {
+				return true
+			}
+		case host == pattern:
+			return true
+		}
+	}
+	return false
+}
+
+// DenyDomains returns a rule that rejects the urls whose host matches any of the given patterns
+func DenyDomains(patterns []string) (Rule, error) {
+	list, err := newDomainList(patterns)
+	if err != nil {
+		return nil, err
+	}
+	return RuleFunc(func(u *url.URL) error {
+		if host := hostname(u); list.match(host) {
+			return &Violation{
+				Code:   CodeDomainDenied,
+				Reason: fmt.Sprintf("domain %s is not allowed", host),
+			}
+		}
+		return nil
+	}), nil
+}
+
+// AllowDomains returns a rule that only accepts the urls whose host matches any of the given patterns
+func AllowDomains(patterns []string) (Rule, error) {
+	list, err := newDomainList(patterns)
+	if err != nil {
+		return nil, err
+	}
+	return RuleFunc(func(u *url.URL) error {
+		if host := hostname(u); !list.match(host) {
+			return &Violation{
+				Code:   CodeDomainNotAllowed,
+				Reason: fmt.Sprintf("domain %s is not in the allowed domains", host),
+			}
+		}
+		return nil
+	}), nil
+}
@@ -0,0 +1,68 @@
+package safety
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainList_Match(t *testing.T) {
+	t.Parallel()
+	list, err := newDomainList([]string{"Example.com", ".example.org", "*.example.net", "cdn-*.example.io"})
+	require.NoError(t, err)
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{"example.com", true},
+		{"www.example.com", false},
+		{"example.org", true},
+		{"www.example.org", true},
+		{"a.b.example.org", true},
+		{"badexample.org", false},
+		{"example.net", false},
+		{"www.example.net", true},
+		{"a.b.example.net", true},
+		{"cdn-eu.example.io", true},
+		{"www.example.io", false},
+		{"example.io", false},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.host, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, list.match(tt.host))
+		})
+	}
+}
+
+func TestNewDomainList_Invalid(t *testing.T) {
+	for _, pattern := range []string{"", " ", ".", "[a-"} {
+		_, err := newDomainList([]string{pattern})
+		assert.Error(t, err, pattern)
+	}
+}
+
+func TestDenyDomains(t *testing.T) {
+	rule, err := DenyDomains([]string{".evil.example"})
+	require.NoError(t, err)
+	assert.Equal(t, "", check(t, rule, "https://example.com/"))
+	assert.Equal(t, CodeDomainDenied, check(t, rule, "https://Login.Evil.Example./"))
+	u := mustParse(t, "https://login.evil.example/")
+	assert.EqualError(t, rule.Check(u), "domain login.evil.example is not allowed")
+	_, err = DenyDomains([]string{"["})
+	assert.Error(t, err)
+}
+
+func TestAllowDomains(t *testing.T) {
+	rule, err := AllowDomains([]string{"example.com", "*.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "", check(t, rule, "https://example.com/"))
+	assert.Equal(t, "", check(t, rule, "https://www.example.com:8443/"))
+	assert.Equal(t, CodeDomainNotAllowed, check(t, rule, "https://example.org/"))
+	u := mustParse(t, "https://example.org/")
+	assert.EqualError(t, rule.Check(u), "domain example.org is not in the allowed domains")
+	_, err = AllowDomains([]string{"["})
+	assert.Error(t, err)
+}
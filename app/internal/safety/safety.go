@@ -0,0 +1,172 @@
+package safety
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+)
+
+// defaultReloadInterval is the blocklist reload interval used when the configuration does not define it
+const defaultReloadInterval = time.Minute
+
+// The codes of the violations, which identify the rule that rejected an url
+const (
+	CodeURLTooLong       = "url_too_long"
+	CodePrivateNetwork   = "private_network"
+	CodeDomainDenied     = "domain_denied"
+	CodeDomainNotAllowed = "domain_not_allowed"
+	CodeBlocklisted      = "blocklisted"
+)
+
+// A Violation is returned when a destination url is not allowed by the policy
+type Violation struct {
+	// Code identifies the rule that rejected the url
+	Code string
+	// Reason can be shown to the end user
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return v.Reason
+}
+
+// A Rule checks the destination urls, returning a *Violation if one is not allowed
+type Rule interface {
+	Check(u *url.URL) error
+}
+
+// A RuleFunc is an ordinary function used as a rule
+type RuleFunc func(u *url.URL) error
+
+// Check calls the function
+func (f RuleFunc) Check(u *url.URL) error {
+	return f(u)
+}
+
+// A Policy checks the destination urls with a pipeline of rules
+type Policy struct {
+	rules []Rule
+}
+
+// NewPolicy creates a policy that runs the given rules in order
+func NewPolicy(rules ...Rule) *Policy {
+	return &Policy{rules: rules}
+}
+
+// New creates the policy defined in the application config. If a blocklist file is
+// configured, it is reloaded on change until the given context is done.
+func New(ctx context.Context, conf *config.Values, logger logging.Logger) (*Policy, error) {
+	var rules []Rule
+	if conf.Safety.MaxURLLength > 0 {
+		rules = append(rules, MaxLength(conf.Safety.MaxURLLength))
+	}
+	if conf.Safety.BlockPrivateNetworks {
+		rules = append(rules, RuleFunc(checkPrivateNetwork))
+	}
+	if len(conf.Safety.DenyDomains) > 0 {
+		deny, err := DenyDomains(conf.Safety.DenyDomains)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, deny)
+	}
+	if len(conf.Safety.AllowDomains) > 0 {
+		allow, err := AllowDomains(conf.Safety.AllowDomains)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, allow)
+	}
+	if conf.Safety.BlocklistFile != "" {
+		interval := time.Duration(conf.Safety.BlocklistReloadIntervalInSeconds) * time.Second
+		if interval <= 0 {
+			interval = defaultReloadInterval
+		}
+		blocklist, err := NewBlocklist(ctx, conf.Safety.BlocklistFile, interval, logger)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, blocklist)
+	}
+	return NewPolicy(rules...), nil
+}
+
+// Check returns the *Violation of the first rule that does not allow the given url
+func (p *Policy) Check(u *url.URL) error {
+	for _, rule := range p.rules {
+		if err := rule.Check(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaxLength returns a rule that rejects the urls longer than the given length
+func MaxLength(length int) Rule {
+	return RuleFunc(func(u *url.URL) error {
+		if len(u.String()) > length {
+			return &Violation{
+				Code:   CodeURLTooLong,
+				Reason: fmt.Sprintf("url must have at most %d characters", length),
+			}
+		}
+		return nil
+	})
+}
+
+// checkPrivateNetwork rejects the urls whose host is not public: loopback, private,
+// link-local and unspecified addresses, localhost and single label (intranet) names.
+// Hosts are not resolved, as the records could change once the url is shortened.
+func checkPrivateNetwork(u *url.URL) error {
+	host := hostname(u)
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+			ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+			return privateNetworkViolation(host)
+		}
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || !strings.Contains(host, ".") {
+		return privateNetworkViolation(host)
+	}
+	// Browsers parse a host that ends with a number as an IPv4 address in a short,
+	// octal or hexadecimal notation (e.g. 127.1 or 0x7f.1), which can not be checked
+	labels := strings.Split(host, ".")
+	if isNumeric(labels[len(labels)-1]) {
+		return privateNetworkViolation(host)
+	}
+	return nil
+}
+
+func privateNetworkViolation(host string) error {
+	return &Violation{
+		Code:   CodePrivateNetwork,
+		Reason: fmt.Sprintf("host %s is not a public address", host),
+	}
+}
+
+// isNumeric returns true if the given label is a decimal or hexadecimal number
+func isNumeric(label string) bool {
+	if label == "" {
+		return false
+	}
+	lower := strings.ToLower(label)
+	if strings.HasPrefix(lower, "0x") {
+		_, err := strconv.ParseUint(lower[2:], 16, 64)
+		return err == nil || lower == "0x"
+	}
+	_, err := strconv.ParseUint(label, 10, 64)
+	return err == nil
+}
+
+// hostname returns the lowercase host of the given url, without port nor trailing dot
+func hostname(u *url.URL) string {
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
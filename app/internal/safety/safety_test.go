@@ -0,0 +1,175 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mustParse parses the given url, failing the test if it is invalid
+func mustParse(t *testing.T, rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return u
+}
+
+// check parses and checks the given url with the given rule, returning the code of its violation
+func check(t *testing.T, rule Rule, rawURL string) string {
+	err := rule.Check(mustParse(t, rawURL))
+	if err == nil {
+		return ""
+	}
+	var violation *Violation
+	require.True(t, errors.As(err, &violation))
+	return violation.Code
+}
+
+func TestViolation_Error(t *testing.T) {
+	violation := &Violation{Code: CodeBlocklisted, Reason: "url of phishing.example is blocklisted"}
+	assert.EqualError(t, violation, "url of phishing.example is blocklisted")
+}
+
+func TestNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("0.0.0.0 phishing.example\n"), 0600))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	policy, err := New(ctx, &config.Values{Safety: config.SafetyValues{
+		AllowDomains:         []string{".example", ".example.com"},
+		BlockPrivateNetworks: true,
+		BlocklistFile:        path,
+		DenyDomains:          []string{"denied.example.com"},
+		MaxURLLength:         64,
+	}}, logging.NewTest(t))
+	require.NoError(t, err)
+
+	tests := []struct {
+		url          string
+		expectedCode string
+	}{
+		{"https://example.com/path", ""},
+		{"https://www.example/path", ""},
+		{"https://example.com/" + strings.Repeat("a", 64), CodeURLTooLong},
+		{"http://127.0.0.1/", CodePrivateNetwork},
+		{"https://denied.example.com/", CodeDomainDenied},
+		{"https://darioblanco.com/", CodeDomainNotAllowed},
+		{"https://phishing.example/", CodeBlocklisted},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expectedCode, check(t, policy, tt.url), tt.url)
+	}
+}
+
+func TestNew_Empty(t *testing.T) {
+	policy, err := New(context.Background(), &config.Values{}, logging.NewTest(t))
+	require.NoError(t, err)
+	assert.Empty(t, policy.rules)
+	assert.Equal(t, "", check(t, policy, "http://127.0.0.1/"))
+}
+
+func TestNew_Error(t *testing.T) {
+	tests := []struct {
+		name          string
+		conf          config.SafetyValues
+		expectedError string
+	}{
+		{
+			"deny domains",
+			config.SafetyValues{DenyDomains: []string{"[example.com"}},
+			`invalid domain pattern "[example.com": syntax error in pattern`,
+		},
+		{
+			"allow domains",
+			config.SafetyValues{AllowDomains: []string{""}},
+			`invalid domain pattern ""`,
+		},
+		{
+			"blocklist",
+			config.SafetyValues{BlocklistFile: "missing.txt"},
+			"unable to load blocklist: stat missing.txt: no such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(context.Background(), &config.Values{Safety: tt.conf}, logging.NewTest(t))
+			assert.EqualError(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	var calls []string
+	rule := func(name string, err error) Rule {
+		return RuleFunc(func(u *url.URL) error {
+			calls = append(calls, name)
+			return err
+		})
+	}
+	violation := &Violation{Code: "custom", Reason: "custom rule"}
+	policy := NewPolicy(rule("first", nil), rule("second", violation), rule("third", nil))
+	u, _ := url.Parse("https://example.com")
+	assert.Equal(t, violation, policy.Check(u))
+	// The pipeline stops at the first violation
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+func TestMaxLength(t *testing.T) {
+	rule := MaxLength(24)
+	assert.Equal(t, "", check(t, rule, "https://example.com/1234"))
+	assert.Equal(t, CodeURLTooLong, check(t, rule, "https://example.com/12345"))
+	u, _ := url.Parse("https://example.com/12345")
+	assert.EqualError(t, rule.Check(u), "url must have at most 24 characters")
+}
+
+func TestCheckPrivateNetwork(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url     string
+		private bool
+	}{
+		{"https://example.com/", false},
+		{"https://8.8.8.8/", false},
+		{"https://[2001:4860:4860::8888]/", false},
+		{"http://127.0.0.1/", true},
+		{"http://127.0.0.1:8080/", true},
+		{"http://10.0.0.1/", true},
+		{"http://172.16.0.1/", true},
+		{"http://192.168.1.1/", true},
+		{"http://169.254.169.254/latest/meta-data/", true},
+		{"http://0.0.0.0/", true},
+		{"http://[::1]/", true},
+		{"http://[::ffff:127.0.0.1]/", true},
+		{"http://[fe80::1]/", true},
+		{"http://[fd00::1]/", true},
+		{"http://localhost/", true},
+		{"http://LOCALHOST./", true},
+		{"http://app.localhost/", true},
+		{"http://intranet/", true},
+		{"http://2130706433/", true},
+		{"http://127.1/", true},
+		{"http://0x7f.1/", true},
+		{"http://example.0x10/", true},
+		{"http://example.123abc/", false},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.url, func(t *testing.T) {
+			t.Parallel()
+			expectedCode := ""
+			if tt.private {
+				expectedCode = CodePrivateNetwork
+			}
+			assert.Equal(t, expectedCode, check(t, RuleFunc(checkPrivateNetwork), tt.url))
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package slug
+
+import (
+	"math/big"
+	"strings"
+)
+
+// base62Alphabet holds the symbols that can be used in a url path without escaping
+const base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+var base62 = big.NewInt(int64(len(base62Alphabet)))
+
+// encodeBase62 encodes the given bytes as a big-endian number in base62,
+// left padded with zeros up to the given width
+func encodeBase62(b []byte, width int) string {
+	n := new(big.Int).SetBytes(b)
+	mod := new(big.Int)
+	var sb strings.Builder
+	for n.Sign() > 0 {
+		n.DivMod(n, base62, mod)
+		sb.WriteByte(base62Alphabet[mod.Int64()])
+	}
+	for sb.Len() < width {
+		sb.WriteByte(base62Alphabet[0])
+	}
+	// The digits were written from the least significant one
+	encoded := []byte(sb.String())
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+// base62Width returns the number of base62 digits needed to encode any number
+// of the given amount of bytes
+func base62Width(size int) int {
+	max := new(big.Int).Lsh(big.NewInt(1), uint(size*8))
+	return len(max.Sub(max, big.NewInt(1)).Text(62))
+}
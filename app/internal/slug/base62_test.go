@@ -0,0 +1,20 @@
+package slug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeBase62(t *testing.T) {
+	assert.Equal(t, "000", encodeBase62([]byte{}, 3))
+	assert.Equal(t, "0Z", encodeBase62([]byte{61}, 2))
+	assert.Equal(t, "10", encodeBase62([]byte{62}, 1))
+	assert.Equal(t, "48", encodeBase62([]byte{1, 0}, 0))
+}
+
+func TestBase62Width(t *testing.T) {
+	assert.Equal(t, 2, base62Width(1))
+	assert.Equal(t, 11, base62Width(8))
+	assert.Equal(t, 43, base62Width(32))
+}
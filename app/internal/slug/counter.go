@@ -0,0 +1,113 @@
+package slug
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+)
+
+// CounterKey is the cache key that holds the sequence used by the counter generator
+const CounterKey = "counter:slug"
+
+const (
+	// feistelRounds is the number of rounds of the Feistel network,
+	// four rounds are enough to turn it into a pseudorandom permutation
+	feistelRounds = 4
+	// maxCounterBits caps the domain of the counter, so it always fits in an int64
+	maxCounterBits = 62
+)
+
+// A counterGenerator obfuscates a sequential counter stored in the cache.
+// Every sequence number is permuted with a keyed Feistel network and encoded in base62,
+// therefore, consecutive slugs do not look consecutive and they can not be iterated
+// without knowing the secret, while the counter guarantees that they never collide.
+type counterGenerator struct {
+	cache  cache.Cache
+	length int
+	// domain is the amount of different slugs the generator can create
+	domain uint64
+	// bits is the size of the Feistel network (always even)
+	bits   uint
+	secret []byte
+}
+
+// NewCounter creates a generator of obfuscated sequential slugs
+func NewCounter(length int, secret string, c cache.Cache) (Generator, error) {
+	if length < 1 {
+		return nil, fmt.Errorf("invalid url length %d for the counter slug generator", length)
+	}
+	if secret == "" {
+		return nil, errors.New("the counter slug generator requires a secret")
+	}
+	domain := uint64(1) << maxCounterBits
+	if float64(length)*math.Log2(float64(len(base62Alphabet))) < maxCounterBits {
+		domain = uint64(1)
+		for i := 0; i < length; i++ {
+			domain *= uint64(len(base62Alphabet))
+		}
+	}
+	bits := uint(2)
+	for bits < maxCounterBits && uint64(1)<<bits < domain {
+		bits += 2
+	}
+	return &counterGenerator{
+		cache:  c,
+		length: length,
+		domain: domain,
+		bits:   bits,
+		secret: []byte(secret),
+	}, nil
+}
+
+func (g counterGenerator) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	// Collisions are only possible with aliases, the next sequence number is used then
+	n, err := g.cache.Increment(ctx, CounterKey)
+	if err != nil {
+		return "", err
+	}
+	// The sequence starts in 1
+	if uint64(n) > g.domain {
+		return "", fmt.Errorf("the counter slug generator is exhausted (%d slugs)", g.domain)
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, g.permute(uint64(n-1)))
+	return encodeBase62(b, g.length), nil
+}
+
+// permute maps a number of the domain to a different number of the same domain.
+// As the Feistel network might return numbers outside the domain (its size is a power of two),
+// it is applied again until the result is within the domain (cycle walking).
+func (g counterGenerator) permute(n uint64) uint64 {
+	for {
+		n = g.feistel(n)
+		if n < g.domain {
+			return n
+		}
+	}
+}
+
+func (g counterGenerator) feistel(n uint64) uint64 {
+	half := g.bits / 2
+	mask := uint64(1)<<half - 1
+	left, right := n>>half, n&mask
+	for round := 0; round < feistelRounds; round++ {
+		left, right = right, left^(g.round(round, right)&mask)
+	}
+	return left<<half | right
+}
+
+// round is the keyed function of each Feistel round
+func (g counterGenerator) round(round int, value uint64) uint64 {
+	mac := hmac.New(sha256.New, g.secret)
+	b := make([]byte, 9)
+	b[0] = byte(round)
+	binary.BigEndian.PutUint64(b[1:], value)
+	mac.Write(b)
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
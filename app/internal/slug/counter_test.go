@@ -0,0 +1,75 @@
+package slug
+
+import (
+	"context"
+	"testing"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCounter(t *testing.T) {
+	g, err := NewCounter(6, "secret", cache.NewTest())
+	assert.NoError(t, err)
+	// 62^6 fits in 36 bits
+	assert.Equal(t, uint64(56800235584), g.(*counterGenerator).domain)
+	assert.Equal(t, uint(36), g.(*counterGenerator).bits)
+
+	g, err = NewCounter(12, "secret", cache.NewTest())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1)<<maxCounterBits, g.(*counterGenerator).domain)
+	assert.Equal(t, uint(maxCounterBits), g.(*counterGenerator).bits)
+}
+
+func TestNewCounter_InvalidLength(t *testing.T) {
+	g, err := NewCounter(0, "secret", cache.NewTest())
+	assert.EqualError(t, err, "invalid url length 0 for the counter slug generator")
+	assert.Nil(t, g)
+}
+
+func TestNewCounter_MissingSecret(t *testing.T) {
+	g, err := NewCounter(6, "", cache.NewTest())
+	assert.EqualError(t, err, "the counter slug generator requires a secret")
+	assert.Nil(t, g)
+}
+
+func TestCounterGenerate(t *testing.T) {
+	g, _ := NewCounter(6, "secret", cache.NewTest())
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		slug, err := g.Generate(context.Background(), "https://github.com/darioblanco", 0)
+		assert.NoError(t, err)
+		assert.Regexp(t, "^[0-9a-zA-Z]{6}$", slug)
+		assert.False(t, seen[slug])
+		seen[slug] = true
+	}
+}
+
+func TestCounterGenerate_Exhausted(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	mr.Set("test:"+CounterKey, "62")
+	g, _ := NewCounter(1, "secret", c)
+	_, err := g.Generate(context.Background(), "https://github.com/darioblanco", 0)
+	assert.EqualError(t, err, "the counter slug generator is exhausted (62 slugs)")
+}
+
+func TestCounterGenerate_CacheError(t *testing.T) {
+	mr, c := cache.NewMiniredis()
+	mr.SetError("mock error")
+	g, _ := NewCounter(6, "secret", c)
+	_, err := g.Generate(context.Background(), "https://github.com/darioblanco", 0)
+	assert.Error(t, err)
+}
+
+func TestCounterPermute(t *testing.T) {
+	g, _ := NewCounter(1, "secret", cache.NewTest())
+	counter := g.(*counterGenerator)
+	// The permutation is a bijection of the domain
+	seen := map[uint64]bool{}
+	for n := uint64(0); n < counter.domain; n++ {
+		p := counter.permute(n)
+		assert.Less(t, p, counter.domain)
+		assert.False(t, seen[p])
+		seen[p] = true
+	}
+}
@@ -0,0 +1,86 @@
+package slug
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// A hashGenerator slides a window of the slug length across the encoded digest
+// of the url. Once all the windows of the digest are exhausted by collisions,
+// the encoded digest is hashed again to get new windows, therefore, it never
+// indexes past the digest.
+type hashGenerator struct {
+	length int
+	width  int
+	sum    func([]byte) []byte
+	encode func([]byte) string
+}
+
+func newHashGenerator(
+	name string, length int, width int, sum func([]byte) []byte, encode func([]byte) string,
+) (Generator, error) {
+	if length < 1 || length > width {
+		return nil, fmt.Errorf(
+			"invalid url length %d for the %s slug generator (allowed: 1-%d)",
+			length, name, width,
+		)
+	}
+	return &hashGenerator{
+		length: length,
+		width:  width,
+		sum:    sum,
+		encode: encode,
+	}, nil
+}
+
+// NewMD5 creates a generator that uses the hexadecimal MD5 digest of the url
+func NewMD5(length int) (Generator, error) {
+	return newHashGenerator("md5", length, md5.Size*2,
+		func(b []byte) []byte {
+			sum := md5.Sum(b)
+			return sum[:]
+		},
+		hex.EncodeToString,
+	)
+}
+
+// NewSHA256 creates a generator that uses the base62 SHA-256 digest of the url
+func NewSHA256(length int) (Generator, error) {
+	width := base62Width(sha256.Size)
+	return newHashGenerator("sha256", length, width,
+		func(b []byte) []byte {
+			sum := sha256.Sum256(b)
+			return sum[:]
+		},
+		func(b []byte) string { return encodeBase62(b, width) },
+	)
+}
+
+// NewXXHash creates a generator that uses the base62 xxHash (64 bits) digest of the url.
+// It is not a cryptographic hash, but it is considerably faster.
+func NewXXHash(length int) (Generator, error) {
+	width := base62Width(8)
+	return newHashGenerator("xxhash", length, width,
+		func(b []byte) []byte {
+			sum := make([]byte, 8)
+			binary.BigEndian.PutUint64(sum, xxhash.Sum64(b))
+			return sum
+		},
+		func(b []byte) string { return encodeBase62(b, width) },
+	)
+}
+
+func (g hashGenerator) Generate(_ context.Context, url string, attempt int) (string, error) {
+	encoded := g.encode(g.sum([]byte(url)))
+	windows := g.width - g.length + 1
+	for ; attempt >= windows; attempt -= windows {
+		encoded = g.encode(g.sum([]byte(encoded)))
+	}
+	return encoded[attempt : attempt+g.length], nil
+}
@@ -0,0 +1,79 @@
+package slug
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMD5_InvalidLength(t *testing.T) {
+	for _, length := range []int{0, 33} {
+		g, err := NewMD5(length)
+		assert.EqualError(t, err, fmt.Sprintf(
+			"invalid url length %d for the md5 slug generator (allowed: 1-32)", length,
+		))
+		assert.Nil(t, g)
+	}
+}
+
+func TestNewSHA256_InvalidLength(t *testing.T) {
+	g, err := NewSHA256(44)
+	assert.EqualError(t, err, "invalid url length 44 for the sha256 slug generator (allowed: 1-43)")
+	assert.Nil(t, g)
+}
+
+func TestNewXXHash_InvalidLength(t *testing.T) {
+	g, err := NewXXHash(12)
+	assert.EqualError(t, err, "invalid url length 12 for the xxhash slug generator (allowed: 1-11)")
+	assert.Nil(t, g)
+}
+
+func TestMD5Generate(t *testing.T) {
+	g, _ := NewMD5(6)
+	ctx := context.Background()
+	// The md5 of the url is 64fc5e4dfd2a0ff5e...
+	slug, err := g.Generate(ctx, "https://github.com/darioblanco", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "64fc5e", slug)
+	slug, err = g.Generate(ctx, "https://github.com/darioblanco", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "4fc5e4", slug)
+}
+
+func TestHashGenerate_Deterministic(t *testing.T) {
+	md5, _ := NewMD5(6)
+	sha256, _ := NewSHA256(8)
+	xxhash, _ := NewXXHash(7)
+	for _, g := range []Generator{md5, sha256, xxhash} {
+		first, err := g.Generate(context.Background(), "https://github.com/darioblanco", 0)
+		assert.NoError(t, err)
+		second, err := g.Generate(context.Background(), "https://github.com/darioblanco", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, first, second)
+		assert.Len(t, first, g.(*hashGenerator).length)
+	}
+}
+
+func TestHashGenerate_DigestExhausted(t *testing.T) {
+	// The full digest only has one window, every attempt must rehash it without panicking
+	g, _ := NewMD5(32)
+	seen := map[string]bool{}
+	for attempt := 0; attempt < 10; attempt++ {
+		slug, err := g.Generate(context.Background(), "https://github.com/darioblanco", attempt)
+		assert.NoError(t, err)
+		assert.Len(t, slug, 32)
+		assert.False(t, seen[slug])
+		seen[slug] = true
+	}
+}
+
+func TestHashGenerate_Base62(t *testing.T) {
+	g, _ := NewSHA256(43)
+	for attempt := 0; attempt < 3; attempt++ {
+		slug, err := g.Generate(context.Background(), "https://github.com/darioblanco", attempt)
+		assert.NoError(t, err)
+		assert.Regexp(t, "^[0-9a-zA-Z]{43}$", slug)
+	}
+}
@@ -0,0 +1,43 @@
+package slug
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// maxUnbiasedByte is the biggest multiple of the alphabet size that fits in a byte,
+// random bytes equal or greater than it are discarded to avoid a modulo bias
+const maxUnbiasedByte = 256 - 256%len(base62Alphabet)
+
+// A randomGenerator creates base62 slugs from a cryptographically secure random source.
+// The same url will get a different slug every time.
+type randomGenerator struct {
+	length int
+	source io.Reader
+}
+
+// NewRandom creates a generator of random slugs
+func NewRandom(length int) (Generator, error) {
+	if length < 1 {
+		return nil, fmt.Errorf("invalid url length %d for the random slug generator", length)
+	}
+	return &randomGenerator{length: length, source: rand.Reader}, nil
+}
+
+func (g randomGenerator) Generate(_ context.Context, _ string, _ int) (string, error) {
+	slug := make([]byte, 0, g.length)
+	buf := make([]byte, g.length)
+	for len(slug) < g.length {
+		if _, err := io.ReadFull(g.source, buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < maxUnbiasedByte && len(slug) < g.length {
+				slug = append(slug, base62Alphabet[int(b)%len(base62Alphabet)])
+			}
+		}
+	}
+	return string(slug), nil
+}
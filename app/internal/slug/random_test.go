@@ -0,0 +1,48 @@
+package slug
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("no entropy")
+}
+
+func TestNewRandom_InvalidLength(t *testing.T) {
+	g, err := NewRandom(0)
+	assert.EqualError(t, err, "invalid url length 0 for the random slug generator")
+	assert.Nil(t, g)
+}
+
+func TestRandomGenerate(t *testing.T) {
+	g, _ := NewRandom(8)
+	first, err := g.Generate(context.Background(), "https://github.com/darioblanco", 0)
+	assert.NoError(t, err)
+	assert.Regexp(t, "^[0-9a-zA-Z]{8}$", first)
+	second, err := g.Generate(context.Background(), "https://github.com/darioblanco", 0)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
+
+func TestRandomGenerate_DiscardsBiasedBytes(t *testing.T) {
+	g := randomGenerator{
+		length: 3,
+		source: bytes.NewReader([]byte{255, 0, 61, 248, 62, 1}),
+	}
+	slug, err := g.Generate(context.Background(), "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "0Z0", slug)
+}
+
+func TestRandomGenerate_SourceError(t *testing.T) {
+	g := randomGenerator{length: 3, source: failingReader{}}
+	_, err := g.Generate(context.Background(), "", 0)
+	assert.EqualError(t, err, "no entropy")
+}
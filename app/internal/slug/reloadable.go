@@ -0,0 +1,55 @@
+package slug
+
+import (
+	"context"
+	"sync"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+)
+
+// reloadableGenerator creates the slugs with the generator of the application config,
+// building it again every time the url length is reloaded
+type reloadableGenerator struct {
+	cache cache.Cache
+	conf  *config.Values
+	// mu guards the generator and the url length it was built with
+	mu        sync.Mutex
+	generator Generator
+	length    int
+}
+
+// NewReloadable creates the slug generator selected in the application config, which
+// follows the url length of its reloadable values (see config.Watcher)
+func NewReloadable(conf *config.Values, c cache.Cache) (Generator, error) {
+	g := &reloadableGenerator{cache: c, conf: conf}
+	if _, err := g.current(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// current returns the generator of the url length that is currently applied
+func (g *reloadableGenerator) current() (Generator, error) {
+	length := g.conf.Reloadable().UrlLength
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.generator == nil || g.length != length {
+		conf := *g.conf
+		conf.UrlLength = length
+		generator, err := New(&conf, g.cache)
+		if err != nil {
+			return nil, err
+		}
+		g.generator, g.length = generator, length
+	}
+	return g.generator, nil
+}
+
+func (g *reloadableGenerator) Generate(ctx context.Context, url string, attempt int) (string, error) {
+	generator, err := g.current()
+	if err != nil {
+		return "", err
+	}
+	return generator.Generate(ctx, url, attempt)
+}
@@ -0,0 +1,43 @@
+package slug
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReloadable(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.default.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("httpPort: 3000\nhttpScheme: http\nurlLength: 6\n"), 0600))
+	watcher, err := config.NewWatcher(config.Sources{Dir: dir})
+	require.NoError(t, err)
+	g, err := NewReloadable(watcher.Values(), cache.NewTest())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	slug, err := g.Generate(ctx, "https://github.com/darioblanco", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "64fc5e", slug)
+
+	require.NoError(t, os.WriteFile(path, []byte("httpPort: 3000\nhttpScheme: http\nurlLength: 8\n"), 0600))
+	_, err = watcher.Reload()
+	require.NoError(t, err)
+	slug, err = g.Generate(ctx, "https://github.com/darioblanco", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "64fc5e4d", slug)
+}
+
+func TestNewReloadable_Error(t *testing.T) {
+	g, err := NewReloadable(&config.Values{
+		Slug: config.SlugValues{Generator: "counter"},
+	}, cache.NewTest())
+	assert.EqualError(t, err, "the counter slug generator requires a secret")
+	assert.Nil(t, g)
+}
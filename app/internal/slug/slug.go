@@ -0,0 +1,44 @@
+package slug
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+)
+
+// DefaultLength is the slug length used when the configuration does not define one
+const DefaultLength = 6
+
+// A Generator creates the slug candidates for the long urls that are shortened
+type Generator interface {
+	// Generate returns the slug candidate for the given url.
+	// The attempt starts at 0 and it is increased every time a previous candidate
+	// collided with a slug that is already used by a different url.
+	Generate(ctx context.Context, url string, attempt int) (string, error)
+}
+
+// New creates the slug generator selected in the application config
+func New(conf *config.Values, c cache.Cache) (Generator, error) {
+	length := conf.UrlLength
+	if length == 0 {
+		length = DefaultLength
+	}
+	switch conf.Slug.Generator {
+	case "", "md5":
+		return NewMD5(length)
+	case "sha256":
+		return NewSHA256(length)
+	case "xxhash":
+		return NewXXHash(length)
+	case "random":
+		return NewRandom(length)
+	case "counter":
+		return NewCounter(length, conf.Slug.Secret, c)
+	}
+	return nil, fmt.Errorf(
+		"invalid slug generator %q (allowed: md5, sha256, xxhash, random, counter)",
+		conf.Slug.Generator,
+	)
+}
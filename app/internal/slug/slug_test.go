@@ -0,0 +1,50 @@
+package slug
+
+import (
+	"testing"
+
+	"github.com/darioblanco/shortesturl/app/internal/cache"
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		generator string
+		expected  interface{}
+	}{
+		{"", &hashGenerator{}},
+		{"md5", &hashGenerator{}},
+		{"sha256", &hashGenerator{}},
+		{"xxhash", &hashGenerator{}},
+		{"random", &randomGenerator{}},
+		{"counter", &counterGenerator{}},
+	}
+	for _, tt := range tests {
+		tt := tt // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(tt.generator, func(t *testing.T) {
+			t.Parallel()
+			g, err := New(&config.Values{
+				Slug: config.SlugValues{Generator: tt.generator, Secret: "secret"},
+			}, cache.NewTest())
+			assert.NoError(t, err)
+			assert.IsType(t, tt.expected, g)
+		})
+	}
+}
+
+func TestNew_DefaultLength(t *testing.T) {
+	g, err := New(&config.Values{}, cache.NewTest())
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultLength, g.(*hashGenerator).length)
+}
+
+func TestNew_InvalidGenerator(t *testing.T) {
+	g, err := New(&config.Values{
+		Slug: config.SlugValues{Generator: "sha1"},
+	}, cache.NewTest())
+	assert.EqualError(t, err,
+		"invalid slug generator \"sha1\" (allowed: md5, sha256, xxhash, random, counter)")
+	assert.Nil(t, g)
+}
@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name of the spans created by the service
+const TracerName = "github.com/darioblanco/shortesturl"
+
+// A Shutdown flushes the pending spans and stops exporting them
+type Shutdown func(ctx context.Context) error
+
+// New installs the global tracer provider, which exports the spans with the exporter
+// selected in the config, and the W3C trace context propagator. If tracing is disabled,
+// no span is recorded, but the trace context of the callers is still propagated.
+func New(ctx context.Context, conf *config.Values, logger logging.Logger) (Shutdown, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	if !conf.Tracing.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := newExporter(ctx, conf.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(config.AppName),
+		semconv.ServiceVersionKey.String(conf.Version),
+		semconv.DeploymentEnvironmentKey.String(conf.Environment),
+	))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// The sampling decision of the caller is honoured
+		sdktrace.WithSampler(sdktrace.ParentBased(
+			sdktrace.TraceIDRatioBased(conf.Tracing.SampleRatio),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	logger.Info("Loaded tracing",
+		"exporter", conf.Tracing.Exporter,
+		"endpoint", conf.Tracing.Endpoint,
+		"sampleRatio", conf.Tracing.SampleRatio,
+	)
+	return provider.Shutdown, nil
+}
+
+// newExporter creates the span exporter selected in the config
+func newExporter(ctx context.Context, conf config.TracingValues) (sdktrace.SpanExporter, error) {
+	switch conf.Exporter {
+	case "otlp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(conf.Endpoint)}
+		if conf.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	}
+	return nil, fmt.Errorf("invalid tracing exporter %q (allowed: otlp, stdout)", conf.Exporter)
+}
+
+// Start creates a span with the given name, child of the span of the given context (if any)
+func Start(
+	ctx context.Context, name string, opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+	// The tracer is retrieved on each span, so it always belongs to the current provider
+	return otel.Tracer(TracerName).Start(ctx, name, opts...)
+}
+
+// End records the given error (if any) in the span, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
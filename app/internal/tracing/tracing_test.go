@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/darioblanco/shortesturl/app/internal/config"
+	"github.com/darioblanco/shortesturl/app/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestNew_Disabled(t *testing.T) {
+	shutdown, err := New(context.Background(), &config.Values{}, logging.NewTest(t))
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+	// The trace context of the callers is propagated anyway
+	assert.ElementsMatch(t,
+		[]string{"traceparent", "tracestate", "baggage"}, otel.GetTextMapPropagator().Fields())
+}
+
+func TestNew(t *testing.T) {
+	for _, exporter := range []string{"otlp", "stdout"} {
+		exporter := exporter // NOTE: https://github.com/golang/go/wiki/CommonMistakes#using-goroutines-on-loop-iterator-variables
+		t.Run(exporter, func(t *testing.T) {
+			shutdown, err := New(context.Background(), &config.Values{
+				Tracing: config.TracingValues{
+					Enabled:     true,
+					Endpoint:    "localhost:4318",
+					Exporter:    exporter,
+					Insecure:    true,
+					SampleRatio: 1,
+				},
+			}, logging.NewTest(t))
+			require.NoError(t, err)
+			assert.IsType(t, &sdktrace.TracerProvider{}, otel.GetTracerProvider())
+			// Nothing was traced, thus nothing has to be exported
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestNew_InvalidExporter(t *testing.T) {
+	shutdown, err := New(context.Background(), &config.Values{
+		Tracing: config.TracingValues{Enabled: true, Exporter: "jaeger"},
+	}, logging.NewTest(t))
+	assert.EqualError(t, err, "invalid tracing exporter \"jaeger\" (allowed: otlp, stdout)")
+	assert.Nil(t, shutdown)
+}
+
+func TestStartAndEnd(t *testing.T) {
+	recorder := NewTest()
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("mock error"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "child", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "mock error", spans[0].Status().Description)
+	require.Len(t, spans[0].Events(), 1)
+	assert.Equal(t, "exception", spans[0].Events()[0].Name)
+	assert.Equal(t, "parent", spans[1].Name())
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
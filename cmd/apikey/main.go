@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/darioblanco/shortesturl/app"
+)
+
+func main() {
+	// Creates an API key for the callers of the service, printing its token to stdout
+	sources := app.RegisterFlags(flag.CommandLine)
+	name := flag.String("name", "", "a name that identifies the owner of the api key")
+	flag.Parse()
+	if *name == "" {
+		log.Fatal("The -name flag is required")
+	}
+	a := app.New(context.Background(), *sources)
+	a.CreateAPIKey(*name)
+}
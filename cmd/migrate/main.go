@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/darioblanco/shortesturl/app"
+)
+
+func main() {
+	// One-shot migration that namespaces the keys stored by previous versions
+	// of the service with the configured redis key prefix
+	sources := app.RegisterFlags(flag.CommandLine)
+	dryRun := flag.Bool("dry-run", false, "report the keys to migrate without renaming them")
+	flag.Parse()
+	a := app.New(context.Background(), *sources)
+	a.MigrateKeyPrefix(*dryRun)
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"flag"
+	"log"
+	"os"
 
 	"github.com/darioblanco/shortesturl/app"
 )
@@ -11,6 +14,17 @@ func main() {
 	// rather quickly. Therefore, creating an application abstraction will allow
 	// the creation of multiple configuration parameters (e.g. to execute migrations,
 	// to disable different storage backends, etc...) easily
-	a := app.New(context.Background())
+	sources := app.RegisterFlags(flag.CommandLine)
+	printConfig := flag.Bool(
+		"print-config", false, "print the effective configuration (with secrets redacted) and exit",
+	)
+	flag.Parse()
+	if *printConfig {
+		if err := app.PrintConfig(*sources, os.Stdout); err != nil {
+			log.Fatalf("Unable to print configuration: %v", err)
+		}
+		return
+	}
+	a := app.New(context.Background(), *sources)
 	a.Serve()
 }
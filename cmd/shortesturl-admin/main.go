@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/darioblanco/shortesturl/app"
+)
+
+func main() {
+	// Manages the short urls in the configured store directly (e.g. shortesturl-admin get 64fc5e)
+	sources := app.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s [flags] <command> [command flags] [args]\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintln(out)
+		app.AdminUsage(out)
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	a, err := app.NewAdmin(context.Background(), *sources)
+	if err != nil {
+		log.Fatalf("Unable to load admin: %v", err)
+	}
+	if err := a.RunAdmin(flag.Args()); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatalf("Unable to run admin command: %v", err)
+	}
+}